@@ -0,0 +1,248 @@
+package ghwalk
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/magodo/ghwalk/internal/githash"
+)
+
+// webBaseURL returns the base URL (with a trailing slash) of the Github web host, derived from the API base URL.
+func webBaseURL(opt *WalkOptions) string {
+	base := strings.TrimSuffix(apiBaseURL(opt), "/")
+	switch {
+	case base == "" || base == "https://api.github.com":
+		return "https://github.com/"
+	case strings.HasSuffix(base, "/api/v3"):
+		// Github Enterprise Server
+		return strings.TrimSuffix(base, "api/v3")
+	default:
+		return base + "/"
+	}
+}
+
+// downloadArchive downloads the tarball of the repository at the ref from the Github web host, which doesn't count
+// against the API rate limit, and returns its content as a Snapshot of the whole repository. The tarball is streamed:
+// only the content of the files that the walk fetches (see fetchContent of WalkOptions) and of the .gitattributes is
+// kept, while the other files are hashed on the fly. The FileInfo carries no URL, and the submodules are not included.
+//
+// Note that the tarball is what "git archive" produces, i.e. the paths with the export-ignore git attribute are left
+// out, and the files with the export-subst attribute have their placeholders expanded.
+func downloadArchive(ctx context.Context, owner, repo, ref string, opt *WalkOptions) (*Snapshot, error) {
+	u := fmt.Sprintf("%s%s/%s/archive/%s.tar.gz", webBaseURL(opt), owner, repo, ref)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := newHTTPClient(opt).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading the archive of %s/%s@%s: %s", owner, repo, ref, resp.Status)
+	}
+	gr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading the archive of %s/%s@%s: %v", owner, repo, ref, err)
+	}
+	keep := func(path string, info *FileInfo) bool {
+		return info.Name == gitAttributesFile || opt.fetchContent(path, info)
+	}
+	entries, err := readArchive(tar.NewReader(gr), keep)
+	if err != nil {
+		return nil, fmt.Errorf("reading the archive of %s/%s@%s: %v", owner, repo, ref, err)
+	}
+	return &Snapshot{Owner: owner, Repo: repo, Ref: ref, Entries: entries}, nil
+}
+
+// readArchive reads the entries of the repository tarball, whose entries are all under a top level directory. The
+// FileOnlyInfo is only set for the symlinks and the files that keep returns true for, the content of the other files
+// is not held. The SHA of the directories are computed from their entries, as git does.
+func readArchive(tr *tar.Reader, keep func(path string, info *FileInfo) bool) ([]*FileInfo, error) {
+	var entries []*FileInfo
+	modes := map[*FileInfo]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Strip the top level directory
+		parts := strings.SplitN(strings.Trim(hdr.Name, "/"), "/", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			continue
+		}
+		p := parts[1]
+		info := &FileInfo{Name: path.Base(p), Path: p}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			info.Type = FileTypeDir
+			modes[info] = githash.ModeDir
+		case tar.TypeSymlink:
+			target := hdr.Linkname
+			info.Type = FileTypeSymlink
+			info.Size = len(target)
+			info.SHA = githash.BlobSHA([]byte(target))
+			info.FileOnlyInfo = &FileOnlyInfo{Target: &target}
+			modes[info] = githash.ModeSymlink
+		case tar.TypeReg:
+			info.Type = FileTypeFile
+			info.Size = int(hdr.Size)
+			modes[info] = githash.ModeFile
+			if hdr.Mode&0111 != 0 {
+				modes[info] = githash.ModeExecutable
+				info.Executable = true
+			}
+			if !keep(p, info) {
+				if info.SHA, err = githash.ReadBlobSHA(tr, hdr.Size); err != nil {
+					return nil, err
+				}
+				break
+			}
+			b, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			content := string(b)
+			info.SHA = githash.BlobSHA(b)
+			info.FileOnlyInfo = &FileOnlyInfo{Content: &content}
+		default:
+			// e.g. the pax global header carrying the commit SHA
+			continue
+		}
+		entries = append(entries, info)
+	}
+
+	// Compute the tree SHA from the deepest directories up.
+	children := map[string][]*FileInfo{}
+	var dirs []*FileInfo
+	for _, entry := range entries {
+		children[parentDir(entry.Path)] = append(children[parentDir(entry.Path)], entry)
+		if entry.IsDir() {
+			dirs = append(dirs, entry)
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i].Path, "/") > strings.Count(dirs[j].Path, "/")
+	})
+	for _, dir := range dirs {
+		var treeEntries []githash.TreeEntry
+		for _, child := range children[dir.Path] {
+			treeEntries = append(treeEntries, githash.TreeEntry{Mode: modes[child], Name: child.Name, SHA: child.SHA})
+		}
+		sha, err := githash.TreeSHA(treeEntries)
+		if err != nil {
+			return nil, err
+		}
+		dir.SHA = sha
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return lessPath(entries[i].Path, entries[j].Path, false)
+	})
+	return entries, nil
+}
+
+// isRateLimitError tells whether the error is returned for a request rejected by the (primary or secondary) rate
+// limit.
+func isRateLimitError(err error) bool {
+	var rerr *github.RateLimitError
+	var aerr *github.AbuseRateLimitError
+	return errors.As(err, &rerr) || errors.As(err, &aerr)
+}
+
+// archiveFallbackProvider provides the content via the provider, until it is rejected by the rate limit, from then
+// on the content is provided from the archive of the repository at the ref.
+type archiveFallbackProvider struct {
+	ContentProvider
+	ref string
+	opt *WalkOptions
+
+	mu      sync.Mutex
+	archive ContentProvider
+}
+
+// fallback returns the provider of the archive, downloading it if not yet.
+func (p *archiveFallbackProvider) fallback(ctx context.Context, owner, repo string) (ContentProvider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.archive != nil {
+		return p.archive, nil
+	}
+	if p.opt != nil && p.opt.OnRateLimit != nil {
+		p.opt.OnRateLimit(RateLimitEvent{Kind: RateLimitArchiveFallback})
+	}
+	snapshot, err := downloadArchive(ctx, owner, repo, p.ref, p.opt)
+	if err != nil {
+		return nil, err
+	}
+	p.archive = NewSnapshotProvider(snapshot)
+	return p.archive, nil
+}
+
+// current returns the provider to use, which is nil unless the fallback has happened.
+func (p *archiveFallbackProvider) current() ContentProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.archive
+}
+
+func (p *archiveFallbackProvider) Stat(ctx context.Context, owner, repo, path string) (*FileInfo, error) {
+	if archive := p.current(); archive != nil {
+		return archive.Stat(ctx, owner, repo, path)
+	}
+	info, err := p.ContentProvider.Stat(ctx, owner, repo, path)
+	if err != nil && isRateLimitError(err) {
+		archive, ferr := p.fallback(ctx, owner, repo)
+		if ferr != nil {
+			return nil, fmt.Errorf("%v, and falling back to the archive: %v", err, ferr)
+		}
+		return archive.Stat(ctx, owner, repo, path)
+	}
+	return info, err
+}
+
+func (p *archiveFallbackProvider) ReadDir(ctx context.Context, owner, repo, path string) ([]*FileInfo, error) {
+	if archive := p.current(); archive != nil {
+		return archive.ReadDir(ctx, owner, repo, path)
+	}
+	entries, err := p.ContentProvider.ReadDir(ctx, owner, repo, path)
+	if err != nil && isRateLimitError(err) {
+		archive, ferr := p.fallback(ctx, owner, repo)
+		if ferr != nil {
+			return nil, fmt.Errorf("%v, and falling back to the archive: %v", err, ferr)
+		}
+		return archive.ReadDir(ctx, owner, repo, path)
+	}
+	return entries, err
+}
+
+func (p *archiveFallbackProvider) ReadFile(ctx context.Context, owner, repo, path string) (*FileInfo, error) {
+	if archive := p.current(); archive != nil {
+		return archive.ReadFile(ctx, owner, repo, path)
+	}
+	info, err := p.ContentProvider.ReadFile(ctx, owner, repo, path)
+	if err != nil && isRateLimitError(err) {
+		archive, ferr := p.fallback(ctx, owner, repo)
+		if ferr != nil {
+			return nil, fmt.Errorf("%v, and falling back to the archive: %v", err, ferr)
+		}
+		return archive.ReadFile(ctx, owner, repo, path)
+	}
+	return info, err
+}
@@ -0,0 +1,125 @@
+package ghwalk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/magodo/ghwalk/internal/githash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebBaseURL(t *testing.T) {
+	cases := []struct {
+		baseURL string
+		expect  string
+	}{
+		{baseURL: "", expect: "https://github.com/"},
+		{baseURL: "https://api.github.com/", expect: "https://github.com/"},
+		{baseURL: "https://ghe.example.com/api/v3/", expect: "https://ghe.example.com/"},
+		{baseURL: "http://127.0.0.1:8080", expect: "http://127.0.0.1:8080/"},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.expect, webBaseURL(&WalkOptions{BaseURL: c.baseURL, DisableEnvironment: true}), c.baseURL)
+	}
+}
+
+func TestWalkArchiveFallback(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	type entry struct {
+		Type    FileType
+		Size    int
+		SHA     string
+		Content string
+	}
+	walk := func(opt *WalkOptions) (map[string]entry, error) {
+		entries := map[string]entry{}
+		err := Walk(ctx, "magodo", "ghwalk", "testdata", opt, func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			e := entry{Type: info.Type, Size: info.Size, SHA: info.SHA}
+			if info.Type == FileTypeFile {
+				e.Content, err = info.GetContent()
+				require.NoError(t, err)
+			}
+			entries[path] = e
+			return nil
+		}, nil)
+		return entries, err
+	}
+
+	expect, err := walk(&WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true})
+	require.NoError(t, err)
+
+	srv.InjectFault(ghwalktest.Fault{Kind: ghwalktest.FaultRateLimit, Path: "testdata/dir", Duration: time.Hour})
+	defer srv.ClearFaults()
+
+	_, err = walk(&WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true})
+	require.True(t, isRateLimitError(err))
+
+	var events []RateLimitEvent
+	entries, err := walk(&WalkOptions{
+		BaseURL:            srv.BaseURL(),
+		EnableFileOnlyInfo: true,
+		ArchiveFallback:    true,
+		OnRateLimit: func(event RateLimitEvent) {
+			events = append(events, event)
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, expect, entries)
+	require.Equal(t, RateLimitThrottled, events[0].Kind)
+	require.Equal(t, RateLimitArchiveFallback, events[len(events)-1].Kind)
+}
+
+func TestWalkArchiveExportIgnore(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": newFixture(t, map[string]string{
+		".gitattributes": "*.log export-ignore\n/dir/ignored export-ignore\n",
+		"a.log":          "log\n",
+		"b":              "b\n",
+		"dir/ignored":    "ignored\n",
+		"dir/kept":       "kept\n",
+	})})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	walk := func(strategy Strategy) []string {
+		var paths []string
+		require.NoError(t, Walk(ctx, "foo", "bar", "", &WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true, Strategy: strategy},
+			func(path string, info *FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				paths = append(paths, path)
+				return nil
+			}, nil))
+		return paths
+	}
+
+	require.Equal(t, []string{"", ".gitattributes", "a.log", "b", "dir", "dir/ignored", "dir/kept"}, walk(StrategyContents))
+	// The archive leaves out the export-ignore paths, as documented
+	require.Equal(t, []string{"", ".gitattributes", "b", "dir", "dir/kept"}, walk(StrategyArchive))
+
+	// Only the content to fetch is held, while the SHA of every file is computed
+	snapshot, err := downloadArchive(ctx, "foo", "bar", "HEAD", &WalkOptions{BaseURL: srv.BaseURL(), FetchContentFunc: func(path string, info *FileInfo) bool {
+		return path == "dir/kept"
+	}})
+	require.NoError(t, err)
+	held := map[string]bool{}
+	for _, entry := range snapshot.Entries {
+		if entry.Type == FileTypeFile {
+			held[entry.Path] = entry.FileOnlyInfo != nil
+		}
+	}
+	require.Equal(t, map[string]bool{".gitattributes": true, "b": false, "dir/kept": true}, held)
+	require.Equal(t, githash.BlobSHA([]byte("b\n")), snapshot.indexed().entries["b"].SHA)
+}
@@ -0,0 +1,99 @@
+package ghwalk
+
+import "context"
+
+// DefaultAuditPaths are the governance paths audited by AuditOrg by default.
+var DefaultAuditPaths = []string{
+	".github/workflows",
+	".github/CODEOWNERS",
+	"CODEOWNERS",
+	"docs/CODEOWNERS",
+	".github/dependabot.yml",
+	"SECURITY.md",
+	".github/SECURITY.md",
+	"CONTRIBUTING.md",
+	".github/pull_request_template.md",
+	"LICENSE",
+}
+
+// RepoAudit is the audit report of a repository.
+type RepoAudit struct {
+	Repo string
+	// Paths are the reports of the audited paths, in the same order as they are specified.
+	Paths []PathAudit
+	// Err is the error auditing the repository (e.g. it is empty), in which case Paths is not set.
+	Err error
+}
+
+// PathAudit is the audit report of a path in a repository.
+type PathAudit struct {
+	Path    string
+	Present bool
+	// Files are the files at the path, or under it for a directory, including their FileOnlyInfo.
+	Files []FileInfo
+}
+
+// AuditOrg reports the presence and content of the paths in each repository of the organization (see ListOrgRepos).
+// If paths is nil, DefaultAuditPaths is audited.
+//
+// The presence of the paths of a repository is checked at once by StatMany, then the present paths are walked with
+// their content fetched. The failure to audit a repository is reported in its RepoAudit, rather than failing the
+// whole audit.
+func AuditOrg(ctx context.Context, org string, paths []string, opt *WalkOptions) ([]RepoAudit, error) {
+	if paths == nil {
+		paths = DefaultAuditPaths
+	}
+	repos, err := ListOrgRepos(ctx, org, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	var o WalkOptions
+	if opt != nil {
+		o = *opt
+	}
+	o.EnableFileOnlyInfo = true
+	o.FetchContentFunc = nil
+
+	audits := make([]RepoAudit, 0, len(repos))
+	for _, repo := range repos {
+		audit := RepoAudit{Repo: repo}
+		audit.Paths, audit.Err = auditRepo(ctx, org, repo, paths, &o)
+		if err := ctx.Err(); err != nil {
+			return audits, err
+		}
+		audits = append(audits, audit)
+	}
+	return audits, nil
+}
+
+func auditRepo(ctx context.Context, owner, repo string, paths []string, opt *WalkOptions) ([]PathAudit, error) {
+	paths, err := cleanRepoPaths(owner, repo, paths)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := StatMany(ctx, owner, repo, paths, opt)
+	if err != nil {
+		return nil, err
+	}
+	audits := make([]PathAudit, 0, len(paths))
+	for i, path := range paths {
+		audit := PathAudit{Path: path, Present: infos[i] != nil}
+		if audit.Present {
+			err := Walk(ctx, owner, repo, path, opt, func(path string, info *FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if !info.IsDir() {
+					audit.Files = append(audit.Files, *info)
+				}
+				return nil
+			}, nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+		audits = append(audits, audit)
+	}
+	return audits, nil
+}
@@ -0,0 +1,73 @@
+package ghwalk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditOrg(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{
+		"acme/a": newFixture(t, map[string]string{
+			".github/workflows/ci.yml":      "on: push",
+			".github/workflows/release.yml": "on: tag",
+			"CODEOWNERS":                    "* @acme/team",
+		}),
+		"acme/b": newFixture(t, map[string]string{"SECURITY.md": "# Security"}),
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	audits, err := AuditOrg(ctx, "acme", []string{".github/workflows", "/CODEOWNERS", "SECURITY.md"}, &WalkOptions{BaseURL: srv.BaseURL()})
+	require.NoError(t, err)
+	require.Len(t, audits, 2)
+
+	type pathReport struct {
+		Path    string
+		Present bool
+		Files   map[string]string
+	}
+	report := func(audit RepoAudit) []pathReport {
+		require.NoError(t, audit.Err)
+		var out []pathReport
+		for _, p := range audit.Paths {
+			r := pathReport{Path: p.Path, Present: p.Present}
+			for _, f := range p.Files {
+				content, err := f.GetContent()
+				require.NoError(t, err)
+				if r.Files == nil {
+					r.Files = map[string]string{}
+				}
+				r.Files[f.Path] = content
+			}
+			out = append(out, r)
+		}
+		return out
+	}
+
+	require.Equal(t, "a", audits[0].Repo)
+	require.Equal(t, []pathReport{
+		{
+			Path:    ".github/workflows",
+			Present: true,
+			Files: map[string]string{
+				".github/workflows/ci.yml":      "on: push",
+				".github/workflows/release.yml": "on: tag",
+			},
+		},
+		{Path: "CODEOWNERS", Present: true, Files: map[string]string{"CODEOWNERS": "* @acme/team"}},
+		{Path: "SECURITY.md"},
+	}, report(audits[0]))
+
+	require.Equal(t, "b", audits[1].Repo)
+	require.Equal(t, []pathReport{
+		{Path: ".github/workflows"},
+		{Path: "CODEOWNERS"},
+		{Path: "SECURITY.md", Present: true, Files: map[string]string{"SECURITY.md": "# Security"}},
+	}, report(audits[1]))
+}
@@ -0,0 +1,355 @@
+package ghwalk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/google/go-github/v32/github"
+	"gopkg.in/yaml.v3"
+)
+
+// GHCLIToken returns the access token stored by the Github CLI (gh) for host, which defaults to "github.com".
+//
+// It runs "gh auth token", which also covers the tokens stored in the system keyring. If gh is not available, it
+// falls back to reading the hosts.yml in the gh configuration directory.
+func GHCLIToken(ctx context.Context, host string) (string, error) {
+	if host == "" {
+		host = "github.com"
+	}
+
+	if gh, err := exec.LookPath("gh"); err == nil {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, gh, "auth", "token", "--hostname", host)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err == nil {
+			if token := strings.TrimSpace(stdout.String()); token != "" {
+				return token, nil
+			}
+		}
+	}
+
+	dir, err := ghConfigDir()
+	if err != nil {
+		return "", err
+	}
+	token, err := readGHHostsToken(filepath.Join(dir, "hosts.yml"), host)
+	if err != nil {
+		return "", err
+	}
+	if token == "" {
+		return "", fmt.Errorf("no token found for %s in the gh configuration, run `gh auth login` first", host)
+	}
+	return token, nil
+}
+
+// ghConfigDir returns the configuration directory of gh, following the same rules as gh itself.
+func ghConfigDir() (string, error) {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh"), nil
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("AppData"); dir != "" {
+			return filepath.Join(dir, "GitHub CLI"), nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "gh"), nil
+}
+
+// readGHHostsToken reads the oauth_token of host from the gh hosts.yml, which is of the form:
+//
+//	github.com:
+//	    user: foo
+//	    oauth_token: xxx
+//
+// It returns an empty string if the file or the token doesn't exist.
+func readGHHostsToken(file, host string) (string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	var hosts map[string]struct {
+		OAuthToken string `yaml:"oauth_token"`
+	}
+	if err := yaml.Unmarshal(b, &hosts); err != nil {
+		return "", fmt.Errorf("parsing %s: %v", file, err)
+	}
+	return hosts[host].OAuthToken, nil
+}
+
+// netrcFile returns the path of the netrc file, which is $NETRC if set, otherwise ~/.netrc (~/_netrc on Windows).
+func netrcFile() (string, error) {
+	if file := os.Getenv("NETRC"); file != "" {
+		return file, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "_netrc"), nil
+	}
+	return filepath.Join(home, ".netrc"), nil
+}
+
+// readNetrcToken reads the password of the first of the machines found in the netrc file, which is regarded as the
+// token, e.g.
+//
+//	machine github.com login foo password xxx
+//
+// The default entry is not used, as it is not meant for Github. It returns an empty string if the file or the
+// machines don't exist.
+func readNetrcToken(file string, machines ...string) (string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	passwords := map[string]string{}
+	var machine string
+	var lines []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			lines = append(lines, line)
+		}
+	}
+	fields := strings.Fields(strings.Join(lines, "\n"))
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			if i+1 < len(fields) {
+				i++
+				machine = fields[i]
+			}
+		case "default":
+			machine = ""
+		case "login", "account":
+			i++
+		case "password":
+			if i+1 < len(fields) {
+				i++
+				if _, ok := passwords[machine]; !ok && machine != "" {
+					passwords[machine] = fields[i]
+				}
+			}
+		}
+	}
+	for _, machine := range machines {
+		if password := passwords[machine]; password != "" {
+			return password, nil
+		}
+	}
+	return "", nil
+}
+
+// TokenErrorKind is the kind of a TokenError.
+type TokenErrorKind string
+
+const (
+	// TokenInvalid means the token is rejected by Github, e.g. it is mistyped, revoked or expired.
+	TokenInvalid TokenErrorKind = "invalid"
+	// TokenMissingScope means the repository is not found, while the (classic) token lacks the "repo" scope that is
+	// required to access the private repositories.
+	TokenMissingScope TokenErrorKind = "missing-scope"
+	// TokenSSORequired means the organization of the repository enforces SAML SSO, which the token is not authorized
+	// for.
+	TokenSSORequired TokenErrorKind = "sso-required"
+	// TokenNoRepoAccess means the repository is not found, either because it doesn't exist, or because it is private
+	// and the token (if any) has no access to it, which Github doesn't tell apart.
+	TokenNoRepoAccess TokenErrorKind = "no-repo-access"
+)
+
+// TokenError is returned by ValidateToken if the token doesn't work, or doesn't have access to the repository.
+type TokenError struct {
+	Kind  TokenErrorKind
+	Owner string
+	Repo  string
+	// Scopes are the scopes granted to the token, nil if the token reports no scopes (e.g. a fine-grained token).
+	Scopes []string
+	// SSOURL is the URL to authorize the token for the SAML SSO of the organization, only set for TokenSSORequired.
+	SSOURL string
+	// Err is the underlying error returned by the Github API.
+	Err error
+}
+
+func (e *TokenError) Error() string {
+	switch e.Kind {
+	case TokenInvalid:
+		return fmt.Sprintf("the Github token is invalid, it may be mistyped, revoked or expired: %v", e.Err)
+	case TokenMissingScope:
+		return fmt.Sprintf("repository %s/%s is not found, and the token lacks the %q scope to access private repositories (granted scopes: %s)",
+			e.Owner, e.Repo, "repo", strings.Join(e.Scopes, ", "))
+	case TokenSSORequired:
+		msg := fmt.Sprintf("the organization %s enforces SAML SSO, and the token is not authorized for it", e.Owner)
+		if e.SSOURL != "" {
+			msg += ", authorize it at " + e.SSOURL
+		}
+		return msg
+	default:
+		if e.Scopes == nil {
+			return fmt.Sprintf("repository %s/%s doesn't exist, or the token has no access to it (for a fine-grained token or a Github App, check its repository access)", e.Owner, e.Repo)
+		}
+		return fmt.Sprintf("repository %s/%s doesn't exist, or the token has no access to it", e.Owner, e.Repo)
+	}
+}
+
+func (e *TokenError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes the TokenError of a repository not found match ErrNotExist.
+func (e *TokenError) Is(target error) bool {
+	return target == ErrNotExist && (e.Kind == TokenNoRepoAccess || e.Kind == TokenMissingScope)
+}
+
+// TokenInfo describes the token validated by ValidateToken.
+type TokenInfo struct {
+	// Login is the login of the user owning the token, empty if the token is not owned by a user (e.g. a Github App
+	// installation token), or there is no token.
+	Login string
+	// Scopes are the scopes granted to the token, nil if the token reports no scopes (e.g. a fine-grained token).
+	Scopes []string
+}
+
+// ValidateToken verifies that the token of opt (see the Token of WalkOptions) works, and has access to the
+// repository, unless both owner and repo are empty. The failures are reported by a *TokenError, which tells the
+// reason and how to fix it, e.g. a missing scope or an unauthorized SSO. If there is no token, only the access to the
+// repository is verified.
+//
+// Only the Token, BaseURL and Transport of opt (and the others customizing the API requests) are used.
+func ValidateToken(ctx context.Context, owner, repo string, opt *WalkOptions) (*TokenInfo, error) {
+	client, err := newClient(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &TokenInfo{}
+	if accessToken(opt) != "" {
+		user, resp, err := client.Users.Get(ctx, "")
+		var eresp *github.ErrorResponse
+		switch {
+		case err == nil:
+			info.Login = user.GetLogin()
+			info.Scopes = parseScopes(resp.Header)
+		case errors.As(err, &eresp) && eresp.Response.StatusCode == http.StatusUnauthorized:
+			return nil, &TokenError{Kind: TokenInvalid, Owner: owner, Repo: repo, Err: err}
+		case errors.As(err, &eresp) && eresp.Response.StatusCode == http.StatusForbidden:
+			// The installation tokens of the Github Apps have no access to the user
+		default:
+			return nil, err
+		}
+	}
+
+	if owner == "" && repo == "" {
+		return info, nil
+	}
+	if _, _, err := client.Repositories.Get(ctx, owner, repo); err != nil {
+		return nil, repoAccessError(owner, repo, info.Scopes, err)
+	}
+	return info, nil
+}
+
+// selectToken returns the first token of the Token (as resolved) and the FallbackTokens of opt that has access to
+// the repository. If none has, the *TokenError of each token is returned.
+func selectToken(ctx context.Context, owner, repo string, opt *WalkOptions) (string, error) {
+	var errs []error
+	for i, token := range append([]string{accessToken(opt)}, opt.FallbackTokens...) {
+		o := *opt
+		o.Token, o.FallbackTokens = token, nil
+		client, err := newClient(ctx, &o)
+		if err != nil {
+			return "", err
+		}
+		_, _, err = client.Repositories.Get(ctx, owner, repo)
+		if err == nil {
+			if i > 0 && opt.Logf != nil {
+				opt.Logf("falling back to the token #%d for %s/%s", i, owner, repo)
+			}
+			return token, nil
+		}
+		err = repoAccessError(owner, repo, nil, err)
+		var tokenErr *TokenError
+		if !errors.As(err, &tokenErr) {
+			return "", err
+		}
+		errs = append(errs, err)
+	}
+	return "", errors.Join(errs...)
+}
+
+// repoAccessError returns the *TokenError describing the error of accessing the repository, or err as is if it is
+// not about the access.
+func repoAccessError(owner, repo string, scopes []string, err error) error {
+	var eresp *github.ErrorResponse
+	if !errors.As(err, &eresp) || eresp.Response == nil {
+		return err
+	}
+	switch eresp.Response.StatusCode {
+	case http.StatusUnauthorized:
+		return &TokenError{Kind: TokenInvalid, Owner: owner, Repo: repo, Err: err}
+	case http.StatusForbidden:
+		sso := eresp.Response.Header.Get("X-GitHub-SSO")
+		if !strings.HasPrefix(sso, "required") {
+			return err
+		}
+		tokenErr := &TokenError{Kind: TokenSSORequired, Owner: owner, Repo: repo, Scopes: scopes, Err: err}
+		if i := strings.Index(sso, "url="); i >= 0 {
+			tokenErr.SSOURL = sso[i+len("url="):]
+		}
+		return tokenErr
+	case http.StatusNotFound:
+		if scopes == nil {
+			scopes = parseScopes(eresp.Response.Header)
+		}
+		kind := TokenNoRepoAccess
+		if scopes != nil && !hasScope(scopes, "repo") {
+			kind = TokenMissingScope
+		}
+		return &TokenError{Kind: kind, Owner: owner, Repo: repo, Scopes: scopes, Err: err}
+	}
+	return err
+}
+
+// parseScopes returns the scopes reported by the X-OAuth-Scopes header, or nil if there is no such header.
+func parseScopes(header http.Header) []string {
+	if _, ok := header[http.CanonicalHeaderKey("X-OAuth-Scopes")]; !ok {
+		return nil
+	}
+	scopes := []string{}
+	for _, scope := range strings.Split(header.Get("X-OAuth-Scopes"), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
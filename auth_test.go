@@ -0,0 +1,191 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGHCLIToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ghwalk")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for env, value := range map[string]string{"GH_CONFIG_DIR": dir, "PATH": ""} {
+		if v, ok := os.LookupEnv(env); ok {
+			defer os.Setenv(env, v)
+		} else {
+			defer os.Unsetenv(env)
+		}
+		os.Setenv(env, value)
+	}
+
+	_, err = GHCLIToken(context.Background(), "")
+	require.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "hosts.yml"), []byte(`github.com:
+    user: foo
+    oauth_token: gho_foo
+    git_protocol: https
+# The enterprise instance
+github.example.com:
+    oauth_token: "gho_bar" # quoted
+`), 0600))
+
+	token, err := GHCLIToken(context.Background(), "")
+	require.NoError(t, err)
+	require.Equal(t, "gho_foo", token)
+
+	token, err = GHCLIToken(context.Background(), "github.example.com")
+	require.NoError(t, err)
+	require.Equal(t, "gho_bar", token)
+
+	_, err = GHCLIToken(context.Background(), "github.other.com")
+	require.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "hosts.yml"), []byte("github.com: [\n"), 0600))
+	_, err = GHCLIToken(context.Background(), "")
+	require.Error(t, err)
+}
+
+func TestReadNetrcToken(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".netrc")
+	token, err := readNetrcToken(file, "github.com")
+	require.NoError(t, err)
+	require.Equal(t, "", token)
+
+	require.NoError(t, ioutil.WriteFile(file, []byte(`# comment
+machine api.github.com
+  login foo
+  password api_token
+machine github.example.com login bar password ghe_token
+default login anonymous password default_token
+`), 0600))
+
+	cases := []struct {
+		machines []string
+		expect   string
+	}{
+		{machines: []string{"github.com", "api.github.com"}, expect: "api_token"},
+		{machines: []string{"github.example.com"}, expect: "ghe_token"},
+		{machines: []string{"github.other.com"}, expect: ""},
+	}
+	for _, c := range cases {
+		token, err := readNetrcToken(file, c.machines...)
+		require.NoError(t, err)
+		require.Equal(t, c.expect, token, c.machines)
+	}
+}
+
+func TestValidateToken(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{
+		"magodo/ghwalk":  ".",
+		"acme/private":   ".",
+		"sso-org/secret": ".",
+	})
+	defer srv.Close()
+	srv.AddToken("classic", ghwalktest.Token{Login: "alice", Scopes: []string{"repo", "read:org"}, SSOOrgs: []string{"sso-org"}})
+	srv.AddToken("public", ghwalktest.Token{Login: "bob", Scopes: []string{"public_repo"}, Repos: []string{"magodo/ghwalk"}})
+	srv.AddToken("fine-grained", ghwalktest.Token{Login: "carol", Repos: []string{"magodo/ghwalk"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	cases := []struct {
+		token  string
+		repo   string
+		expect *TokenInfo
+		kind   TokenErrorKind
+	}{
+		{token: "classic", repo: "acme/private", expect: &TokenInfo{Login: "alice", Scopes: []string{"repo", "read:org"}}},
+		{token: "classic", repo: "acme/nonexist", kind: TokenNoRepoAccess},
+		{token: "classic", repo: "sso-org/secret", kind: TokenSSORequired},
+		{token: "public", repo: "magodo/ghwalk", expect: &TokenInfo{Login: "bob", Scopes: []string{"public_repo"}}},
+		{token: "public", repo: "acme/private", kind: TokenMissingScope},
+		{token: "fine-grained", repo: "magodo/ghwalk", expect: &TokenInfo{Login: "carol"}},
+		{token: "fine-grained", repo: "acme/private", kind: TokenNoRepoAccess},
+		{token: "bogus", repo: "magodo/ghwalk", kind: TokenInvalid},
+		{token: "", repo: "acme/private", expect: &TokenInfo{}},
+		{token: "", repo: "acme/nonexist", kind: TokenNoRepoAccess},
+	}
+	for _, c := range cases {
+		name := c.token + "@" + c.repo
+		opt := &WalkOptions{BaseURL: srv.BaseURL(), Token: c.token, DisableEnvironment: true}
+		parts := strings.SplitN(c.repo, "/", 2)
+		info, err := ValidateToken(ctx, parts[0], parts[1], opt)
+		if c.kind != "" {
+			var tokenErr *TokenError
+			require.True(t, errors.As(err, &tokenErr), name)
+			require.Equal(t, c.kind, tokenErr.Kind, name)
+			if c.kind == TokenSSORequired {
+				require.Contains(t, tokenErr.Error(), "https://github.com/orgs/sso-org/sso")
+			}
+
+			// The same error is returned by the walk, if asked
+			opt.ValidateToken = true
+			err = Walk(ctx, parts[0], parts[1], "testdata", opt, func(path string, info *FileInfo, err error) error {
+				return err
+			}, nil)
+			require.True(t, errors.As(err, &tokenErr), name)
+			continue
+		}
+		require.NoError(t, err, name)
+		require.Equal(t, c.expect, info, name)
+	}
+}
+
+func TestWalkFallbackTokens(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{
+		"acme/installed": newFixture(t, map[string]string{"x": "x"}),
+		"acme/other":     newFixture(t, map[string]string{"y": "y"}),
+		"private/secret": newFixture(t, map[string]string{"z": "z"}),
+	})
+	defer srv.Close()
+	srv.AddToken("installation", ghwalktest.Token{Repos: []string{"acme/installed"}})
+	srv.AddToken("pat", ghwalktest.Token{Login: "alice", Scopes: []string{"repo"}, Repos: []string{"acme/installed", "acme/other"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	cases := []struct {
+		repo      string
+		fallbacks []string
+		expect    []string
+		isError   bool
+	}{
+		{repo: "installed", fallbacks: []string{"pat"}, expect: []string{"", "x"}},
+		{repo: "other", fallbacks: []string{"pat"}, expect: []string{"", "y"}},
+		{repo: "other", isError: true},
+		{repo: "secret", fallbacks: []string{"pat"}, isError: true},
+	}
+	for _, c := range cases {
+		owner := "acme"
+		if c.repo == "secret" {
+			owner = "private"
+		}
+		opt := &WalkOptions{BaseURL: srv.BaseURL(), Token: "installation", FallbackTokens: c.fallbacks, DisableEnvironment: true}
+		var visited []string
+		err := Walk(ctx, owner, c.repo, "", opt, func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			visited = append(visited, path)
+			return nil
+		}, nil)
+		if c.isError {
+			require.Error(t, err, c.repo)
+			require.True(t, errors.Is(err, ErrNotExist), c.repo)
+			continue
+		}
+		require.NoError(t, err, c.repo)
+		require.Equal(t, c.expect, visited, c.repo)
+	}
+}
@@ -0,0 +1,277 @@
+package ghwalk
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// snapshotMagic starts the binary encoding of a snapshot, which is followed by a gob stream of a binaryHeader, a
+// binaryRecord for each entry, and a final binaryRecord with End set.
+const snapshotMagic = "GHWS"
+
+// manifestMagic starts the binary encoding of a manifest, which is followed by the gob encoded Manifest.
+const manifestMagic = "GHWM"
+
+type binaryHeader struct {
+	Version int
+	Owner   string
+	Repo    string
+	Ref     string
+	Path    string
+}
+
+type binaryRecord struct {
+	Entry *binaryFileInfo
+	// End marks the end of the entries, along with whether the snapshot is Partial.
+	End     bool
+	Partial bool
+}
+
+// binaryFileInfo is the gob form of FileInfo. As gob doesn't tell a nil pointer from the one to a zero value, the
+// optional fields come with their presence.
+type binaryFileInfo struct {
+	Type       FileType
+	Size       int
+	Name       string
+	Path       string
+	SHA        string
+	URL        string
+	GitURL     string
+	HTMLURL    string
+	Executable bool
+	RawName    string
+	RawPath    string
+	Attributes map[string]string
+
+	HasFileOnlyInfo bool
+	HasTarget       bool
+	Target          string
+	HasEncoding     bool
+	Encoding        string
+	HasContent      bool
+	Content         string
+	DownloadURL     string
+	Truncated       bool
+}
+
+func newBinaryFileInfo(info *FileInfo) *binaryFileInfo {
+	b := &binaryFileInfo{
+		Type:       info.Type,
+		Size:       info.Size,
+		Name:       info.Name,
+		Path:       info.Path,
+		SHA:        info.SHA,
+		URL:        info.URL,
+		GitURL:     info.GitURL,
+		HTMLURL:    info.HTMLURL,
+		Executable: info.Executable,
+		RawName:    info.RawName,
+		RawPath:    info.RawPath,
+		Attributes: info.Attributes,
+	}
+	if foi := info.FileOnlyInfo; foi != nil {
+		b.HasFileOnlyInfo = true
+		b.DownloadURL, b.Truncated = foi.DownloadURL, foi.Truncated
+		if foi.Target != nil {
+			b.HasTarget, b.Target = true, *foi.Target
+		}
+		if foi.Encoding != nil {
+			b.HasEncoding, b.Encoding = true, *foi.Encoding
+		}
+		if foi.Content != nil {
+			b.HasContent, b.Content = true, *foi.Content
+		}
+	}
+	return b
+}
+
+func (b *binaryFileInfo) fileInfo() *FileInfo {
+	info := &FileInfo{
+		Type:       b.Type,
+		Size:       b.Size,
+		Name:       b.Name,
+		Path:       b.Path,
+		SHA:        b.SHA,
+		URL:        b.URL,
+		GitURL:     b.GitURL,
+		HTMLURL:    b.HTMLURL,
+		Executable: b.Executable,
+		RawName:    b.RawName,
+		RawPath:    b.RawPath,
+	}
+	if len(b.Attributes) > 0 {
+		info.Attributes = b.Attributes
+	}
+	if !b.HasFileOnlyInfo {
+		return info
+	}
+	info.FileOnlyInfo = &FileOnlyInfo{DownloadURL: b.DownloadURL, Truncated: b.Truncated}
+	if b.HasTarget {
+		info.FileOnlyInfo.Target = &b.Target
+	}
+	if b.HasEncoding {
+		info.FileOnlyInfo.Encoding = &b.Encoding
+	}
+	if b.HasContent {
+		info.FileOnlyInfo.Content = &b.Content
+	}
+	return info
+}
+
+// SnapshotWriter writes a snapshot in the compact binary encoding, one entry at a time, so that a snapshot never has
+// to be held in memory as a whole, e.g. while walking a large repository. The binary encoding holds the same
+// information as the JSON one, and is read by ReadSnapshot or SnapshotReader.
+type SnapshotWriter struct {
+	enc *gob.Encoder
+	// Partial is recorded in the snapshot by Close, see the Partial of Snapshot.
+	Partial bool
+}
+
+// NewSnapshotWriter writes the header of the snapshot to w, i.e. the snapshot without its Entries, which are to be
+// written by Write.
+func NewSnapshotWriter(w io.Writer, header *Snapshot) (*SnapshotWriter, error) {
+	if _, err := io.WriteString(w, snapshotMagic); err != nil {
+		return nil, err
+	}
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(&binaryHeader{
+		Version: SchemaVersion,
+		Owner:   header.Owner,
+		Repo:    header.Repo,
+		Ref:     header.Ref,
+		Path:    header.Path,
+	}); err != nil {
+		return nil, err
+	}
+	return &SnapshotWriter{enc: enc, Partial: header.Partial}, nil
+}
+
+// Write writes an entry of the snapshot.
+func (w *SnapshotWriter) Write(info *FileInfo) error {
+	return w.enc.Encode(&binaryRecord{Entry: newBinaryFileInfo(info)})
+}
+
+// Close marks the end of the entries, without which the snapshot is regarded as truncated by the reader. It doesn't
+// close the underlying writer.
+func (w *SnapshotWriter) Close() error {
+	return w.enc.Encode(&binaryRecord{End: true, Partial: w.Partial})
+}
+
+// WriteBinary writes the snapshot to w in the compact binary encoding, see SnapshotWriter.
+func (s *Snapshot) WriteBinary(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	sw, err := NewSnapshotWriter(bw, s)
+	if err != nil {
+		return err
+	}
+	for _, entry := range s.Entries {
+		if err := sw.Write(entry); err != nil {
+			return err
+		}
+	}
+	if err := sw.Close(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// SnapshotReader reads a snapshot in the binary encoding written by SnapshotWriter, one entry at a time.
+type SnapshotReader struct {
+	dec    *gob.Decoder
+	header *Snapshot
+	done   bool
+}
+
+// NewSnapshotReader reads the header of the snapshot from r.
+func NewSnapshotReader(r io.Reader) (*SnapshotReader, error) {
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != snapshotMagic {
+		return nil, errors.New("decoding snapshot: not a binary snapshot")
+	}
+	dec := gob.NewDecoder(r)
+	var header binaryHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("decoding snapshot: %v", err)
+	}
+	if header.Version > SchemaVersion {
+		return nil, fmt.Errorf("decoding snapshot: schema version %d is newer than the supported version %d", header.Version, SchemaVersion)
+	}
+	return &SnapshotReader{
+		dec: dec,
+		header: &Snapshot{
+			Version: header.Version,
+			Owner:   header.Owner,
+			Repo:    header.Repo,
+			Ref:     header.Ref,
+			Path:    header.Path,
+		},
+	}, nil
+}
+
+// Header returns the snapshot without its Entries. Its Partial is only known once Next has returned io.EOF.
+func (r *SnapshotReader) Header() *Snapshot {
+	return r.header
+}
+
+// Next returns the next entry of the snapshot, or io.EOF at the end of the entries. If the snapshot is truncated
+// (i.e. written without SnapshotWriter.Close), io.ErrUnexpectedEOF is returned.
+func (r *SnapshotReader) Next() (*FileInfo, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+	var record binaryRecord
+	if err := r.dec.Decode(&record); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("decoding snapshot: %w", err)
+	}
+	if record.End {
+		r.done = true
+		r.header.Partial = record.Partial
+		return nil, io.EOF
+	}
+	if record.Entry == nil {
+		return nil, errors.New("decoding snapshot: malformed entry")
+	}
+	return record.Entry.fileInfo(), nil
+}
+
+// readSnapshotBinary reads a whole snapshot in the binary encoding.
+func readSnapshotBinary(r io.Reader) (*Snapshot, error) {
+	sr, err := NewSnapshotReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*FileInfo
+	for {
+		info, err := sr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, info)
+	}
+	s := sr.Header()
+	s.Entries = entries
+	return s, nil
+}
+
+// WriteBinary writes the manifest to w in the compact binary encoding, which is read by ReadManifest as well.
+func (m *Manifest) WriteBinary(w io.Writer) error {
+	if _, err := io.WriteString(w, manifestMagic); err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(m)
+}
+
+// isBinary tells whether r starts with the magic of a binary encoding, without consuming it.
+func isBinary(r *bufio.Reader, magic string) bool {
+	b, err := r.Peek(len(magic))
+	return err == nil && string(b) == magic
+}
@@ -0,0 +1,77 @@
+package ghwalk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotBinary(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	snapshot, err := TakeSnapshot(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true})
+	require.NoError(t, err)
+	empty := ""
+	snapshot.Entries = append(snapshot.Entries, &FileInfo{
+		Type:         FileTypeFile,
+		Name:         "empty",
+		Path:         "testdata/empty",
+		Attributes:   map[string]string{"text": "true"},
+		FileOnlyInfo: &FileOnlyInfo{Encoding: &empty, Content: &empty},
+	})
+
+	// The binary encoding round-trips losslessly with the JSON one
+	var jsonBuf, binBuf, jsonBuf2 bytes.Buffer
+	require.NoError(t, snapshot.Write(&jsonBuf))
+	fromJSON, err := ReadSnapshot(bytes.NewReader(jsonBuf.Bytes()))
+	require.NoError(t, err)
+	require.NoError(t, fromJSON.WriteBinary(&binBuf))
+	require.Less(t, binBuf.Len(), jsonBuf.Len())
+	fromBinary, err := ReadSnapshot(bytes.NewReader(binBuf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, fromJSON, fromBinary)
+	require.NoError(t, fromBinary.Write(&jsonBuf2))
+	require.Equal(t, jsonBuf.String(), jsonBuf2.String())
+
+	// Streaming
+	var buf bytes.Buffer
+	sw, err := NewSnapshotWriter(&buf, &Snapshot{Owner: "foo", Repo: "bar"})
+	require.NoError(t, err)
+	require.NoError(t, sw.Write(fromJSON.Entries[0]))
+	truncated := buf.Len()
+	sw.Partial = true
+	require.NoError(t, sw.Close())
+
+	sr, err := NewSnapshotReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, "foo", sr.Header().Owner)
+	info, err := sr.Next()
+	require.NoError(t, err)
+	require.Equal(t, fromJSON.Entries[0], info)
+	_, err = sr.Next()
+	require.Equal(t, io.EOF, err)
+	require.True(t, sr.Header().Partial)
+
+	_, err = ReadSnapshot(bytes.NewReader(buf.Bytes()[:truncated]))
+	require.True(t, errors.Is(err, io.ErrUnexpectedEOF), err)
+}
+
+func TestManifestBinary(t *testing.T) {
+	manifest := &Manifest{Owner: "foo", Repo: "bar", Path: "dir", Files: map[string]string{"a": "sha-a", "b/c": "sha-c"}}
+	for _, write := range []func(w io.Writer) error{manifest.Write, manifest.WriteBinary} {
+		var buf bytes.Buffer
+		require.NoError(t, write(&buf))
+		read, err := ReadManifest(&buf)
+		require.NoError(t, err)
+		require.Equal(t, manifest, read)
+	}
+}
@@ -0,0 +1,130 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is the error (possibly wrapped) returned for the requests rejected by an open CircuitBreaker.
+// Use errors.Is to check for it, or errors.As with *CircuitOpenError for the details.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError is the error returned for the requests rejected by an open CircuitBreaker.
+type CircuitOpenError struct {
+	// Failures is the number of consecutive failures that have tripped the breaker.
+	Failures int
+	// LastErr is the last failure.
+	LastErr error
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%v after %d consecutive failures, the last one being: %v", ErrCircuitOpen, e.Failures, e.LastErr)
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitBreaker fails the API requests fast once a number of consecutive requests have failed (e.g. the token is
+// revoked, or Github is down), rather than grinding through the requests that are doomed to fail.
+// A request fails if it gets a network error, a 401 Unauthorized or a 5xx response.
+//
+// A CircuitBreaker is safe for concurrent use, and is meant to be shared by the walks against the same Github, by
+// setting it as the CircuitBreaker of their WalkOptions.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	lastErr  error
+	openedAt time.Time
+
+	// now is replaceable for testing
+	now func() time.Time
+}
+
+// NewCircuitBreaker returns a CircuitBreaker that trips after threshold consecutive failures. Once tripped, all the
+// requests are rejected with a *CircuitOpenError. If cooldown is greater than zero, a trial request is let through
+// after the cooldown, which closes the breaker if it succeeds, otherwise the breaker stays open for another cooldown.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Reset closes the breaker, clearing the failures.
+func (b *CircuitBreaker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.lastErr = nil
+	b.openedAt = time.Time{}
+}
+
+// allow returns the error if the request should be rejected.
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return nil
+	}
+	if b.cooldown > 0 && b.now().Sub(b.openedAt) >= b.cooldown {
+		// Let a trial request through, and hold off the others for another cooldown
+		b.openedAt = b.now()
+		return nil
+	}
+	return &CircuitOpenError{Failures: b.failures, LastErr: b.lastErr}
+}
+
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		b.lastErr = nil
+		return
+	}
+	b.failures++
+	b.lastErr = err
+	if b.failures == b.threshold {
+		b.openedAt = b.now()
+	}
+}
+
+// breakerTransport guards the requests with a CircuitBreaker.
+type breakerTransport struct {
+	base    http.RoundTripper
+	breaker *CircuitBreaker
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.breaker.allow(); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	switch {
+	case err != nil:
+		// The cancellation by the caller is not a failure of the API
+		if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+			t.breaker.record(err)
+		}
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode >= 500:
+		t.breaker.record(&StatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+	default:
+		t.breaker.record(nil)
+	}
+	return resp, err
+}
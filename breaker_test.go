@@ -0,0 +1,73 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	now := time.Now()
+	breaker := NewCircuitBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	walk := func(ignoreErr bool) (int, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		var errCount int
+		err := Walk(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{BaseURL: srv.BaseURL(), CircuitBreaker: breaker},
+			func(path string, info *FileInfo, err error) error {
+				if err != nil {
+					errCount++
+					if !ignoreErr {
+						return err
+					}
+				}
+				return nil
+			},
+			nil)
+		return errCount, err
+	}
+
+	// The failures are not consecutive
+	srv.InjectFault(ghwalktest.Fault{Kind: ghwalktest.FaultServerError, Path: "testdata/dir"})
+	n, err := walk(true)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	srv.ClearFaults()
+	_, err = walk(false)
+	require.NoError(t, err)
+
+	// Trip the breaker
+	srv.InjectFault(ghwalktest.Fault{Kind: ghwalktest.FaultServerError})
+	for i := 0; i < 2; i++ {
+		_, err = walk(false)
+		require.Error(t, err)
+		require.False(t, errors.Is(err, ErrCircuitOpen))
+	}
+	_, err = walk(false)
+	require.True(t, errors.Is(err, ErrCircuitOpen))
+	var cerr *CircuitOpenError
+	require.True(t, errors.As(err, &cerr))
+	require.Equal(t, 2, cerr.Failures)
+
+	// Fail fast even if the server recovers
+	srv.ClearFaults()
+	before := srv.RequestCount()
+	_, err = walk(false)
+	require.True(t, errors.Is(err, ErrCircuitOpen))
+	require.Equal(t, before, srv.RequestCount())
+
+	// Closed by the trial request after the cooldown
+	now = now.Add(time.Minute)
+	n, err = walk(false)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+}
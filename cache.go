@@ -0,0 +1,127 @@
+package ghwalk
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+)
+
+// Cache stores the HTTP responses for the caching transport. Its method set is the same as the Cache of the
+// github.com/gregjones/httpcache package, so that the caches implemented there (e.g. disk or memcache based ones) can
+// be used as is.
+type Cache interface {
+	// Get returns the response bytes stored for the key, and whether it is found.
+	Get(key string) (responseBytes []byte, ok bool)
+	// Set stores the response bytes for the key.
+	Set(key string, responseBytes []byte)
+	// Delete removes the key from the cache.
+	Delete(key string)
+}
+
+// memoryCache is a Cache kept in memory.
+type memoryCache struct {
+	mu    sync.RWMutex
+	items map[string][]byte
+}
+
+// NewMemoryCache returns a Cache that keeps the responses in memory, which is safe for concurrent use.
+func NewMemoryCache() Cache {
+	return &memoryCache{items: map[string][]byte{}}
+}
+
+func (c *memoryCache) Get(key string) ([]byte, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	b, ok := c.items[key]
+	return b, ok
+}
+
+func (c *memoryCache) Set(key string, responseBytes []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[key] = responseBytes
+}
+
+func (c *memoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, key)
+}
+
+// cachingTransport caches the GET responses carrying an ETag or Last-Modified header, and revalidates them by
+// conditional requests. The 304 Not Modified responses don't count against the Github rate limit.
+type cachingTransport struct {
+	base  http.RoundTripper
+	cache Cache
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The conditional requests of the caller (e.g. a caching transport underneath) are passed through as is.
+	if req.Method != http.MethodGet || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	key := cacheKey(req)
+	var cached *http.Response
+	if b, ok := t.cache.Get(key); ok {
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(b)), req)
+		if err == nil {
+			cached = resp
+		} else {
+			t.cache.Delete(key)
+		}
+	}
+
+	creq := req
+	if cached != nil {
+		creq = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			creq.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := cached.Header.Get("Last-Modified"); lastModified != "" {
+			creq.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(creq)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		// The rate limit headers of the fresh response are more accurate
+		for k, v := range resp.Header {
+			cached.Header[k] = v
+		}
+		cached.Header.Set("X-From-Cache", "1")
+		return cached, nil
+	}
+	if cached != nil {
+		cached.Body.Close()
+	}
+
+	if resp.StatusCode == http.StatusOK && (resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "") {
+		// DumpResponse reads the body, and replaces it with an in-memory copy.
+		b, err := httputil.DumpResponse(resp, true)
+		if err != nil {
+			return nil, err
+		}
+		t.cache.Set(key, b)
+	}
+	return resp, nil
+}
+
+// cacheKey returns the cache key of the request, which distinguishes the credentials, so that a cache shared by
+// different tokens never serves the content that is only visible to another token.
+func cacheKey(req *http.Request) string {
+	key := req.Method + " " + req.URL.String()
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		sum := sha256.Sum256([]byte(auth))
+		key += " " + hex.EncodeToString(sum[:8])
+	}
+	return key
+}
@@ -0,0 +1,64 @@
+package ghwalk
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+type statusCountingTransport struct {
+	counts map[int]int
+}
+
+func (t *statusCountingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		t.counts[resp.StatusCode]++
+	}
+	return resp, err
+}
+
+func TestWalkWithCache(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	transport := &statusCountingTransport{counts: map[int]int{}}
+	opt := &WalkOptions{BaseURL: srv.BaseURL(), Token: "secret", Transport: transport, Cache: NewMemoryCache(), EnableFileOnlyInfo: true}
+	walk := func() map[string]string {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		shas := map[string]string{}
+		err := Walk(ctx, "magodo", "ghwalk", "testdata", opt,
+			func(path string, info *FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				shas[path] = info.SHA
+				if info.Type == FileTypeFile {
+					_, err := info.GetContent()
+					return err
+				}
+				return nil
+			},
+			nil)
+		require.NoError(t, err)
+		return shas
+	}
+
+	expect := walk()
+	requests := transport.counts[http.StatusOK]
+	require.Equal(t, map[int]int{http.StatusOK: requests}, transport.counts)
+
+	// All the responses are revalidated
+	require.Equal(t, expect, walk())
+	require.Equal(t, map[int]int{http.StatusOK: requests, http.StatusNotModified: requests}, transport.counts)
+
+	// A different token doesn't share the cache
+	opt.Token = "another"
+	require.Equal(t, expect, walk())
+	require.Equal(t, map[int]int{http.StatusOK: 2 * requests, http.StatusNotModified: requests}, transport.counts)
+}
@@ -0,0 +1,348 @@
+// Command ghvendor vendors directories of other Github repositories into the local tree, e.g. the proto files or the
+// policy bundles shared across repositories, without cloning them. It is meant to be run by go:generate:
+//
+//	//go:generate go run github.com/magodo/ghwalk/cmd/ghvendor
+//
+// The directories to vendor are listed in the spec file (ghvendor.yml by default), e.g.
+//
+//	vendor:
+//	  - repo: googleapis/googleapis
+//	    ref: master
+//	    path: google/api
+//	    dir: third_party/google/api
+//
+// where the dir is relative to the spec file. Each ref is pinned to the commit it points to when first vendored, which
+// is recorded in the lock file (the spec file with the extension replaced by ".lock") along with the SHA of each
+// vendored file. The later runs vendor from the pinned commits, and only download the files that are missing locally
+// or differ from the lock, until the refs are re-resolved by --update (or changed in the spec). The files that are no
+// longer vendored are removed.
+//
+// Usage:
+//
+//	ghvendor [-f ghvendor.yml] [--update | --check] [--token token] [--base-url url]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/magodo/ghwalk"
+	"github.com/magodo/ghwalk/internal/githash"
+	"gopkg.in/yaml.v3"
+)
+
+const usage = "ghvendor [-f ghvendor.yml] [--update | --check] [--token token] [--base-url url]"
+
+// spec is the spec file of ghvendor.
+type spec struct {
+	// BaseURL is the Github API base URL, which is overridden by --base-url.
+	BaseURL string        `yaml:"base-url"`
+	Vendor  []*vendorSpec `yaml:"vendor"`
+}
+
+// vendorSpec is a directory to vendor.
+type vendorSpec struct {
+	// Repo is the repository, in the form of "owner/repo".
+	Repo string `yaml:"repo"`
+	// Ref is the git ref to vendor from (defaults to the default branch).
+	Ref string `yaml:"ref"`
+	// Path is the directory in the repository (defaults to the root).
+	Path string `yaml:"path"`
+	// Dir is the local directory to vendor into, relative to the spec file.
+	Dir string `yaml:"dir"`
+}
+
+// lockFile is the lock file of ghvendor, which records the vendored files.
+type lockFile struct {
+	Vendor []*lockEntry `json:"vendor"`
+}
+
+// lockEntry records the files vendored into Dir from the Ref, whose Manifest records the pinned commit as its Ref.
+type lockEntry struct {
+	Dir      string           `json:"dir"`
+	Ref      string           `json:"ref,omitempty"`
+	Manifest *ghwalk.Manifest `json:"manifest"`
+}
+
+// env is the environment that ghvendor runs in.
+type env struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	// On interrupt, each vendored directory is left with its files either vendored or as they were, see
+	// ghwalk.MirrorError.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], &env{stdout: os.Stdout, stderr: os.Stderr})
+	stop()
+	os.Exit(code)
+}
+
+// run runs the command line args, and returns the exit code.
+func run(ctx context.Context, args []string, env *env) int {
+	fs := flag.NewFlagSet("ghvendor", flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(env.stderr, "usage: %s\n\nflags:\n", usage)
+		fs.PrintDefaults()
+	}
+	specPath := fs.String("f", "ghvendor.yml", "the spec file")
+	update := fs.Bool("update", false, "re-resolve the refs to the commits they currently point to")
+	check := fs.Bool("check", false, "only check that the vendored files match the lock file, without any API request")
+	token := fs.String("token", "", "Github access token (defaults to the GH_TOKEN or GITHUB_TOKEN environment variable)")
+	baseURL := fs.String("base-url", "", "Github API base URL (defaults to the base-url of the spec file, or the GITHUB_API_URL environment variable)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() != 0 || (*update && *check) {
+		fmt.Fprintf(env.stderr, "usage: %s\n", usage)
+		return 2
+	}
+
+	v, err := newVendorer(*specPath)
+	if err == nil {
+		if *check {
+			err = v.check(env)
+		} else {
+			opt := &ghwalk.WalkOptions{Token: *token, BaseURL: *baseURL}
+			if opt.BaseURL == "" {
+				opt.BaseURL = v.spec.BaseURL
+			}
+			err = v.sync(ctx, opt, *update)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(env.stderr, "ghvendor: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// vendorer vendors the directories of a spec file.
+type vendorer struct {
+	spec *spec
+	// root is the directory of the spec file, which the dirs are relative to
+	root     string
+	lockPath string
+	lock     *lockFile
+}
+
+// newVendorer reads the spec file, and the lock file next to it, which is empty if it doesn't exist yet.
+func newVendorer(specPath string) (*vendorer, error) {
+	b, err := os.ReadFile(specPath)
+	if err != nil {
+		return nil, err
+	}
+	var s spec
+	if err := yaml.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("decoding %s: %v", specPath, err)
+	}
+	dirs := map[string]bool{}
+	for _, vs := range s.Vendor {
+		if _, _, err := parseRepo(vs.Repo); err != nil {
+			return nil, fmt.Errorf("%s: %v", specPath, err)
+		}
+		if vs.Dir == "" || filepath.IsAbs(vs.Dir) {
+			return nil, fmt.Errorf("%s: the dir of %s must be a relative path", specPath, vs.Repo)
+		}
+		vs.Dir = filepath.ToSlash(filepath.Clean(vs.Dir))
+		vs.Path = strings.Trim(vs.Path, "/")
+		if dirs[vs.Dir] {
+			return nil, fmt.Errorf("%s: the dir %s is vendored more than once", specPath, vs.Dir)
+		}
+		dirs[vs.Dir] = true
+	}
+
+	v := &vendorer{
+		spec:     &s,
+		root:     filepath.Dir(specPath),
+		lockPath: strings.TrimSuffix(specPath, filepath.Ext(specPath)) + ".lock",
+		lock:     &lockFile{},
+	}
+	b, err = os.ReadFile(v.lockPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, v.lock); err != nil {
+		return nil, fmt.Errorf("decoding %s: %v", v.lockPath, err)
+	}
+	return v, nil
+}
+
+// locked returns the lock entry of the dir, which is nil if it is not vendored yet.
+func (v *vendorer) locked(dir string) *lockEntry {
+	for _, entry := range v.lock.Vendor {
+		if entry.Dir == dir && entry.Manifest != nil {
+			return entry
+		}
+	}
+	return nil
+}
+
+// pinned tells whether the lock entry pins the same repository path and ref as the spec.
+func (vs *vendorSpec) pinned(entry *lockEntry) bool {
+	owner, repo, _ := parseRepo(vs.Repo)
+	m := entry.Manifest
+	return entry.Ref == vs.Ref && m.Owner == owner && m.Repo == repo && m.Path == vs.Path && m.Ref != ""
+}
+
+// sync vendors the directories of the spec, and writes the lock file.
+func (v *vendorer) sync(ctx context.Context, opt *ghwalk.WalkOptions, update bool) error {
+	lock := &lockFile{Vendor: []*lockEntry{}}
+	for _, vs := range v.spec.Vendor {
+		owner, repo, _ := parseRepo(vs.Repo)
+		dir := filepath.Join(v.root, filepath.FromSlash(vs.Dir))
+		o := *opt
+		o.Ref, o.PinCommit = vs.Ref, true
+
+		// The files that differ from the lock are downloaded again, as if they were missing.
+		var prev *ghwalk.Manifest
+		if entry := v.locked(vs.Dir); entry != nil {
+			if vs.pinned(entry) && !update {
+				o.Ref, o.PinCommit = entry.Manifest.Ref, false
+			}
+			prev = &ghwalk.Manifest{Files: map[string]string{}}
+			for rel, sha := range entry.Manifest.Files {
+				if localSHA(dir, rel) == sha {
+					prev.Files[rel] = sha
+				}
+			}
+		}
+		m, err := ghwalk.Mirror(ctx, owner, repo, vs.Path, dir, prev, &o)
+		if err != nil {
+			return fmt.Errorf("vendoring %s/%s into %s: %w", vs.Repo, vs.Path, vs.Dir, err)
+		}
+		lock.Vendor = append(lock.Vendor, &lockEntry{Dir: vs.Dir, Ref: vs.Ref, Manifest: m})
+	}
+
+	for _, entry := range v.lock.Vendor {
+		if entry.Manifest == nil || lock.find(entry.Dir) != nil {
+			continue
+		}
+		if err := removeFiles(filepath.Join(v.root, filepath.FromSlash(entry.Dir)), entry.Manifest); err != nil {
+			return err
+		}
+	}
+
+	b, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(v.lockPath, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	v.lock = lock
+	return nil
+}
+
+// find returns the lock entry of the dir, or nil.
+func (l *lockFile) find(dir string) *lockEntry {
+	for _, entry := range l.Vendor {
+		if entry.Dir == dir {
+			return entry
+		}
+	}
+	return nil
+}
+
+// check reports the vendored files that are missing or differ from the lock, along with the dirs that are not
+// vendored as the spec specifies, and fails if there is any.
+func (v *vendorer) check(env *env) error {
+	var problems int
+	for _, vs := range v.spec.Vendor {
+		entry := v.locked(vs.Dir)
+		if entry == nil || !vs.pinned(entry) {
+			fmt.Fprintf(env.stdout, "%s: not vendored from %s/%s at %q\n", vs.Dir, vs.Repo, vs.Path, vs.Ref)
+			problems++
+			continue
+		}
+		dir := filepath.Join(v.root, filepath.FromSlash(vs.Dir))
+		var rels []string
+		for rel := range entry.Manifest.Files {
+			rels = append(rels, rel)
+		}
+		sort.Strings(rels)
+		for _, rel := range rels {
+			switch sha := localSHA(dir, rel); sha {
+			case entry.Manifest.Files[rel]:
+			case "":
+				fmt.Fprintf(env.stdout, "%s/%s: missing\n", vs.Dir, rel)
+				problems++
+			default:
+				fmt.Fprintf(env.stdout, "%s/%s: modified\n", vs.Dir, rel)
+				problems++
+			}
+		}
+	}
+	if problems > 0 {
+		return fmt.Errorf("%d problem(s) found, run ghvendor to fix", problems)
+	}
+	return nil
+}
+
+// localSHA returns the git blob SHA of the vendored file named by the slash separated path rel under dir, which is
+// the one of the link target for a symlink, or empty if the file doesn't exist.
+func localSHA(dir, rel string) string {
+	local := filepath.Join(dir, filepath.FromSlash(rel))
+	fi, err := os.Lstat(local)
+	if err != nil {
+		return ""
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(local)
+		if err != nil {
+			return ""
+		}
+		return githash.BlobSHA([]byte(filepath.ToSlash(target)))
+	}
+	content, err := os.ReadFile(local)
+	if err != nil {
+		return ""
+	}
+	return githash.BlobSHA(content)
+}
+
+// removeFiles removes the files of the manifest from dir, along with the directories that become empty.
+func removeFiles(dir string, m *ghwalk.Manifest) error {
+	for rel := range m.Files {
+		local := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.Remove(local); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for parent := filepath.Dir(local); strings.HasPrefix(parent, dir); parent = filepath.Dir(parent) {
+			entries, err := os.ReadDir(parent)
+			if err != nil || len(entries) != 0 {
+				break
+			}
+			if err := os.Remove(parent); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseRepo parses the "owner/repo" of a spec.
+func parseRepo(s string) (owner, repo string, err error) {
+	owner, repo, ok := strings.Cut(s, "/")
+	if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return "", "", fmt.Errorf("invalid repository %q, expect owner/repo", s)
+	}
+	return owner, repo, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+// runCommand runs the command line args, and returns the exit code along with the stdout and stderr.
+func runCommand(t *testing.T, args ...string) (int, string, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	code := run(ctx, args, &env{stdout: &stdout, stderr: &stderr})
+	return code, stdout.String(), stderr.String()
+}
+
+func readLock(t *testing.T, p string) *lockFile {
+	b, err := os.ReadFile(p)
+	require.NoError(t, err)
+	var lock lockFile
+	require.NoError(t, json.Unmarshal(b, &lock))
+	return &lock
+}
+
+func TestVendor(t *testing.T) {
+	fixture := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(fixture, "proto", "api"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(fixture, "proto", "api", "a.proto"), []byte("a\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(fixture, "proto", "b.proto"), []byte("b\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(fixture, "README"), []byte("readme\n"), 0644))
+	srv := ghwalktest.NewServer(map[string]string{
+		"foo/protos": fixture,
+		"foo/bar":    "../../testdata",
+	})
+	defer srv.Close()
+
+	root := t.TempDir()
+	specPath := filepath.Join(root, "ghvendor.yml")
+	lockPath := filepath.Join(root, "ghvendor.lock")
+	writeSpec := func(spec string) {
+		require.NoError(t, os.WriteFile(specPath, []byte("base-url: "+srv.BaseURL()+"\n"+spec), 0644))
+	}
+	writeSpec(`
+vendor:
+  - repo: foo/protos
+    path: proto
+    dir: third_party/proto
+  - repo: foo/bar
+    ref: main
+    path: dir
+    dir: third_party/dir
+`)
+
+	code, _, stderr := runCommand(t, "-f", specPath)
+	require.Equal(t, 0, code, stderr)
+	b, err := os.ReadFile(filepath.Join(root, "third_party", "proto", "api", "a.proto"))
+	require.NoError(t, err)
+	require.Equal(t, "a\n", string(b))
+	require.FileExists(t, filepath.Join(root, "third_party", "dir", "c"))
+	require.NoFileExists(t, filepath.Join(root, "third_party", "proto", "README"))
+
+	lock := readLock(t, lockPath)
+	require.Len(t, lock.Vendor, 2)
+	require.Equal(t, "third_party/proto", lock.Vendor[0].Dir)
+	commit := lock.Vendor[0].Manifest.Ref
+	require.Len(t, commit, 40)
+	require.Len(t, lock.Vendor[0].Manifest.Files, 2)
+	require.Equal(t, "main", lock.Vendor[1].Ref)
+
+	code, stdout, _ := runCommand(t, "-f", specPath, "--check")
+	require.Equal(t, 0, code, stdout)
+
+	// The local changes are detected, and reverted by the next run
+	require.NoError(t, os.WriteFile(filepath.Join(root, "third_party", "proto", "b.proto"), []byte("changed\n"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(root, "third_party", "dir", "c")))
+	code, stdout, _ = runCommand(t, "-f", specPath, "--check")
+	require.Equal(t, 1, code)
+	require.Equal(t, "third_party/proto/b.proto: modified\nthird_party/dir/c: missing\n", stdout)
+	count := srv.RequestCount()
+	code, _, stderr = runCommand(t, "-f", specPath)
+	require.Equal(t, 0, code, stderr)
+	// A tree and a blob of each repository
+	require.Equal(t, 4, srv.RequestCount()-count)
+	b, err = os.ReadFile(filepath.Join(root, "third_party", "proto", "b.proto"))
+	require.NoError(t, err)
+	require.Equal(t, "b\n", string(b))
+	code, stdout, _ = runCommand(t, "-f", specPath, "--check")
+	require.Equal(t, 0, code, stdout)
+
+	// The commit stays pinned until --update
+	require.NoError(t, os.WriteFile(filepath.Join(fixture, "proto", "c.proto"), []byte("c\n"), 0644))
+	code, _, stderr = runCommand(t, "-f", specPath)
+	require.Equal(t, 0, code, stderr)
+	require.Equal(t, commit, readLock(t, lockPath).Vendor[0].Manifest.Ref)
+	code, _, stderr = runCommand(t, "-f", specPath, "--update")
+	require.Equal(t, 0, code, stderr)
+	lock = readLock(t, lockPath)
+	require.NotEqual(t, commit, lock.Vendor[0].Manifest.Ref)
+	require.Contains(t, lock.Vendor[0].Manifest.Files, "c.proto")
+
+	// The dirs no longer vendored are removed
+	writeSpec(`
+vendor:
+  - repo: foo/protos
+    path: proto
+    dir: third_party/proto
+`)
+	code, _, stderr = runCommand(t, "-f", specPath)
+	require.Equal(t, 0, code, stderr)
+	require.NoDirExists(t, filepath.Join(root, "third_party", "dir"))
+	require.Len(t, readLock(t, lockPath).Vendor, 1)
+
+	// A changed ref is not pinned until vendored again
+	writeSpec(`
+vendor:
+  - repo: foo/protos
+    ref: v2
+    path: proto
+    dir: third_party/proto
+`)
+	code, stdout, _ = runCommand(t, "-f", specPath, "--check")
+	require.Equal(t, 1, code)
+	require.Contains(t, stdout, `third_party/proto: not vendored from foo/protos/proto at "v2"`)
+
+	writeSpec(`
+vendor:
+  - repo: foo
+    dir: x
+`)
+	code, _, stderr = runCommand(t, "-f", specPath)
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, `invalid repository "foo"`)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/magodo/ghwalk"
+	"gopkg.in/yaml.v3"
+)
+
+// config is the configuration file of ghwalk, e.g.
+//
+//	default: work
+//	profiles:
+//	  work:
+//	    base-url: https://github.example.com/api/v3/
+//	    token-command: gh auth token --hostname github.example.com
+//	    skip: [vendor, node_modules]
+//	    cache-dir: ~/.cache/ghwalk/work
+//	    retry:
+//	      base-delay: 5s
+//	      jitter: 0.3
+type config struct {
+	// Default is the profile used if --profile is not specified.
+	Default  string              `yaml:"default"`
+	Profiles map[string]*profile `yaml:"profiles"`
+}
+
+// profile is a named set of the defaults of the command line flags.
+type profile struct {
+	// BaseURL is the Github API base URL.
+	BaseURL string `yaml:"base-url"`
+
+	// The token source, where the first one set is used: the Token itself, the environment variable named by
+	// TokenEnv, or the output of the TokenCommand (which is not run by a shell).
+	Token        string `yaml:"token"`
+	TokenEnv     string `yaml:"token-env"`
+	TokenCommand string `yaml:"token-command"`
+
+	// Skip are the shell patterns (see path.Match) of the names of the files and directories to skip, which apply to
+	// diff and find.
+	Skip []string `yaml:"skip"`
+
+	// CacheDir is the directory to cache the API responses in, so that the unchanged content is revalidated by the
+	// conditional requests, which don't count against the rate limit.
+	CacheDir string `yaml:"cache-dir"`
+
+	// Retry, if set, retries the failed API requests with the exponential backoff, see retryConfig.
+	Retry *retryConfig `yaml:"retry"`
+}
+
+// retryConfig is the parameters of ghwalk.ExponentialBackoff, where the unset ones default to those of
+// ghwalk.DefaultRetryPolicy.
+type retryConfig struct {
+	MaxAttempts int           `yaml:"max-attempts"`
+	BaseDelay   time.Duration `yaml:"base-delay"`
+	Multiplier  float64       `yaml:"multiplier"`
+	MaxDelay    time.Duration `yaml:"max-delay"`
+	MaxElapsed  time.Duration `yaml:"max-elapsed"`
+	Jitter      float64       `yaml:"jitter"`
+}
+
+func (c *retryConfig) policy() ghwalk.ExponentialBackoff {
+	policy := ghwalk.DefaultRetryPolicy.(ghwalk.ExponentialBackoff)
+	if c.MaxAttempts > 0 {
+		policy.MaxAttempts = c.MaxAttempts
+	}
+	if c.BaseDelay > 0 {
+		policy.BaseDelay = c.BaseDelay
+	}
+	if c.Multiplier > 0 {
+		policy.Multiplier = c.Multiplier
+	}
+	if c.MaxDelay > 0 {
+		policy.MaxDelay = c.MaxDelay
+	}
+	if c.MaxElapsed > 0 {
+		policy.MaxElapsed = c.MaxElapsed
+	}
+	if c.Jitter > 0 {
+		policy.Jitter = c.Jitter
+	}
+	return policy
+}
+
+// configPath returns the path of the configuration file, which is $GHWALK_CONFIG if set, otherwise
+// $XDG_CONFIG_HOME/ghwalk/config.yml, where XDG_CONFIG_HOME defaults to ~/.config.
+func configPath() (string, error) {
+	if p := os.Getenv("GHWALK_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "ghwalk", "config.yml"), nil
+}
+
+// loadConfig loads the configuration file, which is empty if the file doesn't exist.
+func loadConfig() (*config, error) {
+	p, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return &config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cfg config
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("decoding %s: %v", p, err)
+	}
+	return &cfg, nil
+}
+
+// profile returns the named profile, or the default one if name is empty, which is nil if there is no default.
+func (c *config) profile(name string) (*profile, error) {
+	if name == "" {
+		if c.Default == "" {
+			return nil, nil
+		}
+		name = c.Default
+	}
+	p, ok := c.Profiles[name]
+	if !ok || p == nil {
+		return nil, fmt.Errorf("profile %q is not found in the configuration file", name)
+	}
+	return p, nil
+}
+
+// token returns the token from the token source of the profile, which is empty if no source is set.
+func (p *profile) token(ctx context.Context) (string, error) {
+	switch {
+	case p.Token != "":
+		return p.Token, nil
+	case p.TokenEnv != "":
+		return os.Getenv(p.TokenEnv), nil
+	case p.TokenCommand != "":
+		args := strings.Fields(p.TokenCommand)
+		if len(args) == 0 {
+			return "", fmt.Errorf("the token command %q consists of only white spaces", p.TokenCommand)
+		}
+		out, err := exec.CommandContext(ctx, args[0], args[1:]...).Output()
+		if err != nil {
+			return "", fmt.Errorf("running the token command %q: %v", p.TokenCommand, err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	default:
+		return "", nil
+	}
+}
+
+// skipFilter returns the filter that skips the files and directories whose name matches any of the Skip patterns,
+// which is nil if there is no pattern.
+func (p *profile) skipFilter() ghwalk.PathFilterFunc {
+	if len(p.Skip) == 0 {
+		return nil
+	}
+	return func(rel string, info *ghwalk.FileInfo) bool {
+		return p.skipped(path.Base(rel))
+	}
+}
+
+// skipped tells whether any of the slash separated path components matches the Skip patterns.
+func (p *profile) skipped(rel string) bool {
+	for _, name := range strings.Split(rel, "/") {
+		for _, pattern := range p.Skip {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// diskCache is a ghwalk.Cache storing each response in a file under dir.
+type diskCache struct {
+	dir string
+}
+
+func newDiskCache(dir string) (*diskCache, error) {
+	if strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(home, dir[2:])
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &diskCache{dir: dir}, nil
+}
+
+func (c *diskCache) file(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+func (c *diskCache) Get(key string) ([]byte, bool) {
+	b, err := os.ReadFile(c.file(key))
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+func (c *diskCache) Set(key string, responseBytes []byte) {
+	// Write to a temporary file first, so that a concurrent Get never reads a partial response.
+	f, err := os.CreateTemp(c.dir, ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(responseBytes)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+	if err := os.Rename(f.Name(), c.file(key)); err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+func (c *diskCache) Delete(key string) {
+	os.Remove(c.file(key))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk"
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestConfig(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": "../../testdata"})
+	defer srv.Close()
+	srv.AddToken("secret", ghwalktest.Token{Login: "me"})
+
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	configFile := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(configFile, []byte(fmt.Sprintf(`
+default: work
+profiles:
+  work:
+    base-url: %[1]s
+    token-env: GHWALK_TEST_TOKEN
+    skip: [dir]
+    cache-dir: %[2]s
+  plain:
+    base-url: %[1]s
+    token: secret
+  blank:
+    base-url: %[1]s
+    token-command: "  "
+`, srv.BaseURL(), cacheDir)), 0644))
+	t.Setenv("GHWALK_CONFIG", configFile)
+	t.Setenv("GHWALK_TEST_TOKEN", "secret")
+
+	cases := []struct {
+		args   []string
+		expect string
+		code   int
+	}{
+		// The default profile
+		{args: []string{"foo/bar"}, expect: "a\nb\nlink_dir\n"},
+		{args: []string{"foo/bar", "--profile", "plain"}, expect: "a\nb\ndir\ndir/c\nlink_dir\n"},
+		// The flags take precedence over the profile
+		{args: []string{"foo/bar", "--token", "invalid"}, code: 1},
+		{args: []string{"foo/bar", "--profile", "unknown"}, code: 1},
+		{args: []string{"foo/bar", "--profile", "blank"}, code: 1},
+	}
+	for _, c := range cases {
+		code, stdout, stderr := runCommand(t, append([]string{"find"}, c.args...)...)
+		require.Equal(t, c.code, code, stderr)
+		require.Equal(t, c.expect, stdout, c.args)
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+}
+
+func TestDiskCache(t *testing.T) {
+	c, err := newDiskCache(t.TempDir())
+	require.NoError(t, err)
+	_, ok := c.Get("key")
+	require.False(t, ok)
+	c.Set("key", []byte("value"))
+	b, ok := c.Get("key")
+	require.True(t, ok)
+	require.Equal(t, "value", string(b))
+	c.Delete("key")
+	_, ok = c.Get("key")
+	require.False(t, ok)
+}
+
+func TestRetryConfig(t *testing.T) {
+	var cfg config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+profiles:
+  ci:
+    retry:
+      base-delay: 5s
+      max-elapsed: 2m
+      jitter: 0.3
+`), &cfg))
+	prof, err := cfg.profile("ci")
+	require.NoError(t, err)
+	require.Equal(t, ghwalk.ExponentialBackoff{
+		MaxAttempts: 3,
+		BaseDelay:   5 * time.Second,
+		MaxDelay:    30 * time.Second,
+		MaxElapsed:  2 * time.Minute,
+		Jitter:      0.3,
+	}, prof.Retry.policy())
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/magodo/ghwalk"
+)
+
+const diffUsage = "diff owner/repo --base <ref> --head <ref> [path]"
+
+// runDiff prints the files that differ between the base and head refs, one per line, prefixed by the status as
+// "git diff --name-status" does: "A" for added, "D" for removed and "M" for modified.
+func runDiff(ctx context.Context, env *env, args []string) error {
+	fs := newFlagSet(env, diffUsage)
+	wf := newWalkFlags(fs)
+	base := fs.String("base", "", "the base ref")
+	head := fs.String("head", "", "the head ref")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	owner, repo, path, err := parseRepoPath(args)
+	if err != nil {
+		return err
+	}
+	if *base == "" || *head == "" {
+		return usageError("both --base and --head are required")
+	}
+
+	opt, prof, err := wf.options(ctx)
+	if err != nil {
+		return err
+	}
+	return ghwalk.CompareWalk(ctx, owner, repo, path, []string{*base, *head}, opt,
+		func(path string, infos []*ghwalk.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if ghwalk.Identical(infos) {
+				return ghwalk.SkipDir
+			}
+			from, to := diffFile(infos[0]), diffFile(infos[1])
+			switch {
+			case from == nil && to == nil:
+				return nil
+			case from == nil:
+				fmt.Fprintf(env.stdout, "A\t%s\n", path)
+			case to == nil:
+				fmt.Fprintf(env.stdout, "D\t%s\n", path)
+			case from.Type != to.Type || from.SHA != to.SHA:
+				fmt.Fprintf(env.stdout, "M\t%s\n", path)
+			}
+			return nil
+		},
+		prof.skipFilter())
+}
+
+// diffFile returns the info if it is not a directory, as only the files (including symlinks and submodules) are
+// reported by diff.
+func diffFile(info *ghwalk.FileInfo) *ghwalk.FileInfo {
+	if info == nil || info.IsDir() {
+		return nil
+	}
+	return info
+}
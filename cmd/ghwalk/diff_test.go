@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	changed := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(changed, "c"), []byte("changed\n"), 0644))
+	srv := ghwalktest.NewServer(map[string]string{
+		"foo/bar@old":     "../../testdata/dir",
+		"foo/bar@new":     "../../testdata",
+		"foo/bar@changed": changed,
+	})
+	defer srv.Close()
+
+	cases := []struct {
+		args   []string
+		expect string
+		code   int
+	}{
+		{
+			args:   []string{"foo/bar", "--base", "old", "--head", "new"},
+			expect: "A\ta\nA\tb\nD\tc\nA\tdir/c\nA\tlink_dir\n",
+		},
+		{
+			args:   []string{"foo/bar", "--base", "new", "--head", "old"},
+			expect: "D\ta\nD\tb\nA\tc\nD\tdir/c\nD\tlink_dir\n",
+		},
+		{
+			args:   []string{"foo/bar", "--base", "old", "--head", "changed"},
+			expect: "M\tc\n",
+		},
+		{
+			args: []string{"foo/bar", "--base", "new", "--head", "new"},
+		},
+		{
+			args:   []string{"--base", "old", "--head", "new", "foo/bar", "dir"},
+			expect: "A\tdir/c\n",
+		},
+		{
+			args: []string{"foo/bar", "--base", "old"},
+			code: 2,
+		},
+	}
+	for _, c := range cases {
+		code, stdout, stderr := runCommand(t, append([]string{"diff", "--base-url", srv.BaseURL()}, c.args...)...)
+		require.Equal(t, c.code, code, stderr)
+		require.Equal(t, c.expect, stdout, c.args)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/magodo/ghwalk"
+)
+
+const duUsage = "du owner/repo [path] [-s] [-h] [-d depth]"
+
+// runDu prints the cumulative size of path and each directory under it, one per line as "<size>\t<path>", in the walk
+// order. The repo root is printed as ".".
+func runDu(ctx context.Context, env *env, args []string) error {
+	fs := newFlagSet(env, duUsage)
+	wf := newWalkFlags(fs)
+	wf.addRef(fs)
+	summarize := fs.Bool("s", false, "only print the total size of path")
+	human := fs.Bool("h", false, "print the sizes in human readable format (e.g. 1.5K, 20M)")
+	depth := fs.Int("d", -1, "only print the directories at most depth levels below path")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	owner, repo, path, err := parseRepoPath(args)
+	if err != nil {
+		return err
+	}
+	if *summarize {
+		*depth = 0
+	}
+
+	opt, _, err := wf.options(ctx)
+	if err != nil {
+		return err
+	}
+	usages, err := ghwalk.DiskUsage(ctx, owner, repo, path, opt)
+	if err != nil {
+		return err
+	}
+	for _, usage := range usages {
+		if *depth >= 0 && relDepth(path, usage.Path) > *depth {
+			continue
+		}
+		size := fmt.Sprint(usage.Size)
+		if *human {
+			size = humanSize(usage.Size)
+		}
+		p := usage.Path
+		if p == "" {
+			p = "."
+		}
+		fmt.Fprintf(env.stdout, "%s\t%s\n", size, p)
+	}
+	return nil
+}
+
+// relDepth returns the number of levels that p is below the directory dir.
+func relDepth(dir, p string) int {
+	if p == dir {
+		return 0
+	}
+	if dir != "" {
+		p = strings.TrimPrefix(p, dir+"/")
+	}
+	return strings.Count(p, "/") + 1
+}
+
+// humanSize formats the size in bytes with the binary unit suffix, as "du -h" does.
+func humanSize(size int64) string {
+	const units = "KMGTPE"
+	if size < 1024 {
+		return fmt.Sprint(size)
+	}
+	f := float64(size)
+	var i int
+	for f /= 1024; f >= 1024 && i < len(units)-1; f /= 1024 {
+		i++
+	}
+	if f < 10 {
+		return fmt.Sprintf("%.1f%c", f, units[i])
+	}
+	return fmt.Sprintf("%.0f%c", f, units[i])
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDu(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": "../../"})
+	defer srv.Close()
+
+	cases := []struct {
+		args   []string
+		expect string
+	}{
+		{args: []string{"foo/bar", "testdata"}, expect: "49\ttestdata\n20\ttestdata/dir\n"},
+		{args: []string{"foo/bar", "testdata/", "-s"}, expect: "49\ttestdata\n"},
+		{args: []string{"-d", "1", "foo/bar", "testdata"}, expect: "49\ttestdata\n20\ttestdata/dir\n"},
+		{args: []string{"-d", "0", "foo/bar", "testdata/dir"}, expect: "20\ttestdata/dir\n"},
+	}
+	for _, c := range cases {
+		code, stdout, stderr := runCommand(t, append([]string{"du", "--base-url", srv.BaseURL()}, c.args...)...)
+		require.Equal(t, 0, code, stderr)
+		require.Equal(t, c.expect, stdout, c.args)
+	}
+}
+
+func TestHumanSize(t *testing.T) {
+	cases := []struct {
+		size   int64
+		expect string
+	}{
+		{size: 0, expect: "0"},
+		{size: 1023, expect: "1023"},
+		{size: 1536, expect: "1.5K"},
+		{size: 20 << 20, expect: "20M"},
+		{size: 3 << 30, expect: "3.0G"},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.expect, humanSize(c.size), c.size)
+	}
+}
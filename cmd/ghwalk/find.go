@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/magodo/ghwalk"
+)
+
+const findUsage = "find owner/repo [path] [-name pattern] [-size [+-]n[KMG]] [-type f|d|l|s]"
+
+// runFind prints the path of each file or directory under path (including path itself) that matches all the
+// conditions, one per line, in the walk order.
+func runFind(ctx context.Context, env *env, args []string) error {
+	fs := newFlagSet(env, findUsage)
+	wf := newWalkFlags(fs)
+	wf.addRef(fs)
+	name := fs.String("name", "", "the shell pattern (see path.Match) that the base name matches")
+	size := fs.String("size", "", "the size in bytes, optionally with the K, M or G suffix; prefix with + for greater than, - for less than")
+	typ := fs.String("type", "", "the type: f (file), d (directory), l (symlink) or s (submodule)")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	owner, repo, p, err := parseRepoPath(args)
+	if err != nil {
+		return err
+	}
+
+	var conds []ghwalk.MatchFunc
+	if *name != "" {
+		if _, err := path.Match(*name, ""); err != nil {
+			return usageError(fmt.Sprintf("invalid -name %q: %v", *name, err))
+		}
+		conds = append(conds, func(p string, info *ghwalk.FileInfo) bool {
+			ok, _ := path.Match(*name, info.Name)
+			return ok
+		})
+	}
+	if *size != "" {
+		cond, err := sizeCondition(*size)
+		if err != nil {
+			return err
+		}
+		conds = append(conds, cond)
+	}
+	if *typ != "" {
+		ft, ok := map[string]ghwalk.FileType{
+			"f": ghwalk.FileTypeFile,
+			"d": ghwalk.FileTypeDir,
+			"l": ghwalk.FileTypeSymlink,
+			"s": ghwalk.FileTypeSubmodule,
+		}[*typ]
+		if !ok {
+			return usageError(fmt.Sprintf("invalid -type %q", *typ))
+		}
+		conds = append(conds, func(p string, info *ghwalk.FileInfo) bool {
+			return info.Type == ft
+		})
+	}
+
+	opt, prof, err := wf.options(ctx)
+	if err != nil {
+		return err
+	}
+	infos, err := ghwalk.FindAll(ctx, owner, repo, p, func(p string, info *ghwalk.FileInfo) bool {
+		if prof.skipped(p) {
+			return false
+		}
+		for _, cond := range conds {
+			if !cond(p, info) {
+				return false
+			}
+		}
+		return true
+	}, opt)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		fmt.Fprintln(env.stdout, info.Path)
+	}
+	return nil
+}
+
+// sizeCondition parses the -size argument. Only files (including symlinks) match a size condition.
+func sizeCondition(arg string) (ghwalk.MatchFunc, error) {
+	s, cmp := arg, 0
+	switch {
+	case strings.HasPrefix(s, "+"):
+		cmp, s = 1, s[1:]
+	case strings.HasPrefix(s, "-"):
+		cmp, s = -1, s[1:]
+	}
+	unit := int64(1)
+	if i := strings.IndexAny(s, "KkMG"); i >= 0 && i == len(s)-1 {
+		unit = map[byte]int64{'K': 1 << 10, 'k': 1 << 10, 'M': 1 << 20, 'G': 1 << 30}[s[i]]
+		s = s[:i]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return nil, usageError(fmt.Sprintf("invalid -size %q", arg))
+	}
+	n *= unit
+	return func(p string, info *ghwalk.FileInfo) bool {
+		if info.IsDir() || info.Type == ghwalk.FileTypeSubmodule {
+			return false
+		}
+		switch size := int64(info.Size); cmp {
+		case 1:
+			return size > n
+		case -1:
+			return size < n
+		default:
+			return size == n
+		}
+	}, nil
+}
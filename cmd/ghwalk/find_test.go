@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFind(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": "../../testdata"})
+	defer srv.Close()
+
+	cases := []struct {
+		args   []string
+		expect string
+		code   int
+	}{
+		{args: []string{"foo/bar"}, expect: "a\nb\ndir\ndir/c\nlink_dir\n"},
+		{args: []string{"foo/bar", "dir"}, expect: "dir\ndir/c\n"},
+		{args: []string{"foo/bar", "-name", "[ac]"}, expect: "a\ndir/c\n"},
+		{args: []string{"foo/bar", "-type", "d"}, expect: "dir\n"},
+		{args: []string{"foo/bar", "-type", "l"}, expect: "link_dir\n"},
+		{args: []string{"foo/bar", "-size", "13"}, expect: "a\nb\n"},
+		{args: []string{"foo/bar", "-size", "+13"}, expect: "dir/c\n"},
+		{args: []string{"foo/bar", "-size", "-13"}, expect: "link_dir\n"},
+		{args: []string{"foo/bar", "-size", "-1K", "-type", "f", "-name", "*"}, expect: "a\nb\ndir/c\n"},
+		{args: []string{"foo/bar", "-size", "1X"}, code: 2},
+		{args: []string{"foo/bar", "-type", "x"}, code: 2},
+		{args: []string{"foo/bar", "-name", "["}, code: 2},
+	}
+	for _, c := range cases {
+		code, stdout, stderr := runCommand(t, append([]string{"find", "--base-url", srv.BaseURL()}, c.args...)...)
+		require.Equal(t, c.code, code, stderr)
+		require.Equal(t, c.expect, stdout, c.args)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/magodo/ghwalk"
+)
+
+const loginUsage = "login [--client-id id] [--scopes repo,...]"
+
+// runLogin authorizes a token by the OAuth device flow, and caches it in the ghwalk.DeviceTokenFile, which is picked
+// up by the other commands (and any other program built on ghwalk) if no other token is found.
+func runLogin(ctx context.Context, env *env, args []string) error {
+	fs := newFlagSet(env, loginUsage)
+	wf := newWalkFlags(fs)
+	clientID := fs.String("client-id", os.Getenv("GHWALK_CLIENT_ID"), "the client ID of the OAuth App with the device flow enabled (defaults to the GHWALK_CLIENT_ID environment variable)")
+	scopes := fs.String("scopes", "repo", "the comma separated OAuth scopes to request")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError("expect no argument")
+	}
+	if *clientID == "" {
+		return usageError("--client-id is required")
+	}
+	opt, _, err := wf.options(ctx)
+	if err != nil {
+		return err
+	}
+
+	flow := &ghwalk.DeviceFlow{
+		ClientID: *clientID,
+		Prompt: func(code *ghwalk.DeviceCode) {
+			fmt.Fprintf(env.stderr, "Open %s in the browser and enter the code: %s\n", code.VerificationURI, code.UserCode)
+		},
+		// Always authorize a new token, e.g. to replace a revoked one
+		Renew: true,
+	}
+	if *scopes != "" {
+		flow.Scopes = strings.Split(*scopes, ",")
+	}
+	if _, err := flow.Token(ctx, opt); err != nil {
+		return err
+	}
+	fmt.Fprintln(env.stderr, "Logged in")
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk"
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+// approvingWriter approves the device code once it is prompted.
+type approvingWriter struct {
+	bytes.Buffer
+	srv   *ghwalktest.Server
+	token string
+}
+
+func (w *approvingWriter) Write(p []byte) (int, error) {
+	if m := regexp.MustCompile(`enter the code: (\S+)`).FindSubmatch(p); m != nil {
+		w.srv.ApproveDevice(string(m[1]), w.token)
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestLogin(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": "../../testdata"})
+	defer srv.Close()
+	srv.AddToken("device-token", ghwalktest.Token{Login: "me"})
+	t.Setenv("GHWALK_CONFIG", filepath.Join(t.TempDir(), "config.yml"))
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GHWALK_CLIENT_ID", "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	var stdout bytes.Buffer
+	stderr := &approvingWriter{srv: srv, token: "device-token"}
+	code := run(ctx, []string{"login", "--base-url", srv.BaseURL(), "--client-id", "client"}, &env{stdout: &stdout, stderr: stderr})
+	require.Equal(t, 0, code, stderr.String())
+	require.Contains(t, stderr.String(), "Logged in")
+
+	file, err := ghwalk.DeviceTokenFile("127.0.0.1")
+	require.NoError(t, err)
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, "device-token\n", string(b))
+
+	code, _, stderr2 := runCommand(t, "login", "--base-url", srv.BaseURL())
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr2, "--client-id is required")
+}
@@ -0,0 +1,224 @@
+// Command ghwalk walks a Github repository from the command line, without cloning it.
+//
+// Usage:
+//
+//	ghwalk <command> [flags] [arguments]
+//
+// Run "ghwalk <command> -h" for the flags of a command.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/magodo/ghwalk"
+)
+
+// command is a subcommand of ghwalk.
+type command struct {
+	usage string
+	short string
+	run   func(ctx context.Context, env *env, args []string) error
+}
+
+var commands = map[string]command{
+	"diff": {
+		usage: diffUsage,
+		short: "print the files added, removed or modified between two refs",
+		run:   runDiff,
+	},
+	"login": {
+		usage: loginUsage,
+		short: "authorize a token by the OAuth device flow, which is used by the other commands",
+		run:   runLogin,
+	},
+	"du": {
+		usage: duUsage,
+		short: "print the cumulative size of each directory",
+		run:   runDu,
+	},
+	"find": {
+		usage: findUsage,
+		short: "print the files and directories matching the conditions",
+		run:   runFind,
+	},
+}
+
+// env is the environment that a command runs in.
+type env struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], &env{stdout: os.Stdout, stderr: os.Stderr}))
+}
+
+// run runs the command line args, and returns the exit code.
+func run(ctx context.Context, args []string, env *env) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		printUsage(env.stderr)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(env.stderr, "ghwalk: unknown command %q\n", args[0])
+		printUsage(env.stderr)
+		return 2
+	}
+	if err := cmd.run(ctx, env, args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		var uerr usageError
+		if errors.As(err, &uerr) {
+			fmt.Fprintf(env.stderr, "ghwalk %s: %v\nusage: ghwalk %s\n", args[0], err, cmd.usage)
+			return 2
+		}
+		fmt.Fprintf(env.stderr, "ghwalk %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: ghwalk <command> [flags] [arguments]\n\ncommands:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].short)
+	}
+}
+
+// usageError is returned by a command for an invalid command line.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// walkFlags are the flags shared by the commands, which make up the WalkOptions along with the profile.
+type walkFlags struct {
+	profile string
+	token   string
+	baseURL string
+	ref     string
+}
+
+func newWalkFlags(fs *flag.FlagSet) *walkFlags {
+	f := &walkFlags{}
+	fs.StringVar(&f.profile, "profile", "", "the profile in the configuration file (defaults to its default profile)")
+	fs.StringVar(&f.token, "token", "", "Github access token (defaults to the GH_TOKEN or GITHUB_TOKEN environment variable)")
+	fs.StringVar(&f.baseURL, "base-url", "", "Github API base URL (defaults to the GITHUB_API_URL environment variable, or https://api.github.com/)")
+	return f
+}
+
+// addRef adds the --ref flag, for the commands working on a single ref.
+func (f *walkFlags) addRef(fs *flag.FlagSet) {
+	fs.StringVar(&f.ref, "ref", "", "git ref, can be a SHA, branch or a tag (defaults to the default branch)")
+}
+
+// options returns the WalkOptions made up of the flags, which take precedence over the selected profile, along with
+// the profile (which is empty if there is none).
+func (f *walkFlags) options(ctx context.Context) (*ghwalk.WalkOptions, *profile, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	prof, err := cfg.profile(f.profile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if prof == nil {
+		prof = &profile{}
+	}
+
+	opt := &ghwalk.WalkOptions{
+		Token:   f.token,
+		BaseURL: f.baseURL,
+		Ref:     f.ref,
+	}
+	if opt.BaseURL == "" {
+		opt.BaseURL = prof.BaseURL
+	}
+	if opt.Token == "" {
+		if opt.Token, err = prof.token(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+	if prof.CacheDir != "" {
+		if opt.Cache, err = newDiskCache(prof.CacheDir); err != nil {
+			return nil, nil, err
+		}
+	}
+	if prof.Retry != nil {
+		opt.RetryPolicy = prof.Retry.policy()
+	}
+	return opt, prof, nil
+}
+
+// parseFlags parses args with fs, allowing the flags to be interleaved with the positional arguments, which are
+// returned.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// parseRepo parses the "owner/repo" argument.
+func parseRepo(s string) (owner, repo string, err error) {
+	owner, repo, ok := strings.Cut(s, "/")
+	if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return "", "", usageError(fmt.Sprintf("invalid repository %q, expect owner/repo", s))
+	}
+	return owner, repo, nil
+}
+
+// parseRepoPath parses the "owner/repo [path]" arguments.
+func parseRepoPath(args []string) (owner, repo, path string, err error) {
+	if len(args) < 1 || len(args) > 2 {
+		return "", "", "", usageError("expect the repository and an optional path")
+	}
+	if owner, repo, err = parseRepo(args[0]); err != nil {
+		return "", "", "", err
+	}
+	if len(args) == 2 {
+		path = strings.Trim(args[1], "/")
+	}
+	return owner, repo, path, nil
+}
+
+// newFlagSet returns the flag set of a command, whose errors are returned rather than exiting.
+func newFlagSet(env *env, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet("ghwalk "+strings.Fields(usage)[0], flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(env.stderr, "usage: ghwalk %s\n\nflags:\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// runCommand runs the command line args, and returns the exit code along with the stdout and stderr.
+func runCommand(t *testing.T, args ...string) (int, string, string) {
+	// Never pick up the configuration file of the user
+	if os.Getenv("GHWALK_CONFIG") == "" {
+		t.Setenv("GHWALK_CONFIG", filepath.Join(t.TempDir(), "config.yml"))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	code := run(ctx, args, &env{stdout: &stdout, stderr: &stderr})
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	code, _, stderr := runCommand(t)
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, "usage: ghwalk <command>")
+
+	code, _, stderr = runCommand(t, "help")
+	require.Equal(t, 0, code)
+	require.Contains(t, stderr, "diff")
+
+	code, _, stderr = runCommand(t, "foo")
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, `unknown command "foo"`)
+
+	code, _, stderr = runCommand(t, "diff", "-h")
+	require.Equal(t, 0, code)
+	require.Contains(t, stderr, "usage: ghwalk "+diffUsage)
+
+	code, _, stderr = runCommand(t, "diff", "foo", "--base", "a", "--head", "b")
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, `invalid repository "foo"`)
+}
+
+func TestParseFlags(t *testing.T) {
+	cases := []struct {
+		args       []string
+		positional []string
+		name       string
+	}{
+		{args: nil},
+		{args: []string{"a", "b"}, positional: []string{"a", "b"}},
+		{args: []string{"-name", "x", "a"}, positional: []string{"a"}, name: "x"},
+		{args: []string{"a", "--name", "x", "b"}, positional: []string{"a", "b"}, name: "x"},
+		{args: []string{"a", "--", "--name", "x"}, positional: []string{"a", "--name", "x"}},
+	}
+	for _, c := range cases {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		name := fs.String("name", "", "")
+		positional, err := parseFlags(fs, c.args)
+		require.NoError(t, err, c.args)
+		require.Equal(t, c.positional, positional, c.args)
+		require.Equal(t, c.name, *name, c.args)
+	}
+}
@@ -0,0 +1,207 @@
+package ghwalk
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LanguageExtensions maps the file extensions (in lower case) to the languages counted by CodeStats. It can be
+// modified before calling CodeStats to count other languages.
+var LanguageExtensions = map[string]string{
+	".c":     "C",
+	".h":     "C",
+	".cc":    "C++",
+	".cpp":   "C++",
+	".hpp":   "C++",
+	".cs":    "C#",
+	".css":   "CSS",
+	".go":    "Go",
+	".html":  "HTML",
+	".java":  "Java",
+	".js":    "JavaScript",
+	".mjs":   "JavaScript",
+	".jsx":   "JavaScript",
+	".json":  "JSON",
+	".kt":    "Kotlin",
+	".md":    "Markdown",
+	".php":   "PHP",
+	".proto": "Protocol Buffers",
+	".py":    "Python",
+	".rb":    "Ruby",
+	".rs":    "Rust",
+	".scala": "Scala",
+	".sh":    "Shell",
+	".sql":   "SQL",
+	".swift": "Swift",
+	".tf":    "HCL",
+	".ts":    "TypeScript",
+	".tsx":   "TypeScript",
+	".yaml":  "YAML",
+	".yml":   "YAML",
+}
+
+// codeStatsVendoredDirs are the directories holding the vendored code, which are excluded by CodeStats.
+var codeStatsVendoredDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"third_party":  true,
+}
+
+// generatedSuffixes are the file name suffixes of the generated code.
+var generatedSuffixes = []string{".pb.go", "_generated.go", ".gen.go", ".min.js", ".min.css"}
+
+// generatedMarker matches the comment marking the generated code, see https://golang.org/s/generatedcode.
+var generatedMarker = regexp.MustCompile(`(?m)^.*Code generated .* DO NOT EDIT\.`)
+
+// generatedMarkerLines is the number of the leading lines in which the generated marker is looked for.
+const generatedMarkerLines = 10
+
+// LanguageStats is the code statistics of a language.
+type LanguageStats struct {
+	Language string
+	Files    int
+	// Lines is the total number of lines, including the blank ones.
+	Lines      int
+	BlankLines int
+	Bytes      int64
+}
+
+// CodeStats returns the code statistics under path per language (see LanguageExtensions), in descending order of
+// the bytes. The vendored code (i.e. under "node_modules", "vendor" or "third_party") and the generated code (by the
+// file name, or the "Code generated ... DO NOT EDIT." comment in the leading lines) are excluded. The
+// "linguist-vendored" and "linguist-generated" git attributes, if specified, take precedence over these heuristics,
+// as Github's language statistics do.
+//
+// The content of each file of a known language is fetched, regardless of the EnableFileOnlyInfo and FetchContentFunc
+// of opt.
+func CodeStats(ctx context.Context, owner, repo, path string, opt *WalkOptions) ([]LanguageStats, error) {
+	path, err := cleanRepoPath(owner, repo, path)
+	if err != nil {
+		return nil, err
+	}
+	language := func(p string, info *FileInfo) string {
+		if info.Type != FileTypeFile {
+			return ""
+		}
+		if generated, ok := info.Attributes["linguist-generated"]; ok {
+			if generated == "true" {
+				return ""
+			}
+		} else if isGeneratedName(info.Name) {
+			return ""
+		}
+		rel := p
+		if path != "" {
+			rel = strings.TrimPrefix(rel, path+"/")
+		}
+		if vendored, ok := info.Attributes["linguist-vendored"]; ok {
+			if vendored == "true" {
+				return ""
+			}
+		} else if underDirs(rel, codeStatsVendoredDirs) {
+			return ""
+		}
+		return LanguageExtensions[strings.ToLower(filepath.Ext(info.Name))]
+	}
+
+	var o WalkOptions
+	if opt != nil {
+		o = *opt
+	}
+	o.EnableGitAttributes = true
+	o.FetchContentFunc = func(p string, info *FileInfo) bool {
+		return language(p, info) != ""
+	}
+
+	stats := map[string]*LanguageStats{}
+	err = Walk(ctx, owner, repo, path, &o, func(p string, info *FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info == nil || info.IsDir() {
+			return nil
+		}
+		lang := language(p, info)
+		if lang == "" {
+			return nil
+		}
+		content, err := readContent(ctx, info)
+		if err != nil {
+			return err
+		}
+		if _, ok := info.Attributes["linguist-generated"]; !ok && isGeneratedContent(content) {
+			return nil
+		}
+
+		s, ok := stats[lang]
+		if !ok {
+			s = &LanguageStats{Language: lang}
+			stats[lang] = s
+		}
+		lines, blank := countLines(content)
+		s.Files++
+		s.Lines += lines
+		s.BlankLines += blank
+		s.Bytes += int64(len(content))
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]LanguageStats, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Bytes != result[j].Bytes {
+			return result[i].Bytes > result[j].Bytes
+		}
+		return result[i].Language < result[j].Language
+	})
+	return result, nil
+}
+
+func isGeneratedName(name string) bool {
+	for _, suffix := range generatedSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isGeneratedContent(content []byte) bool {
+	head := content
+	for i, n := 0, 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			if n++; n == generatedMarkerLines {
+				head = content[:i]
+				break
+			}
+		}
+	}
+	return generatedMarker.Match(head)
+}
+
+// countLines returns the number of the lines and the blank lines of the content. The last line is counted even if
+// it doesn't end with a newline.
+func countLines(content []byte) (lines, blank int) {
+	for len(content) != 0 {
+		line := content
+		if i := bytes.IndexByte(content, '\n'); i >= 0 {
+			line, content = content[:i], content[i+1:]
+		} else {
+			content = nil
+		}
+		lines++
+		if len(bytes.TrimSpace(line)) == 0 {
+			blank++
+		}
+	}
+	return lines, blank
+}
@@ -0,0 +1,76 @@
+package ghwalk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountLines(t *testing.T) {
+	cases := []struct {
+		content string
+		lines   int
+		blank   int
+	}{
+		{content: ""},
+		{content: "a", lines: 1},
+		{content: "a\n", lines: 1},
+		{content: "a\n\n  \nb", lines: 4, blank: 2},
+	}
+	for idx, c := range cases {
+		lines, blank := countLines([]byte(c.content))
+		require.Equal(t, c.lines, lines, idx)
+		require.Equal(t, c.blank, blank, idx)
+	}
+}
+
+func TestCodeStats(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{
+		"foo/bar": newFixture(t, map[string]string{
+			"main.go":               "package main\n\nfunc main() {}\n",
+			"api/api.pb.go":         "package api\n",
+			"api/zz_generated.go":   "package api\n",
+			"api/enum.go":           "// Code generated by stringer. DO NOT EDIT.\n\npackage api\n",
+			"api/api.go":            "package api\n",
+			"vendor/x/x.go":         "package x\n",
+			"web/app.ts":            "let a = 1;\n",
+			"web/node_modules/m.js": "module.exports = {}\n",
+			"web/README":            "no extension\n",
+			"scripts/build.SH":      "#!/bin/sh\n\nmake\n\n",
+		}),
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	stats, err := CodeStats(ctx, "foo", "bar", "", &WalkOptions{BaseURL: srv.BaseURL()})
+	require.NoError(t, err)
+	require.Equal(t, []LanguageStats{
+		{Language: "Go", Files: 2, Lines: 4, BlankLines: 1, Bytes: 41},
+		{Language: "Shell", Files: 1, Lines: 4, BlankLines: 2, Bytes: 17},
+		{Language: "TypeScript", Files: 1, Lines: 1, Bytes: 11},
+	}, stats)
+
+	// The linguist attributes take precedence
+	srv = ghwalktest.NewServer(map[string]string{
+		"foo/bar": newFixture(t, map[string]string{
+			".gitattributes": "vendor/** -linguist-vendored\nzz_*.go -linguist-generated\nlib/*.go linguist-vendored\n",
+			"vendor/x/x.go":  "package x\n",
+			"api/zz_api.go":  "// Code generated by foo. DO NOT EDIT.\npackage api\n",
+			"lib/lib.go":     "package lib\n",
+		}),
+	})
+	defer srv.Close()
+	stats, err = CodeStats(ctx, "foo", "bar", "", &WalkOptions{BaseURL: srv.BaseURL()})
+	require.NoError(t, err)
+	require.Equal(t, []LanguageStats{{Language: "Go", Files: 2, Lines: 3, Bytes: 61}}, stats)
+
+	// The vendored directories are only excluded below the path
+	stats, err = CodeStats(ctx, "foo", "bar", "vendor", &WalkOptions{BaseURL: srv.BaseURL()})
+	require.NoError(t, err)
+	require.Equal(t, []LanguageStats{{Language: "Go", Files: 1, Lines: 1, Bytes: 10}}, stats)
+}
@@ -0,0 +1,77 @@
+package ghwalk
+
+import "sync"
+
+// Collect returns a WalkFunc that collects a value for each of the visited entries, and a function that returns the
+// collected values, in the order of the walk (including the Reverse, EntryOrder and PriorityFunc of the WalkOptions).
+//
+// The fn returns the value to collect for the entry, and whether to collect it at all. The errors passed to the
+// WalkFunc stop the walk and are returned as is.
+//
+// The returned functions are safe for concurrent use. The values are collected in the order in which the WalkFunc is
+// called, which is the order of the walk as the WalkFunc is called sequentially even if the walk is concurrent (see
+// ContentConcurrency), while the WalkFunc shared among several walks at once interleaves their values.
+func Collect[T any](fn func(path string, info *FileInfo) (T, bool)) (WalkFunc, func() []T) {
+	var c collector[T]
+	return c.walkFunc(fn), func() []T {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return append([]T(nil), c.values...)
+	}
+}
+
+// CollectMap is like Collect, but the collected values are keyed by the path.
+func CollectMap[T any](fn func(path string, info *FileInfo) (T, bool)) (WalkFunc, func() map[string]T) {
+	var c collector[T]
+	return c.walkFunc(fn), func() map[string]T {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		m := make(map[string]T, len(c.paths))
+		for i, path := range c.paths {
+			m[path] = c.values[i]
+		}
+		return m
+	}
+}
+
+// CollectInto is like Collect, but the collected values are appended to the slice pointed by dst directly, after the
+// values it already holds. The dst must not be accessed while the walk is in progress.
+func CollectInto[T any](dst *[]T, fn func(path string, info *FileInfo) (T, bool)) WalkFunc {
+	c := collector[T]{dst: dst}
+	return c.walkFunc(fn)
+}
+
+// collector keeps the collected values in the order in which they are added, along with their paths.
+type collector[T any] struct {
+	mu     sync.Mutex
+	paths  []string
+	values []T
+
+	// dst, if set, receives the values instead of values
+	dst *[]T
+}
+
+func (c *collector[T]) walkFunc(fn func(path string, info *FileInfo) (T, bool)) WalkFunc {
+	return func(path string, info *FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		v, ok := fn(path, info)
+		if !ok {
+			return nil
+		}
+		c.add(path, v)
+		return nil
+	}
+}
+
+func (c *collector[T]) add(path string, v T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dst != nil {
+		*c.dst = append(*c.dst, v)
+		return
+	}
+	c.paths = append(c.paths, path)
+	c.values = append(c.values, v)
+}
@@ -0,0 +1,71 @@
+package ghwalk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollect(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	name := func(path string, info *FileInfo) (string, bool) {
+		if info == nil || info.IsDir() {
+			return "", false
+		}
+		return path, true
+	}
+	opt := &WalkOptions{Token: githubToken, BaseURL: githubBaseURL}
+
+	walkFn, values := Collect(name)
+	require.NoError(t, Walk(ctx, "magodo", "ghwalk", "testdata", opt, walkFn, nil))
+	require.Equal(t, []string{"testdata/a", "testdata/b", "testdata/dir/c", "testdata/link_dir"}, values())
+
+	walkFn, m := CollectMap(func(path string, info *FileInfo) (int, bool) {
+		if info == nil || info.IsDir() {
+			return 0, false
+		}
+		return info.Size, true
+	})
+	require.NoError(t, Walk(ctx, "magodo", "ghwalk", "testdata", opt, walkFn, nil))
+	require.Equal(t, map[string]int{"testdata/a": 13, "testdata/b": 13, "testdata/dir/c": 20, "testdata/link_dir": 3}, m())
+
+	// The values already in dst are kept
+	dst := []string{"x"}
+	require.NoError(t, Walk(ctx, "magodo", "ghwalk", "testdata", opt, CollectInto(&dst, name), nil))
+	require.Equal(t, []string{"x", "testdata/a", "testdata/b", "testdata/dir/c", "testdata/link_dir"}, dst)
+
+	// The values are in the order of the walk, rather than of the paths
+	walkFn, values = Collect(name)
+	require.NoError(t, Walk(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{Token: githubToken, BaseURL: githubBaseURL, Reverse: true}, walkFn, nil))
+	require.Equal(t, []string{"testdata/link_dir", "testdata/dir/c", "testdata/b", "testdata/a"}, values())
+	walkFn, values = Collect(name)
+	require.NoError(t, Walk(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{Token: githubToken, BaseURL: githubBaseURL, EntryOrder: EntryOrderDirsFirst}, walkFn, nil))
+	require.Equal(t, []string{"testdata/dir/c", "testdata/a", "testdata/b", "testdata/link_dir"}, values())
+}
+
+func TestCollectConcurrently(t *testing.T) {
+	walkFn, values := Collect(func(path string, info *FileInfo) (string, bool) {
+		return path, true
+	})
+
+	var wg sync.WaitGroup
+	for i := 9; i >= 0; i-- {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			require.NoError(t, walkFn(fmt.Sprintf("d/%d", i), nil, nil))
+		}(i)
+	}
+	wg.Wait()
+	require.NoError(t, walkFn("d", nil, nil))
+	// The values of the concurrent calls are all collected, in the order of the calls
+	got := values()
+	require.ElementsMatch(t, []string{"d", "d/0", "d/1", "d/2", "d/3", "d/4", "d/5", "d/6", "d/7", "d/8", "d/9"}, got)
+	require.Equal(t, "d", got[len(got)-1])
+}
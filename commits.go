@@ -0,0 +1,151 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// CommitInfo is the metadata of a commit.
+type CommitInfo struct {
+	SHA       string
+	Message   string
+	Author    CommitSignature
+	Committer CommitSignature
+	URL       string
+	HTMLURL   string
+}
+
+// CommitSignature is the author or committer of a commit.
+type CommitSignature struct {
+	Name  string
+	Email string
+	Date  time.Time
+}
+
+func newCommitInfo(c *github.RepositoryCommit) *CommitInfo {
+	commit := c.GetCommit()
+	return &CommitInfo{
+		SHA:     c.GetSHA(),
+		Message: commit.GetMessage(),
+		Author: CommitSignature{
+			Name:  commit.GetAuthor().GetName(),
+			Email: commit.GetAuthor().GetEmail(),
+			Date:  commit.GetAuthor().GetDate(),
+		},
+		Committer: CommitSignature{
+			Name:  commit.GetCommitter().GetName(),
+			Email: commit.GetCommitter().GetEmail(),
+			Date:  commit.GetCommitter().GetDate(),
+		},
+		URL:     c.GetURL(),
+		HTMLURL: c.GetHTMLURL(),
+	}
+}
+
+// CommitWalkFunc is the type of the function called for each commit visited by WalkCommits.
+//
+// The info argument is the FileInfo of the walked path as of the commit, which is only set if EnableCommitFileInfo of
+// the WalkOptions is set. It is nil if the path doesn't exist at the commit (e.g. the commit deletes it).
+//
+// If there was a problem listing the commits, the function is called with a nil commit and the error. If there was
+// a problem retrieving the FileInfo, the function is called with the commit and the error. If an error is returned,
+// processing stops. The sole exception is SkipAll, which stops the processing without an error.
+type CommitWalkFunc func(commit *CommitInfo, info *FileInfo, err error) error
+
+// WalkCommits walks the commits touching path (or all commits if path is empty), starting from the Ref of the
+// WalkOptions (which defaults to the default branch), in reverse chronological order, calling fn for each commit.
+//
+// WalkCommits always talks to the Github API, the Snapshot and Provider of the WalkOptions are ignored.
+func WalkCommits(ctx context.Context, owner, repo, path string, opt *WalkOptions, fn CommitWalkFunc) error {
+	path, err := cleanRepoPath(owner, repo, path)
+	if err != nil {
+		return err
+	}
+	client, err := newClient(ctx, opt)
+	if err != nil {
+		return err
+	}
+	err = walkCommits(ctx, client, owner, repo, path, opt, fn)
+	if err == SkipAll {
+		return nil
+	}
+	return err
+}
+
+func walkCommits(ctx context.Context, client *github.Client, owner, repo, path string, opt *WalkOptions, fn CommitWalkFunc) error {
+	listOpt := &github.CommitsListOptions{
+		Path:        path,
+		ListOptions: opt.listOptions(),
+	}
+	if opt != nil {
+		listOpt.SHA = opt.Ref
+		listOpt.Until = opt.At
+	}
+
+	for page := 1; ; page++ {
+		commits, resp, err := client.Repositories.ListCommits(ctx, owner, repo, listOpt)
+		if err != nil {
+			return fn(nil, nil, err)
+		}
+		for _, c := range commits {
+			commit := newCommitInfo(c)
+
+			var info *FileInfo
+			if opt != nil && opt.EnableCommitFileInfo && path != "" {
+				info, err = commitFileInfo(ctx, client, owner, repo, path, commit.SHA, opt)
+			}
+			if err := fn(commit, info, err); err != nil {
+				return err
+			}
+		}
+		if listOpt.Page = opt.nextPage(resp, page); listOpt.Page == 0 {
+			return nil
+		}
+	}
+}
+
+// commitFileInfo retrieves the FileInfo of path as of the commit. It returns nil FileInfo if the path doesn't exist.
+func commitFileInfo(ctx context.Context, client *github.Client, owner, repo, path, sha string, opt *WalkOptions) (*FileInfo, error) {
+	commitOpt := *opt
+	commitOpt.Ref = sha
+	p := &githubProvider{client: client, opt: &commitOpt}
+
+	info, err := stat(ctx, owner, repo, path, p, &commitOpt)
+	if errors.Is(err, ErrNotExist) {
+		return nil, nil
+	}
+	return info, err
+}
+
+// History returns an iterator over the commits that modified the file or directory, in reverse chronological order,
+// starting from the ref that the FileInfo is retrieved at.
+// The iteration stops after yielding an error. History is only available for the FileInfo retrieved from Github.
+func (f *FileInfo) History(ctx context.Context) iter.Seq2[CommitInfo, error] {
+	return func(yield func(CommitInfo, error) bool) {
+		if f.origin == nil {
+			yield(CommitInfo{}, fmt.Errorf("history of %s is not available as it is not retrieved from Github", f.Path))
+			return
+		}
+
+		var opt WalkOptions
+		if f.origin.opt != nil {
+			opt.Ref = f.origin.opt.Ref
+		}
+		walkCommits(ctx, f.origin.client, f.origin.owner, f.origin.repo, f.rawPath(), &opt,
+			func(commit *CommitInfo, _ *FileInfo, err error) error {
+				if err != nil {
+					yield(CommitInfo{}, err)
+					return SkipAll
+				}
+				if !yield(*commit, nil) {
+					return SkipAll
+				}
+				return nil
+			})
+	}
+}
@@ -0,0 +1,85 @@
+package ghwalk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkCommits(t *testing.T) {
+	cases := []struct {
+		path         string
+		withFileInfo bool
+		expectCommit bool
+	}{
+		{
+			path:         "testdata/a",
+			withFileInfo: true,
+			expectCommit: true,
+		},
+		{
+			path:         "testdata",
+			expectCommit: true,
+		},
+		{
+			path: "testdata/non_existent",
+		},
+	}
+
+	for _, c := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		var commits []*CommitInfo
+		err := WalkCommits(ctx, "magodo", "ghwalk", c.path,
+			&WalkOptions{Token: githubToken, BaseURL: githubBaseURL, EnableCommitFileInfo: c.withFileInfo},
+			func(commit *CommitInfo, info *FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				commits = append(commits, commit)
+				if c.withFileInfo {
+					// The file is never changed
+					require.NotNil(t, info)
+					require.Equal(t, "6069a889501d80bf232556e5397cf1c230960a5c", info.SHA)
+				} else {
+					require.Nil(t, info)
+				}
+				// only check the latest one
+				return SkipAll
+			})
+		require.NoError(t, err)
+		if !c.expectCommit {
+			require.Empty(t, commits)
+			continue
+		}
+		require.Len(t, commits, 1)
+		require.NotEmpty(t, commits[0].SHA)
+		require.False(t, commits[0].Author.Date.IsZero())
+	}
+}
+
+func TestFileInfoHistory(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	infos, err := FindAll(ctx, "magodo", "ghwalk", "testdata/a", func(string, *FileInfo) bool { return true },
+		&WalkOptions{Token: githubToken, BaseURL: githubBaseURL})
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+
+	var commits []CommitInfo
+	for commit, err := range infos[0].History(ctx) {
+		require.NoError(t, err)
+		commits = append(commits, commit)
+		break
+	}
+	require.Len(t, commits, 1)
+	require.NotEmpty(t, commits[0].SHA)
+
+	info := &FileInfo{Path: "foo"}
+	for _, err := range info.History(ctx) {
+		require.Error(t, err)
+	}
+}
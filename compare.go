@@ -0,0 +1,211 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// CompareFunc is the type of the function called for each file or directory visited by CompareWalk. The infos
+// argument holds one FileInfo per ref walked, in the same order as the refs. A nil FileInfo means the path doesn't
+// exist at that ref. Like WalkFunc, the FileInfo of the repository root is always nil.
+//
+// The error handling, as well as the handling of SkipDir and SkipAll, is the same as WalkFunc, where a path is
+// regarded as a directory if it is a directory at any of the refs.
+type CompareFunc func(path string, infos []*FileInfo, err error) error
+
+// Identical tells whether the path exists at every ref with the same type and SHA, i.e. it has the same content.
+func Identical(infos []*FileInfo) bool {
+	for _, info := range infos {
+		if info == nil || info.Type != infos[0].Type || info.SHA != infos[0].SHA {
+			return false
+		}
+	}
+	return true
+}
+
+// CompareWalk walks the same path at two or more refs in lockstep, calling fn for each file or directory that exists
+// at any of the refs, with the FileInfo of all the refs aligned. The files are walked in lexical order (or reversed,
+// if Reverse is set), the same as Walk. The Ref of opt is ignored.
+//
+// Identical subtrees are still walked; fn can check them with Identical and return SkipDir to skip them.
+func CompareWalk(ctx context.Context, owner, repo, path string, refs []string, opt *WalkOptions, fn CompareFunc, filterFn PathFilterFunc) error {
+	if len(refs) == 0 {
+		return errors.New("no ref to compare")
+	}
+	path, err := cleanRepoPath(owner, repo, path)
+	if err != nil {
+		return err
+	}
+
+	c := &comparer{
+		owner:     owner,
+		repo:      repo,
+		opts:      make([]*WalkOptions, len(refs)),
+		providers: make([]ContentProvider, len(refs)),
+		fn:        fn,
+		filterFn:  filterFn,
+	}
+	for i, ref := range refs {
+		var refOpt WalkOptions
+		if opt != nil {
+			refOpt = *opt
+		}
+		refOpt.Ref = ref
+		p, err := newProvider(ctx, &refOpt)
+		if err != nil {
+			return err
+		}
+		c.opts[i] = &refOpt
+		c.providers[i] = p
+	}
+
+	err = c.start(ctx, path)
+	if err == SkipDir || err == SkipAll {
+		return nil
+	}
+	return err
+}
+
+type comparer struct {
+	owner     string
+	repo      string
+	opts      []*WalkOptions
+	providers []ContentProvider
+	fn        CompareFunc
+	filterFn  PathFilterFunc
+}
+
+func (c *comparer) start(ctx context.Context, path string) error {
+	infos := make([]*FileInfo, len(c.providers))
+	if path == "" {
+		return c.walk(ctx, path, infos, true)
+	}
+
+	var found bool
+	for i, p := range c.providers {
+		info, err := stat(ctx, c.owner, c.repo, path, p, c.opts[i])
+		if errors.Is(err, ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return c.fn(path, nil, fmt.Errorf("ref %s: %w", c.opts[i].Ref, err))
+		}
+		infos[i] = info
+		found = true
+	}
+	if !found {
+		return c.fn(path, nil, errNoSuchPath(path))
+	}
+	if c.filterFn != nil && c.filterFn(path, firstFileInfo(infos)) {
+		return nil
+	}
+	return c.walk(ctx, path, infos, false)
+}
+
+func (c *comparer) walk(ctx context.Context, path string, infos []*FileInfo, isRoot bool) error {
+	if !isRoot && !anyDir(infos) {
+		return c.fn(path, infos, nil)
+	}
+
+	lists := make([]map[string]*FileInfo, len(infos))
+	var names []string
+	var err error
+	for i, info := range infos {
+		if !isRoot && (info == nil || !info.IsDir()) {
+			continue
+		}
+		var entries []*FileInfo
+		entries, err = c.providers[i].ReadDir(ctx, c.owner, c.repo, path)
+		if err != nil {
+			err = fmt.Errorf("ref %s: %w", c.opts[i].Ref, err)
+			break
+		}
+		lists[i] = map[string]*FileInfo{}
+		for _, entry := range entries {
+			if !seen(lists, entry.Name) {
+				names = append(names, entry.Name)
+			}
+			lists[i][entry.Name] = entry
+		}
+	}
+
+	err1 := c.fn(path, infos, err)
+	if err != nil || err1 != nil {
+		return err1
+	}
+
+	reverse := c.opts[0].Reverse
+	sort.Slice(names, func(i, j int) bool {
+		if reverse {
+			return names[i] > names[j]
+		}
+		return names[i] < names[j]
+	})
+
+	for _, name := range names {
+		filename := filepath.Join(path, name)
+		children := make([]*FileInfo, len(infos))
+		for i, list := range lists {
+			children[i] = list[name]
+		}
+
+		if c.filterFn != nil && c.filterFn(filename, firstFileInfo(children)) {
+			continue
+		}
+
+		var err error
+		for i, child := range children {
+			if child == nil || !c.opts[i].fetchContent(filename, child) {
+				continue
+			}
+			if children[i], err = readFile(ctx, c.owner, c.repo, filename, c.providers[i], c.opts[i], child); err != nil {
+				err = fmt.Errorf("ref %s: %w", c.opts[i].Ref, err)
+				break
+			}
+		}
+		if err != nil {
+			if err := c.fn(filename, children, err); err != nil && err != SkipDir {
+				return err
+			}
+			continue
+		}
+
+		if err := c.walk(ctx, filename, children, false); err != nil {
+			if !anyDir(children) || err != SkipDir {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// seen tells whether name has been seen in any of the lists.
+func seen(lists []map[string]*FileInfo, name string) bool {
+	for _, list := range lists {
+		if _, ok := list[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func anyDir(infos []*FileInfo) bool {
+	for _, info := range infos {
+		if info != nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+func firstFileInfo(infos []*FileInfo) *FileInfo {
+	for _, info := range infos {
+		if info != nil {
+			return info
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareWalk(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{
+		"foo/bar@old":  "testdata/dir",
+		"foo/bar@new":  "testdata",
+		"foo/bar@same": "testdata/dir",
+	})
+	defer srv.Close()
+
+	type entry struct {
+		path      string
+		present   []bool
+		identical bool
+	}
+
+	cases := []struct {
+		refs    []string
+		reverse bool
+		expect  []entry
+		isError bool
+	}{
+		{
+			refs: []string{"old", "new"},
+			expect: []entry{
+				{path: "", present: []bool{false, false}},
+				{path: "a", present: []bool{false, true}},
+				{path: "b", present: []bool{false, true}},
+				{path: "c", present: []bool{true, false}},
+				{path: "dir", present: []bool{false, true}},
+				{path: "dir/c", present: []bool{false, true}},
+				{path: "link_dir", present: []bool{false, true}},
+			},
+		},
+		{
+			refs:    []string{"old", "new"},
+			reverse: true,
+			expect: []entry{
+				{path: "", present: []bool{false, false}},
+				{path: "link_dir", present: []bool{false, true}},
+				{path: "dir", present: []bool{false, true}},
+				{path: "dir/c", present: []bool{false, true}},
+				{path: "c", present: []bool{true, false}},
+				{path: "b", present: []bool{false, true}},
+				{path: "a", present: []bool{false, true}},
+			},
+		},
+		{
+			refs: []string{"old", "same"},
+			expect: []entry{
+				{path: "", present: []bool{false, false}},
+				{path: "c", present: []bool{true, true}, identical: true},
+			},
+		},
+		{
+			refs:    nil,
+			isError: true,
+		},
+	}
+
+	for _, c := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		entries := []entry{}
+		err := CompareWalk(ctx, "foo", "bar", "", c.refs, &WalkOptions{BaseURL: srv.BaseURL(), Reverse: c.reverse},
+			func(path string, infos []*FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				present := make([]bool, len(infos))
+				for i, info := range infos {
+					present[i] = info != nil
+				}
+				entries = append(entries, entry{path: path, present: present, identical: path != "" && Identical(infos)})
+				return nil
+			},
+			nil)
+		if c.isError {
+			require.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, c.expect, entries)
+	}
+}
+
+func TestCompareWalkPath(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{
+		"foo/bar@old": "testdata/dir",
+		"foo/bar@new": "testdata",
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	var paths []string
+	err := CompareWalk(ctx, "foo", "bar", "dir", []string{"old", "new"}, &WalkOptions{BaseURL: srv.BaseURL()},
+		func(path string, infos []*FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			require.Nil(t, infos[0])
+			require.NotNil(t, infos[1])
+			paths = append(paths, path)
+			return nil
+		},
+		nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"dir", "dir/c"}, paths)
+
+	err = CompareWalk(ctx, "foo", "bar", "nonexist", []string{"old", "new"}, &WalkOptions{BaseURL: srv.BaseURL()},
+		func(path string, infos []*FileInfo, err error) error {
+			return err
+		},
+		nil)
+	require.True(t, errors.Is(err, ErrNotExist))
+}
@@ -0,0 +1,205 @@
+package ghwalk
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeadlineError is the error that the walk stops with if DeadlineAware of the WalkOptions is set, once the deadline of
+// the context is too close to send another request, or once the MaxDuration of the WalkOptions elapses. It matches
+// context.DeadlineExceeded.
+type DeadlineError struct {
+	// Checkpoint is the path of the first entry not visited, which can be passed as the ResumeFrom of the
+	// WalkOptions to continue the walk.
+	Checkpoint string
+	// Commit is the commit that the walk is pinned to (see PinCommit and At of the WalkOptions), if any, which the
+	// resumed walk should walk as well.
+	Commit string
+}
+
+func (e *DeadlineError) Error() string {
+	return fmt.Sprintf("stopped before %s to meet the deadline", e.Checkpoint)
+}
+
+func (e *DeadlineError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// resumeToken is the content of the token returned by DeadlineError.ResumeToken.
+type resumeToken struct {
+	Checkpoint string `json:"checkpoint"`
+	Commit     string `json:"commit,omitempty"`
+}
+
+// ResumeToken returns an opaque token of the Checkpoint and Commit, which can be passed as the ResumeToken of the
+// WalkOptions to continue the walk, e.g. by the next CI job. Unlike the Checkpoint alone, the token also makes the
+// resumed walk stay on the commit of the stopped walk.
+func (e *DeadlineError) ResumeToken() string {
+	b, _ := json.Marshal(resumeToken{Checkpoint: e.Checkpoint, Commit: e.Commit})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// applyResumeToken sets the ResumeFrom and Ref of opt by the ResumeToken of opt (if any).
+func applyResumeToken(opt *WalkOptions) error {
+	if opt.ResumeToken == "" {
+		return nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(opt.ResumeToken)
+	var token resumeToken
+	if err == nil {
+		err = json.Unmarshal(b, &token)
+	}
+	if err == nil && token.Checkpoint == "" {
+		err = fmt.Errorf("no checkpoint")
+	}
+	if err != nil {
+		return fmt.Errorf("invalid resume token %q: %v", opt.ResumeToken, err)
+	}
+	opt.ResumeFrom = token.Checkpoint
+	if token.Commit != "" {
+		opt.Ref, opt.At, opt.PinCommit = token.Commit, time.Time{}, true
+	}
+	return nil
+}
+
+// timeNow returns the current time for the deadline budget, which is replaced in tests.
+var timeNow = time.Now
+
+// deadlineBudget estimates whether the requests can be sent before the deadline, by the average latency of the
+// requests sent so far.
+type deadlineBudget struct {
+	deadline time.Time
+	// aware tells that the requests are budgeted (see DeadlineAware), otherwise the walk only stops once the deadline
+	// passes (see MaxDuration).
+	aware bool
+	// concurrency is the number of the content fetches that can be in flight at once.
+	concurrency int
+
+	mu       sync.Mutex
+	requests int
+	latency  time.Duration
+	// fetches is the estimated number of the file contents still to fetch, which is negative if unknown.
+	fetches int
+}
+
+// newDeadlineBudget returns the budget of the walk, which is nil unless DeadlineAware is set and ctx has a deadline, or
+// MaxDuration is set. The deadline is the earlier one of the context and the MaxDuration.
+func newDeadlineBudget(ctx context.Context, opt *WalkOptions) *deadlineBudget {
+	if opt == nil {
+		return nil
+	}
+	deadline, ok := ctx.Deadline()
+	if !opt.DeadlineAware {
+		deadline, ok = time.Time{}, false
+	}
+	if opt.MaxDuration > 0 {
+		if max := timeNow().Add(opt.MaxDuration); !ok || max.Before(deadline) {
+			deadline, ok = max, true
+		}
+	}
+	if !ok {
+		return nil
+	}
+	concurrency := 1
+	if opt.ContentConcurrency > 1 {
+		concurrency = opt.ContentConcurrency
+	}
+	return &deadlineBudget{deadline: deadline, aware: opt.DeadlineAware, concurrency: concurrency, fetches: -1}
+}
+
+func (b *deadlineBudget) observe(latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests++
+	b.latency += latency
+}
+
+// expired tells whether the deadline has passed.
+func (b *deadlineBudget) expired() bool {
+	return !timeNow().Before(b.deadline)
+}
+
+// affords tells whether n more requests can be sent one after another before the deadline. It is true if no
+// request has been sent, as there is nothing to estimate by, or if the requests are not budgeted.
+func (b *deadlineBudget) affords(n int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.aware || b.requests == 0 {
+		return true
+	}
+	return b.deadline.Sub(timeNow()) >= b.latency/time.Duration(b.requests)*time.Duration(n)
+}
+
+// fetch tells whether the content of the next file is fetched: the contents are fetched as long as the remaining
+// ones (if known) can be fetched before the deadline.
+func (b *deadlineBudget) fetch() bool {
+	if !b.aware {
+		return true
+	}
+	b.mu.Lock()
+	fetches := b.fetches
+	if b.fetches > 0 {
+		b.fetches--
+	}
+	b.mu.Unlock()
+	if fetches < 0 {
+		return true
+	}
+	return b.affords((fetches + b.concurrency - 1) / b.concurrency)
+}
+
+// budgetTransport measures the latency of the requests for the deadlineBudget.
+type budgetTransport struct {
+	base   http.RoundTripper
+	budget *deadlineBudget
+}
+
+func (t *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	start := timeNow()
+	resp, err := base.RoundTrip(req)
+	t.budget.observe(timeNow().Sub(start))
+	return resp, err
+}
+
+// resumeSkips tells whether the entry named by path was visited before the ResumeFrom checkpoint, given the entries
+// of its directory in the walk order of opt.
+func resumeSkips(checkpoint, path string, entries []*FileInfo, i int, opt *WalkOptions) bool {
+	dir := parentDir(path)
+	if !isResumedAncestor(checkpoint, dir) {
+		return false
+	}
+	rel := checkpoint
+	if dir != "" {
+		rel = strings.TrimPrefix(checkpoint, dir+"/")
+	}
+	name := strings.SplitN(rel, "/", 2)[0]
+	for j, entry := range entries {
+		if entry.Name == name {
+			return i < j
+		}
+	}
+	// The checkpoint no longer exists, it is placed where it would be in the walk order. It must have been a directory
+	// if the checkpoint is under it, otherwise it is taken as a file.
+	missing := &FileInfo{Name: name, Path: filepath.Join(dir, name), Type: FileTypeFile}
+	if strings.Contains(rel, "/") {
+		missing.Type = FileTypeDir
+	}
+	return entryLess(dir, opt)(entries[i], missing)
+}
+
+// isResumedAncestor tells whether path is a directory containing the ResumeFrom checkpoint, which has been visited
+// before the checkpoint.
+func isResumedAncestor(checkpoint, path string) bool {
+	return checkpoint != "" && (path == "" || strings.HasPrefix(checkpoint, path+"/"))
+}
@@ -0,0 +1,197 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is the clock of the deadline budget, which advances by step on each request.
+type fakeClock struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.now = c.now.Add(c.step)
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestWalkDeadlineAware(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": "testdata"})
+	defer srv.Close()
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+
+	walk := func(ctx context.Context, opt *WalkOptions) (map[string]bool, error) {
+		visited := map[string]bool{}
+		err := Walk(ctx, "foo", "bar", "", opt, func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			visited[path] = info != nil && info.FileOnlyInfo != nil
+			return nil
+		}, nil)
+		return visited, err
+	}
+
+	// The tree costs a quarter of the time to the deadline, then only three of the four file contents can be fetched.
+	clock := &fakeClock{now: time.Now(), step: 15 * time.Minute}
+	timeNow = clock.Now
+	ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(time.Hour))
+	defer cancel()
+	opt := &WalkOptions{BaseURL: srv.BaseURL(), Transport: clock, EnableFileOnlyInfo: true, DeadlineAware: true}
+	visited, err := walk(ctx, opt)
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{"": false, "a": false, "b": true, "dir": false, "dir/c": true, "link_dir": true}, visited)
+
+	// Without the whole tree, the walk stops at the deadline, and resumes from the checkpoint.
+	srv.SetTreeLimit(1)
+	clock = &fakeClock{now: time.Now(), step: 15 * time.Minute}
+	timeNow = clock.Now
+	ctx, cancel = context.WithDeadline(context.Background(), clock.Now().Add(time.Hour))
+	defer cancel()
+	opt.Transport = clock
+	visited, err = walk(ctx, opt)
+	var derr *DeadlineError
+	require.True(t, errors.As(err, &derr), err)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.NotContains(t, visited, derr.Checkpoint)
+
+	opt.ResumeFrom = derr.Checkpoint
+	resumed, err := walk(context.Background(), opt)
+	require.NoError(t, err)
+	for path := range resumed {
+		require.NotContains(t, visited, path)
+		visited[path] = true
+	}
+	require.ElementsMatch(t, []string{"", "a", "b", "dir", "dir/c", "link_dir"}, keys(visited))
+}
+
+func TestWalkResumeFromOrder(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": "testdata"})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	cases := []struct {
+		name       string
+		opt        WalkOptions
+		checkpoint string
+		expect     []string
+	}{
+		{
+			name:       "reverse",
+			opt:        WalkOptions{Reverse: true},
+			checkpoint: "b",
+			expect:     []string{"b", "a"},
+		},
+		{
+			name:       "reverse, missing checkpoint",
+			opt:        WalkOptions{Reverse: true},
+			checkpoint: "c",
+			expect:     []string{"b", "a"},
+		},
+		{
+			// The order is link_dir, b, a, dir
+			name:       "reverse files first, missing checkpoint",
+			opt:        WalkOptions{Reverse: true, EntryOrder: EntryOrderFilesFirst},
+			checkpoint: "bb",
+			expect:     []string{"b", "a", "dir", "dir/c"},
+		},
+		{
+			// The order is dir, link_dir, b, a
+			name: "reverse by priority, missing checkpoint",
+			opt: WalkOptions{Reverse: true, PriorityFunc: func(path string, info *FileInfo) int {
+				if info.IsDir() {
+					return 1
+				}
+				return 0
+			}},
+			checkpoint: "da/x",
+			expect:     []string{"link_dir", "b", "a"},
+		},
+	}
+	for _, c := range cases {
+		opt := c.opt
+		opt.BaseURL, opt.ResumeFrom = srv.BaseURL(), c.checkpoint
+		var visited []string
+		require.NoError(t, Walk(ctx, "foo", "bar", "", &opt, func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			visited = append(visited, path)
+			return nil
+		}, nil), c.name)
+		require.Equal(t, c.expect, visited, c.name)
+	}
+}
+
+func keys(m map[string]bool) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
+
+func TestWalkMaxDuration(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": "testdata"})
+	defer srv.Close()
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+
+	// Each visit takes a minute
+	clock := &fakeClock{now: time.Now()}
+	timeNow = clock.Now
+	walk := func(opt *WalkOptions) ([]string, error) {
+		var visited []string
+		err := Walk(context.Background(), "foo", "bar", "", opt, func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			visited = append(visited, path)
+			clock.mu.Lock()
+			clock.now = clock.now.Add(time.Minute)
+			clock.mu.Unlock()
+			return nil
+		}, nil)
+		return visited, err
+	}
+
+	opt := &WalkOptions{BaseURL: srv.BaseURL(), PinCommit: true, MaxDuration: 150 * time.Second}
+	visited, err := walk(opt)
+	var derr *DeadlineError
+	require.True(t, errors.As(err, &derr), err)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Equal(t, []string{"", "a", "b"}, visited)
+	require.Equal(t, "dir", derr.Checkpoint)
+	require.Len(t, derr.Commit, 40)
+
+	// The walk resumes from the token on the same commit, where the rest fits into the MaxDuration
+	resumed, err := walk(&WalkOptions{BaseURL: srv.BaseURL(), Ref: "main", MaxDuration: time.Hour, ResumeToken: derr.ResumeToken()})
+	require.NoError(t, err)
+	require.Equal(t, []string{"dir", "dir/c", "link_dir"}, resumed)
+	result, err := WalkWithResult(context.Background(), "foo", "bar", "", &WalkOptions{BaseURL: srv.BaseURL(), ResumeToken: derr.ResumeToken()},
+		func(path string, info *FileInfo, err error) error { return err }, nil)
+	require.NoError(t, err)
+	require.Equal(t, derr.Commit, result.CommitSHA)
+
+	_, err = walk(&WalkOptions{BaseURL: srv.BaseURL(), ResumeToken: "not a token"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid resume token")
+}
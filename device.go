@@ -0,0 +1,216 @@
+package ghwalk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DeviceCode is the code of the device authorization flow, which the user enters at the VerificationURI to authorize
+// the token.
+type DeviceCode struct {
+	UserCode        string
+	VerificationURI string
+	// ExpiresAt is when the code expires, after which the flow fails.
+	ExpiresAt time.Time
+}
+
+// DeviceFlow performs the OAuth device authorization flow of Github, which lets the user authorize a token in the
+// browser, rather than minting a personal access token manually.
+type DeviceFlow struct {
+	// ClientID is the client ID of the OAuth App (or Github App) that has the device flow enabled.
+	ClientID string
+
+	// Scopes are the OAuth scopes to request, e.g. "repo" to access the private repositories. They are ignored for a
+	// Github App.
+	Scopes []string
+
+	// Prompt is called with the code once it is issued, which should tell the user to enter it at the verification
+	// URI. It defaults to printing the instruction to os.Stderr.
+	Prompt func(code *DeviceCode)
+
+	// CacheFile is the file that the token is cached in, which defaults to the DeviceTokenFile of the Github host.
+	// As the token resolution chain (see the Token of WalkOptions) looks up the DeviceTokenFile, the default one
+	// makes the token available to every program built on ghwalk.
+	CacheFile string
+
+	// Renew ignores the cached token, and performs the flow to replace it, e.g. once it is revoked.
+	Renew bool
+
+	// NoCache disables reading and writing the CacheFile, so that the flow is always performed.
+	NoCache bool
+}
+
+// DeviceTokenFile returns the file that DeviceFlow caches the token of the Github host in by default, which is
+// $XDG_CONFIG_HOME/ghwalk/tokens/<host>, where XDG_CONFIG_HOME defaults to ~/.config.
+func DeviceTokenFile(host string) (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "ghwalk", "tokens", host), nil
+}
+
+// readDeviceToken reads the token cached in file, which is empty if the file doesn't exist.
+func readDeviceToken(file string) (string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Token returns the cached token if any, otherwise it performs the device flow against the Github host of the
+// BaseURL of opt, and caches the authorized token. Only the BaseURL, Transport and DisableEnvironment of opt are used.
+//
+// It blocks until the user authorizes the token, the code expires, or the context is done.
+func (f *DeviceFlow) Token(ctx context.Context, opt *WalkOptions) (string, error) {
+	if f.ClientID == "" {
+		return "", errors.New("the client ID of the device flow is not specified")
+	}
+	cacheFile := f.CacheFile
+	if !f.NoCache {
+		if cacheFile == "" {
+			var err error
+			if cacheFile, err = DeviceTokenFile(apiHost(opt)); err != nil {
+				return "", err
+			}
+		}
+	}
+	if !f.NoCache && !f.Renew {
+		token, err := readDeviceToken(cacheFile)
+		if err != nil {
+			return "", err
+		}
+		if token != "" {
+			return token, nil
+		}
+	}
+
+	transport := http.DefaultTransport
+	if opt != nil && opt.Transport != nil {
+		transport = opt.Transport
+	}
+	client := &http.Client{Transport: transport}
+	base := webBaseURL(opt)
+
+	var code struct {
+		DeviceCode      string `json:"device_code"`
+		UserCode        string `json:"user_code"`
+		VerificationURI string `json:"verification_uri"`
+		ExpiresIn       int    `json:"expires_in"`
+		Interval        int    `json:"interval"`
+	}
+	form := url.Values{"client_id": {f.ClientID}, "scope": {strings.Join(f.Scopes, " ")}}
+	if err := postForm(ctx, client, base+"login/device/code", form, &code); err != nil {
+		return "", fmt.Errorf("requesting the device code: %w", err)
+	}
+	dc := &DeviceCode{
+		UserCode:        code.UserCode,
+		VerificationURI: code.VerificationURI,
+		ExpiresAt:       time.Now().Add(time.Duration(code.ExpiresIn) * time.Second),
+	}
+	if f.Prompt != nil {
+		f.Prompt(dc)
+	} else {
+		fmt.Fprintf(os.Stderr, "Open %s in the browser and enter the code: %s\n", dc.VerificationURI, dc.UserCode)
+	}
+
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		// The default interval of the device flow
+		interval = 5 * time.Second
+	}
+	form = url.Values{
+		"client_id":   {f.ClientID},
+		"device_code": {code.DeviceCode},
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(interval):
+		}
+
+		var resp struct {
+			AccessToken string `json:"access_token"`
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+			Interval    int    `json:"interval"`
+		}
+		if err := postForm(ctx, client, base+"login/oauth/access_token", form, &resp); err != nil {
+			return "", fmt.Errorf("polling the access token: %w", err)
+		}
+		switch resp.Error {
+		case "":
+			if resp.AccessToken == "" {
+				return "", errors.New("polling the access token: no token returned")
+			}
+			if !f.NoCache {
+				if err := os.MkdirAll(filepath.Dir(cacheFile), 0700); err != nil {
+					return "", err
+				}
+				if err := os.WriteFile(cacheFile, []byte(resp.AccessToken+"\n"), 0600); err != nil {
+					return "", err
+				}
+				// Let the token resolution chain pick up the new token
+				storedTokensMu.Lock()
+				delete(storedTokens, apiHost(opt))
+				storedTokensMu.Unlock()
+			}
+			return resp.AccessToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			if resp.Interval > 0 {
+				interval = time.Duration(resp.Interval) * time.Second
+			} else {
+				interval += 5 * time.Second
+			}
+		default:
+			// e.g. expired_token, access_denied
+			if resp.Description != "" {
+				return "", fmt.Errorf("device flow: %s: %s", resp.Error, resp.Description)
+			}
+			return "", fmt.Errorf("device flow: %s", resp.Error)
+		}
+	}
+}
+
+// postForm posts the form to u, and decodes the JSON response into out.
+func postForm(ctx context.Context, client *http.Client, u string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,77 @@
+package ghwalk
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceFlow(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	opt := &WalkOptions{BaseURL: srv.BaseURL()}
+
+	var prompts int
+	flow := &DeviceFlow{
+		ClientID: "client",
+		Scopes:   []string{"repo"},
+		Prompt: func(code *DeviceCode) {
+			prompts++
+			require.NotEmpty(t, code.UserCode)
+			require.Equal(t, srv.URL+"/login/device", code.VerificationURI)
+			srv.ApproveDevice(code.UserCode, "device-token")
+		},
+	}
+	token, err := flow.Token(ctx, opt)
+	require.NoError(t, err)
+	require.Equal(t, "device-token", token)
+	require.Equal(t, 1, prompts)
+
+	// The token is cached, and picked up by the token resolution chain
+	file, err := DeviceTokenFile("127.0.0.1")
+	require.NoError(t, err)
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, "device-token\n", string(b))
+	token, err = flow.Token(ctx, opt)
+	require.NoError(t, err)
+	require.Equal(t, "device-token", token)
+	require.Equal(t, 1, prompts)
+	require.Equal(t, "device-token", storedToken("127.0.0.1"))
+
+	// Renew the cached token
+	renew := *flow
+	renew.Renew = true
+	renew.Prompt = func(code *DeviceCode) {
+		srv.ApproveDevice(code.UserCode, "renewed-token")
+	}
+	token, err = renew.Token(ctx, opt)
+	require.NoError(t, err)
+	require.Equal(t, "renewed-token", token)
+	require.Equal(t, "renewed-token", storedToken("127.0.0.1"))
+
+	// Denied
+	flow = &DeviceFlow{
+		ClientID:  "client",
+		CacheFile: filepath.Join(t.TempDir(), "token"),
+		Prompt: func(code *DeviceCode) {
+			srv.DenyDevice(code.UserCode)
+		},
+	}
+	_, err = flow.Token(ctx, opt)
+	require.EqualError(t, err, "device flow: access_denied")
+	_, err = os.Stat(flow.CacheFile)
+	require.True(t, os.IsNotExist(err))
+
+	_, err = (&DeviceFlow{}).Token(ctx, opt)
+	require.Error(t, err)
+}
@@ -0,0 +1,196 @@
+package ghwalk
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"path/filepath"
+)
+
+// defaultDirPageSize is the default PageSize of DirIter.
+const defaultDirPageSize = 100
+
+// DirIter iterates over the entries of a directory page by page, in the same order as Walk would visit them.
+//
+// Github lists a directory in a single response, which is fetched by the first Next. What is paged is the work per
+// entry: the file only info (see EnableFileOnlyInfo and FetchContentFunc) is only fetched for the entries of the page
+// being returned, so that the caller can stop early without paying for the rest of an enormous directory. The
+// position of the iteration can be persisted by Cursor, and restored by Seek.
+type DirIter struct {
+	// PageSize is the maximum number of the entries returned by each Next, defaults to 100.
+	PageSize int
+
+	owner string
+	repo  string
+	path  string
+	opt   *WalkOptions
+
+	p       ContentProvider
+	entries []*FileInfo
+	listed  bool
+	pos     int
+	// seek is the cursor passed to Seek before the listing, if any
+	seek *dirCursor
+}
+
+// NewDirIter returns a DirIter over the entries of the directory path (or the repo root, if empty) in the
+// repository, configured by opt as Walk does. No request is sent until the first Next.
+func NewDirIter(owner, repo, path string, opt *WalkOptions) *DirIter {
+	return &DirIter{owner: owner, repo: repo, path: path, opt: opt}
+}
+
+// Next returns the next page of the entries. It returns io.EOF after all the entries have been returned.
+func (it *DirIter) Next(ctx context.Context) ([]*FileInfo, error) {
+	if !it.listed {
+		if err := it.list(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if it.pos >= len(it.entries) {
+		return nil, io.EOF
+	}
+
+	size := it.PageSize
+	if size <= 0 {
+		size = defaultDirPageSize
+	}
+	end := it.pos + size
+	if end > len(it.entries) {
+		end = len(it.entries)
+	}
+	page := make([]*FileInfo, 0, end-it.pos)
+	for _, entry := range it.entries[it.pos:end] {
+		filename := filepath.Join(it.path, entry.Name)
+		if it.opt.fetchContent(filename, entry) {
+			info, err := readFile(ctx, it.owner, it.repo, filename, it.p, it.opt, entry)
+			if err != nil {
+				return nil, err
+			}
+			entry = info
+		}
+		page = append(page, entry)
+	}
+	it.pos = end
+	return page, nil
+}
+
+// All returns an iterator over the remaining entries, which pages through them by Next.
+// The iteration stops after yielding an error.
+func (it *DirIter) All(ctx context.Context) iter.Seq2[*FileInfo, error] {
+	return func(yield func(*FileInfo, error) bool) {
+		for {
+			page, err := it.Next(ctx)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, entry := range page {
+				if !yield(entry, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (it *DirIter) list(ctx context.Context) error {
+	path, err := cleanRepoPath(it.owner, it.repo, it.path)
+	if err != nil {
+		return err
+	}
+	it.path = path
+	opt, err := resolveOptions(ctx, it.owner, it.repo, it.opt)
+	if err != nil {
+		return err
+	}
+	p, err := newProvider(ctx, opt)
+	if err != nil {
+		return err
+	}
+	entries, err := readDirEntries(ctx, it.owner, it.repo, it.path, p, opt)
+	if err != nil {
+		return err
+	}
+	it.opt, it.p, it.entries, it.listed = opt, p, entries, true
+	if it.seek != nil {
+		it.pos, it.seek = it.seek.position(it.path, entries, opt), nil
+	}
+	return nil
+}
+
+// dirCursor is the decoded cursor of a DirIter.
+type dirCursor struct {
+	// Path is the path of the directory.
+	Path string `json:"p"`
+	// Next is the name of the first entry not yet returned, which is empty at the start.
+	Next string `json:"n,omitempty"`
+	// End tells that all the entries have been returned.
+	End bool `json:"e,omitempty"`
+}
+
+// position returns the position in the entries of the directory path that the cursor points to. The entries are
+// located by name, as the directory might have changed since the cursor is taken.
+func (c *dirCursor) position(path string, entries []*FileInfo, opt *WalkOptions) int {
+	if c.End {
+		return len(entries)
+	}
+	if c.Next == "" {
+		return 0
+	}
+	checkpoint := filepath.Join(path, c.Next)
+	for i, entry := range entries {
+		if !resumeSkips(checkpoint, filepath.Join(path, entry.Name), entries, i, opt) {
+			return i
+		}
+	}
+	return len(entries)
+}
+
+// Cursor returns the opaque cursor of the position of the iterator, i.e. right after the entries returned so far.
+// The cursor can be persisted, and passed to Seek of another DirIter over the same directory (e.g. in another
+// process) to continue the iteration from there. As the directory is listed again then, the cursor tracks the
+// position by the name of the next entry, so that the entries added or removed meanwhile don't shift it.
+func (it *DirIter) Cursor() string {
+	c := dirCursor{Path: it.path}
+	switch {
+	case it.seek != nil:
+		c = *it.seek
+	case !it.listed:
+	case it.pos >= len(it.entries):
+		c.End = true
+	default:
+		c.Next = it.entries[it.pos].Name
+	}
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Seek moves the iterator to the position of the cursor returned by Cursor, which must be taken from an iterator over
+// the same directory. An empty cursor is the start of the directory.
+func (it *DirIter) Seek(cursor string) error {
+	c := dirCursor{Path: it.path}
+	if cursor != "" {
+		b, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return fmt.Errorf("decoding cursor: %v", err)
+		}
+		if err := json.Unmarshal(b, &c); err != nil {
+			return fmt.Errorf("decoding cursor: %v", err)
+		}
+		if c.Path != it.path {
+			return fmt.Errorf("the cursor is taken from %q, not %q", c.Path, it.path)
+		}
+	}
+	if !it.listed {
+		it.seek = &c
+		return nil
+	}
+	it.pos = c.position(it.path, it.entries, it.opt)
+	return nil
+}
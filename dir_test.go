@@ -0,0 +1,145 @@
+package ghwalk
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirIter(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	it := NewDirIter("magodo", "ghwalk", "testdata", &WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true})
+	it.PageSize = 2
+
+	count := srv.RequestCount()
+	var pages [][]string
+	for {
+		page, err := it.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		var names []string
+		for _, entry := range page {
+			names = append(names, entry.Name)
+			require.Equal(t, entry.Type != FileTypeDir, entry.FileOnlyInfo != nil, entry.Name)
+		}
+		pages = append(pages, names)
+		if len(pages) == 1 {
+			// The listing, and the content of the two files in the first page
+			require.Equal(t, 3, srv.RequestCount()-count)
+		}
+	}
+	require.Equal(t, [][]string{{"a", "b"}, {"dir", "link_dir"}}, pages)
+
+	_, err := it.Next(ctx)
+	require.Equal(t, io.EOF, err)
+
+	var names []string
+	for entry, err := range NewDirIter("magodo", "ghwalk", "testdata", &WalkOptions{BaseURL: srv.BaseURL(), Reverse: true}).All(ctx) {
+		require.NoError(t, err)
+		names = append(names, entry.Name)
+	}
+	require.Equal(t, []string{"link_dir", "dir", "b", "a"}, names)
+
+	for _, err := range NewDirIter("magodo", "ghwalk", "nonexist", &WalkOptions{BaseURL: srv.BaseURL()}).All(ctx) {
+		require.Error(t, err)
+	}
+}
+
+func TestDirIterCursor(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	cases := []struct {
+		name    string
+		reverse bool
+		// pages are read before taking the cursor
+		pages  int
+		expect []string
+	}{
+		{
+			name:   "start",
+			expect: []string{"a", "b", "dir", "link_dir"},
+		},
+		{
+			name:   "middle",
+			pages:  1,
+			expect: []string{"b", "dir", "link_dir"},
+		},
+		{
+			name:    "middle reverse",
+			reverse: true,
+			pages:   2,
+			expect:  []string{"b", "a"},
+		},
+		{
+			name:  "end",
+			pages: 5,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			opt := &WalkOptions{BaseURL: srv.BaseURL(), Reverse: c.reverse}
+			it := NewDirIter("magodo", "ghwalk", "testdata", opt)
+			it.PageSize = 1
+			for i := 0; i < c.pages; i++ {
+				_, err := it.Next(ctx)
+				if err != io.EOF {
+					require.NoError(t, err)
+				}
+			}
+			cursor := it.Cursor()
+
+			it = NewDirIter("magodo", "ghwalk", "testdata", opt)
+			require.NoError(t, it.Seek(cursor))
+			// The cursor of the iterator not listed yet is the one sought
+			require.Equal(t, cursor, it.Cursor())
+			var names []string
+			for entry, err := range it.All(ctx) {
+				require.NoError(t, err)
+				names = append(names, entry.Name)
+			}
+			require.Equal(t, c.expect, names)
+		})
+	}
+
+	// The cursor is located by name, the entry removed meanwhile doesn't shift it
+	snapshot := &Snapshot{
+		Owner: "foo",
+		Repo:  "bar",
+		Entries: []*FileInfo{
+			{Type: FileTypeFile, Name: "a", Path: "a"},
+			{Type: FileTypeFile, Name: "b", Path: "b"},
+			{Type: FileTypeFile, Name: "c", Path: "c"},
+		},
+	}
+	it := NewDirIter("foo", "bar", "", &WalkOptions{Snapshot: snapshot})
+	it.PageSize = 2
+	_, err := it.Next(ctx)
+	require.NoError(t, err)
+	cursor := it.Cursor()
+	snapshot.Entries = snapshot.Entries[1:]
+	it = NewDirIter("foo", "bar", "", &WalkOptions{Snapshot: snapshot})
+	require.NoError(t, it.Seek(cursor))
+	page, err := it.Next(ctx)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	require.Equal(t, "c", page[0].Name)
+
+	require.Error(t, NewDirIter("foo", "bar", "dir", nil).Seek(cursor))
+	require.Error(t, NewDirIter("foo", "bar", "", nil).Seek("!"))
+}
@@ -0,0 +1,239 @@
+package ghwalk
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/magodo/ghwalk/internal/githash"
+)
+
+// DriftKind is the kind of the difference between a local file and the one in the repository.
+type DriftKind string
+
+const (
+	// DriftModified means the file exists at both sides, but with different content.
+	DriftModified DriftKind = "modified"
+	// DriftLocalOnly means the file only exists in the local directory.
+	DriftLocalOnly DriftKind = "local-only"
+	// DriftRemoteOnly means the file only exists in the repository.
+	DriftRemoteOnly DriftKind = "remote-only"
+)
+
+// Drift is a file that differs between a local directory and the repository.
+type Drift struct {
+	// Path is the slash separated path of the file, relative to both the local directory and the repository path.
+	Path string
+	Kind DriftKind
+	// LocalSHA is the git blob SHA of the local file, empty if it only exists in the repository.
+	LocalSHA string
+	// Remote is the FileInfo (without FileOnlyInfo) of the file in the repository, nil if it only exists locally.
+	Remote *FileInfo
+}
+
+// DetectDrift compares the directory path in the repository with the local directory dir, and returns the files that
+// differ, in the same order as Walk would visit them. The files are compared by their git blob SHA, so no content is
+// downloaded, and the remote tree is fetched with a single call to the Git Trees API if possible (see FindAll).
+//
+// Only files and symlinks are compared, i.e. empty directories, submodules and the file modes are not taken into
+// account. The ".git" directory at the top of dir is ignored.
+func DetectDrift(ctx context.Context, owner, repo, path, dir string, opt *WalkOptions) ([]Drift, error) {
+	path, err := cleanRepoPath(owner, repo, path)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := treeFiles(ctx, owner, repo, path, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	local, err := localBlobSHAs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var drifts []Drift
+	for rel, sha := range local {
+		info, ok := remote[rel]
+		switch {
+		case !ok:
+			drifts = append(drifts, Drift{Path: rel, Kind: DriftLocalOnly, LocalSHA: sha})
+		case info.SHA != sha:
+			drifts = append(drifts, Drift{Path: rel, Kind: DriftModified, LocalSHA: sha, Remote: info})
+		}
+	}
+	for rel, info := range remote {
+		if _, ok := local[rel]; !ok {
+			drifts = append(drifts, Drift{Path: rel, Kind: DriftRemoteOnly, Remote: info})
+		}
+	}
+
+	reverse := opt != nil && opt.Reverse
+	sort.Slice(drifts, func(i, j int) bool {
+		return lessPath(drifts[i].Path, drifts[j].Path, reverse)
+	})
+	return drifts, nil
+}
+
+// treeFiles returns the FileInfo (without FileOnlyInfo) of each file and symlink under the directory path in the
+// repository, keyed by the slash separated path relative to path.
+func treeFiles(ctx context.Context, owner, repo, path string, opt *WalkOptions) (map[string]*FileInfo, error) {
+	infos, err := listTree(ctx, owner, repo, path, opt)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]*FileInfo{}
+	for _, info := range infos {
+		if info.Path == path {
+			if !info.IsDir() {
+				return nil, fmt.Errorf("%s is not a directory", path)
+			}
+			continue
+		}
+		if info.IsDir() || info.Type == FileTypeSubmodule {
+			continue
+		}
+		rel := info.Path
+		if path != "" {
+			rel = strings.TrimPrefix(rel, path+"/")
+		}
+		files[rel] = info
+	}
+	return files, nil
+}
+
+// localBlobSHAs returns the git blob SHA of each file and symlink under dir, keyed by the slash separated path
+// relative to dir.
+func localBlobSHAs(dir string) (map[string]string, error) {
+	shas := map[string]string{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			shas[rel] = githash.BlobSHA([]byte(filepath.ToSlash(target)))
+		case d.Type().IsRegular():
+			sha, err := localFileBlobSHA(p)
+			if err != nil {
+				return err
+			}
+			shas[rel] = sha
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return shas, nil
+}
+
+// localFileBlobSHA returns the git blob SHA of the regular file p, which is hashed while being read.
+func localFileBlobSHA(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	return githash.ReadBlobSHA(f, fi.Size())
+}
+
+// TemplateDriftKind is the kind of the difference between a file in a repository and the one in its template.
+type TemplateDriftKind string
+
+const (
+	// TemplateDriftModified means the file exists in both repositories, but with different content.
+	TemplateDriftModified TemplateDriftKind = "modified"
+	// TemplateDriftAdded means the file is added in the derived repository, i.e. it doesn't exist in the template.
+	TemplateDriftAdded TemplateDriftKind = "added"
+	// TemplateDriftRemoved means the file is removed from the derived repository, i.e. it only exists in the template.
+	TemplateDriftRemoved TemplateDriftKind = "removed"
+)
+
+// TemplateDrift is a file that differs between a template repository and a repository derived from it.
+type TemplateDrift struct {
+	// Path is the slash separated path of the file, relative to the compared path.
+	Path string
+	Kind TemplateDriftKind
+	// Template is the FileInfo (without FileOnlyInfo) of the file in the template, nil if it is added.
+	Template *FileInfo
+	// Derived is the FileInfo (without FileOnlyInfo) of the file in the derived repository, nil if it is removed.
+	Derived *FileInfo
+}
+
+// DetectTemplateDrift compares the directory path in the repository with the same directory in the template
+// repository it is derived from, and returns the files that differ, in the same order as Walk would visit them.
+// Like DetectDrift, the files are compared by their git blob SHA, and no content is downloaded.
+//
+// The opt applies to both repositories, except that the Ref and At only apply to the derived one, while the template
+// is compared at its default branch.
+func DetectTemplateDrift(ctx context.Context, templateOwner, templateRepo, owner, repo, path string, opt *WalkOptions) ([]TemplateDrift, error) {
+	if err := ValidateRepo(templateOwner, templateRepo); err != nil {
+		return nil, err
+	}
+	path, err := cleanRepoPath(owner, repo, path)
+	if err != nil {
+		return nil, err
+	}
+	derived, err := treeFiles(ctx, owner, repo, path, opt)
+	if err != nil {
+		return nil, err
+	}
+	var templateOpt WalkOptions
+	if opt != nil {
+		templateOpt = *opt
+	}
+	templateOpt.Ref = ""
+	templateOpt.At = time.Time{}
+	template, err := treeFiles(ctx, templateOwner, templateRepo, path, &templateOpt)
+	if err != nil {
+		return nil, fmt.Errorf("listing the template: %w", err)
+	}
+
+	var drifts []TemplateDrift
+	for rel, info := range derived {
+		tinfo, ok := template[rel]
+		switch {
+		case !ok:
+			drifts = append(drifts, TemplateDrift{Path: rel, Kind: TemplateDriftAdded, Derived: info})
+		case tinfo.SHA != info.SHA:
+			drifts = append(drifts, TemplateDrift{Path: rel, Kind: TemplateDriftModified, Template: tinfo, Derived: info})
+		}
+	}
+	for rel, tinfo := range template {
+		if _, ok := derived[rel]; !ok {
+			drifts = append(drifts, TemplateDrift{Path: rel, Kind: TemplateDriftRemoved, Template: tinfo})
+		}
+	}
+
+	reverse := opt != nil && opt.Reverse
+	sort.Slice(drifts, func(i, j int) bool {
+		return lessPath(drifts[i].Path, drifts[j].Path, reverse)
+	})
+	return drifts, nil
+}
@@ -0,0 +1,98 @@
+package ghwalk
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectDrift(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "dir"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0755))
+	for _, name := range []string{"a", "dir/c"} {
+		b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), b, 0644))
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "d"), []byte("new\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0644))
+	require.NoError(t, os.Symlink("a", filepath.Join(dir, "link_dir")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	drifts, err := DetectDrift(ctx, "magodo", "ghwalk", "testdata", dir, &WalkOptions{Token: githubToken, BaseURL: githubBaseURL})
+	require.NoError(t, err)
+
+	type result struct {
+		path string
+		kind DriftKind
+	}
+	results := []result{}
+	for _, d := range drifts {
+		results = append(results, result{d.Path, d.Kind})
+		if d.Kind != DriftLocalOnly {
+			require.NotNil(t, d.Remote)
+		}
+		if d.Kind != DriftRemoteOnly {
+			require.NotEmpty(t, d.LocalSHA)
+		}
+	}
+	require.Equal(t, []result{
+		{"b", DriftRemoteOnly},
+		{"d", DriftLocalOnly},
+		{"link_dir", DriftModified},
+	}, results)
+
+	_, err = DetectDrift(ctx, "magodo", "ghwalk", "testdata/a", dir, &WalkOptions{Token: githubToken, BaseURL: githubBaseURL})
+	require.Error(t, err)
+}
+
+func TestDetectTemplateDrift(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{
+		"acme/template": newFixture(t, map[string]string{
+			"root/README.md":  "# Template\n",
+			"root/LICENSE":    "MIT\n",
+			"root/ci/lint.sh": "lint\n",
+			"other":           "other\n",
+		}),
+		"acme/service": newFixture(t, map[string]string{
+			"root/README.md": "# Service\n",
+			"root/LICENSE":   "MIT\n",
+			"root/main.go":   "package main\n",
+		}),
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	drifts, err := DetectTemplateDrift(ctx, "acme", "template", "acme", "service", "root", &WalkOptions{BaseURL: srv.BaseURL()})
+	require.NoError(t, err)
+
+	type result struct {
+		path     string
+		kind     TemplateDriftKind
+		template bool
+		derived  bool
+	}
+	results := []result{}
+	for _, d := range drifts {
+		results = append(results, result{d.Path, d.Kind, d.Template != nil, d.Derived != nil})
+	}
+	require.Equal(t, []result{
+		{"README.md", TemplateDriftModified, true, true},
+		{"ci/lint.sh", TemplateDriftRemoved, true, false},
+		{"main.go", TemplateDriftAdded, false, true},
+	}, results)
+
+	_, err = DetectTemplateDrift(ctx, "acme", "nonexist", "acme", "service", "root", &WalkOptions{BaseURL: srv.BaseURL()})
+	require.Error(t, err)
+}
@@ -0,0 +1,70 @@
+package ghwalk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// NDJSONEntry is the JSON object written by NDJSONWalkFunc for each visited entry.
+type NDJSONEntry struct {
+	Path string   `json:"path"`
+	Type FileType `json:"type"`
+	Size int      `json:"size"`
+	SHA  string   `json:"sha"`
+	// SHA256 is the hex encoded SHA-256 checksum of the file content, which is only set if the checksum is asked for
+	// and the FileOnlyInfo of the file is fetched (see EnableFileOnlyInfo).
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// NDJSONWalkFunc returns a WalkFunc that streams each visited entry to w as an NDJSONEntry, one JSON object per line,
+// as the walk proceeds. The repo root is not written. If checksum is set, the SHA-256 checksum of the files that have
+// their content fetched is written as well.
+//
+// The errors passed to the WalkFunc, as well as the errors writing to w, stop the walk and are returned as is.
+func NDJSONWalkFunc(w io.Writer, checksum bool) WalkFunc {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(path string, info *FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// repo root has no info
+		if info == nil {
+			return nil
+		}
+		entry := NDJSONEntry{
+			Path: path,
+			Type: info.Type,
+			Size: info.Size,
+			SHA:  info.SHA,
+		}
+		if checksum {
+			if entry.SHA256, err = contentSHA256(info); err != nil {
+				return err
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return enc.Encode(entry)
+	}
+}
+
+// contentSHA256 returns the hex encoded SHA-256 checksum of the file content, which is empty if the content is not
+// fetched.
+func contentSHA256(info *FileInfo) (string, error) {
+	if info.Type != FileTypeFile || info.FileOnlyInfo == nil || info.FileOnlyInfo.Content == nil {
+		return "", nil
+	}
+	r, err := info.ContentReader()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
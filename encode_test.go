@@ -0,0 +1,63 @@
+package ghwalk
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNDJSONWalkFunc(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	cases := []struct {
+		path     string
+		opt      *WalkOptions
+		checksum bool
+		expect   []NDJSONEntry
+	}{
+		{
+			path: "testdata",
+			opt:  &WalkOptions{Token: githubToken, BaseURL: githubBaseURL},
+			expect: []NDJSONEntry{
+				{Path: "testdata", Type: FileTypeDir, SHA: "96de04f1113f0adc6bd407e00afb0cf723e89ab7"},
+				{Path: "testdata/a", Type: FileTypeFile, Size: 13, SHA: "6069a889501d80bf232556e5397cf1c230960a5c"},
+				{Path: "testdata/b", Type: FileTypeFile, Size: 13, SHA: "0bc67c2f18a9f5f0afcd37927b91db36b6edfd76"},
+				{Path: "testdata/dir", Type: FileTypeDir, SHA: "76f49cc8f7110196ec370864801ce6ab09704e32"},
+				{Path: "testdata/dir/c", Type: FileTypeFile, Size: 20, SHA: "203ca1a091ca32c39dd43d375e7ac394ee21dcaf"},
+				{Path: "testdata/link_dir", Type: FileTypeSymlink, Size: 3, SHA: "87245193225f8ff56488ceab0dcd11467fe098d0"},
+			},
+		},
+		{
+			path:     "testdata/a",
+			opt:      &WalkOptions{Token: githubToken, BaseURL: githubBaseURL, EnableFileOnlyInfo: true},
+			checksum: true,
+			expect: []NDJSONEntry{
+				{Path: "testdata/a", Type: FileTypeFile, Size: 13, SHA: "6069a889501d80bf232556e5397cf1c230960a5c", SHA256: "ae1234b2b51186ede2fca3f1c69ec585b263c4bdc45adaafcd3921bd3eb9fea6"},
+			},
+		},
+	}
+	for idx, c := range cases {
+		var buf bytes.Buffer
+		err := Walk(ctx, "magodo", "ghwalk", c.path, c.opt, NDJSONWalkFunc(&buf, c.checksum), nil)
+		require.NoError(t, err, idx)
+
+		var entries []NDJSONEntry
+		scanner := bufio.NewScanner(&buf)
+		for scanner.Scan() {
+			var entry NDJSONEntry
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry), idx)
+			entries = append(entries, entry)
+		}
+		require.Equal(t, c.expect, entries, idx)
+	}
+
+	boom := errors.New("boom")
+	require.Equal(t, boom, NDJSONWalkFunc(&bytes.Buffer{}, false)("a", nil, boom))
+}
@@ -0,0 +1,93 @@
+package ghwalk
+
+import (
+	"context"
+	"iter"
+)
+
+// WalkEventKind is the kind of a WalkEvent.
+type WalkEventKind string
+
+const (
+	// EventEnterDir is emitted for a directory, before its entries.
+	EventEnterDir WalkEventKind = "enter-dir"
+	// EventLeaveDir is emitted for a directory, after all its entries (or the remaining ones are skipped).
+	EventLeaveDir WalkEventKind = "leave-dir"
+	// EventFile is emitted for a file, including submodules.
+	EventFile WalkEventKind = "file"
+	// EventSymlink is emitted for a symlink.
+	EventSymlink WalkEventKind = "symlink"
+	// EventError is emitted for an error, in the same cases as a WalkFunc would be called with the error.
+	EventError WalkEventKind = "error"
+	// EventSkipped is emitted for an entry not visited, e.g. excluded by the filter.
+	EventSkipped WalkEventKind = "skipped"
+)
+
+// WalkEvent is an event emitted by WalkEvents.
+type WalkEvent struct {
+	Kind WalkEventKind
+	Path string
+	// Info is the FileInfo of the entry, which is nil for the repo root, and may be nil for EventError.
+	Info *FileInfo
+	// Err is only set for EventError.
+	Err error
+	// Reason is only set for EventSkipped.
+	Reason SkipReason
+}
+
+// WalkEvents returns an iterator over the events of walking path, in the same way as Walk. Unlike the WalkFunc,
+// the events tell when a directory is finished, which allows to build the tree accurately.
+//
+// The errors are emitted as EventError, after which the walk continues (as if the WalkFunc returned nil). The walk
+// stops when the iteration stops.
+func WalkEvents(ctx context.Context, owner, repo, path string, opt *WalkOptions, filterFn PathFilterFunc) iter.Seq[WalkEvent] {
+	return func(yield func(WalkEvent) bool) {
+		var (
+			w       *walker
+			stopped bool
+		)
+		emit := func(event WalkEvent) error {
+			if !yield(event) {
+				stopped = true
+				return SkipAll
+			}
+			return nil
+		}
+
+		// The events of leaving or skipping an entry can't tell the walk to stop by the returned error, so the walk is
+		// cancelled instead, and nothing is yielded once the iteration stops.
+		stop := func(event WalkEvent) {
+			if !stopped && emit(event) != nil {
+				w.cancel()
+			}
+		}
+		w = &walker{
+			walkFn: func(path string, info *FileInfo, err error) error {
+				if stopped {
+					return SkipAll
+				}
+				switch {
+				case err != nil:
+					return emit(WalkEvent{Kind: EventError, Path: path, Info: info, Err: err})
+				case info == nil || info.IsDir():
+					return emit(WalkEvent{Kind: EventEnterDir, Path: path, Info: info})
+				case info.Type == FileTypeSymlink:
+					return emit(WalkEvent{Kind: EventSymlink, Path: path, Info: info})
+				default:
+					return emit(WalkEvent{Kind: EventFile, Path: path, Info: info})
+				}
+			},
+			filterFn: filterFn,
+			onLeave: func(path string, info *FileInfo) {
+				stop(WalkEvent{Kind: EventLeaveDir, Path: path, Info: info})
+			},
+			onSkip: func(path string, info *FileInfo, reason SkipReason) {
+				stop(WalkEvent{Kind: EventSkipped, Path: path, Info: info, Reason: reason})
+			},
+		}
+		_, err := runWalk(ctx, owner, repo, path, opt, w)
+		if err != nil && !stopped {
+			yield(WalkEvent{Kind: EventError, Path: path, Err: err})
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package ghwalk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkEvents(t *testing.T) {
+	cases := []struct {
+		path     string
+		filterFn PathFilterFunc
+		stopAt   string
+		expect   []string
+	}{
+		{
+			path: "testdata",
+			filterFn: func(path string, info *FileInfo) bool {
+				return path == "testdata/b"
+			},
+			expect: []string{
+				"enter-dir testdata",
+				"file testdata/a",
+				"skipped testdata/b",
+				"enter-dir testdata/dir",
+				"file testdata/dir/c",
+				"leave-dir testdata/dir",
+				"symlink testdata/link_dir",
+				"leave-dir testdata",
+			},
+		},
+		{
+			path:   "testdata",
+			stopAt: "file testdata/dir/c",
+			expect: []string{
+				"enter-dir testdata",
+				"file testdata/a",
+				"file testdata/b",
+				"enter-dir testdata/dir",
+				"file testdata/dir/c",
+			},
+		},
+		{
+			path:   "testdata",
+			stopAt: "leave-dir testdata/dir",
+			expect: []string{
+				"enter-dir testdata",
+				"file testdata/a",
+				"file testdata/b",
+				"enter-dir testdata/dir",
+				"file testdata/dir/c",
+				"leave-dir testdata/dir",
+			},
+		},
+		{
+			path: "testdata",
+			filterFn: func(path string, info *FileInfo) bool {
+				return path == "testdata/b"
+			},
+			stopAt: "skipped testdata/b",
+			expect: []string{
+				"enter-dir testdata",
+				"file testdata/a",
+				"skipped testdata/b",
+			},
+		},
+		{
+			path: "testdata/nonexist",
+			expect: []string{
+				"error testdata/nonexist",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		events := []string{}
+		for event := range WalkEvents(ctx, "magodo", "ghwalk", c.path, &WalkOptions{Token: githubToken, BaseURL: githubBaseURL}, c.filterFn) {
+			e := string(event.Kind) + " " + event.Path
+			events = append(events, e)
+			if event.Kind == EventError {
+				require.Error(t, event.Err)
+			}
+			if e == c.stopAt {
+				break
+			}
+		}
+		require.Equal(t, c.expect, events)
+	}
+}
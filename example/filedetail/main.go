@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 	"strings"
 
 	"github.com/magodo/ghwalk"
@@ -13,7 +12,10 @@ import (
 var sep = strings.Repeat("=", 20)
 
 func main() {
-	token := os.Args[1]
+	token, err := ghwalk.GHCLIToken(context.TODO(), "")
+	if err != nil {
+		log.Fatal(err)
+	}
 	if err := ghwalk.Walk(context.TODO(), "magodo", "ghwalk", "testdata",
 		&ghwalk.WalkOptions{
 			Token:              token,
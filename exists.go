@@ -0,0 +1,102 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+)
+
+// Exists tells whether the file or directory named by path exists in the repository. A path that doesn't exist is
+// reported as (false, nil), while any other failure (e.g. network or authentication errors) is returned as error.
+func Exists(ctx context.Context, owner, repo, path string, opt *WalkOptions) (bool, error) {
+	path, err := cleanRepoPath(owner, repo, path)
+	if err != nil {
+		return false, err
+	}
+	opt, err = resolveOptions(ctx, owner, repo, opt)
+	if err != nil {
+		return false, err
+	}
+	p, err := newProvider(ctx, opt)
+	if err != nil {
+		return false, err
+	}
+
+	if path == "" {
+		// Stat doesn't touch the API for the repo root
+		_, err = p.ReadDir(ctx, owner, repo, path)
+	} else {
+		_, err = p.Stat(ctx, owner, repo, path)
+	}
+	if errors.Is(err, ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ExistsAll tells whether each of the paths exists in the repository, keyed by the paths as given. Like Exists, a
+// path that doesn't exist is reported as false, while any other failure is returned as error, including a missing
+// repository (see TokenError) or ref (see RefNotFoundError).
+//
+// Rather than checking the paths one by one, ExistsAll fetches the whole repository tree with a single call to the
+// Git Trees API. In case the tree is too large to be returned at once, the paths are looked up via the GraphQL API
+// instead (see StatMany), which requires an access token. If opt.Snapshot or opt.Provider is set, each path is
+// checked via the provider.
+func ExistsAll(ctx context.Context, owner, repo string, paths []string, opt *WalkOptions) (map[string]bool, error) {
+	cleaned, err := cleanRepoPaths(owner, repo, paths)
+	if err != nil {
+		return nil, err
+	}
+	if opt != nil && (opt.Snapshot != nil || opt.Provider != nil) {
+		return existsAllByStat(ctx, owner, repo, paths, cleaned, opt)
+	}
+
+	opt, err = resolveOptions(ctx, owner, repo, opt)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClient(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]bool, len(paths))
+	tree, _, err := client.Git.GetTree(ctx, owner, repo, treeRef(opt), true)
+	if err != nil {
+		// Unlike a missing path, a missing tree means the repository or the ref is missing (or not accessible)
+		if isNotFound(err) {
+			return nil, diagnoseNotExist(ctx, owner, repo, opt, &notExistError{path: owner + "/" + repo, err: err})
+		}
+		return nil, err
+	}
+	if tree.GetTruncated() {
+		return existsAllByStat(ctx, owner, repo, paths, cleaned, opt)
+	}
+
+	exists := make(map[string]bool, len(tree.Entries))
+	for _, entry := range tree.Entries {
+		if entry != nil {
+			exists[entry.GetPath()] = true
+		}
+	}
+	for i, path := range paths {
+		result[path] = cleaned[i] == "" || exists[cleaned[i]]
+	}
+	return result, nil
+}
+
+// existsAllByStat checks the existence of the paths via StatMany, the cleaned are the canonical forms of the paths.
+func existsAllByStat(ctx context.Context, owner, repo string, paths, cleaned []string, opt *WalkOptions) (map[string]bool, error) {
+	infos, err := StatMany(ctx, owner, repo, cleaned, opt)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]bool, len(paths))
+	for i, path := range paths {
+		// StatMany returns no FileInfo for the repo root, which exists as the stats succeed
+		result[path] = infos[i] != nil || cleaned[i] == ""
+	}
+	return result, nil
+}
@@ -0,0 +1,129 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExists(t *testing.T) {
+	cases := []struct {
+		owner  string
+		repo   string
+		path   string
+		exists bool
+	}{
+		{
+			owner:  "magodo",
+			repo:   "ghwalk",
+			path:   "",
+			exists: true,
+		},
+		{
+			owner:  "magodo",
+			repo:   "ghwalk",
+			path:   "testdata/dir/c",
+			exists: true,
+		},
+		{
+			owner:  "magodo",
+			repo:   "ghwalk",
+			path:   "testdata/link_dir",
+			exists: true,
+		},
+		{
+			owner:  "magodo",
+			repo:   "ghwalk",
+			path:   "testdata/non_existent",
+			exists: false,
+		},
+		{
+			owner:  "magodo",
+			repo:   "ghwalk",
+			path:   "testdata/non_existent/a",
+			exists: false,
+		},
+	}
+
+	for _, c := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		exists, err := Exists(ctx, c.owner, c.repo, c.path, &WalkOptions{Token: githubToken, BaseURL: githubBaseURL})
+		require.NoError(t, err)
+		require.Equal(t, c.exists, exists)
+	}
+}
+
+func TestExistsAll(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": "testdata"})
+	defer srv.Close()
+
+	paths := []string{"", "a", "/dir/c", "link_dir", "nonexist", "dir/nonexist", "a/b"}
+	expect := map[string]bool{
+		"":             true,
+		"a":            true,
+		"/dir/c":       true,
+		"link_dir":     true,
+		"nonexist":     false,
+		"dir/nonexist": false,
+		"a/b":          false,
+	}
+
+	cases := []struct {
+		name      string
+		treeLimit int
+		snapshot  bool
+		requests  int
+	}{
+		{
+			name:     "tree",
+			requests: 1,
+		},
+		{
+			name:      "truncated tree",
+			treeLimit: 2,
+			// the tree and the GraphQL query
+			requests: 2,
+		},
+		{
+			name:     "snapshot",
+			snapshot: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+			defer cancel()
+			opt := &WalkOptions{BaseURL: srv.BaseURL()}
+			if c.snapshot {
+				snapshot, err := TakeSnapshot(ctx, "foo", "bar", "", opt)
+				require.NoError(t, err)
+				opt = &WalkOptions{Snapshot: snapshot}
+			}
+			srv.SetTreeLimit(c.treeLimit)
+			defer srv.SetTreeLimit(0)
+			requests := srv.RequestCount()
+			exists, err := ExistsAll(ctx, "foo", "bar", paths, opt)
+			require.NoError(t, err)
+			require.Equal(t, expect, exists)
+			require.Equal(t, c.requests, srv.RequestCount()-requests)
+		})
+	}
+
+	// The missing repository or ref is an error, rather than all the paths missing
+	_, err := ExistsAll(context.Background(), "foo", "nonexist", []string{"", "a"}, &WalkOptions{BaseURL: srv.BaseURL()})
+	var tokenErr *TokenError
+	require.True(t, errors.As(err, &tokenErr), err)
+	require.Equal(t, TokenNoRepoAccess, tokenErr.Kind)
+	tagged := ghwalktest.NewServer(map[string]string{"foo/bar@v1": "testdata"})
+	defer tagged.Close()
+	_, err = ExistsAll(context.Background(), "foo", "bar", []string{"", "a"}, &WalkOptions{BaseURL: tagged.BaseURL(), Ref: "nonexist"})
+	var refErr *RefNotFoundError
+	require.True(t, errors.As(err, &refErr), err)
+	require.True(t, errors.Is(err, ErrNotExist))
+}
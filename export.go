@@ -0,0 +1,195 @@
+package ghwalk
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat is the format of the archive written by Export.
+type ArchiveFormat string
+
+const (
+	ArchiveTar   ArchiveFormat = "tar"
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	ArchiveZip   ArchiveFormat = "zip"
+)
+
+// DeterministicModTime is the modification time of the archive entries written by Export in the deterministic mode,
+// unless ExportOptions.ModTime is set. It is the earliest time that a zip archive can represent.
+var DeterministicModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ExportOptions controls how Export writes the archive.
+type ExportOptions struct {
+	// Format is the archive format, which defaults to ArchiveTarGz.
+	Format ArchiveFormat
+
+	// Deterministic makes the archives of the same content byte-identical: the entries have a fixed modification
+	// time (ModTime, or DeterministicModTime if unset), no owner, and the gzip header has no timestamp.
+	// Otherwise, the entries have the current time as their modification time.
+	Deterministic bool
+
+	// ModTime overrides the modification time of the entries in the deterministic mode, e.g. to use the commit date.
+	ModTime time.Time
+}
+
+// Export writes the directory path in the repository to w as an archive, with the entry names relative to path.
+// The entries are always in the walk order (directories before their children). Symlinks are archived as symlinks,
+// files with the git mode 100755 as executables, while submodules are skipped.
+//
+// For the zip format, the Zip64 extensions are used once the archive grows beyond 4 GiB or 65535 entries, so there is
+// no limit on the output size.
+//
+// Like Mirror, the remote tree is fetched with a single call to the Git Trees API if possible, and each file is
+// downloaded via the Git Blobs API, unless opt.Snapshot or opt.Provider is set.
+func Export(ctx context.Context, owner, repo, path string, w io.Writer, eopt *ExportOptions, opt *WalkOptions) error {
+	if eopt == nil {
+		eopt = &ExportOptions{}
+	}
+	path, err := cleanRepoPath(owner, repo, path)
+	if err != nil {
+		return err
+	}
+	opt, err = resolveOptions(ctx, owner, repo, opt)
+	if err != nil {
+		return err
+	}
+	infos, err := listTree(ctx, owner, repo, path, opt)
+	if err != nil {
+		return err
+	}
+	fetch, err := newBlobFetcher(ctx, owner, repo, opt)
+	if err != nil {
+		return err
+	}
+
+	var aw archiveWriter
+	switch eopt.Format {
+	case ArchiveTarGz, "":
+		gw := gzip.NewWriter(w)
+		if !eopt.Deterministic {
+			gw.ModTime = time.Now()
+		}
+		aw = &tarArchiveWriter{tw: tar.NewWriter(gw), closer: gw}
+	case ArchiveTar:
+		aw = &tarArchiveWriter{tw: tar.NewWriter(w)}
+	case ArchiveZip:
+		aw = &zipArchiveWriter{zw: zip.NewWriter(w)}
+	default:
+		return fmt.Errorf("unknown archive format %q", eopt.Format)
+	}
+
+	modTime := time.Now()
+	if eopt.Deterministic {
+		modTime = DeterministicModTime
+		if !eopt.ModTime.IsZero() {
+			modTime = eopt.ModTime
+		}
+	}
+	modTime = modTime.UTC().Truncate(time.Second)
+
+	sort.Slice(infos, func(i, j int) bool {
+		return lessPath(infos[i].Path, infos[j].Path, false)
+	})
+	for _, info := range infos {
+		if info.Path == path {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", path)
+			}
+			continue
+		}
+		if info.Type == FileTypeSubmodule {
+			continue
+		}
+		rel := info.Path
+		if path != "" {
+			rel = strings.TrimPrefix(rel, path+"/")
+		}
+		var content []byte
+		if !info.IsDir() {
+			if content, err = fetch(ctx, info); err != nil {
+				return fmt.Errorf("downloading %s: %w", info.Path, err)
+			}
+		}
+		if err := aw.add(rel, info, content, modTime); err != nil {
+			return fmt.Errorf("archiving %s: %w", info.Path, err)
+		}
+	}
+	return aw.close()
+}
+
+// archiveWriter writes the entries of an archive. The content of a symlink is its target.
+type archiveWriter interface {
+	add(name string, info *FileInfo, content []byte, modTime time.Time) error
+	close() error
+}
+
+type tarArchiveWriter struct {
+	tw     *tar.Writer
+	closer io.Closer
+}
+
+func (w *tarArchiveWriter) add(name string, info *FileInfo, content []byte, modTime time.Time) error {
+	hdr := &tar.Header{Name: name, ModTime: modTime}
+	switch {
+	case info.IsDir():
+		hdr.Typeflag, hdr.Name, hdr.Mode = tar.TypeDir, name+"/", 0755
+	case info.Type == FileTypeSymlink:
+		hdr.Typeflag, hdr.Linkname, hdr.Mode = tar.TypeSymlink, string(content), 0777
+	default:
+		hdr.Typeflag, hdr.Size, hdr.Mode = tar.TypeReg, int64(len(content)), int64(localFileMode(info))
+	}
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if hdr.Typeflag == tar.TypeReg {
+		_, err := w.tw.Write(content)
+		return err
+	}
+	return nil
+}
+
+func (w *tarArchiveWriter) close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	if w.closer != nil {
+		return w.closer.Close()
+	}
+	return nil
+}
+
+type zipArchiveWriter struct {
+	zw *zip.Writer
+}
+
+func (w *zipArchiveWriter) add(name string, info *FileInfo, content []byte, modTime time.Time) error {
+	hdr := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime}
+	switch {
+	case info.IsDir():
+		hdr.Name, hdr.Method = name+"/", zip.Store
+		hdr.SetMode(os.ModeDir | 0755)
+	case info.Type == FileTypeSymlink:
+		hdr.Method = zip.Store
+		hdr.SetMode(os.ModeSymlink | 0777)
+	default:
+		hdr.SetMode(localFileMode(info))
+	}
+	fw, err := w.zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(content)
+	return err
+}
+
+func (w *zipArchiveWriter) close() error {
+	return w.zw.Close()
+}
@@ -0,0 +1,114 @@
+package ghwalk
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExport(t *testing.T) {
+	fixture := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(fixture, "root", "dir"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(fixture, "root", "a"), []byte("a\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(fixture, "root", "dir", "run.sh"), []byte("#!/bin/sh\n"), 0755))
+	require.NoError(t, os.Symlink("a", filepath.Join(fixture, "root", "link")))
+
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": fixture})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	opt := &WalkOptions{BaseURL: srv.BaseURL()}
+
+	type entry struct {
+		Name    string
+		Mode    os.FileMode
+		Content string
+	}
+	expect := []entry{
+		{Name: "a", Mode: 0644, Content: "a\n"},
+		{Name: "dir/", Mode: os.ModeDir | 0755},
+		{Name: "dir/run.sh", Mode: 0755, Content: "#!/bin/sh\n"},
+		{Name: "link", Mode: os.ModeSymlink | 0777, Content: "a"},
+	}
+	readTar := func(r io.Reader) []entry {
+		var entries []entry
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return entries
+			}
+			require.NoError(t, err)
+			b, err := ioutil.ReadAll(tr)
+			require.NoError(t, err)
+			e := entry{Name: hdr.Name, Mode: hdr.FileInfo().Mode(), Content: string(b)}
+			if hdr.Typeflag == tar.TypeSymlink {
+				e.Content = hdr.Linkname
+			}
+			entries = append(entries, e)
+		}
+	}
+
+	cases := []struct {
+		format ArchiveFormat
+		read   func(b []byte) []entry
+	}{
+		{
+			format: ArchiveTar,
+			read: func(b []byte) []entry {
+				return readTar(bytes.NewReader(b))
+			},
+		},
+		{
+			format: ArchiveTarGz,
+			read: func(b []byte) []entry {
+				gr, err := gzip.NewReader(bytes.NewReader(b))
+				require.NoError(t, err)
+				return readTar(gr)
+			},
+		},
+		{
+			format: ArchiveZip,
+			read: func(b []byte) []entry {
+				zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+				require.NoError(t, err)
+				var entries []entry
+				for _, f := range zr.File {
+					rc, err := f.Open()
+					require.NoError(t, err)
+					content, err := ioutil.ReadAll(rc)
+					require.NoError(t, err)
+					rc.Close()
+					entries = append(entries, entry{Name: f.Name, Mode: f.Mode(), Content: string(content)})
+				}
+				return entries
+			},
+		},
+	}
+	for _, c := range cases {
+		var first, second bytes.Buffer
+		eopt := &ExportOptions{Format: c.format, Deterministic: true}
+		require.NoError(t, Export(ctx, "foo", "bar", "root", &first, eopt, opt), c.format)
+		require.Equal(t, expect, c.read(first.Bytes()), c.format)
+
+		// Archives of the same content are byte-identical in the deterministic mode
+		time.Sleep(time.Second)
+		require.NoError(t, Export(ctx, "foo", "bar", "root", &second, eopt, opt), c.format)
+		require.Equal(t, first.Bytes(), second.Bytes(), c.format)
+	}
+
+	err := Export(ctx, "foo", "bar", "root", ioutil.Discard, &ExportOptions{Format: "rar"}, opt)
+	require.EqualError(t, err, `unknown archive format "rar"`)
+}
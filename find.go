@@ -0,0 +1,187 @@
+package ghwalk
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/magodo/ghwalk/internal/githash"
+)
+
+// MatchFunc reports whether the file or directory named by path is wanted.
+// The info argument is never nil.
+type MatchFunc func(path string, info *FileInfo) bool
+
+// FindAll returns the FileInfo of every file or directory under path (including path itself) for which predicate
+// returns true, in the same order as Walk would visit them.
+//
+// Rather than listing each directory, FindAll fetches the whole repository tree with a single call to the Git Trees API.
+// The returned FileInfo therefore only carries the metadata available in a tree entry (e.g. URL and HTMLURL are empty).
+// If opt.EnableFileOnlyInfo (or opt.FetchContentFunc) asks for it, one extra API call is issued for each matched file
+// to fill in its FileOnlyInfo.
+// In case the tree is too large to be returned at once, or opt.Snapshot or opt.Provider is set, FindAll falls back
+// to Walk.
+func FindAll(ctx context.Context, owner, repo, path string, predicate MatchFunc, opt *WalkOptions) ([]FileInfo, error) {
+	path, err := cleanRepoPath(owner, repo, path)
+	if err != nil {
+		return nil, err
+	}
+	if opt != nil && (opt.Snapshot != nil || opt.Provider != nil) {
+		return findAllByWalk(ctx, owner, repo, path, predicate, opt)
+	}
+
+	opt, err = resolveOptions(ctx, owner, repo, opt)
+	if err != nil {
+		return nil, err
+	}
+	infos, client, ok, err := treeEntries(ctx, owner, repo, path, opt)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return findAllByWalk(ctx, owner, repo, path, predicate, opt)
+	}
+
+	normalize := opt != nil && opt.NormalizeUnicode
+	var matches []*FileInfo
+	for _, info := range infos {
+		p := info.Path
+		if normalize {
+			p, info = normalizeEntry(p, info)
+		}
+		if predicate(p, info) {
+			matches = append(matches, info)
+		}
+	}
+
+	reverse := opt != nil && opt.Reverse
+	sort.Slice(matches, func(i, j int) bool {
+		return lessPath(matches[i].Path, matches[j].Path, reverse)
+	})
+
+	p := &githubProvider{client: client, opt: opt}
+	result := make([]FileInfo, 0, len(matches))
+	for _, info := range matches {
+		if info.Type == FileTypeFile && opt.fetchContent(info.Path, info) {
+			info, err = p.ReadFile(ctx, owner, repo, info.rawPath())
+			if err != nil {
+				return nil, err
+			}
+			if normalize {
+				_, info = normalizeEntry(info.Path, info)
+			}
+		}
+		result = append(result, *info)
+	}
+	return result, nil
+}
+
+func findAllByWalk(ctx context.Context, owner, repo, path string, predicate MatchFunc, opt *WalkOptions) ([]FileInfo, error) {
+	var result []FileInfo
+	err := Walk(ctx, owner, repo, path, opt,
+		func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info != nil && predicate(path, info) {
+				result = append(result, *info)
+			}
+			return nil
+		},
+		nil)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// treeEntries returns the FileInfo (without FileOnlyInfo) of every file or directory under path (including path itself,
+// unless it is the repo root) in the order of the tree, which is fetched with a single call to the Git Trees API, along
+// with the client used. The opt is expected to be resolved already (see resolveOptions). It returns false if the tree
+// is too large to be returned at once, in which case the caller falls back to Walk.
+func treeEntries(ctx context.Context, owner, repo, path string, opt *WalkOptions) ([]*FileInfo, *github.Client, bool, error) {
+	client, err := newClient(ctx, opt)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	tree, _, err := client.Git.GetTree(ctx, owner, repo, treeRef(opt), true)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if tree.GetTruncated() {
+		return nil, client, false, nil
+	}
+
+	o := &origin{client: client, owner: owner, repo: repo, opt: opt}
+	var found bool
+	var infos []*FileInfo
+	for _, entry := range tree.Entries {
+		if entry == nil {
+			continue
+		}
+		p := entry.GetPath()
+		if path != "" && p != path && !strings.HasPrefix(p, path+"/") {
+			continue
+		}
+		if p == path {
+			found = true
+		}
+		info := newFileInfoFromTreeEntry(entry)
+		info.origin = o
+		infos = append(infos, info)
+	}
+	if path != "" && !found {
+		return nil, nil, false, errNoSuchPath(path)
+	}
+	return infos, client, true, nil
+}
+
+// treeRef returns the tree-ish used to query the Git Trees API, which defaults to the HEAD of the default branch.
+func treeRef(opt *WalkOptions) string {
+	if opt == nil || opt.Ref == "" {
+		return "HEAD"
+	}
+	return opt.Ref
+}
+
+func newFileInfoFromTreeEntry(entry *github.TreeEntry) *FileInfo {
+	var typ FileType
+	switch entry.GetMode() {
+	case "040000":
+		typ = FileTypeDir
+	case "120000":
+		typ = FileTypeSymlink
+	case "160000":
+		typ = FileTypeSubmodule
+	default:
+		typ = FileTypeFile
+	}
+
+	return &FileInfo{
+		Type:       typ,
+		Size:       entry.GetSize(),
+		Name:       filepath.Base(entry.GetPath()),
+		Path:       entry.GetPath(),
+		SHA:        entry.GetSHA(),
+		GitURL:     entry.GetURL(),
+		Executable: entry.GetMode() == githash.ModeExecutable,
+	}
+}
+
+// lessPath compares two slash separated paths component by component, so that the resulting order is the same as
+// the one that a depth-first walk produces, i.e. a directory always goes before its children.
+func lessPath(a, b string, reverse bool) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		if reverse {
+			return as[i] > bs[i]
+		}
+		return as[i] < bs[i]
+	}
+	return len(as) < len(bs)
+}
@@ -0,0 +1,88 @@
+package ghwalk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindAll(t *testing.T) {
+	cases := []struct {
+		owner      string
+		repo       string
+		path       string
+		reverse    bool
+		predicate  MatchFunc
+		expectPath []string
+		isError    bool
+	}{
+		{
+			owner: "magodo",
+			repo:  "ghwalk",
+			path:  "testdata",
+			predicate: func(path string, info *FileInfo) bool {
+				return true
+			},
+			expectPath: []string{
+				"testdata",
+				"testdata/a",
+				"testdata/b",
+				"testdata/dir",
+				"testdata/dir/c",
+				"testdata/link_dir",
+			},
+		},
+		{
+			owner:   "magodo",
+			repo:    "ghwalk",
+			path:    "testdata",
+			reverse: true,
+			predicate: func(path string, info *FileInfo) bool {
+				return info.Type == FileTypeFile
+			},
+			expectPath: []string{
+				"testdata/dir/c",
+				"testdata/b",
+				"testdata/a",
+			},
+		},
+		{
+			owner: "magodo",
+			repo:  "ghwalk",
+			path:  "testdata/dir",
+			predicate: func(path string, info *FileInfo) bool {
+				return !info.IsDir()
+			},
+			expectPath: []string{
+				"testdata/dir/c",
+			},
+		},
+		{
+			owner: "magodo",
+			repo:  "ghwalk",
+			path:  "testdata/non_existent",
+			predicate: func(path string, info *FileInfo) bool {
+				return true
+			},
+			isError: true,
+		},
+	}
+
+	for _, c := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		infos, err := FindAll(ctx, c.owner, c.repo, c.path, c.predicate, &WalkOptions{Token: githubToken, BaseURL: githubBaseURL, Reverse: c.reverse})
+		if c.isError {
+			require.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		paths := []string{}
+		for _, info := range infos {
+			paths = append(paths, info.Path)
+		}
+		require.Equal(t, c.expectPath, paths)
+	}
+}
@@ -0,0 +1,615 @@
+package ghwalk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSymlinks is the maximum number of the symlinks followed to open a path, like the one of Linux.
+const maxSymlinks = 40
+
+// providerFS is the fs.FS of the path root in a repository, whose content is provided by a ContentProvider.
+type providerFS struct {
+	ctx      context.Context
+	owner    string
+	repo     string
+	root     string
+	provider ContentProvider
+	opt      *WalkOptions
+}
+
+// NewFS returns the fs.FS of the directory path in the repository, whose requests are made with ctx. The content is
+// retrieved via the Github API (or from the Snapshot or Provider of opt), and the symlinks are followed as long as
+// their targets are in the repository. The Ref is resolved once by NewFS if PinCommit (or At) of opt is set, so that
+// the fs.FS serves a consistent state.
+//
+// The returned fs.FS implements fs.ReadDirFS, fs.ReadFileFS, fs.StatFS, fs.GlobFS and fs.SubFS (and fs.ReadLinkFS as
+// of Go 1.25), so that the helpers of io/fs don't fall back to the generic implementations, which take more API
+// calls. It passes fstest.TestFS, where the directories have the mode fs.ModeDir|0555, the files 0444 (or 0555 if
+// known to be executable), and the ModTime is always zero as git doesn't track it.
+//
+// The files are seekable, so that the fs.FS can be served by http.FileServer via http.FS. Set the ContentCache of opt
+// to serve the hot files from memory, rather than reading them via the API on every Open. The fs.FS returned by its Sub
+// shares the client (and thus the Cache and the rate limit handling) with it.
+func NewFS(ctx context.Context, owner, repo, path string, opt *WalkOptions) (fs.FS, error) {
+	path, err := cleanRepoPath(owner, repo, path)
+	if err != nil {
+		return nil, err
+	}
+	opt, err = resolveOptions(ctx, owner, repo, opt)
+	if err != nil {
+		return nil, err
+	}
+	p, err := newProvider(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	return &providerFS{
+		ctx:      ctx,
+		owner:    owner,
+		repo:     repo,
+		root:     path,
+		provider: p,
+		opt:      opt,
+	}, nil
+}
+
+// fullPath returns the path in the repository of the fs.FS path name.
+func (fsys *providerFS) fullPath(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	switch {
+	case name == ".":
+		return fsys.root, nil
+	case fsys.root == "":
+		return name, nil
+	}
+	return fsys.root + "/" + name, nil
+}
+
+func (fsys *providerFS) Open(name string) (fs.File, error) {
+	full, err := fsys.fullPath("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info, content, ok := fsys.cached(full); ok {
+		return &fsFile{info: newFSFileInfo(info, name), content: bytes.NewReader(content)}, nil
+	}
+	info, err := fsys.resolve(full)
+	if err != nil {
+		return nil, fsError("open", name, err)
+	}
+	fi := newFSFileInfo(info, name)
+	switch {
+	case info == nil || info.IsDir():
+		dir := full
+		if info != nil {
+			dir = info.Path
+		}
+		return &fsDir{fsys: fsys, path: dir, name: name, info: fi}, nil
+	case info.Type == FileTypeSubmodule:
+		return &fsFile{info: fi, content: bytes.NewReader(nil)}, nil
+	}
+	if info.FileOnlyInfo == nil {
+		if info, err = readFile(fsys.ctx, fsys.owner, fsys.repo, info.Path, fsys.provider, fsys.opt, info); err != nil {
+			return nil, fsError("open", name, err)
+		}
+	}
+	// The files to be cached are read at once, others are streamed
+	if c := fsys.contentCache(); c != nil && c.fits(int64(info.Size)) {
+		content, err := readContent(fsys.ctx, info)
+		if err != nil {
+			return nil, fsError("open", name, err)
+		}
+		fsys.cache(full, info, content)
+		return &fsFile{info: fi, content: bytes.NewReader(content)}, nil
+	}
+	r, err := info.Open(fsys.ctx)
+	if err != nil {
+		return nil, fsError("open", name, err)
+	}
+	return &fsFile{info: fi, r: r, open: func() (io.ReadCloser, error) { return info.Open(fsys.ctx) }}, nil
+}
+
+func (fsys *providerFS) Stat(name string) (fs.FileInfo, error) {
+	full, err := fsys.fullPath("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	if info, _, ok := fsys.cached(full); ok {
+		return newFSFileInfo(info, name), nil
+	}
+	info, err := fsys.resolve(full)
+	if err != nil {
+		return nil, fsError("stat", name, err)
+	}
+	return newFSFileInfo(info, name), nil
+}
+
+func (fsys *providerFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	full, err := fsys.fullPath("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fsys.resolve(full)
+	if err != nil {
+		return nil, fsError("readdir", name, err)
+	}
+	if info != nil {
+		if !info.IsDir() {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+		}
+		full = info.Path
+	}
+	entries, err := fsys.readDir(full)
+	if err != nil {
+		return nil, fsError("readdir", name, err)
+	}
+	return entries, nil
+}
+
+// ReadFile reads the file with a single request in the common case, i.e. a file not behind any symlink, rather than
+// a Stat followed by the read.
+func (fsys *providerFS) ReadFile(name string) ([]byte, error) {
+	full, err := fsys.fullPath("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if _, content, ok := fsys.cached(full); ok {
+		return bytes.Clone(content), nil
+	}
+	if info, err := readFile(fsys.ctx, fsys.owner, fsys.repo, full, fsys.provider, fsys.opt, &FileInfo{}); err == nil && info.Type == FileTypeFile {
+		b, err := readContent(fsys.ctx, info)
+		if err != nil {
+			return nil, fsError("read", name, err)
+		}
+		fsys.cache(full, info, b)
+		return bytes.Clone(b), nil
+	}
+
+	// Otherwise, e.g. a directory, a symlink or an error, go through the path resolution for the exact error
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// contentCache returns the ContentCache of the files, which is nil if not set.
+func (fsys *providerFS) contentCache() *ContentCache {
+	if fsys.opt == nil {
+		return nil
+	}
+	return fsys.opt.ContentCache
+}
+
+// contentCacheKey returns the key of the file at path in the repository in the ContentCache.
+func (fsys *providerFS) contentCacheKey(path string) string {
+	return fmt.Sprintf("%s/%s@%s:%s", fsys.owner, fsys.repo, fsys.opt.Ref, path)
+}
+
+// cached returns the FileInfo and content of the file at path in the repository from the ContentCache, if any.
+func (fsys *providerFS) cached(path string) (*FileInfo, []byte, bool) {
+	c := fsys.contentCache()
+	if c == nil {
+		return nil, nil, false
+	}
+	return c.get(fsys.contentCacheKey(path))
+}
+
+// cache caches the file at path in the repository in the ContentCache, if any. The FileOnlyInfo is not cached along,
+// as the content is.
+func (fsys *providerFS) cache(path string, info *FileInfo, content []byte) {
+	c := fsys.contentCache()
+	if c == nil {
+		return
+	}
+	meta := *info
+	meta.FileOnlyInfo = nil
+	c.set(fsys.contentCacheKey(path), &meta, content)
+}
+
+// Glob lists the directories with a single call to the Git Trees API, if the content is retrieved via the Github API
+// and the pattern has the meta characters in its directory part, i.e. more than one directory would be read
+// otherwise. The directories behind the symlinks are read from the provider as fs.Glob does.
+func (fsys *providerFS) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	var tree map[string][]fs.DirEntry
+	if dir, _ := path.Split(pattern); hasMeta(dir) {
+		tree = fsys.tree()
+	}
+	// fs.Glob doesn't call the Glob of treeFS, as it doesn't implement fs.GlobFS
+	return fs.Glob(&treeFS{fsys: fsys, tree: tree}, pattern)
+}
+
+// hasMeta tells whether the path contains any of the meta characters recognized by path.Match.
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+// tree returns the entries of each directory under the root (keyed by the path in the repository, and sorted by
+// name) listed by a single call to the Git Trees API. It returns nil if the content is not retrieved via the Github
+// API, or the tree can't be listed at once.
+func (fsys *providerFS) tree() map[string][]fs.DirEntry {
+	p, ok := fsys.provider.(*githubProvider)
+	if !ok {
+		return nil
+	}
+	tree, _, err := p.client.Git.GetTree(fsys.ctx, fsys.owner, fsys.repo, treeRef(fsys.opt), true)
+	if err != nil || tree.GetTruncated() {
+		return nil
+	}
+	// A directory in git is never empty, so every directory of the tree has its entries here
+	dirs := map[string][]fs.DirEntry{}
+	for _, entry := range tree.Entries {
+		if entry == nil {
+			continue
+		}
+		info := newFileInfoFromTreeEntry(entry)
+		if fsys.root != "" && !strings.HasPrefix(info.Path, fsys.root+"/") {
+			continue
+		}
+		dir := parentDir(info.Path)
+		dirs[dir] = append(dirs[dir], fs.FileInfoToDirEntry(newFSFileInfo(info, info.Name)))
+	}
+	for _, entries := range dirs {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	}
+	return dirs
+}
+
+// Sub returns the fs.FS of the directory dir, which shares the provider with fsys. As fs.Sub, the directory is not
+// checked until it is used.
+func (fsys *providerFS) Sub(dir string) (fs.FS, error) {
+	full, err := fsys.fullPath("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	sub := *fsys
+	sub.root = full
+	return &sub, nil
+}
+
+// treeFS serves the directory listings from the tree prefetched by the Glob of providerFS, and the rest from the
+// providerFS.
+type treeFS struct {
+	fsys *providerFS
+	tree map[string][]fs.DirEntry
+}
+
+func (t *treeFS) Open(name string) (fs.File, error) {
+	return t.fsys.Open(name)
+}
+
+func (t *treeFS) Stat(name string) (fs.FileInfo, error) {
+	return t.fsys.Stat(name)
+}
+
+func (t *treeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	full, err := t.fsys.fullPath("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	// The directories behind the symlinks are not in the tree
+	if entries, ok := t.tree[full]; ok {
+		return slices.Clone(entries), nil
+	}
+	return t.fsys.ReadDir(name)
+}
+
+// Lstat is the same as Stat, except that the named file is not followed if it is a symlink, while the symlinks of its
+// parent directories are. Along with ReadLink, it implements fs.ReadLinkFS as of Go 1.25.
+func (fsys *providerFS) Lstat(name string) (fs.FileInfo, error) {
+	full, err := fsys.fullPath("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fsys.lstat(name, full)
+	if err != nil {
+		return nil, fsError("lstat", name, err)
+	}
+	return newFSFileInfo(info, name), nil
+}
+
+// ReadLink returns the target of the named symlink, as recorded in the repository.
+func (fsys *providerFS) ReadLink(name string) (string, error) {
+	full, err := fsys.fullPath("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	info, err := fsys.lstat(name, full)
+	if err != nil {
+		return "", fsError("readlink", name, err)
+	}
+	if info == nil || info.Type != FileTypeSymlink {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	target, err := fsys.readLink(info)
+	if err != nil {
+		return "", fsError("readlink", name, err)
+	}
+	return target, nil
+}
+
+// lstat returns the FileInfo of the fs.FS path name, whose path in the repository is p, without following it if it
+// is a symlink. The root of the fs.FS is always followed, as os.DirFS does.
+func (fsys *providerFS) lstat(name, p string) (*FileInfo, error) {
+	if name == "." {
+		return fsys.resolve(p)
+	}
+	info, err := fsys.provider.Stat(fsys.ctx, fsys.owner, fsys.repo, p)
+	if err == nil || !errors.Is(err, ErrNotExist) {
+		return info, err
+	}
+	// The path might be behind the symlinks of its parent directories
+	dir, err := fsys.resolve(parentDir(p))
+	if err != nil {
+		return nil, err
+	}
+	if dir == nil || !dir.IsDir() || dir.Path == parentDir(p) {
+		return nil, errNoSuchPath(p)
+	}
+	return fsys.provider.Stat(fsys.ctx, fsys.owner, fsys.repo, dir.Path+"/"+path.Base(p))
+}
+
+// readLink returns the target of the symlink.
+func (fsys *providerFS) readLink(info *FileInfo) (string, error) {
+	// Github returns the content of the target for a symlink pointing to a file, rather than the target itself
+	if p, ok := fsys.provider.(*githubProvider); ok {
+		b, _, err := p.client.Git.GetBlobRaw(fsys.ctx, fsys.owner, fsys.repo, info.SHA)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	out, err := readFile(fsys.ctx, fsys.owner, fsys.repo, info.Path, fsys.provider, fsys.opt, info)
+	if err != nil {
+		return "", err
+	}
+	if out.FileOnlyInfo == nil || out.FileOnlyInfo.Target == nil {
+		return "", fmt.Errorf("the target of the symlink %s is unknown", info.Path)
+	}
+	return *out.FileOnlyInfo.Target, nil
+}
+
+// readDir returns the entries of the directory named by path in the repository, sorted by name.
+func (fsys *providerFS) readDir(path string) ([]fs.DirEntry, error) {
+	infos, err := fsys.provider.ReadDir(fsys.ctx, fsys.owner, fsys.repo, path)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	entries := make([]fs.DirEntry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, fs.FileInfoToDirEntry(newFSFileInfo(info, info.Name)))
+	}
+	return entries, nil
+}
+
+// resolve returns the FileInfo of the path in the repository, following the symlinks, including the ones of its
+// parent directories. The FileInfo of a symlink pointing to a file comes with the FileOnlyInfo of the file.
+func (fsys *providerFS) resolve(p string) (*FileInfo, error) {
+	// Most paths don't go through any symlink, which are resolved by a single Stat
+	info, err := fsys.provider.Stat(fsys.ctx, fsys.owner, fsys.repo, p)
+	if err == nil && (info == nil || info.Type != FileTypeSymlink) {
+		return info, nil
+	}
+	if err != nil && !errors.Is(err, ErrNotExist) {
+		return nil, err
+	}
+
+	info = nil
+	var dir string
+	for links := 0; p != ""; {
+		name, rest, _ := strings.Cut(p, "/")
+		next := name
+		if dir != "" {
+			next = dir + "/" + name
+		}
+		info, err = fsys.provider.Stat(fsys.ctx, fsys.owner, fsys.repo, next)
+		if err != nil {
+			return nil, err
+		}
+		dir, p = next, rest
+		if info.Type != FileTypeSymlink {
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return nil, fmt.Errorf("too many levels of symlinks: %s", next)
+		}
+		// Github returns the content of the target for a symlink pointing to a file
+		out, err := readFile(fsys.ctx, fsys.owner, fsys.repo, next, fsys.provider, fsys.opt, info)
+		if err != nil {
+			return nil, err
+		}
+		if out.Type != FileTypeSymlink {
+			info = out
+			continue
+		}
+		if out.FileOnlyInfo == nil || out.FileOnlyInfo.Target == nil {
+			return nil, fmt.Errorf("the target of the symlink %s is unknown", next)
+		}
+		target := path.Join(path.Dir(next), *out.FileOnlyInfo.Target)
+		if target == ".." || strings.HasPrefix(target, "../") || path.IsAbs(target) {
+			return nil, fmt.Errorf("the symlink %s points outside of the repository", next)
+		}
+		// Resolve the target from the repo root, followed by the rest of the path
+		info, dir, p = nil, "", path.Join(target, p)
+		if p == "." {
+			p = ""
+		}
+	}
+	return info, nil
+}
+
+// fsError returns the fs.PathError of err, which matches fs.ErrNotExist if err matches ErrNotExist.
+func fsError(op, name string, err error) error {
+	if errors.Is(err, ErrNotExist) && !errors.Is(err, fs.ErrNotExist) {
+		err = &notExistError{path: name, err: err}
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// fsFileInfo is the fs.FileInfo of a FileInfo, whose Sys returns the FileInfo (nil for the repo root).
+type fsFileInfo struct {
+	info *FileInfo
+	name string
+}
+
+// newFSFileInfo returns the fs.FileInfo of info, which is named after the base of the fs.FS path name.
+func newFSFileInfo(info *FileInfo, name string) *fsFileInfo {
+	return &fsFileInfo{info: info, name: path.Base(name)}
+}
+
+func (fi *fsFileInfo) Name() string {
+	return fi.name
+}
+
+func (fi *fsFileInfo) Size() int64 {
+	if fi.info == nil || fi.info.IsDir() {
+		return 0
+	}
+	return int64(fi.info.Size)
+}
+
+// Mode returns the mode of the file, which is read-only as the repository content can't be changed.
+func (fi *fsFileInfo) Mode() fs.FileMode {
+	switch {
+	case fi.info == nil || fi.info.IsDir():
+		return fs.ModeDir | 0555
+	case fi.info.Type == FileTypeSymlink:
+		return fs.ModeSymlink | 0777
+	case fi.info.Type == FileTypeSubmodule:
+		return fs.ModeIrregular | 0444
+	case fi.info.Executable:
+		return 0555
+	}
+	return 0444
+}
+
+// ModTime returns the zero time, as the modification time is not tracked by git.
+func (fi *fsFileInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (fi *fsFileInfo) IsDir() bool {
+	return fi.Mode().IsDir()
+}
+
+func (fi *fsFileInfo) Sys() any {
+	return fi.info
+}
+
+// fsFile is an opened file (other than a directory) of providerFS, whose content is either streamed from r, or held
+// in memory. The content streamed is read into memory on the first Seek, so that the file can be served by
+// http.FileServer via http.FS.
+type fsFile struct {
+	info    *fsFileInfo
+	r       io.ReadCloser
+	content *bytes.Reader
+	// open opens the content again, for the Seek after some of the content is read from r
+	open func() (io.ReadCloser, error)
+	// off is the number of bytes read from r
+	off int64
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *fsFile) Read(b []byte) (int, error) {
+	if f.content != nil {
+		return f.content.Read(b)
+	}
+	n, err := f.r.Read(b)
+	f.off += int64(n)
+	return n, err
+}
+
+func (f *fsFile) Seek(offset int64, whence int) (int64, error) {
+	if f.content == nil {
+		r := f.r
+		if f.off > 0 {
+			f.r.Close()
+			var err error
+			if r, err = f.open(); err != nil {
+				return 0, &fs.PathError{Op: "seek", Path: f.info.name, Err: err}
+			}
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return 0, &fs.PathError{Op: "seek", Path: f.info.name, Err: err}
+		}
+		f.r, f.content = nil, bytes.NewReader(content)
+		f.content.Seek(f.off, io.SeekStart)
+	}
+	return f.content.Seek(offset, whence)
+}
+
+func (f *fsFile) Close() error {
+	if f.r == nil {
+		return nil
+	}
+	return f.r.Close()
+}
+
+// fsDir is an opened directory of providerFS, whose entries are read on the first ReadDir.
+type fsDir struct {
+	fsys *providerFS
+	// path is the path of the directory in the repository
+	path    string
+	name    string
+	info    *fsFileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *fsDir) Close() error {
+	return nil
+}
+
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.readDir(d.path)
+		if err != nil {
+			return nil, fsError("readdir", d.name, err)
+		}
+		d.entries, d.read = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
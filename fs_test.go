@@ -0,0 +1,204 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotFS(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	snapshot, err := TakeSnapshot(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true})
+	require.NoError(t, err)
+	requests := srv.RequestCount()
+
+	fsys := snapshot.FS()
+	var paths []string
+	require.NoError(t, fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		require.NoError(t, err)
+		paths = append(paths, path)
+		return nil
+	}))
+	require.Equal(t, []string{".", "a", "b", "dir", "dir/c", "link_dir"}, paths)
+
+	b, err := fs.ReadFile(fsys, "dir/c")
+	require.NoError(t, err)
+	require.Equal(t, "content of c in dir\n", string(b))
+
+	fi, err := fs.Stat(fsys, "a")
+	require.NoError(t, err)
+	require.Equal(t, "a", fi.Name())
+	require.Equal(t, int64(len("content of a\n")), fi.Size())
+	require.Equal(t, fs.FileMode(0444), fi.Mode())
+
+	// The symlink is followed
+	entries, err := fs.ReadDir(fsys, "link_dir")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "c", entries[0].Name())
+
+	matches, err := fs.Glob(fsys, "*/c")
+	require.NoError(t, err)
+	require.Equal(t, []string{"dir/c", "link_dir/c"}, matches)
+
+	b, err = fs.ReadFile(fsys, "link_dir/c")
+	require.NoError(t, err)
+	require.Equal(t, "content of c in dir\n", string(b))
+
+	_, err = fsys.Open("nonexist")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+	require.True(t, errors.Is(err, ErrNotExist))
+	_, err = fsys.Open("../a")
+	require.True(t, errors.Is(err, fs.ErrInvalid))
+
+	// The FS serves the cached data only
+	require.Equal(t, requests, srv.RequestCount())
+}
+
+func TestFS(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	fsys, err := NewFS(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{BaseURL: srv.BaseURL()})
+	require.NoError(t, err)
+	require.Implements(t, (*fs.ReadDirFS)(nil), fsys)
+	require.Implements(t, (*fs.ReadFileFS)(nil), fsys)
+	require.Implements(t, (*fs.StatFS)(nil), fsys)
+	require.Implements(t, (*fs.GlobFS)(nil), fsys)
+	require.Implements(t, (*fs.SubFS)(nil), fsys)
+
+	// A file is read by a single request
+	requests := srv.RequestCount()
+	b, err := fs.ReadFile(fsys, "dir/c")
+	require.NoError(t, err)
+	require.Equal(t, "content of c in dir\n", string(b))
+	require.Equal(t, 1, srv.RequestCount()-requests)
+
+	b, err = fs.ReadFile(fsys, "link_dir/c")
+	require.NoError(t, err)
+	require.Equal(t, "content of c in dir\n", string(b))
+	_, err = fs.ReadFile(fsys, "dir")
+	require.Error(t, err)
+	_, err = fs.ReadFile(fsys, "nonexist")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+
+	cases := []struct {
+		pattern string
+		expect  []string
+	}{
+		{pattern: "*/c", expect: []string{"dir/c", "link_dir/c"}},
+		{pattern: "d*", expect: []string{"dir"}},
+		{pattern: "[ab]", expect: []string{"a", "b"}},
+		{pattern: "dir/c", expect: []string{"dir/c"}},
+		{pattern: "*/x", expect: nil},
+	}
+	for _, c := range cases {
+		matches, err := fs.Glob(fsys, c.pattern)
+		require.NoError(t, err, c.pattern)
+		require.Equal(t, c.expect, matches, c.pattern)
+	}
+	_, err = fs.Glob(fsys, "[")
+	require.Error(t, err)
+
+	for _, dir := range []string{"dir", "link_dir"} {
+		sub, err := fs.Sub(fsys, dir)
+		require.NoError(t, err, dir)
+		require.Implements(t, (*fs.ReadFileFS)(nil), sub, dir)
+		b, err := fs.ReadFile(sub, "c")
+		require.NoError(t, err, dir)
+		require.Equal(t, "content of c in dir\n", string(b), dir)
+		matches, err := fs.Glob(sub, "*")
+		require.NoError(t, err, dir)
+		require.Equal(t, []string{"c"}, matches, dir)
+	}
+	_, err = fs.Sub(fsys, "../x")
+	require.Error(t, err)
+}
+
+func TestFSConformance(t *testing.T) {
+	dir := newFixture(t, map[string]string{
+		"a":              "content of a\n",
+		"empty":          "",
+		"with space":     "space\n",
+		"sub/y":          "y\n",
+		"sub/deep/x.txt": "x\n",
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh\n"), 0755))
+	require.NoError(t, os.Symlink("a", filepath.Join(dir, "link_a")))
+	require.NoError(t, os.Symlink("sub", filepath.Join(dir, "link_sub")))
+	require.NoError(t, os.Symlink("../y", filepath.Join(dir, "sub", "deep", "link_y")))
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": dir})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	opt := &WalkOptions{BaseURL: srv.BaseURL()}
+	fsys, err := NewFS(ctx, "foo", "bar", "", opt)
+	require.NoError(t, err)
+	expect := []string{"a", "empty", "with space", "run.sh", "link_a", "link_sub", "sub/y", "sub/deep/x.txt", "sub/deep/link_y"}
+	require.NoError(t, fstest.TestFS(fsys, expect...))
+
+	sub, err := fs.Sub(fsys, "link_sub")
+	require.NoError(t, err)
+	require.NoError(t, fstest.TestFS(sub, "y", "deep/x.txt", "deep/link_y"))
+
+	snapshot, err := TakeSnapshot(ctx, "foo", "bar", "", &WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true})
+	require.NoError(t, err)
+	require.NoError(t, fstest.TestFS(snapshot.FS(), expect...))
+
+	fi, err := fs.Stat(fsys, "sub")
+	require.NoError(t, err)
+	require.Equal(t, fs.ModeDir|0555, fi.Mode())
+	require.True(t, fi.ModTime().IsZero())
+
+	// The symlinks are reported as is by Lstat and ReadLink, while followed by the others
+	type readLinkFS interface {
+		ReadLink(name string) (string, error)
+		Lstat(name string) (fs.FileInfo, error)
+	}
+	cases := []struct {
+		name   string
+		fsys   fs.FS
+		target string
+		mode   fs.FileMode
+	}{
+		{name: "link_a", fsys: fsys, target: "a", mode: fs.ModeSymlink | 0777},
+		{name: "link_sub", fsys: fsys, target: "sub", mode: fs.ModeSymlink | 0777},
+		{name: "link_sub/deep/link_y", fsys: fsys, target: "../y", mode: fs.ModeSymlink | 0777},
+		{name: "link_sub/y", fsys: fsys, mode: 0444},
+		{name: ".", fsys: fsys, mode: fs.ModeDir | 0555},
+		{name: "link_sub", fsys: snapshot.FS(), target: "sub", mode: fs.ModeSymlink | 0777},
+		// A symlink pointing to a file is captured as the file, as Github serves it
+		{name: "link_a", fsys: snapshot.FS(), mode: 0444},
+		{name: "link_sub/deep/link_y", fsys: snapshot.FS(), mode: 0444},
+	}
+	for _, c := range cases {
+		lfs := c.fsys.(readLinkFS)
+		fi, err := lfs.Lstat(c.name)
+		require.NoError(t, err, c.name)
+		require.Equal(t, c.mode, fi.Mode(), c.name)
+		target, err := lfs.ReadLink(c.name)
+		if c.target == "" {
+			require.True(t, errors.Is(err, fs.ErrInvalid), c.name)
+			continue
+		}
+		require.NoError(t, err, c.name)
+		require.Equal(t, c.target, target, c.name)
+	}
+	_, err = fsys.(readLinkFS).Lstat("nonexist/a")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
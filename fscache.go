@@ -0,0 +1,118 @@
+package ghwalk
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// ContentCache is a bounded in-memory cache of the files read via the fs.FS of NewFS (e.g. set as the ContentCache of
+// its WalkOptions), so that the repeated reads of the hot files, like the templates or the static assets served by
+// http.FileServer, don't hit the API. It can be shared by several fs.FS, and is safe for concurrent use.
+//
+// Unlike the Cache, which still revalidates each response by a conditional request, the cached files are served
+// without any request until they expire.
+type ContentCache struct {
+	maxBytes int64
+	ttl      time.Duration
+
+	// now is replaceable for testing
+	now func() time.Time
+
+	mu    sync.Mutex
+	size  int64
+	lru   *list.List
+	items map[string]*list.Element
+}
+
+type contentCacheItem struct {
+	key     string
+	info    *FileInfo
+	content []byte
+	expires time.Time
+}
+
+// NewContentCache returns a ContentCache holding up to maxBytes of the file content, where the least recently used
+// files are evicted beyond. The cached files expire after ttl (zero means never), after which they are read again,
+// e.g. to pick up the new commits of a branch.
+func NewContentCache(maxBytes int64, ttl time.Duration) *ContentCache {
+	return &ContentCache{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		now:      time.Now,
+		lru:      list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+// Len returns the number of the files cached, including the expired ones not evicted yet.
+func (c *ContentCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Size returns the total size of the content cached.
+func (c *ContentCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Purge removes all the cached files.
+func (c *ContentCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Init()
+	c.items = map[string]*list.Element{}
+	c.size = 0
+}
+
+// get returns the FileInfo and content of the file cached for the key, which is not found if expired.
+func (c *ContentCache) get(key string) (*FileInfo, []byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, nil, false
+	}
+	item := elem.Value.(*contentCacheItem)
+	if !item.expires.IsZero() && !c.now().Before(item.expires) {
+		c.remove(elem)
+		return nil, nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return item.info, item.content, true
+}
+
+// fits tells whether the content of the size can be cached.
+func (c *ContentCache) fits(size int64) bool {
+	return size <= c.maxBytes
+}
+
+// set caches the FileInfo and content of the file for the key, evicting the least recently used files to make room.
+func (c *ContentCache) set(key string, info *FileInfo, content []byte) {
+	if !c.fits(int64(len(content))) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+	item := &contentCacheItem{key: key, info: info, content: content}
+	if c.ttl > 0 {
+		item.expires = c.now().Add(c.ttl)
+	}
+	c.items[key] = c.lru.PushFront(item)
+	c.size += int64(len(content))
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *ContentCache) remove(elem *list.Element) {
+	item := c.lru.Remove(elem).(*contentCacheItem)
+	delete(c.items, item.key)
+	c.size -= int64(len(item.content))
+}
@@ -0,0 +1,127 @@
+package ghwalk
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentCache(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{
+		"foo/bar": newFixture(t, map[string]string{"a": "aaaaaaaaaa", "b": "bbbbbbbbbb", "large": "0123456789abcdefghij"}),
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	cache := NewContentCache(15, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	fsys, err := NewFS(ctx, "foo", "bar", "", &WalkOptions{BaseURL: srv.BaseURL(), ContentCache: cache})
+	require.NoError(t, err)
+
+	// readFile reads the file, and returns the number of requests made
+	readFile := func(name string, open bool) int {
+		requests := srv.RequestCount()
+		var b []byte
+		if open {
+			f, err := fsys.Open(name)
+			require.NoError(t, err, name)
+			b, err = io.ReadAll(f)
+			require.NoError(t, err, name)
+			require.NoError(t, f.Close())
+		} else {
+			b, err = fs.ReadFile(fsys, name)
+			require.NoError(t, err, name)
+		}
+		require.Len(t, b, map[string]int{"a": 10, "b": 10, "large": 20}[name], name)
+		return srv.RequestCount() - requests
+	}
+
+	require.NotZero(t, readFile("a", false))
+	require.Zero(t, readFile("a", false))
+	require.Zero(t, readFile("a", true))
+	fi, err := fs.Stat(fsys, "a")
+	require.NoError(t, err)
+	require.Equal(t, int64(10), fi.Size())
+	require.Equal(t, 1, cache.Len())
+
+	// The least recently used file is evicted beyond the size limit
+	require.NotZero(t, readFile("b", true))
+	require.Zero(t, readFile("b", false))
+	require.Equal(t, 1, cache.Len())
+	require.Equal(t, int64(10), cache.Size())
+	require.NotZero(t, readFile("a", false))
+
+	// The files larger than the limit are never cached
+	require.NotZero(t, readFile("large", true))
+	require.NotZero(t, readFile("large", false))
+	require.Equal(t, 1, cache.Len())
+
+	// The cached files expire after the TTL
+	require.Zero(t, readFile("a", true))
+	now = now.Add(time.Minute)
+	require.NotZero(t, readFile("a", true))
+	require.Zero(t, readFile("a", true))
+
+	cache.Purge()
+	require.Zero(t, cache.Len())
+	require.Zero(t, cache.Size())
+}
+
+func TestFSHTTP(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	cache := NewContentCache(1<<20, 0)
+	fsys, err := NewFS(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{BaseURL: srv.BaseURL(), ContentCache: cache})
+	require.NoError(t, err)
+	hsrv := httptest.NewServer(http.FileServer(http.FS(fsys)))
+	defer hsrv.Close()
+	// The files streamed without the cache are served as well
+	fsys, err = NewFS(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{BaseURL: srv.BaseURL()})
+	require.NoError(t, err)
+	uncached := httptest.NewServer(http.FileServer(http.FS(fsys)))
+	defer uncached.Close()
+
+	get := func(url, rng string) (int, string, string) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		if rng != "" {
+			req.Header.Set("Range", rng)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, resp.Header.Get("Content-Type"), string(b)
+	}
+
+	for _, url := range []string{hsrv.URL, uncached.URL} {
+		// The content type of a file without extension is sniffed, which seeks the file
+		code, ctype, body := get(url+"/dir/c", "")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "text/plain; charset=utf-8", ctype)
+		require.Equal(t, "content of c in dir\n", body)
+		code, _, body = get(url+"/dir/c", "bytes=13-")
+		require.Equal(t, http.StatusPartialContent, code)
+		require.Equal(t, "in dir\n", body)
+	}
+
+	// The cached file is served without any request
+	requests := srv.RequestCount()
+	code, _, body := get(hsrv.URL+"/dir/c", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "content of c in dir\n", body)
+	require.Equal(t, requests, srv.RequestCount())
+}
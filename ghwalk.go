@@ -1,15 +1,24 @@
 package ghwalk
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
 	"net/http"
 	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/go-github/v32/github"
-	"golang.org/x/oauth2"
 )
 
 // SkipDir is used as a return value from WalkFuncs to indicate that
@@ -17,63 +26,503 @@ import (
 // as an error by any function.
 var SkipDir = errors.New("skip this directory")
 
+// SkipAll is used as a return value from WalkFuncs to indicate that
+// all remaining files and directories are to be skipped. It is not returned
+// as an error by any function.
+var SkipAll = errors.New("skip everything and stop the walk")
+
+// ErrNotExist is the error (possibly wrapped) returned when the requested path doesn't exist in the repository.
+// Use errors.Is to check for it.
+var ErrNotExist = errors.New("no such path found")
+
+// ErrUnsupportedEncoding is returned by reading the content of a file that is encoded in an unknown way, which can be
+// downloaded by Open instead.
+var ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// ErrTooLarge is returned by reading the content of a file whose content is truncated by Github, see the Truncated
+// of FileOnlyInfo.
+var ErrTooLarge = errors.New("file content is too large to be returned inline")
+
 type WalkOptions struct {
-	// Github oauth2 access token
+	// Github oauth2 access token.
+	// If not specified, it is resolved by the following chain, where the first one found is used, unless
+	// DisableEnvironment is set:
+	//
+	//   - the GH_TOKEN or GITHUB_TOKEN environment variable, if the host of the BaseURL is the GH_HOST environment
+	//     variable (defaults to github.com), or the host of the GITHUB_API_URL environment variable
+	//   - the token stored by the Github CLI for the host of the BaseURL (see GHCLIToken)
+	//   - the password of the host in the netrc file ($NETRC, or ~/.netrc), where api.github.com is also looked up
+	//     for github.com
+	//   - the token of the host cached by DeviceFlow (see DeviceTokenFile)
+	//
+	// The chain is not resolved if Snapshot or Provider is set, as no API request is sent.
 	Token string
 
+	// FallbackTokens are the tokens to fall back to in turn, if the Token has no access to the repository walked,
+	// e.g. a personal access token for the repositories that a Github App is not installed on, when walking an
+	// organization with the installation token of the app. Picking the token costs an extra request per walk.
+	// It is ignored if Snapshot or Provider is set.
+	FallbackTokens []string
+
 	// Github git ref, can be a SHA, branch or a tag
 	Ref string
 
+	// Github API base URL, defaults to "https://api.github.com/".
+	// For Github Enterprise Server, it is of the form "https://github.example.com/api/v3/".
+	// If not specified, the GITHUB_API_URL environment variable is used, unless DisableEnvironment is set.
+	BaseURL string
+
+	// DisableEnvironment disables the fallback of Token and BaseURL to the environment, i.e. the environment variables,
+	// the Github CLI and the netrc file.
+	DisableEnvironment bool
+
+	// ValidateToken makes the walk verify the token and its access to the repository at the start, by ValidateToken,
+	// so that the authorization failures are reported by a *TokenError that tells the reason. It is ignored if
+	// Snapshot or Provider is set.
+	ValidateToken bool
+
+	// Logf, if set, is called to log the warnings of the walk, e.g. log.Printf. Currently, a warning is logged if the
+	// walk is unauthenticated (i.e. there is no Token), which is limited to 60 requests per hour by Github.
+	Logf func(format string, args ...interface{})
+
+	// Transport is the underlying HTTP transport used to send the API requests, defaults to http.DefaultTransport.
+	// It can be an RFC 7234 caching transport (e.g. httpcache.Transport), as the walker only adds the Authorization
+	// header on top of it, leaving the conditional request headers it sets untouched.
+	Transport http.RoundTripper
+
+	// Cache, if set, caches the API responses, which are revalidated by conditional requests on reuse. As a 304 Not
+	// Modified response doesn't count against the rate limit, this saves the quota for repeated walks. It works on
+	// top of the Transport, see NewMemoryCache for an in-memory one.
+	Cache Cache
+
+	// WaitRateLimit makes the walker sleep until the rate limit resets and retry the request, rather than failing
+	// with a rate limit error.
+	WaitRateLimit bool
+
+	// FetchRepoMetadata fetches the metadata of the repository at the start of the walk, which is reported by the
+	// WalkResult of WalkWithResult. It is ignored if Snapshot or Provider is set.
+	FetchRepoMetadata bool
+
+	// Strategy is the way to retrieve the repository content, see Strategy. It is ignored if Snapshot or Provider is
+	// set.
+	Strategy Strategy
+
+	// ArchiveFallback makes the walk, once a request is rejected by the rate limit, download the archive of the
+	// repository from the Github web host (which doesn't count against the API rate limit) and complete the rest of
+	// the walk from it, rather than failing. The archive is streamed, only the content of the files to fetch (see
+	// FetchContentFunc) is held in memory, and the FileInfo from it carries no URL. It implies PinCommit, so that the
+	// archive is of the same commit, and takes no effect along with WaitRateLimit.
+	//
+	// Note that the archive diverges from the repository as "git archive" does: the paths with the export-ignore git
+	// attribute (see SkipExportIgnore) are missing from it, and the files with the export-subst attribute have their
+	// placeholders (e.g. "$Format:%H$") expanded, so that their content, size and SHA differ.
+	ArchiveFallback bool
+
+	// OnRateLimit, if set, is called when a request is throttled by the rate limit, and when the walker starts sleeping
+	// and resumes afterwards (if WaitRateLimit is set).
+	OnRateLimit func(RateLimitEvent)
+
+	// RetryPolicy, if set, decides whether and when to retry the failed API requests, e.g. DefaultRetryPolicy.
+	// By default, the requests are not retried. Only the idempotent requests are retried, see WithIdempotent.
+	RetryPolicy RetryPolicy
+
+	// OnRetry, if set, is called for each failed API request while RetryPolicy is set, telling whether it is retried.
+	OnRetry func(RetryEvent)
+
+	// CircuitBreaker, if set, fails the API requests fast with ErrCircuitOpen once it is tripped by consecutive
+	// failures (after the retries, if any).
+	CircuitBreaker *CircuitBreaker
+
+	// Scheduler, if set, coordinates the API requests with the other walks sharing the same Scheduler, see
+	// RateLimitScheduler.
+	Scheduler *RateLimitScheduler
+
+	// Priority is the priority of the API requests in the Scheduler, the requests of a higher priority are sent
+	// first once the Scheduler holds them.
+	Priority int
+
+	// ContentCache, if set, caches the files read via the fs.FS of NewFS, see ContentCache.
+	ContentCache *ContentCache
+
+	// Snapshot, if set, is walked instead of the repository on Github, without any network access.
+	// The Token, Ref, BaseURL and Transport are ignored in this case.
+	Snapshot *Snapshot
+
+	// Provider, if set, provides the content to walk instead of the Github API. It takes precedence over Snapshot.
+	// The Token, Ref, BaseURL and Transport are ignored in this case.
+	Provider ContentProvider
+
 	// FileInfo of file (rather than dir) will contain file only FileInfo's
 	EnableFileOnlyInfo bool
 
+	// FetchContentFunc, if set, decides for each file (rather than dir) whether its FileInfo will contain the file
+	// only FileInfo, which costs an extra API call per file. The info argument has no FileOnlyInfo yet.
+	// It takes precedence over EnableFileOnlyInfo.
+	FetchContentFunc func(path string, info *FileInfo) bool
+
+	// InlineContentLimit, if greater than zero, is the size in bytes above which the file content is not inlined
+	// into the FileOnlyInfo. The FileOnlyInfo of such files only carries the DownloadURL (i.e. the Content is nil),
+	// which costs no extra API call, and the content can be read lazily via FileInfo.Open.
+	InlineContentLimit int
+
+	// TransformContent, if set, transforms the content of each file before it reaches the WalkFunc (via the
+	// FileOnlyInfo, or FileInfo.Open for the content not inlined), Mirror or Export, e.g. to strip secrets or convert
+	// the line endings. The transformed content replaces the FileOnlyInfo.Content without any encoding, while the Size
+	// and SHA still describe the file in the repository. Symlinks are not transformed.
+	TransformContent func(path string, r io.Reader) (io.Reader, error)
+
 	// Reverse search ordering
 	Reverse bool
+
+	// EntryOrder, if set, visits the subdirectories of a directory before its files, or vice versa. The entries of
+	// the same kind are visited in the lexical (or reversed) order.
+	EntryOrder EntryOrder
+
+	// PriorityFunc, if set, scores each entry of a directory, so that the entries with higher scores are visited
+	// first (e.g. "src" before "vendor"), which helps search-style walks that stop early (by SkipAll) to find the
+	// match with fewer API calls. The entries with the same score are visited in the EntryOrder and lexical (or
+	// reversed) order.
+	PriorityFunc func(path string, info *FileInfo) int
+
+	// NormalizeUnicode makes the walk report the paths in the Unicode Normalization Form C (NFC) to the WalkFunc,
+	// PathFilterFunc and OnSkip, as the repositories authored on macOS may contain the names in the decomposed form
+	// (NFD), which don't compare equal to the same names typed elsewhere. The FileInfo of such an entry carries the
+	// names as stored in the repository in its RawName and RawPath.
+	NormalizeUnicode bool
+
+	// At, if not zero, makes the walk happen at the latest commit at or before this time, on the branch specified
+	// by Ref (defaults to the default branch). For WalkCommits, it limits the commits to those at or before this time.
+	At time.Time
+
+	// PinCommit makes the walk resolve the Ref (defaults to the default branch) to the commit it points to at the
+	// start, and issue all the subsequent requests against that commit, so that the walk observes a consistent state
+	// even if the branch moves meanwhile. The commit is reported by the WalkResult of WalkWithResult.
+	PinCommit bool
+
+	// ListConcurrency, if greater than zero, is the number of workers that list the subdirectories of a directory
+	// ahead of the walk, concurrently.
+	ListConcurrency int
+
+	// ContentConcurrency, if greater than zero, is the number of workers that fetch the content (see
+	// EnableFileOnlyInfo) of the files in a directory ahead of the walk, concurrently.
+	//
+	// Either way, the WalkFunc is still called sequentially in the walk order. But the PathFilterFunc and
+	// FetchContentFunc are called for the entries of a directory before the WalkFunc is called for any of them, and
+	// the PriorityFunc, OnRateLimit and Provider (if any) must be safe for concurrent use.
+	ContentConcurrency int
+
+	// EnableGitAttributes makes the FileInfo carry the git attributes of the path in its Attributes, as defined by the
+	// .gitattributes files in the repository, which costs an extra API call per .gitattributes file (including the
+	// ones in the parent directories of the walked path).
+	EnableGitAttributes bool
+
+	// SkipExportIgnore makes Walk skip the paths with the "export-ignore" git attribute, as "git archive" does.
+	// It implies EnableGitAttributes.
+	SkipExportIgnore bool
+
+	// ErrorMode decides how the errors of the walk are handled, see ErrorMode.
+	ErrorMode ErrorMode
+
+	// CallbackTimeout, if greater than zero, is the time that each call of the WalkFunc is expected to finish within.
+	// The calls taking longer are reported to OnCallbackOverrun and counted by the WalkStats, and the context passed
+	// to the WalkContextFunc (see WalkContext) is done once the timeout passes. A WalkFunc can't be interrupted
+	// otherwise, the walk waits for it to return anyway.
+	CallbackTimeout time.Duration
+
+	// OnCallbackOverrun, if set, is called once a call of the WalkFunc has run for the CallbackTimeout without
+	// returning, which is usually while the call is still running (i.e. concurrently with it).
+	OnCallbackOverrun func(path string, elapsed time.Duration)
+
+	// SummarizeExtensions makes the WalkResult (see WalkWithResult) summarize the files by their extension, along with
+	// the summary by their type.
+	SummarizeExtensions bool
+
+	// DeadlineAware makes the walk budget its requests against the deadline of the context (if any), rather than
+	// failing wherever the deadline lands. The time of the remaining requests is estimated by the average latency of
+	// the requests so far:
+	//   - StrategyContents is replaced by StrategyTrees, which lists the whole tree with a single request.
+	//   - The file contents (see EnableFileOnlyInfo) are no longer fetched once the remaining ones can't be fetched
+	//     before the deadline, i.e. the FileInfo of the rest of the files has no FileOnlyInfo.
+	//   - The walk stops with a *DeadlineError once no more request can be sent before the deadline, whose Checkpoint
+	//     can be passed as the ResumeFrom to continue the walk later.
+	DeadlineAware bool
+
+	// MaxDuration, if greater than zero, is the wall-clock time that the walk runs for at most, e.g. to fit within the
+	// timeout of a CI step. Once it elapses, the walk stops cleanly before the next entry with a *DeadlineError, rather
+	// than being killed wherever the deadline of the context lands, and its ResumeToken can be passed as the
+	// ResumeToken to continue the walk later. The request in flight is not interrupted, set DeadlineAware as well to
+	// budget the requests against the MaxDuration (or the deadline of the context, if earlier).
+	MaxDuration time.Duration
+
+	// ResumeFrom, if set, resumes the walk stopped by a DeadlineError at its Checkpoint: the entries visited before
+	// the checkpoint (including the directories containing it) are not visited again. The other options are
+	// expected to be the same as the stopped walk.
+	ResumeFrom string
+
+	// ResumeToken, if set, resumes the walk stopped by a DeadlineError from its ResumeToken, i.e. at its Checkpoint
+	// (see ResumeFrom), and on the commit that the stopped walk is pinned to (if any). It overrides the ResumeFrom,
+	// and the Ref if a commit is pinned.
+	ResumeToken string
+
+	// DeferFunc, if set, selects the directories whose contents are not walked, but deferred to be walked later, e.g.
+	// by another worker. The deferred directories are visited without being listed, and returned as the Subtrees of
+	// the WalkResult (see WalkWithResult), each of which can be walked by Subtree.Walk. The walked path itself is
+	// never deferred. It may be called more than once for a directory, so it should be cheap and deterministic.
+	DeferFunc MatchFunc
+
+	// OnSkip, if set, is called for each entry that is not visited by Walk, along with the reason. The entries not
+	// visited because the walk stops (e.g. due to SkipAll or an error) are not reported.
+	OnSkip func(path string, reason SkipReason)
+
+	// PerPage is the page size of the paginated API requests, i.e. listing the commits, the tags and the repositories
+	// of an organization. It defaults to 100, which is also the maximum allowed by Github. A smaller page size helps
+	// the callers that stop early (e.g. by SkipAll) to send less data.
+	PerPage int
+
+	// MaxPages, if greater than zero, stops the paginated API requests after this many pages, which bounds the cost
+	// of listing e.g. a long history or a giant organization. The items beyond are silently left out.
+	MaxPages int
+
+	// EnableCommitFileInfo makes WalkCommits retrieve the FileInfo of the walked path as of each commit, which costs
+	// an extra API call per commit (two for files, if EnableFileOnlyInfo is also set).
+	EnableCommitFileInfo bool
 }
 
+// ErrorMode decides how the errors of a walk are handled, which matters the most for the concurrent walks (see
+// ListConcurrency and ContentConcurrency).
+type ErrorMode string
+
+const (
+	// ErrorModeWalkFunc passes each error to the WalkFunc, whose return value decides whether to continue, which is
+	// the default. The errors of the prefetches are passed when the walk reaches the failed entries, in walk order.
+	ErrorModeWalkFunc ErrorMode = ""
+	// ErrorModeFailFast stops the walk at the first error, including the one of any prefetch in the background, which
+	// also cancels all the other prefetches in progress. The walk returns the error, without passing it to the
+	// WalkFunc.
+	ErrorModeFailFast ErrorMode = "fail-fast"
+	// ErrorModeCollectAll walks everything regardless of the errors: the errors are passed to the WalkFunc, but the
+	// errors returned by it (other than SkipDir and SkipAll) don't stop the walk. Instead, they are collected and
+	// returned all together (joined by errors.Join) once the walk finishes.
+	ErrorModeCollectAll ErrorMode = "collect-all"
+)
+
+// EntryOrder decides whether the subdirectories or the files of a directory are visited first.
+type EntryOrder string
+
+const (
+	// EntryOrderLexical visits the entries in the lexical order regardless of their types, which is the default.
+	EntryOrderLexical EntryOrder = ""
+	// EntryOrderDirsFirst visits the subdirectories before the files (including symlinks and submodules).
+	EntryOrderDirsFirst EntryOrder = "dirs-first"
+	// EntryOrderFilesFirst visits the files (including symlinks and submodules) before the subdirectories.
+	EntryOrderFilesFirst EntryOrder = "files-first"
+)
+
 type FileType string
 
 const (
-	FileTypeFile    FileType = "file"
-	FileTypeDir     FileType = "dir"
-	FileTypeSymlink FileType = "symlink"
+	FileTypeFile      FileType = "file"
+	FileTypeDir       FileType = "dir"
+	FileTypeSymlink   FileType = "symlink"
+	FileTypeSubmodule FileType = "submodule"
 )
 
+// FileInfo describes a file or directory. Its JSON form is part of the schema of SchemaVersion, see Snapshot.
 type FileInfo struct {
-	raw github.RepositoryContent
+	// origin is set if the FileInfo is retrieved from Github
+	origin *origin
+	// downloadURL is the download URL returned by the directory listing, if any
+	downloadURL string
+
+	Type    FileType `json:"Type"`
+	Size    int      `json:"Size"`
+	Name    string   `json:"Name"`
+	Path    string   `json:"Path"`
+	SHA     string   `json:"SHA"`
+	URL     string   `json:"URL"`
+	GitURL  string   `json:"GitURL"`
+	HTMLURL string   `json:"HTMLURL"`
+
+	// Executable tells whether the file has the git mode 100755. It is only known if the FileInfo comes from the Git
+	// Trees API, GraphQL or the archive, as the Contents API doesn't report the file mode.
+	Executable bool `json:"Executable,omitempty"`
 
-	Type    FileType
-	Size    int
-	Name    string
-	Path    string
-	SHA     string
-	URL     string
-	GitURL  string
-	HTMLURL string
+	// RawName and RawPath are the name and path as stored in the repository, only set if they differ from the Name
+	// and Path normalized by the NormalizeUnicode of WalkOptions.
+	RawName string `json:"RawName,omitempty"`
+	RawPath string `json:"RawPath,omitempty"`
 
-	FileOnlyInfo *FileOnlyInfo
+	// Attributes are the git attributes of the path, only set if the EnableGitAttributes of WalkOptions is set.
+	// The value of a set attribute is "true", and the one of an unset attribute (e.g. "-text") is "false".
+	Attributes map[string]string `json:"Attributes,omitempty"`
+
+	FileOnlyInfo *FileOnlyInfo `json:"FileOnlyInfo"`
 }
 
 type FileOnlyInfo struct {
 	// Target is only set if the type is "symlink" and the target is not a normal file.
-	Target *string
+	Target *string `json:"Target"`
 	// Encoding is only set if the type is "file" (but not "symlink")
-	Encoding *string
+	Encoding *string `json:"Encoding"`
 	// Content contains the actual file content, which may be encoded.
 	// Callers should call GetContent which will decode the content if
 	// necessary.
 	//
 	// Content is only set if the type is "file" (but not "symlink")
-	Content     *string
-	DownloadURL string
+	Content     *string `json:"Content"`
+	DownloadURL string  `json:"DownloadURL"`
+
+	// Truncated tells that Github has omitted the content, as the file is too large (i.e. larger than 1 MB) to be
+	// returned by the Contents API. Reading the content fails with ErrTooLarge then, while Open downloads it from
+	// the DownloadURL.
+	Truncated bool `json:"Truncated,omitempty"`
+}
+
+// UnmarshalJSON decodes the FileInfo of the JSON schema of any version up to SchemaVersion, the unknown fields (e.g.
+// added compatibly by a later version) are ignored.
+func (f *FileInfo) UnmarshalJSON(b []byte) error {
+	// The alias type has no UnmarshalJSON, which avoids the recursion
+	type fileInfo FileInfo
+	var v fileInfo
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch v.Type {
+	case FileTypeFile, FileTypeDir, FileTypeSymlink, FileTypeSubmodule:
+	default:
+		return fmt.Errorf("unknown file type %q of %s", v.Type, v.Path)
+	}
+	*f = FileInfo(v)
+	return nil
 }
 
 func (f *FileInfo) IsDir() bool {
 	return f.Type == FileTypeDir
 }
 
+// GetContent returns the decoded content of the file as a string. It is only available when the FileOnlyInfo is set.
 func (f *FileInfo) GetContent() (string, error) {
-	return f.raw.GetContent()
+	r, err := f.ContentReader()
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.Grow(f.Size)
+	if _, err := io.Copy(&sb, r); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// GetContentBytes returns the decoded content of the file. It is only available when the FileOnlyInfo is set.
+// Unlike GetContent, the content is never converted to a string, which is preferred for binary files.
+func (f *FileInfo) GetContentBytes() ([]byte, error) {
+	r, err := f.ContentReader()
+	if err != nil {
+		return nil, err
+	}
+	if f.FileOnlyInfo == nil || f.FileOnlyInfo.Content == nil {
+		return nil, nil
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, f.Size))
+	if _, err := io.Copy(buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ContentReader returns a reader of the decoded content of the file. It is only available when the FileOnlyInfo is set.
+// The content is decoded while being read, so that the decoded content is never held in memory as a whole.
+func (f *FileInfo) ContentReader() (io.Reader, error) {
+	encoding := f.GetEncoding()
+	if f.FileOnlyInfo != nil && f.FileOnlyInfo.Truncated {
+		return nil, fmt.Errorf("%w: %s (%d bytes), open it to download the content", ErrTooLarge, f.Path, f.Size)
+	}
+	if f.FileOnlyInfo == nil || f.FileOnlyInfo.Content == nil {
+		if encoding == "base64" {
+			return nil, errors.New("malformed response: base64 encoding of null content")
+		}
+		return strings.NewReader(""), nil
+	}
+
+	content := *f.FileOnlyInfo.Content
+	switch encoding {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, strings.NewReader(content)), nil
+	case "", "none":
+		return strings.NewReader(content), nil
+	default:
+		return nil, fmt.Errorf("%w: %v, open it to download the content", ErrUnsupportedEncoding, encoding)
+	}
+}
+
+// decodable tells whether the content can be read without downloading it.
+func (f *FileInfo) decodable() bool {
+	if f.FileOnlyInfo == nil || f.FileOnlyInfo.Truncated {
+		return false
+	}
+	switch f.GetEncoding() {
+	case "base64", "", "none":
+		return true
+	}
+	return false
+}
+
+// Open returns a reader of the decoded content of the file. It is only available when the FileOnlyInfo is set.
+// If the content is not inlined in the FileOnlyInfo (see InlineContentLimit), it is downloaded from the DownloadURL
+// while being read. The caller should close the reader.
+func (f *FileInfo) Open(ctx context.Context) (io.ReadCloser, error) {
+	if f.FileOnlyInfo == nil {
+		return nil, fmt.Errorf("the file only info of %s is not available", f.Path)
+	}
+	if (f.FileOnlyInfo.Content != nil && f.decodable()) || f.FileOnlyInfo.DownloadURL == "" {
+		r, err := f.ContentReader()
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(r), nil
+	}
+
+	var opt *WalkOptions
+	if f.origin != nil {
+		opt = f.origin.opt
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.FileOnlyInfo.DownloadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := newHTTPClient(opt).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, errNoSuchPath(f.Path)
+		}
+		return nil, fmt.Errorf("downloading %s: %s", f.Path, resp.Status)
+	}
+	if opt == nil || opt.TransformContent == nil || f.Type != FileTypeFile {
+		return resp.Body, nil
+	}
+	r, err := opt.TransformContent(f.Path, resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("transforming %s: %w", f.Path, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, resp.Body}, nil
+}
+
+// GetEncoding returns the encoding of the file content returned by Github (e.g. "base64"), or an empty string if
+// the content is not encoded or the FileOnlyInfo is not set.
+func (f *FileInfo) GetEncoding() string {
+	if f.FileOnlyInfo == nil || f.FileOnlyInfo.Encoding == nil {
+		return ""
+	}
+	return *f.FileOnlyInfo.Encoding
 }
 
 // WalkFunc is the type of the function called for each file or directory
@@ -86,11 +535,12 @@ func (f *FileInfo) GetContent() (string, error) {
 // incoming error will describe the problem and the function can decide how
 // to handle that error (and Walk will not descend into that directory). In the
 // case of an error, the info argument will be nil. If an error is returned,
-// processing stops. The sole exception is when the function returns the special
-// value SkipDir. If the function returns SkipDir when invoked on a directory,
+// processing stops. The sole exceptions are when the function returns the special
+// value SkipDir or SkipAll. If the function returns SkipDir when invoked on a directory,
 // Walk skips the directory's contents entirely. If the function returns SkipDir
 // when invoked on a non-directory file, Walk skips the remaining files in the
-// containing directory.
+// containing directory. If the function returns SkipAll, Walk skips all
+// remaining files and directories.
 //
 // Especially, for the FileInfo is nil when WalkFunc is called on the root path
 // of the repository.
@@ -108,47 +558,440 @@ type PathFilterFunc func(path string, info *FileInfo) bool
 // large directories Walk can be inefficient.
 // Walk does not follow symbolic links.
 func Walk(ctx context.Context, owner, repo, path string, opt *WalkOptions, walkFn WalkFunc, filterFn PathFilterFunc) error {
+	_, err := WalkWithResult(ctx, owner, repo, path, opt, walkFn, filterFn)
+	return err
+}
+
+// WalkContextFunc is the same as WalkFunc, except that it also receives the context of the walk, which is done once
+// the walk ends (e.g. it fails or is cancelled), so that the function can issue its own context-aware calls.
+type WalkContextFunc func(ctx context.Context, path string, info *FileInfo, err error) error
+
+// WalkContext is the same as Walk, except that walkFn receives the context of the walk. If the CallbackTimeout of opt
+// is set, the context is also done once the timeout of each call passes, so that a slow call can be cancelled.
+func WalkContext(ctx context.Context, owner, repo, path string, opt *WalkOptions, walkFn WalkContextFunc, filterFn PathFilterFunc) error {
+	w := &walker{filterFn: filterFn}
+	w.walkFn = func(path string, info *FileInfo, err error) error {
+		return walkFn(w.callCtx, path, info, err)
+	}
+	_, err := runWalk(ctx, owner, repo, path, opt, w)
+	return err
+}
+
+// WalkResult describes what a walk has walked.
+type WalkResult struct {
+	// Owner and Repo are the canonical owner and name of the repository walked. They differ from the ones passed to
+	// the walk if the repository has been renamed or transferred, in which case Github redirects the requests against
+	// the old name, and the walk follows the redirects.
+	Owner string
+	Repo  string
+	// Repository is the metadata of the repository walked, which is only set if the FetchRepoMetadata of the
+	// WalkOptions is set, or the repository is found renamed.
+	Repository *RepoMetadata
+	// CommitSHA is the SHA of the commit walked, which is only set if the PinCommit or At of the WalkOptions is set.
+	CommitSHA string
+	// RootTreeSHA is the SHA of the root tree of the commit walked, which is only set along with CommitSHA.
+	RootTreeSHA string
+	// Entries is the number of the files and directories visited, excluding the repo root.
+	Entries int
+	// Strategy is the strategy used to retrieve the repository content, which is the one picked if StrategyAuto is
+	// specified.
+	Strategy Strategy
+	Stats    WalkStats
+	// Types summarizes the entries visited (excluding the repo root) by their FileType.
+	Types map[FileType]TypeSummary
+	// Extensions summarizes the files (i.e. of FileTypeFile) visited by their extension in lower case, e.g. ".go",
+	// or "" for no extension. It is only set if the SummarizeExtensions of the WalkOptions is set.
+	Extensions map[string]TypeSummary
+	// Subtrees are the directories deferred by the DeferFunc of the WalkOptions, in walk order.
+	Subtrees []Subtree
+	// Partial tells that the walk has failed before visiting everything, e.g. the context is cancelled.
+	Partial bool
+}
+
+// TypeSummary is the number and the total size of the entries of a kind, see the Types of WalkResult.
+type TypeSummary struct {
+	Count int
+	// Bytes is the total of the Size of the FileInfo, which is zero for the directories and submodules.
+	Bytes int64
+}
 
-	var tc *http.Client
+// summarize adds the visited entry to the summaries of the result.
+func (r *WalkResult) summarize(info *FileInfo, extensions bool) {
+	if r.Types == nil {
+		r.Types = map[FileType]TypeSummary{}
+	}
+	s := r.Types[info.Type]
+	s.Count++
+	s.Bytes += int64(info.Size)
+	r.Types[info.Type] = s
 
-	// construct the github client
-	if opt != nil && opt.Token != "" {
-		ts := oauth2.StaticTokenSource(
-			&oauth2.Token{AccessToken: opt.Token},
-		)
-		tc = oauth2.NewClient(ctx, ts)
+	if !extensions || info.Type != FileTypeFile {
+		return
+	}
+	if r.Extensions == nil {
+		r.Extensions = map[string]TypeSummary{}
 	}
+	ext := strings.ToLower(filepath.Ext(info.Name))
+	s = r.Extensions[ext]
+	s.Count++
+	s.Bytes += int64(info.Size)
+	r.Extensions[ext] = s
+}
+
+// WalkStats is the statistics of a walk.
+type WalkStats struct {
+	// Files is the number of the non-directory entries visited, including symlinks and submodules.
+	Files int
+	// Dirs is the number of the directories visited, excluding the repo root.
+	Dirs int
+	// Errors is the number of the errors passed to the WalkFunc.
+	Errors int
+	// Requests is the number of the HTTP requests sent to Github.
+	Requests int
+	// CallbackOverruns is the number of the WalkFunc calls that have taken longer than the CallbackTimeout.
+	CallbackOverruns int
+	// RateLimit is the rate limit status observed from the responses.
+	RateLimit RateLimitStats
+}
+
+// WalkWithResult is the same as Walk, except that it also returns the WalkResult, which is nil if the walk fails
+// to start. If the walk fails afterwards (e.g. the context is cancelled), the result accumulated so far is returned
+// along with the error, with Partial set.
+func WalkWithResult(ctx context.Context, owner, repo, path string, opt *WalkOptions, walkFn WalkFunc, filterFn PathFilterFunc) (*WalkResult, error) {
+	return runWalk(ctx, owner, repo, path, opt, &walker{walkFn: walkFn, filterFn: filterFn})
+}
+
+// walker holds the state of a walk.
+type walker struct {
+	// ctx is the context of the walk, which is done once the walk ends.
+	ctx context.Context
+	// callCtx is the context of the WalkFunc call in progress, which is done once the CallbackTimeout (if any) passes.
+	callCtx  context.Context
+	owner    string
+	repo     string
+	p        ContentProvider
+	opt      *WalkOptions
+	walkFn   WalkFunc
+	filterFn PathFilterFunc
+
+	// onLeave, if set, is called after the entries of a directory have been walked, or the remaining ones have been
+	// skipped by SkipDir.
+	onLeave func(path string, info *FileInfo)
+
+	// onSkip, if set, is called for each entry not visited, along with the OnSkip of the WalkOptions.
+	onSkip func(path string, info *FileInfo, reason SkipReason)
 
-	client := github.NewClient(tc)
+	// listSem and contentSem limit the concurrent directory listings and content fetches, they are nil if the
+	// corresponding concurrency is not enabled.
+	listSem    chan struct{}
+	contentSem chan struct{}
 
-	info, err := stat(ctx, owner, repo, path, client, opt)
+	// attrs is nil unless the git attributes are enabled.
+	attrs *gitAttributes
+
+	// cancel stops the walk, failErr is the error that the walk fails with in ErrorModeFailFast.
+	cancel  context.CancelFunc
+	failMu  sync.Mutex
+	failErr error
+	// errs are the errors collected in ErrorModeCollectAll.
+	errs []error
+
+	// budget is nil unless the walk is DeadlineAware with a deadline, or has a MaxDuration. freeListing tells that listing a directory
+	// costs no request, i.e. the whole tree has been retrieved.
+	budget      *deadlineBudget
+	freeListing bool
+
+	// root is the walked path, subtrees are the directories deferred by the DeferFunc.
+	root     string
+	subtrees []Subtree
+}
+
+// runWalk walks path with the walkFn, filterFn and hooks of w, the rest of w is set up here.
+func runWalk(ctx context.Context, owner, repo, path string, opt *WalkOptions, w *walker) (*WalkResult, error) {
+	path, err := cleanRepoPath(owner, repo, path)
 	if err != nil {
-		err = walkFn(path, nil, err)
-	} else {
-		if filterFn != nil && filterFn(path, info) {
+		return nil, err
+	}
+	if opt != nil && opt.NormalizeUnicode {
+		w.normalize()
+	}
+	result := &WalkResult{Owner: owner, Repo: repo}
+
+	// Count the requests and detect the redirects by wrapping the transport
+	var countOpt WalkOptions
+	if opt != nil {
+		countOpt = *opt
+	}
+	if err := applyResumeToken(&countOpt); err != nil {
+		return nil, err
+	}
+	budget := newDeadlineBudget(ctx, opt)
+	if budget != nil {
+		countOpt.Transport = &budgetTransport{base: countOpt.Transport, budget: budget}
+		// Listing the whole tree at once saves a request per directory
+		if budget.aware && countOpt.Strategy == StrategyContents {
+			countOpt.Strategy = StrategyTrees
+		}
+	}
+	var redirected atomic.Bool
+	rateLimitStats := &rateLimitStatsTransport{
+		base:  &countingTransport{base: countOpt.Transport, count: &result.Stats.Requests},
+		stats: &result.Stats.RateLimit,
+	}
+	countOpt.Transport = &redirectTransport{base: rateLimitStats, redirected: &redirected}
+	countOpt.OnRateLimit = rateLimitStats.onRateLimit(countOpt.OnRateLimit)
+	if len(countOpt.FallbackTokens) > 0 && countOpt.Snapshot == nil && countOpt.Provider == nil {
+		token, err := selectToken(ctx, owner, repo, &countOpt)
+		if err != nil {
+			return nil, err
+		}
+		countOpt.Token = token
+	}
+	var anonymous *anonymousTransport
+	// The token is not resolved for a snapshot or provider, which doesn't touch the API
+	if countOpt.Snapshot == nil && countOpt.Provider == nil && accessToken(&countOpt) == "" {
+		anonymous = &anonymousTransport{base: countOpt.Transport, logf: countOpt.Logf}
+		countOpt.Transport = anonymous
+	}
+	if countOpt.ArchiveFallback {
+		countOpt.PinCommit = true
+	}
+
+	if countOpt.ValidateToken && countOpt.Snapshot == nil && countOpt.Provider == nil {
+		if _, err := ValidateToken(ctx, owner, repo, &countOpt); err != nil {
+			return nil, err
+		}
+	}
+	if countOpt.FetchRepoMetadata && countOpt.Snapshot == nil && countOpt.Provider == nil {
+		metadata, err := GetRepoMetadata(ctx, owner, repo, &countOpt)
+		if err != nil {
+			return nil, err
+		}
+		result.Repository = metadata
+		result.Owner, result.Repo = metadata.Owner, metadata.Name
+	}
+
+	resolved, commit, err := resolveCommit(ctx, owner, repo, &countOpt)
+	if err != nil {
+		return nil, err
+	}
+	if commit != nil {
+		result.CommitSHA = commit.GetSHA()
+		result.RootTreeSHA = commit.GetCommit().GetTree().GetSHA()
+	}
+	opt = resolved
+
+	rootPath := path
+	var diagnosed bool
+	walkFn := w.walkFn
+	w.walkFn = func(path string, info *FileInfo, err error) error {
+		// Tell why the walked path is not found, as Github doesn't
+		if err != nil && path == rootPath && !diagnosed && opt.Snapshot == nil && opt.Provider == nil && errors.Is(err, ErrNotExist) {
+			diagnosed = true
+			err = diagnoseNotExist(ctx, owner, repo, opt, err)
+		}
+		switch {
+		case err != nil:
+			result.Stats.Errors++
+		case info == nil:
+			// repo root
+		case info.IsDir():
+			result.Stats.Dirs++
+			result.Entries++
+			result.summarize(info, opt.SummarizeExtensions)
+		default:
+			result.Stats.Files++
+			result.Entries++
+			result.summarize(info, opt.SummarizeExtensions)
+		}
+		if err != nil && opt.ErrorMode == ErrorModeFailFast {
+			w.fail(err)
+			return err
+		}
+		err, overrun := w.call(walkFn, path, info, err)
+		if overrun {
+			result.Stats.CallbackOverruns++
+		}
+		if err != nil && err != SkipDir && err != SkipAll && opt.ErrorMode == ErrorModeCollectAll {
+			w.errs = append(w.errs, err)
 			return nil
 		}
-		err = walk(ctx, owner, repo, path, client, opt, info, walkFn, filterFn)
+		return err
 	}
 
-	if err == SkipDir {
-		return nil
+	p, strategy, err := newStrategyProvider(ctx, owner, repo, path, opt, w.filterFn)
+	if err != nil {
+		return nil, err
+	}
+	result.Strategy = strategy
+	if opt.ArchiveFallback && commit != nil && strategy != StrategyArchive {
+		p = &archiveFallbackProvider{ContentProvider: p, ref: commit.GetSHA(), opt: opt}
+	}
+	w.owner, w.repo, w.p, w.opt, w.root = owner, repo, p, opt, path
+	w.budget, w.freeListing = budget, strategy != StrategyContents
+	if tp, ok := p.(*treesProvider); ok && budget != nil {
+		if sp, ok := tp.tree.(*snapshotProvider); ok {
+			budget.fetches = estimateWalk(sp.snapshot.Entries, path, opt, w.filterFn).fetches
+		}
+	}
+	if opt != nil && opt.ListConcurrency > 0 {
+		w.listSem = make(chan struct{}, opt.ListConcurrency)
+	}
+	if opt != nil && opt.ContentConcurrency > 0 {
+		w.contentSem = make(chan struct{}, opt.ContentConcurrency)
+	}
+	// Stop the prefetching once the walk ends
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w.ctx, w.cancel = ctx, cancel
+
+	if opt != nil && (opt.EnableGitAttributes || opt.SkipExportIgnore) {
+		w.attrs = &gitAttributes{}
+		// The .gitattributes of the walked path itself (if a directory) is loaded when it is walked, while the ones of
+		// its ancestors are loaded here, from its parent up. A path of n components has exactly n ancestors, the last
+		// one being the repo root, so the loop ends right after loading the repo root, and doesn't run for the root.
+		dir, ancestors := path, 0
+		if path != "" {
+			ancestors = strings.Count(path, "/") + 1
+		}
+		for range ancestors {
+			dir = parentDir(dir)
+			if err := w.attrs.load(ctx, owner, repo, dir, p); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	info, err := stat(ctx, owner, repo, path, p, opt)
+	if err != nil && anonymous != nil && isRateLimitError(err) {
+		err = anonymous.budgetError(err)
+	}
+	if redirected.Load() && result.Repository == nil {
+		metadata, rerr := GetRepoMetadata(ctx, owner, repo, opt)
+		if rerr != nil {
+			return nil, fmt.Errorf("resolving the renamed repository %s/%s: %w", owner, repo, rerr)
+		}
+		result.Repository = metadata
+		result.Owner, result.Repo = metadata.Owner, metadata.Name
+	}
+	if err != nil {
+		err = w.walkFn(path, nil, err)
+	} else {
+		if info != nil && w.attrs != nil {
+			info.Attributes = w.attrs.attributes(path, info.IsDir())
+		}
+		if reason := w.skipReason(path, info); reason != "" {
+			w.skip(path, info, reason)
+			return result, nil
+		}
+		err = w.walk(ctx, path, info, nil)
+	}
+
+	// The walk stopped by the failure returns the context error
+	w.failMu.Lock()
+	if err != nil && err != SkipDir && err != SkipAll && w.failErr != nil {
+		err = w.failErr
+	}
+	w.failMu.Unlock()
+	if err == SkipDir || err == SkipAll {
+		err = nil
+	}
+	if len(w.errs) > 0 {
+		err = errors.Join(append(w.errs, err)...)
+	}
+	result.Subtrees = w.subtrees
+	if err != nil {
+		result.Partial = true
+	}
+	var derr *DeadlineError
+	if commit != nil && errors.As(err, &derr) {
+		derr.Commit = commit.GetSHA()
+	}
+	return result, err
+}
+
+// call calls walkFn, with the context of the call set up. It also tells whether the call overruns the
+// CallbackTimeout, which is reported to the OnCallbackOverrun.
+func (w *walker) call(walkFn WalkFunc, path string, info *FileInfo, err error) (error, bool) {
+	timeout := w.opt.CallbackTimeout
+	if timeout <= 0 {
+		w.callCtx = w.ctx
+		return walkFn(path, info, err), false
+	}
+
+	start := time.Now()
+	callCtx, cancel := context.WithTimeout(w.ctx, timeout)
+	defer cancel()
+	w.callCtx = callCtx
+	var timer *time.Timer
+	reported := make(chan struct{})
+	if w.opt.OnCallbackOverrun != nil {
+		timer = time.AfterFunc(timeout, func() {
+			defer close(reported)
+			w.opt.OnCallbackOverrun(path, timeout)
+		})
+	}
+	err = walkFn(path, info, err)
+	elapsed := time.Since(start)
+	if timer != nil {
+		if timer.Stop() {
+			// The call may return right at the timeout, before the timer fires
+			if elapsed >= timeout {
+				w.opt.OnCallbackOverrun(path, elapsed)
+			}
+		} else {
+			<-reported
+		}
+	}
+	return err, elapsed >= timeout
+}
+
+// fail records the first error of the walk in ErrorModeFailFast, and stops the walk.
+func (w *walker) fail(err error) {
+	w.failMu.Lock()
+	defer w.failMu.Unlock()
+	if w.failErr == nil {
+		w.failErr = err
+		w.cancel()
 	}
-	return err
 }
 
-func walk(ctx context.Context, owner, repo, path string, client *github.Client, opt *WalkOptions, info *FileInfo, walkFn WalkFunc, filterFn PathFilterFunc) error {
+func (w *walker) walk(ctx context.Context, path string, info *FileInfo, listing *future[[]*FileInfo]) error {
 	// If walk is called against the repo root, the info is nil
 	if info != nil && !info.IsDir() {
-		return walkFn(path, info, nil)
+		return w.walkFn(path, info, nil)
+	}
+	if w.deferred(path, info) {
+		err := w.walkFn(path, info, nil)
+		if err == nil {
+			w.subtrees = append(w.subtrees, Subtree{Owner: w.owner, Repo: w.repo, Ref: w.opt.Ref, Path: path, Info: info})
+			w.skip(path, info, SkipReasonDeferred)
+		}
+		return err
 	}
 
-	entries, err := readDirEntries(ctx, owner, repo, path, client, opt)
-	err1 := walkFn(path, info, err)
+	var entries []*FileInfo
+	var err error
+	if listing != nil {
+		entries, err = listing.wait()
+	} else {
+		entries, err = readDirEntries(ctx, w.owner, w.repo, path, w.p, w.opt)
+	}
+	if err == nil && w.attrs != nil {
+		err = w.loadAttributes(ctx, path, entries)
+	}
+	var err1 error
+	// The directories containing the checkpoint have been visited before it
+	if err != nil || !isResumedAncestor(w.opt.ResumeFrom, path) {
+		err1 = w.walkFn(path, info, err)
+	}
 	// If err != nil, walk can't walk into this directory.
 	// err1 != nil means walkFn want walk to skip this directory or stop walking.
 	// Therefore, if one of err and err1 isn't nil, walk will return.
 	if err != nil || err1 != nil {
+		if err == nil && err1 == SkipDir {
+			w.skip(path, info, SkipReasonSkipDir)
+		}
 		// The caller's behavior is controlled by the return value, which is decided
 		// by walkFn. walkFn may ignore err and return nil.
 		// If walkFn returns SkipDir, it will be handled by the caller.
@@ -156,41 +999,173 @@ func walk(ctx context.Context, owner, repo, path string, client *github.Client,
 		return err1
 	}
 
-	for _, entry := range entries {
+	// pf is nil unless the walk is concurrent
+	pf := w.prefetch(ctx, path, entries)
+
+	for i, entry := range entries {
 		filename := filepath.Join(path, entry.Name)
+		if resumeSkips(w.opt.ResumeFrom, filename, entries, i, w.opt) {
+			continue
+		}
 
-		if filterFn != nil && filterFn(filename, &entry) {
+		var reason SkipReason
+		var fetch bool
+		if pf != nil {
+			reason, fetch = pf.skipReasons[i], pf.fetch[i]
+		} else {
+			reason = w.skipReason(filename, entry)
+			fetch = reason == "" && w.fetchContent(filename, entry)
+		}
+		if reason != "" {
+			w.skip(filename, entry, reason)
 			continue
 		}
+		if w.budget != nil && w.budget.expired() {
+			return &DeadlineError{Checkpoint: filename}
+		}
+		if w.budget != nil && !w.budget.affords(1) {
+			prefetched := pf != nil && (pf.contents[i] != nil || pf.listings[i] != nil)
+			if !prefetched && (fetch || (entry.IsDir() && !w.freeListing)) {
+				return &DeadlineError{Checkpoint: filename}
+			}
+		}
 
-		fileInfo, err := stat(ctx, owner, repo, filename, client, opt)
+		// The directory listing already contains the metadata of the entry, only the file only info
+		// (if requested) needs another API call.
+		fileInfo := entry
+		var listing *future[[]*FileInfo]
+		// The error is scoped to the entry, so that neither a swallowed error nor a SkipDir carries over to the next one
+		var err error
+		switch {
+		case pf != nil && pf.contents[i] != nil:
+			fileInfo, err = pf.contents[i].wait()
+		case fetch:
+			fileInfo, err = readFile(ctx, w.owner, w.repo, filename, w.p, w.opt, entry)
+		case pf != nil:
+			listing = pf.listings[i]
+		}
 		if err != nil {
-			if err := walkFn(filename, fileInfo, err); err != nil && err != SkipDir {
+			if err := w.walkFn(filename, fileInfo, err); err != nil && err != SkipDir {
 				return err
 			}
 		} else {
-			err = walk(ctx, owner, repo, filename, client, opt, fileInfo, walkFn, filterFn)
+			err = w.walk(ctx, filename, fileInfo, listing)
 			if err != nil {
 				if !fileInfo.IsDir() || err != SkipDir {
+					if err == SkipDir {
+						// The remaining entries are skipped
+						for _, entry := range entries[i+1:] {
+							w.skip(filepath.Join(path, entry.Name), entry, SkipReasonSkipDir)
+						}
+						w.leave(path, info)
+					}
 					return err
 				}
 			}
 		}
 	}
+	w.leave(path, info)
 	return nil
 }
 
-func newFileInfo(c github.RepositoryContent, includeDetail bool) *FileInfo {
+// fetchContent tells whether the FileOnlyInfo of the file should be retrieved, which is given up if the deadline
+// budget can't afford it.
+func (w *walker) fetchContent(path string, info *FileInfo) bool {
+	if !w.opt.fetchContent(path, info) {
+		return false
+	}
+	if w.budget != nil && !w.budget.fetch() {
+		if w.opt.Logf != nil {
+			w.opt.Logf("skipping the content of %s to meet the deadline", path)
+		}
+		return false
+	}
+	return true
+}
+
+// deferred tells whether the directory is deferred by the DeferFunc, rather than walked into.
+func (w *walker) deferred(path string, info *FileInfo) bool {
+	return info != nil && info.IsDir() && path != w.root && w.opt.DeferFunc != nil && !isResumedAncestor(w.opt.ResumeFrom, path) && w.opt.DeferFunc(path, info)
+}
+
+// skipReason returns the reason why the entry is not to be visited, or an empty string if it is to be visited.
+// The info is nil for the repo root.
+func (w *walker) skipReason(path string, info *FileInfo) SkipReason {
+	if info == nil {
+		return ""
+	}
+	if w.opt != nil && w.opt.SkipExportIgnore && info.Attributes["export-ignore"] == "true" {
+		return SkipReasonExportIgnore
+	}
+	if w.filterFn != nil && w.filterFn(path, info) {
+		return SkipReasonFilter
+	}
+	return ""
+}
+
+// loadAttributes loads the .gitattributes of the directory (if any), and sets the git attributes of the entries.
+func (w *walker) loadAttributes(ctx context.Context, path string, entries []*FileInfo) error {
+	for _, entry := range entries {
+		if entry.Name == gitAttributesFile && entry.Type == FileTypeFile {
+			if err := w.attrs.load(ctx, w.owner, w.repo, path, w.p); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	for _, entry := range entries {
+		entry.Attributes = w.attrs.attributes(filepath.Join(path, entry.Name), entry.IsDir())
+	}
+	return nil
+}
+
+func (w *walker) leave(path string, info *FileInfo) {
+	if w.onLeave != nil {
+		w.onLeave(path, info)
+	}
+}
+
+func (w *walker) skip(path string, info *FileInfo, reason SkipReason) {
+	if w.opt != nil && w.opt.NormalizeUnicode {
+		path, info = normalizeEntry(path, info)
+	}
+	if w.onSkip != nil {
+		w.onSkip(path, info, reason)
+	}
+	if w.opt != nil && w.opt.OnSkip != nil {
+		w.opt.OnSkip(path, reason)
+	}
+}
+
+// SkipReason is the reason why an entry is not visited, see OnSkip of WalkOptions.
+type SkipReason string
+
+const (
+	// SkipReasonFilter means the entry is excluded by the PathFilterFunc.
+	SkipReasonFilter SkipReason = "filter"
+	// SkipReasonSkipDir means the WalkFunc has returned SkipDir, either for the directory itself (then the directory
+	// is reported, as its entries are skipped), or for a file before the entry in the same directory.
+	SkipReasonSkipDir SkipReason = "skip-dir"
+	// SkipReasonExportIgnore means the entry has the "export-ignore" git attribute, see SkipExportIgnore of
+	// WalkOptions.
+	SkipReasonExportIgnore SkipReason = "export-ignore"
+	// SkipReasonDeferred means the directory is deferred by the DeferFunc of WalkOptions, the directory is reported
+	// as its entries are not walked.
+	SkipReasonDeferred SkipReason = "deferred"
+)
+
+func newFileInfo(c *github.RepositoryContent, includeDetail bool) *FileInfo {
 	fileinfo := &FileInfo{
-		raw:     c,
-		Type:    FileType(*c.Type),
-		Size:    *c.Size,
-		Name:    *c.Name,
-		Path:    *c.Path,
-		SHA:     *c.SHA,
-		URL:     *c.URL,
-		GitURL:  *c.GitURL,
-		HTMLURL: *c.HTMLURL,
+		Type:    FileType(c.GetType()),
+		Size:    c.GetSize(),
+		Name:    c.GetName(),
+		Path:    c.GetPath(),
+		SHA:     c.GetSHA(),
+		URL:     c.GetURL(),
+		GitURL:  c.GetGitURL(),
+		HTMLURL: c.GetHTMLURL(),
+
+		downloadURL: c.GetDownloadURL(),
 	}
 
 	if includeDetail {
@@ -198,83 +1173,179 @@ func newFileInfo(c github.RepositoryContent, includeDetail bool) *FileInfo {
 			Encoding:    c.Encoding,
 			Content:     c.Content,
 			Target:      c.Target,
-			DownloadURL: *c.DownloadURL,
+			DownloadURL: c.GetDownloadURL(),
+			// The content of a large file comes empty, with the encoding "none"
+			Truncated: fileinfo.Type == FileTypeFile && fileinfo.Size > 0 && (c.Content == nil || *c.Content == "" || c.GetEncoding() == "none"),
 		}
 	}
 
 	return fileinfo
 }
 
-func stat(ctx context.Context, owner, repo, path string, client *github.Client, opt *WalkOptions) (*FileInfo, error) {
-	// The root directory of the repo has no meta info
-	if path == "" {
-		return nil, nil
+// stat retrieves the FileInfo of path, including its FileOnlyInfo if it is a file and the user asks for it.
+func stat(ctx context.Context, owner, repo, path string, p ContentProvider, opt *WalkOptions) (*FileInfo, error) {
+	info, err := p.Stat(ctx, owner, repo, path)
+	if err != nil || info == nil {
+		return info, err
 	}
 
-	parentPath := filepath.Dir(path)
-	// If the `path` is at the root level, then we explicitly turn its parent path to be empty
-	// string, which indicates to get repository content at the root level.
-	if parentPath == "." {
-		parentPath = ""
+	// users ask for the file only info, then we need to invoke another API call against the path to the file
+	if opt.fetchContent(path, info) {
+		return readFile(ctx, owner, repo, path, p, opt, info)
 	}
+	return info, nil
+}
 
-	_, dircontent, _, err := client.Repositories.GetContents(ctx, owner, repo, parentPath, newRepositoryGetContentOptions(opt))
+// readFile retrieves the FileInfo of the file, including its FileOnlyInfo. For the file larger than the
+// InlineContentLimit, only the download URL is filled in, without an API call.
+func readFile(ctx context.Context, owner, repo, path string, p ContentProvider, opt *WalkOptions, info *FileInfo) (*FileInfo, error) {
+	if opt != nil && opt.InlineContentLimit > 0 && info.Size > opt.InlineContentLimit && info.downloadURL != "" {
+		out := *info
+		out.FileOnlyInfo = &FileOnlyInfo{DownloadURL: info.downloadURL}
+		return &out, nil
+	}
+	out, err := p.ReadFile(ctx, owner, repo, path)
 	if err != nil {
 		return nil, err
 	}
-
-	for _, content := range dircontent {
-		if content == nil {
-			continue
+	out.Attributes = info.Attributes
+	// Download the content that can't be decoded, unless it is too large to be held inline. The downloaded content
+	// has been transformed by Open already.
+	if foi := out.FileOnlyInfo; out.Type == FileTypeFile && foi != nil && !foi.Truncated && !out.decodable() && foi.DownloadURL != "" {
+		return download(ctx, out)
+	}
+	if opt != nil && opt.TransformContent != nil && out.Type == FileTypeFile && out.FileOnlyInfo != nil && out.FileOnlyInfo.Content != nil && !out.FileOnlyInfo.Truncated {
+		r, err := out.ContentReader()
+		if err != nil {
+			return nil, err
 		}
-		if *content.Name == filepath.Base(path) {
-			fileInfo := newFileInfo(*content, false)
-
-			// users specify to enable file only info, then we need to invoke another API call against the path to the file
-			if !fileInfo.IsDir() && opt != nil && opt.EnableFileOnlyInfo {
-				filecontent, _, _, err := client.Repositories.GetContents(ctx, owner, repo, path, newRepositoryGetContentOptions(opt))
-				if err != nil {
-					return nil, err
-				}
-				return newFileInfo(*filecontent, true), nil
-			}
-			return fileInfo, nil
+		content, err := transformContent(path, r, opt.TransformContent)
+		if err != nil {
+			return nil, err
 		}
+		// The FileOnlyInfo might be shared with the provider, e.g. a snapshot
+		s, encoding := string(content), ""
+		foi := *out.FileOnlyInfo
+		foi.Content, foi.Encoding = &s, &encoding
+		out.FileOnlyInfo = &foi
 	}
-
-	return nil, fmt.Errorf("no such path found: %s", path)
+	return out, nil
 }
 
-func readDirEntries(ctx context.Context, owner, repo, path string, client *github.Client, opt *WalkOptions) ([]FileInfo, error) {
-	_, dircontent, _, err := client.Repositories.GetContents(ctx, owner, repo, path, newRepositoryGetContentOptions(opt))
+// download returns the copy of info with the content downloaded from the DownloadURL, which is not encoded.
+func download(ctx context.Context, info *FileInfo) (*FileInfo, error) {
+	rc, err := info.Open(ctx)
 	if err != nil {
 		return nil, err
 	}
-	entryNames := make([]string, 0, len(dircontent))
-	entryMap := map[string]FileInfo{}
-	for _, content := range dircontent {
-		entryMap[*content.Name] = *newFileInfo(*content, false)
-		entryNames = append(entryNames, *content.Name)
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", info.Path, err)
 	}
+	out := *info
+	foi := *info.FileOnlyInfo
+	content, encoding := string(b), ""
+	foi.Content, foi.Encoding = &content, &encoding
+	out.FileOnlyInfo = &foi
+	return &out, nil
+}
 
-	if opt != nil && opt.Reverse {
-		sort.Sort(sort.Reverse(sort.StringSlice(entryNames)))
-	} else {
-		sort.Strings(entryNames)
+// transformContent reads the content of the file from r, transformed by fn.
+func transformContent(path string, r io.Reader, fn func(path string, r io.Reader) (io.Reader, error)) ([]byte, error) {
+	tr, err := fn(path, r)
+	if err != nil {
+		return nil, fmt.Errorf("transforming %s: %w", path, err)
+	}
+	content, err := ioutil.ReadAll(tr)
+	if err != nil {
+		return nil, fmt.Errorf("transforming %s: %w", path, err)
+	}
+	return content, nil
+}
+
+// fetchContent tells whether the FileOnlyInfo of the file (rather than dir) should be retrieved.
+func (opt *WalkOptions) fetchContent(path string, info *FileInfo) bool {
+	if opt == nil || info.IsDir() {
+		return false
+	}
+	if opt.FetchContentFunc != nil {
+		return opt.FetchContentFunc(path, info)
+	}
+	return opt.EnableFileOnlyInfo
+}
+
+func errNoSuchPath(path string) error {
+	return &notExistError{path: path}
+}
+
+// notExistError is the error returned for a path that doesn't exist, which matches ErrNotExist as well as
+// fs.ErrNotExist.
+type notExistError struct {
+	path string
+	// err is the underlying error, if any
+	err error
+}
+
+func (e *notExistError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("%v: %s (%v)", ErrNotExist, e.path, e.err)
 	}
+	return fmt.Sprintf("%v: %s", ErrNotExist, e.path)
+}
 
-	entries := make([]FileInfo, 0, len(entryMap))
-	for _, name := range entryNames {
-		entries = append(entries, entryMap[name])
+func (e *notExistError) Is(target error) bool {
+	return target == ErrNotExist || target == fs.ErrNotExist
+}
+
+func (e *notExistError) Unwrap() error {
+	return e.err
+}
+
+// isNotFound tells whether err is a Github API error response of status 404.
+func isNotFound(err error) bool {
+	var errResp *github.ErrorResponse
+	return errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound
+}
+
+func readDirEntries(ctx context.Context, owner, repo, path string, p ContentProvider, opt *WalkOptions) ([]*FileInfo, error) {
+	entries, err := p.ReadDir(ctx, owner, repo, path)
+	if err != nil {
+		return nil, err
 	}
+
+	less := entryLess(path, opt)
+	sort.Slice(entries, func(i, j int) bool {
+		return less(entries[i], entries[j])
+	})
 	return entries, nil
 }
 
-func newRepositoryGetContentOptions(opt *WalkOptions) *github.RepositoryContentGetOptions {
-	if opt == nil {
-		return nil
+// entryLess returns the function telling whether the entry a of the directory dir is visited before the entry b,
+// which orders the entries by the score of the PriorityFunc (higher first), then by the EntryOrder, and then by the
+// name (reversed if Reverse is set). The score of each entry is only computed once.
+func entryLess(dir string, opt *WalkOptions) func(a, b *FileInfo) bool {
+	scores := map[*FileInfo]int{}
+	score := func(info *FileInfo) int {
+		s, ok := scores[info]
+		if !ok {
+			s = opt.PriorityFunc(filepath.Join(dir, info.Name), info)
+			scores[info] = s
+		}
+		return s
 	}
-	return &github.RepositoryContentGetOptions{
-		Ref: opt.Ref,
+	return func(a, b *FileInfo) bool {
+		if opt != nil && opt.PriorityFunc != nil {
+			if sa, sb := score(a), score(b); sa != sb {
+				return sa > sb
+			}
+		}
+		if opt != nil && opt.EntryOrder != EntryOrderLexical && a.IsDir() != b.IsDir() {
+			return a.IsDir() == (opt.EntryOrder == EntryOrderDirsFirst)
+		}
+		if opt != nil && opt.Reverse {
+			return a.Name > b.Name
+		}
+		return a.Name < b.Name
 	}
 }
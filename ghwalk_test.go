@@ -1,25 +1,54 @@
 package ghwalk
 
 import (
+	"bytes"
 	"context"
-	"log"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/google/go-github/v32/github"
+	"github.com/magodo/ghwalk/ghwalktest"
 	"github.com/stretchr/testify/require"
 )
 
-var githubToken string
+var (
+	githubToken   string
+	githubBaseURL string
+)
 
+// TestMain runs the tests against Github if "GHWALK_GITHUB_TOKEN" is specified, otherwise against a fake server
+// serving this repository from the local working tree.
 func TestMain(m *testing.M) {
 	githubToken = os.Getenv("GHWALK_GITHUB_TOKEN")
 	if githubToken == "" {
-		log.Fatal(`"GHWALK_GITHUB_TOKEN" is not specified`)
+		srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+		githubBaseURL = srv.BaseURL()
+		code := m.Run()
+		srv.Close()
+		os.Exit(code)
 	}
 	os.Exit(m.Run())
 }
 
+// newFixture creates a fixture directory holding the files, which maps the slash separated paths to the contents.
+func newFixture(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
 func TestWalk(t *testing.T) {
 	cases := []struct {
 		owner      string
@@ -89,10 +118,11 @@ func TestWalk(t *testing.T) {
 
 	for _, c := range cases {
 		traversedPath := []string{}
-		ctx, _ := context.WithTimeout(context.Background(), 20*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
 		err := Walk(ctx,
 			c.owner, c.repo, c.path,
-			&WalkOptions{Token: githubToken, Reverse: c.reverse},
+			&WalkOptions{Token: githubToken, BaseURL: githubBaseURL, Reverse: c.reverse},
 			func(path string, info *FileInfo, err error) error {
 				if err != nil {
 					if c.skipError {
@@ -143,10 +173,11 @@ func TestWalkWithFileOnlyInfo(t *testing.T) {
 
 	for _, c := range cases {
 		traversedPath := []string{}
-		ctx, _ := context.WithTimeout(context.Background(), 20*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
 		err := Walk(ctx,
 			c.owner, c.repo, c.path,
-			&WalkOptions{Token: githubToken, EnableFileOnlyInfo: true},
+			&WalkOptions{Token: githubToken, BaseURL: githubBaseURL, EnableFileOnlyInfo: true},
 			func(path string, info *FileInfo, err error) error {
 				if err != nil {
 					return err
@@ -165,3 +196,751 @@ func TestWalkWithFileOnlyInfo(t *testing.T) {
 		require.Equal(t, c.expectPath, traversedPath)
 	}
 }
+
+func TestWalkWithFetchContentFunc(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	withContent := []string{}
+	err := Walk(ctx, "magodo", "ghwalk", "testdata",
+		&WalkOptions{
+			Token:   githubToken,
+			BaseURL: githubBaseURL,
+			// EnableFileOnlyInfo is overridden
+			EnableFileOnlyInfo: true,
+			FetchContentFunc: func(path string, info *FileInfo) bool {
+				require.Nil(t, info.FileOnlyInfo)
+				return filepath.Base(path) == "c"
+			},
+		},
+		func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.FileOnlyInfo != nil {
+				withContent = append(withContent, path)
+			}
+			return nil
+		},
+		nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"testdata/dir/c"}, withContent)
+}
+
+func TestWalkWithInlineContentLimit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	inlined := map[string]bool{}
+	contents := map[string]string{}
+	err := Walk(ctx, "magodo", "ghwalk", "testdata",
+		&WalkOptions{Token: githubToken, BaseURL: githubBaseURL, EnableFileOnlyInfo: true, InlineContentLimit: 15},
+		func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Type != FileTypeFile {
+				return nil
+			}
+			require.NotNil(t, info.FileOnlyInfo)
+			require.NotEmpty(t, info.FileOnlyInfo.DownloadURL)
+			inlined[path] = info.FileOnlyInfo.Content != nil
+
+			r, err := info.Open(ctx)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			b, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			contents[path] = string(b)
+			return nil
+		},
+		nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{"testdata/a": true, "testdata/b": true, "testdata/dir/c": false}, inlined)
+	for path, content := range contents {
+		b, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, string(b), content)
+	}
+}
+
+func TestWalkWithPriorityFunc(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	traversedPath := []string{}
+	err := Walk(ctx, "magodo", "ghwalk", "testdata",
+		&WalkOptions{
+			Token:   githubToken,
+			BaseURL: githubBaseURL,
+			PriorityFunc: func(path string, info *FileInfo) int {
+				if info.IsDir() {
+					return 1
+				}
+				if path == "testdata/b" {
+					return -1
+				}
+				return 0
+			},
+		},
+		func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			traversedPath = append(traversedPath, path)
+			return nil
+		},
+		nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"testdata",
+		"testdata/dir",
+		"testdata/dir/c",
+		"testdata/a",
+		"testdata/link_dir",
+		"testdata/b",
+	}, traversedPath)
+}
+
+func TestWalkContext(t *testing.T) {
+	type key struct{}
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), key{}, "value"), 20*time.Second)
+	defer cancel()
+
+	var walkCtx context.Context
+	traversedPath := []string{}
+	err := WalkContext(ctx, "magodo", "ghwalk", "testdata/dir",
+		&WalkOptions{
+			Token:   githubToken,
+			BaseURL: githubBaseURL,
+		},
+		func(ctx context.Context, path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			require.Equal(t, "value", ctx.Value(key{}))
+			require.NoError(t, ctx.Err())
+			walkCtx = ctx
+			traversedPath = append(traversedPath, path)
+			return nil
+		},
+		nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"testdata/dir", "testdata/dir/c"}, traversedPath)
+	// The context of the walk is done once the walk ends
+	require.Error(t, walkCtx.Err())
+}
+
+func TestWalkCallbackTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	var overruns []string
+	opt := &WalkOptions{
+		Token:           githubToken,
+		BaseURL:         githubBaseURL,
+		CallbackTimeout: 20 * time.Millisecond,
+		OnCallbackOverrun: func(path string, elapsed time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			overruns = append(overruns, path)
+		},
+	}
+
+	result, err := WalkWithResult(ctx, "magodo", "ghwalk", "testdata", opt,
+		func(path string, info *FileInfo, err error) error {
+			if path == "testdata/b" {
+				time.Sleep(100 * time.Millisecond)
+			}
+			return err
+		},
+		nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Stats.CallbackOverruns)
+	mu.Lock()
+	require.Equal(t, []string{"testdata/b"}, overruns)
+	overruns = nil
+	mu.Unlock()
+
+	// The context passed to the callback is done once the timeout passes
+	err = WalkContext(ctx, "magodo", "ghwalk", "testdata", opt,
+		func(ctx context.Context, path string, info *FileInfo, err error) error {
+			if path == "testdata/dir/c" {
+				<-ctx.Done()
+				require.Equal(t, context.DeadlineExceeded, ctx.Err())
+				return nil
+			}
+			require.NoError(t, ctx.Err())
+			return err
+		},
+		nil)
+	require.NoError(t, err)
+	mu.Lock()
+	require.Equal(t, []string{"testdata/dir/c"}, overruns)
+	mu.Unlock()
+}
+
+func TestWalkErrorPerEntry(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": newFixture(t, map[string]string{
+		"a/x": "x\n",
+		"b":   "b\n",
+		"c/y": "y\n",
+		"d":   "d\n",
+	})})
+	defer srv.Close()
+	srv.InjectFault(ghwalktest.Fault{Kind: ghwalktest.FaultServerError, Path: "b"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	// Neither the SkipDir of "a" nor the swallowed error of "b" is passed on to the entries after them
+	var visited, failed []string
+	require.NoError(t, Walk(ctx, "foo", "bar", "", &WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true},
+		func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				failed = append(failed, path)
+				return nil
+			}
+			visited = append(visited, path)
+			if path == "a" {
+				return SkipDir
+			}
+			return nil
+		},
+		nil))
+	require.Equal(t, []string{"", "a", "c", "c/y", "d"}, visited)
+	require.Equal(t, []string{"b"}, failed)
+}
+
+func TestWalkErrorMode(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+	srv.InjectFault(ghwalktest.Fault{Kind: ghwalktest.FaultServerError, Path: "testdata/dir"})
+	srv.InjectFault(ghwalktest.Fault{Kind: ghwalktest.FaultServerError, Path: "testdata/b"})
+	defer srv.ClearFaults()
+
+	cases := []struct {
+		mode       ErrorMode
+		concurrent bool
+		visited    []string
+		failed     []string
+		errs       int
+	}{
+		{
+			mode:    ErrorModeWalkFunc,
+			visited: []string{"testdata", "testdata/a", "testdata/link_dir"},
+			failed:  []string{"testdata/b", "testdata/dir"},
+		},
+		{
+			mode:    ErrorModeFailFast,
+			visited: []string{"testdata", "testdata/a"},
+			errs:    1,
+		},
+		{
+			mode:       ErrorModeFailFast,
+			concurrent: true,
+			errs:       1,
+		},
+		{
+			mode:    ErrorModeCollectAll,
+			visited: []string{"testdata", "testdata/a", "testdata/link_dir"},
+			failed:  []string{"testdata/b", "testdata/dir"},
+			errs:    2,
+		},
+		{
+			mode:       ErrorModeCollectAll,
+			concurrent: true,
+			visited:    []string{"testdata", "testdata/a", "testdata/link_dir"},
+			failed:     []string{"testdata/b", "testdata/dir"},
+			errs:       2,
+		},
+	}
+	for idx, c := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		opt := &WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true, ErrorMode: c.mode}
+		if c.concurrent {
+			opt.ListConcurrency, opt.ContentConcurrency = 4, 4
+		}
+		var visited, failed []string
+		err := Walk(ctx, "magodo", "ghwalk", "testdata", opt,
+			func(path string, info *FileInfo, err error) error {
+				if err != nil {
+					failed = append(failed, path)
+					if c.mode == ErrorModeWalkFunc {
+						return nil
+					}
+					return err
+				}
+				visited = append(visited, path)
+				return nil
+			},
+			nil)
+		cancel()
+		if c.errs == 0 {
+			require.NoError(t, err, idx)
+		} else {
+			require.Error(t, err, idx)
+			var errResp *github.ErrorResponse
+			require.True(t, errors.As(err, &errResp), idx)
+			if joined, ok := err.(interface{ Unwrap() []error }); ok {
+				require.Len(t, joined.Unwrap(), c.errs, idx)
+			} else {
+				require.Equal(t, 1, c.errs, idx)
+			}
+		}
+		if !c.concurrent || c.mode != ErrorModeFailFast {
+			// The fail-fast concurrent walk stops at whichever prefetch fails first
+			require.Equal(t, c.visited, visited, idx)
+		}
+		require.Equal(t, c.failed, failed, idx)
+	}
+}
+
+func TestWalkTransformContent(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	transform := func(path string, r io.Reader) (io.Reader, error) {
+		if path == "testdata/b" {
+			return nil, errors.New("boom")
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return io.MultiReader(strings.NewReader(path+": "), bytes.NewReader(bytes.ToUpper(b))), nil
+	}
+
+	cases := []struct {
+		inlineContentLimit int
+	}{
+		{},
+		// The content is transformed when it is downloaded
+		{inlineContentLimit: 1},
+	}
+	for _, c := range cases {
+		opt := &WalkOptions{
+			Token:              githubToken,
+			BaseURL:            githubBaseURL,
+			EnableFileOnlyInfo: true,
+			InlineContentLimit: c.inlineContentLimit,
+			TransformContent:   transform,
+		}
+		contents := map[string]string{}
+		err := Walk(ctx, "magodo", "ghwalk", "testdata/dir", opt, func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Type != FileTypeFile {
+				return nil
+			}
+			r, err := info.Open(ctx)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			b, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			contents[path] = string(b)
+			return nil
+		}, nil)
+		require.NoError(t, err, c.inlineContentLimit)
+		require.Equal(t, map[string]string{"testdata/dir/c": "testdata/dir/c: CONTENT OF C IN DIR\n"}, contents, c.inlineContentLimit)
+	}
+
+	err := Walk(ctx, "magodo", "ghwalk", "testdata/b", &WalkOptions{Token: githubToken, BaseURL: githubBaseURL, EnableFileOnlyInfo: true, TransformContent: transform}, func(path string, info *FileInfo, err error) error {
+		return err
+	}, nil)
+	require.EqualError(t, err, "transforming testdata/b: boom")
+}
+
+func TestWalkWithEntryOrder(t *testing.T) {
+	cases := []struct {
+		order   EntryOrder
+		reverse bool
+		expect  []string
+	}{
+		{
+			order:  EntryOrderLexical,
+			expect: []string{"testdata", "testdata/a", "testdata/b", "testdata/dir", "testdata/dir/c", "testdata/link_dir"},
+		},
+		{
+			order:  EntryOrderDirsFirst,
+			expect: []string{"testdata", "testdata/dir", "testdata/dir/c", "testdata/a", "testdata/b", "testdata/link_dir"},
+		},
+		{
+			order:  EntryOrderFilesFirst,
+			expect: []string{"testdata", "testdata/a", "testdata/b", "testdata/link_dir", "testdata/dir", "testdata/dir/c"},
+		},
+		{
+			order:   EntryOrderFilesFirst,
+			reverse: true,
+			expect:  []string{"testdata", "testdata/link_dir", "testdata/b", "testdata/a", "testdata/dir", "testdata/dir/c"},
+		},
+	}
+
+	for idx, c := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		traversedPath := []string{}
+		err := Walk(ctx, "magodo", "ghwalk", "testdata",
+			&WalkOptions{
+				Token:      githubToken,
+				BaseURL:    githubBaseURL,
+				EntryOrder: c.order,
+				Reverse:    c.reverse,
+			},
+			func(path string, info *FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				traversedPath = append(traversedPath, path)
+				return nil
+			},
+			nil)
+		cancel()
+		require.NoError(t, err, idx)
+		require.Equal(t, c.expect, traversedPath, idx)
+	}
+}
+
+func TestWalkConcurrently(t *testing.T) {
+	cases := []struct {
+		listConcurrency    int
+		contentConcurrency int
+	}{
+		{listConcurrency: 2},
+		{contentConcurrency: 4},
+		{listConcurrency: 2, contentConcurrency: 4},
+	}
+
+	for idx, c := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		traversedPath := []string{}
+		err := Walk(ctx, "magodo", "ghwalk", "testdata",
+			&WalkOptions{
+				Token:              githubToken,
+				BaseURL:            githubBaseURL,
+				EnableFileOnlyInfo: true,
+				ListConcurrency:    c.listConcurrency,
+				ContentConcurrency: c.contentConcurrency,
+			},
+			func(path string, info *FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info != nil && info.Type == FileTypeFile {
+					require.NotNil(t, info.FileOnlyInfo, idx)
+				}
+				traversedPath = append(traversedPath, path)
+				return nil
+			},
+			func(path string, info *FileInfo) bool {
+				return path == "testdata/b"
+			})
+		cancel()
+		require.NoError(t, err, idx)
+		require.Equal(t, []string{
+			"testdata",
+			"testdata/a",
+			"testdata/dir",
+			"testdata/dir/c",
+			"testdata/link_dir",
+		}, traversedPath, idx)
+	}
+}
+
+func TestWalkOnSkip(t *testing.T) {
+	cases := []struct {
+		walkFn      WalkFunc
+		filterFn    PathFilterFunc
+		expectSkips []string
+	}{
+		{
+			walkFn: func(path string, info *FileInfo, err error) error {
+				if path == "testdata/dir" {
+					return SkipDir
+				}
+				return err
+			},
+			filterFn: func(path string, info *FileInfo) bool {
+				return path == "testdata/a"
+			},
+			expectSkips: []string{
+				"testdata/a: filter",
+				"testdata/dir: skip-dir",
+			},
+		},
+		{
+			walkFn: func(path string, info *FileInfo, err error) error {
+				if path == "testdata/a" {
+					return SkipDir
+				}
+				return err
+			},
+			expectSkips: []string{
+				"testdata/b: skip-dir",
+				"testdata/dir: skip-dir",
+				"testdata/link_dir: skip-dir",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		skips := []string{}
+		err := Walk(ctx, "magodo", "ghwalk", "testdata",
+			&WalkOptions{
+				Token:   githubToken,
+				BaseURL: githubBaseURL,
+				OnSkip: func(path string, reason SkipReason) {
+					skips = append(skips, path+": "+string(reason))
+				},
+			},
+			c.walkFn, c.filterFn)
+		require.NoError(t, err)
+		require.Equal(t, c.expectSkips, skips)
+	}
+}
+
+func TestWalkWithResultPartial(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var paths []string
+	result, err := WalkWithResult(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{Token: githubToken, BaseURL: githubBaseURL},
+		func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			paths = append(paths, path)
+			if path == "testdata/a" {
+				cancel()
+			}
+			return nil
+		},
+		nil)
+	require.True(t, errors.Is(err, context.Canceled))
+	require.True(t, result.Partial)
+	require.Equal(t, 3, result.Entries)
+	// The files listed already are still visited, while the directory fails to be listed
+	require.Equal(t, []string{"testdata", "testdata/a", "testdata/b"}, paths)
+}
+
+func TestWalkResultSummary(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": newFixture(t, map[string]string{
+		"main.go":    "package main\n",
+		"lib/x.GO":   "package lib\n",
+		"Makefile":   "all:\n",
+		"docs/a.md":  "# a\n",
+		"docs/b.txt": "b",
+	})})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	for _, extensions := range []bool{false, true} {
+		result, err := WalkWithResult(ctx, "foo", "bar", "", &WalkOptions{BaseURL: srv.BaseURL(), SummarizeExtensions: extensions},
+			func(path string, info *FileInfo, err error) error {
+				return err
+			},
+			nil)
+		require.NoError(t, err)
+		require.Equal(t, map[FileType]TypeSummary{
+			FileTypeFile: {Count: 5, Bytes: 35},
+			FileTypeDir:  {Count: 2},
+		}, result.Types)
+		if !extensions {
+			require.Nil(t, result.Extensions)
+			continue
+		}
+		require.Equal(t, map[string]TypeSummary{
+			".go":  {Count: 2, Bytes: 25},
+			"":     {Count: 1, Bytes: 5},
+			".md":  {Count: 1, Bytes: 4},
+			".txt": {Count: 1, Bytes: 1},
+		}, result.Extensions)
+	}
+}
+
+func TestWalkTruncatedContent(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": newFixture(t, map[string]string{
+		"small": "abc",
+		"large": "0123456789",
+	})})
+	defer srv.Close()
+	srv.SetContentLimit(4)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	infos := map[string]*FileInfo{}
+	err := Walk(ctx, "foo", "bar", "", &WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true},
+		func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			infos[path] = info
+			return nil
+		},
+		nil)
+	require.NoError(t, err)
+
+	small := infos["small"]
+	require.False(t, small.FileOnlyInfo.Truncated)
+	content, err := small.GetContent()
+	require.NoError(t, err)
+	require.Equal(t, "abc", content)
+
+	large := infos["large"]
+	require.True(t, large.FileOnlyInfo.Truncated)
+	_, err = large.GetContent()
+	require.True(t, errors.Is(err, ErrTooLarge), err)
+	_, err = large.GetContentBytes()
+	require.True(t, errors.Is(err, ErrTooLarge), err)
+	r, err := large.Open(ctx)
+	require.NoError(t, err)
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "0123456789", string(b))
+}
+
+func TestGetContentBytes(t *testing.T) {
+	strPtr := func(s string) *string { return &s }
+	cases := []struct {
+		info    FileInfo
+		expect  []byte
+		isError bool
+	}{
+		{
+			info:   FileInfo{},
+			expect: nil,
+		},
+		{
+			info: FileInfo{FileOnlyInfo: &FileOnlyInfo{
+				Encoding: strPtr("base64"),
+				Content:  strPtr("AAEC/w==\n"),
+			}},
+			expect: []byte{0x00, 0x01, 0x02, 0xff},
+		},
+		{
+			info: FileInfo{FileOnlyInfo: &FileOnlyInfo{
+				Content: strPtr("plain"),
+			}},
+			expect: []byte("plain"),
+		},
+		{
+			info: FileInfo{FileOnlyInfo: &FileOnlyInfo{
+				Encoding: strPtr("base64"),
+			}},
+			isError: true,
+		},
+		{
+			info: FileInfo{FileOnlyInfo: &FileOnlyInfo{
+				Encoding: strPtr("unknown"),
+				Content:  strPtr("foo"),
+			}},
+			isError: true,
+		},
+		{
+			info: FileInfo{FileOnlyInfo: &FileOnlyInfo{
+				Encoding: strPtr("none"),
+				Content:  strPtr("raw"),
+			}},
+			expect: []byte("raw"),
+		},
+	}
+
+	for _, c := range cases {
+		b, err := c.info.GetContentBytes()
+		if c.isError {
+			require.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, c.expect, b)
+	}
+}
+
+// encodingTransport rewrites the encoding of the file contents returned by the Contents API.
+type encodingTransport struct {
+	encoding string
+}
+
+func (t *encodingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil || !strings.Contains(req.URL.Path, "/contents/") {
+		return resp, err
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.ReplaceAll(b, []byte(`"encoding":"base64"`), []byte(`"encoding":"`+t.encoding+`"`))
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	resp.ContentLength = int64(len(b))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+func TestWalkUnsupportedEncoding(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": newFixture(t, map[string]string{"a": "content of a"})})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	for _, transform := range []bool{false, true} {
+		opt := &WalkOptions{BaseURL: srv.BaseURL(), Transport: &encodingTransport{encoding: "gzip"}, EnableFileOnlyInfo: true}
+		expect := "content of a"
+		if transform {
+			opt.TransformContent = func(path string, r io.Reader) (io.Reader, error) {
+				b, err := ioutil.ReadAll(r)
+				return bytes.NewReader(bytes.ToUpper(b)), err
+			}
+			expect = "CONTENT OF A"
+		}
+		var content string
+		err := Walk(ctx, "foo", "bar", "a", opt, func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			content, err = info.GetContent()
+			return err
+		}, nil)
+		require.NoError(t, err)
+		require.Equal(t, expect, content)
+	}
+
+	info := &FileInfo{FileOnlyInfo: &FileOnlyInfo{Encoding: github.String("gzip"), Content: github.String("foo")}}
+	_, err := info.GetContent()
+	require.True(t, errors.Is(err, ErrUnsupportedEncoding), err)
+}
+
+func TestWalkSkipAll(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	traversedPath := []string{}
+	err := Walk(ctx, "magodo", "ghwalk", "testdata",
+		&WalkOptions{Token: githubToken, BaseURL: githubBaseURL},
+		func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			traversedPath = append(traversedPath, path)
+			if path == "testdata/dir/c" {
+				return SkipAll
+			}
+			return nil
+		},
+		nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"testdata", "testdata/a", "testdata/b", "testdata/dir", "testdata/dir/c"}, traversedPath)
+}
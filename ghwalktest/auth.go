@@ -0,0 +1,106 @@
+package ghwalktest
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// Token describes an access token accepted by the Server, see AddToken.
+type Token struct {
+	// Login is the login of the user owning the token.
+	Login string
+
+	// Scopes are the OAuth scopes of the token, reported by the X-OAuth-Scopes header. A nil Scopes means the token
+	// is a fine-grained one, which reports no scopes.
+	Scopes []string
+
+	// Repos, if not nil, are the only repositories (of the form "owner/repo") accessible to the token, the others
+	// are responded with 404 Not Found, as Github does for the private repositories.
+	Repos []string
+
+	// SSOOrgs are the organizations enforcing SAML SSO, which the token is not authorized for. The requests against
+	// their repositories are responded with 403 Forbidden and the X-GitHub-SSO header.
+	SSOOrgs []string
+}
+
+// AddToken registers an access token to the Server. Once any token is registered, the requests carrying a token
+// (i.e. the Authorization header) other than the registered ones are responded with 401 Unauthorized, and the
+// requests against the repositories (i.e. GET /repos/{owner}/{repo}/...) are restricted as described by the Token.
+// Otherwise, any token is accepted.
+//
+// GET /user returns the user owning the token.
+func (s *Server) AddToken(token string, t Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokens == nil {
+		s.tokens = map[string]Token{}
+	}
+	s.tokens[token] = t
+}
+
+// token returns the Token of the request, along with whether the request carries a token and whether it is valid.
+func (s *Server) token(r *http.Request) (Token, bool, bool) {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return Token{}, false, true
+	}
+	fields := strings.Fields(auth)
+	secret := fields[len(fields)-1]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokens == nil {
+		return Token{Login: "ghwalktest"}, true, true
+	}
+	t, ok := s.tokens[secret]
+	return t, true, ok
+}
+
+// authenticate checks the token of the request, and serves GET /user. It writes the response and returns false if
+// the request is done.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) bool {
+	t, hasToken, ok := s.token(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "Bad credentials")
+		return false
+	}
+	if t.Scopes != nil {
+		w.Header().Set("X-OAuth-Scopes", strings.Join(t.Scopes, ", "))
+	}
+	if r.URL.Path != "/user" {
+		return true
+	}
+	if !hasToken {
+		writeError(w, http.StatusUnauthorized, "Requires authentication")
+		return false
+	}
+	writeJSON(w, &github.User{Login: github.String(t.Login)})
+	return false
+}
+
+// authorize checks whether the token of the request has access to the repository. It writes the error response and
+// returns false if not.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, owner, repo string) bool {
+	t, hasToken, _ := s.token(r)
+	if !hasToken {
+		return true
+	}
+	for _, org := range t.SSOOrgs {
+		if strings.EqualFold(org, owner) {
+			w.Header().Set("X-GitHub-SSO", "required; url=https://github.com/orgs/"+org+"/sso?authorization_request=ghwalktest")
+			writeError(w, http.StatusForbidden, "Resource protected by organization SAML enforcement. You must grant your Personal Access token access to this organization.")
+			return false
+		}
+	}
+	if t.Repos == nil {
+		return true
+	}
+	for _, name := range t.Repos {
+		if strings.EqualFold(name, owner+"/"+repo) {
+			return true
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+	return false
+}
@@ -0,0 +1,93 @@
+package ghwalktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// deviceInterval is the polling interval in seconds of the device flow, which is shorter than Github's to keep the
+// tests fast.
+const deviceInterval = 1
+
+// device is a device code issued by the device flow.
+type device struct {
+	userCode string
+	// token is set once the code is approved
+	token  string
+	denied bool
+}
+
+// ApproveDevice approves the device flow of the user code (see ghwalk.DeviceCode), so that the pending poll is
+// responded with the token.
+func (s *Server) ApproveDevice(userCode, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.devices {
+		if d.userCode == userCode {
+			d.token = token
+		}
+	}
+}
+
+// DenyDevice denies the device flow of the user code, so that the pending poll is responded with "access_denied".
+func (s *Server) DenyDevice(userCode string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.devices {
+		if d.userCode == userCode {
+			d.denied = true
+		}
+	}
+}
+
+// handleDeviceCode serves POST /login/device/code, which issues a device code.
+func (s *Server) handleDeviceCode(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("client_id") == "" {
+		writeError(w, http.StatusUnauthorized, "Missing client_id")
+		return
+	}
+	s.mu.Lock()
+	if s.devices == nil {
+		s.devices = map[string]*device{}
+	}
+	n := len(s.devices) + 1
+	deviceCode := fmt.Sprintf("device-%d", n)
+	d := &device{userCode: fmt.Sprintf("CODE-%04d", n)}
+	s.devices[deviceCode] = d
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"device_code":      deviceCode,
+		"user_code":        d.userCode,
+		"verification_uri": "http://" + r.Host + "/login/device",
+		"expires_in":       900,
+		"interval":         deviceInterval,
+	})
+}
+
+// handleAccessToken serves POST /login/oauth/access_token for the device flow, which responds the errors with 200
+// OK, as Github does.
+func (s *Server) handleAccessToken(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]interface{}{}
+	s.mu.Lock()
+	d, ok := s.devices[r.FormValue("device_code")]
+	switch {
+	case r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:device_code":
+		resp["error"] = "unsupported_grant_type"
+	case !ok:
+		resp["error"] = "incorrect_device_code"
+	case d.denied:
+		resp["error"] = "access_denied"
+	case d.token == "":
+		resp["error"] = "authorization_pending"
+	default:
+		resp["access_token"] = d.token
+		resp["token_type"] = "bearer"
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,113 @@
+package ghwalktest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// FaultKind is the kind of a Fault.
+type FaultKind int
+
+const (
+	// FaultNotFound responds with 404 Not Found.
+	FaultNotFound FaultKind = iota
+
+	// FaultRateLimit responds with 403 Forbidden and the headers indicating that the rate limit is exhausted.
+	// The rate limit resets after the Duration of the Fault.
+	FaultRateLimit
+
+	// FaultServerError responds with 500 Internal Server Error.
+	FaultServerError
+
+	// FaultTimeout delays the response for the Duration of the Fault, or until the client gives up the request if
+	// the Duration is zero.
+	FaultTimeout
+)
+
+// Fault describes an error to inject into the responses of a Server.
+type Fault struct {
+	Kind FaultKind
+
+	// Path, if not empty, restricts the fault to the requests against this path in the repository, which includes
+	// the Contents API requests and the file downloads.
+	Path string
+
+	// Call, if greater than zero, restricts the fault to the Call-th (starting from 1) request that matches the Path.
+	// Otherwise, every matching request is faulted.
+	Call int
+
+	// Duration is the duration until the rate limit resets for FaultRateLimit, or the duration to delay the response
+	// for FaultTimeout.
+	Duration time.Duration
+
+	// matched is the count of requests that have matched the Path so far
+	matched int
+}
+
+// InjectFault adds a fault to the server, which takes effect from the next request.
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults removes all the faults injected into the server.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// RequestCount returns the number of requests served by the server so far.
+func (s *Server) RequestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// fault returns the fault to inject into the request against the given repository path, if any. The path is empty
+// for the requests not against a certain path (e.g. the Trees API).
+func (s *Server) fault(path string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	var fault *Fault
+	for _, f := range s.faults {
+		if f.Path != "" && f.Path != path {
+			continue
+		}
+		f.matched++
+		if fault == nil && (f.Call <= 0 || f.Call == f.matched) {
+			fault = f
+		}
+	}
+	return fault
+}
+
+// writeFault writes the response of the fault. It returns false if the request should be served normally after
+// the fault.
+func writeFault(w http.ResponseWriter, r *http.Request, f *Fault) bool {
+	switch f.Kind {
+	case FaultNotFound:
+		writeError(w, http.StatusNotFound, "Not Found")
+	case FaultRateLimit:
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(f.Duration).Unix(), 10))
+		writeError(w, http.StatusForbidden, "API rate limit exceeded for 127.0.0.1.")
+	case FaultServerError:
+		writeError(w, http.StatusInternalServerError, "Server Error")
+	case FaultTimeout:
+		if f.Duration == 0 {
+			<-r.Context().Done()
+			return true
+		}
+		select {
+		case <-time.After(f.Duration):
+			return false
+		case <-r.Context().Done():
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package ghwalktest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/magodo/ghwalk"
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFault(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": ".."})
+	defer srv.Close()
+
+	walk := func(ctx context.Context) (map[string]error, error) {
+		errs := map[string]error{}
+		err := ghwalk.Walk(ctx, "magodo", "ghwalk", "testdata", &ghwalk.WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true},
+			func(path string, info *ghwalk.FileInfo, err error) error {
+				if err != nil {
+					errs[path] = err
+				}
+				return nil
+			}, nil)
+		return errs, err
+	}
+
+	// Fault on a certain path
+	srv.InjectFault(ghwalktest.Fault{Kind: ghwalktest.FaultNotFound, Path: "testdata/dir"})
+	errs, err := walk(context.Background())
+	require.NoError(t, err)
+	require.Len(t, errs, 1)
+	require.True(t, errors.Is(errs["testdata/dir"], ghwalk.ErrNotExist))
+	srv.ClearFaults()
+
+	// Fault on a certain call
+	srv.InjectFault(ghwalktest.Fault{Kind: ghwalktest.FaultServerError, Call: 3})
+	errs, err = walk(context.Background())
+	require.NoError(t, err)
+	require.Len(t, errs, 1)
+	var errResp *github.ErrorResponse
+	for _, err := range errs {
+		require.True(t, errors.As(err, &errResp))
+		require.Equal(t, http.StatusInternalServerError, errResp.Response.StatusCode)
+	}
+	srv.ClearFaults()
+
+	// Rate limit
+	srv.InjectFault(ghwalktest.Fault{Kind: ghwalktest.FaultRateLimit, Path: "testdata/b", Duration: time.Hour})
+	errs, err = walk(context.Background())
+	require.NoError(t, err)
+	var rateLimitErr *github.RateLimitError
+	require.True(t, errors.As(errs["testdata/b"], &rateLimitErr))
+	srv.ClearFaults()
+
+	// Timeout
+	srv.InjectFault(ghwalktest.Fault{Kind: ghwalktest.FaultTimeout, Path: "testdata/a"})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	errs, err = walk(ctx)
+	require.NoError(t, err)
+	require.True(t, errors.Is(errs["testdata/a"], context.DeadlineExceeded))
+	srv.ClearFaults()
+
+	count := srv.RequestCount()
+	_, err = walk(context.Background())
+	require.NoError(t, err)
+	// 1 listing for testdata's parent, 2 listings of the directories and 4 files (including the symlink)
+	require.Equal(t, 7, srv.RequestCount()-count)
+}
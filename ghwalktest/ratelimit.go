@@ -0,0 +1,62 @@
+package ghwalktest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+)
+
+const (
+	// defaultRateLimit is the rate limit of the authenticated requests reported by the Server, unless SetRateLimit is
+	// called.
+	defaultRateLimit = 5000
+	// defaultAnonymousRateLimit is the rate limit of the unauthenticated requests (i.e. without the Authorization
+	// header) reported by the Server, unless SetRateLimit is called.
+	defaultAnonymousRateLimit = 60
+)
+
+// SetRateLimit sets the core and GraphQL rate limit status reported by GET /rate_limit and the X-RateLimit-* headers
+// of the responses. The rate limit is not enforced by the Server, use InjectFault with FaultRateLimit to reject the
+// requests.
+func (s *Server) SetRateLimit(limit, remaining int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimit = &github.Rate{Limit: limit, Remaining: remaining}
+}
+
+// rate returns the rate limit status of the request.
+func (s *Server) rate(r *http.Request) github.Rate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rate := github.Rate{Limit: defaultRateLimit, Remaining: defaultRateLimit}
+	switch {
+	case s.rateLimit != nil:
+		rate = *s.rateLimit
+	case r.Header.Get("Authorization") == "":
+		rate = github.Rate{Limit: defaultAnonymousRateLimit, Remaining: defaultAnonymousRateLimit}
+	}
+	rate.Reset = github.Timestamp{Time: time.Now().Add(time.Hour).Truncate(time.Second)}
+	return rate
+}
+
+// setRateLimitHeaders sets the X-RateLimit-* headers of the response, which are overridden by FaultRateLimit.
+func (s *Server) setRateLimitHeaders(w http.ResponseWriter, r *http.Request) {
+	rate := s.rate(r)
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(rate.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(rate.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(rate.Reset.Unix(), 10))
+}
+
+func (s *Server) handleRateLimit(w http.ResponseWriter, r *http.Request) {
+	rate := s.rate(r)
+	writeJSON(w, map[string]interface{}{
+		"resources": map[string]interface{}{
+			"core":    rate,
+			"graphql": rate,
+			"search":  github.Rate{Limit: 30, Remaining: 30, Reset: github.Timestamp{Time: time.Now().Add(time.Minute).Truncate(time.Second)}},
+		},
+		"rate": rate,
+	})
+}
@@ -0,0 +1,139 @@
+package ghwalktest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Mode is the mode of a Recorder.
+type Mode int
+
+const (
+	// ModeReplay serves the responses from the recorded interactions only, a request that has not been recorded
+	// fails with an error.
+	ModeReplay Mode = iota
+
+	// ModeRecord always sends the requests to the underlying transport, and records the interactions.
+	ModeRecord
+
+	// ModeReplayOrRecord serves the recorded interactions if any, otherwise sends the request to the underlying
+	// transport and records the interaction.
+	ModeReplayOrRecord
+)
+
+// Recorder is an http.RoundTripper that records the API interactions to a directory, and replays them later on.
+// It is meant to be set as the Transport of the ghwalk.WalkOptions, so that tests against the real Github API become
+// deterministic and can run offline.
+//
+// Each interaction is stored as a JSON file in the directory, named after the request method and URL. Only the request
+// method and URL are recorded (e.g. the Authorization header is not), so the recorded files are safe to share.
+type Recorder struct {
+	mode      Mode
+	dir       string
+	transport http.RoundTripper
+}
+
+// NewRecorder returns a Recorder working in the given mode, storing the interactions in dir. The transport is used to
+// send the requests that are not replayed, it defaults to http.DefaultTransport.
+func NewRecorder(mode Mode, dir string, transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{
+		mode:      mode,
+		dir:       dir,
+		transport: transport,
+	}
+}
+
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+type recordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	file := filepath.Join(r.dir, interactionName(req))
+
+	if r.mode != ModeRecord {
+		b, err := ioutil.ReadFile(file)
+		switch {
+		case err == nil:
+			var i interaction
+			if err := json.Unmarshal(b, &i); err != nil {
+				return nil, fmt.Errorf("decoding recorded interaction %s: %v", file, err)
+			}
+			return &http.Response{
+				Status:        fmt.Sprintf("%d %s", i.Response.StatusCode, http.StatusText(i.Response.StatusCode)),
+				StatusCode:    i.Response.StatusCode,
+				Proto:         "HTTP/1.1",
+				ProtoMajor:    1,
+				ProtoMinor:    1,
+				Header:        i.Response.Header,
+				Body:          ioutil.NopCloser(bytes.NewReader(i.Response.Body)),
+				ContentLength: int64(len(i.Response.Body)),
+				Request:       req,
+			}, nil
+		case !os.IsNotExist(err):
+			return nil, err
+		case r.mode == ModeReplay:
+			return nil, fmt.Errorf("no recorded interaction for %s %s", req.Method, req.URL)
+		}
+	}
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	b, err := json.MarshalIndent(interaction{
+		Request: recordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+		},
+		Response: recordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       body,
+		},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(file, b, 0644); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func interactionName(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.Method + " " + req.URL.String()))
+	return hex.EncodeToString(sum[:8]) + ".json"
+}
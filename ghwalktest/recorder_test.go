@@ -0,0 +1,46 @@
+package ghwalktest_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/magodo/ghwalk"
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ghwalktest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	walk := func(opt *ghwalk.WalkOptions) ([]string, error) {
+		paths := []string{}
+		err := ghwalk.Walk(context.Background(), "magodo", "ghwalk", "testdata", opt,
+			func(path string, info *ghwalk.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				paths = append(paths, path)
+				return nil
+			}, nil)
+		return paths, err
+	}
+
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": ".."})
+	baseURL := srv.BaseURL()
+
+	// Replaying without any recorded interaction fails
+	_, err = walk(&ghwalk.WalkOptions{BaseURL: baseURL, Transport: ghwalktest.NewRecorder(ghwalktest.ModeReplay, dir, nil)})
+	require.Error(t, err)
+
+	expect, err := walk(&ghwalk.WalkOptions{BaseURL: baseURL, Transport: ghwalktest.NewRecorder(ghwalktest.ModeRecord, dir, nil)})
+	require.NoError(t, err)
+	srv.Close()
+
+	actual, err := walk(&ghwalk.WalkOptions{BaseURL: baseURL, Transport: ghwalktest.NewRecorder(ghwalktest.ModeReplay, dir, nil)})
+	require.NoError(t, err)
+	require.Equal(t, expect, actual)
+}
@@ -0,0 +1,1117 @@
+// Package ghwalktest provides utilities for testing code built on top of ghwalk: a fake Github API server that works
+// without network access or a Github access token, and a transport recording and replaying the real API interactions.
+package ghwalktest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/magodo/ghwalk/internal/githash"
+)
+
+// Server is a fake Github API server, which serves the repository contents from local fixture directories.
+//
+// The following endpoints are implemented:
+//
+//	GET /repos/{owner}/{repo}
+//	GET /repos/{owner}/{repo}/contents/{path}
+//	GET /repos/{owner}/{repo}/git/trees/{tree_sha}
+//	GET /repos/{owner}/{repo}/git/blobs/{file_sha}
+//	GET /repos/{owner}/{repo}/commits
+//	GET /repos/{owner}/{repo}/tags
+//	GET /orgs/{org}/repos
+//	GET /users/{user}/repos
+//	GET /search/repositories
+//	GET /rate_limit
+//	GET /user
+//	POST /graphql
+//
+// The repository search only supports the keywords (matching the repository names) and the "org:" and "user:"
+// qualifiers, and reports the rate limit of the search API, i.e. 30 requests per minute.
+//
+// The GraphQL endpoint only supports the queries issued by ghwalk, which look up the entries of trees: each variable
+// other than "owner" and "name" is an alias of the repository's object field, whose value is the object expression
+// (i.e. "ref:path").
+//
+// The fixtures have no history, each state of a repository (i.e. each fixture directory) is presented as a single
+// commit, whose SHA is derived from the root tree SHA and whose date is CommitDate. The refs of the fixtures (i.e. the
+// keys of the form "owner/repo@ref") are served as the tags.
+//
+// The download URL of the files are also served by the Server, as well as the repository tarball served by the Github
+// web host, i.e. GET /{owner}/{repo}/archive/{ref}.tar.gz, where the Server acts as the web host as well. Like "git
+// archive", the tarball leaves out the paths with the export-ignore attribute in the .gitattributes files (only the
+// patterns matched by path.Match are supported), while export-subst is not supported. So is the
+// OAuth device flow, i.e. POST /login/device/code and POST /login/oauth/access_token, whose codes are approved by
+// ApproveDevice.
+//
+// The successful responses carry an ETag, and the conditional requests with a matching If-None-Match header are
+// responded with 304 Not Modified.
+//
+// Faults can be injected into the responses by InjectFault, in order to test the error handling. Repositories can be
+// renamed by RenameRepo, in order to test the handling of the redirects. The access tokens can be restricted by
+// AddToken, in order to test the handling of the authorization errors.
+type Server struct {
+	*httptest.Server
+
+	repos map[string]string
+
+	mu       sync.Mutex
+	faults   []*Fault
+	requests int
+	// renames maps the old "owner/repo" to the new one
+	renames map[string]string
+	// rateLimit is the rate limit status set by SetRateLimit
+	rateLimit *github.Rate
+	// tokens are the tokens registered by AddToken
+	tokens map[string]Token
+	// devices maps the device codes issued by the device flow
+	devices map[string]*device
+	// treeLimit is the limit set by SetTreeLimit
+	treeLimit int
+	// contentLimit is the limit set by SetContentLimit
+	contentLimit int
+	// repoFlags are the flags set by SetRepoFlags, keyed by "owner/repo"
+	repoFlags map[string]RepoFlags
+}
+
+// NewServer starts and returns a new Server serving the given repositories. The keys of repos are of the form
+// "owner/repo", the values are the local directories whose content forms the repository tree (the ".git" directory
+// is ignored). A key of the form "owner/repo@ref" serves the directory only when the given ref is requested, which
+// can be used to emulate different states of one repository.
+//
+// The fixture directories are read on every request, so changes to them are reflected immediately.
+// The caller should call Close when finished, to shut it down.
+func NewServer(repos map[string]string) *Server {
+	s := &Server{repos: repos, contentLimit: DefaultContentLimit}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// BaseURL returns the Github API base URL of the server, which is meant to be set as the BaseURL of the
+// ghwalk.WalkOptions, in order to point the walker to the server.
+func (s *Server) BaseURL() string {
+	return s.URL + "/"
+}
+
+type node struct {
+	name string
+	path string
+	mode string
+	sha  string
+
+	// content is the content of the file, or the target of the symlink
+	content []byte
+
+	// children is sorted by name, only set for directories
+	children []*node
+}
+
+func (n *node) isDir() bool {
+	return n.mode == githash.ModeDir
+}
+
+func (n *node) contentType() string {
+	switch n.mode {
+	case githash.ModeDir:
+		return "dir"
+	case githash.ModeSymlink:
+		return "symlink"
+	default:
+		return "file"
+	}
+}
+
+func (n *node) gitType() string {
+	if n.isDir() {
+		return "tree"
+	}
+	return "blob"
+}
+
+func (n *node) lookup(p string) *node {
+	if p == "" {
+		return n
+	}
+	cur := n
+	for _, name := range strings.Split(p, "/") {
+		var next *node
+		for _, child := range cur.children {
+			if child.name == name {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		cur = next
+	}
+	return cur
+}
+
+// find returns the first node (in depth first order) for which f returns true.
+func (n *node) find(f func(*node) bool) *node {
+	if f(n) {
+		return n
+	}
+	for _, child := range n.children {
+		if found := child.find(f); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func load(dir, p string) (*node, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &node{path: p, mode: githash.ModeDir}
+	if p != "" {
+		n.name = path.Base(p)
+	}
+	var treeEntries []githash.TreeEntry
+	for _, entry := range entries {
+		if p == "" && entry.Name() == ".git" {
+			continue
+		}
+		fpath := filepath.Join(dir, entry.Name())
+		cpath := path.Join(p, entry.Name())
+
+		var child *node
+		switch {
+		case entry.IsDir():
+			child, err = load(fpath, cpath)
+			if err != nil {
+				return nil, err
+			}
+		case entry.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(fpath)
+			if err != nil {
+				return nil, err
+			}
+			child = &node{mode: githash.ModeSymlink, content: []byte(filepath.ToSlash(target))}
+		default:
+			content, err := ioutil.ReadFile(fpath)
+			if err != nil {
+				return nil, err
+			}
+			child = &node{mode: githash.ModeFile, content: content}
+			if entry.Mode()&0111 != 0 {
+				child.mode = githash.ModeExecutable
+			}
+		}
+		if !child.isDir() {
+			child.name = entry.Name()
+			child.path = cpath
+			child.sha = githash.BlobSHA(child.content)
+		}
+		n.children = append(n.children, child)
+		treeEntries = append(treeEntries, githash.TreeEntry{Mode: child.mode, Name: child.name, SHA: child.sha})
+	}
+	sort.Slice(n.children, func(i, j int) bool {
+		return n.children[i].name < n.children[j].name
+	})
+
+	if n.sha, err = githash.TreeSHA(treeEntries); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// request holds the information of a request being served.
+type request struct {
+	baseURL string
+	owner   string
+	repo    string
+	ref     string
+	root    *node
+	// contentLimit is the size of the largest file content returned by the Contents API
+	contentLimit int
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	// The device flow is served by the web host, without authentication
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/login/device/code":
+		s.handleDeviceCode(w, r)
+		return
+	case r.Method == http.MethodPost && r.URL.Path == "/login/oauth/access_token":
+		s.handleAccessToken(w, r)
+		return
+	}
+	s.setRateLimitHeaders(w, r)
+	if !s.authenticate(w, r) {
+		return
+	}
+	if r.URL.Path == "/graphql" {
+		if f := s.fault(""); f != nil && writeFault(w, r, f) {
+			return
+		}
+		s.handleGraphQL(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	if r.URL.Path == "/rate_limit" {
+		if f := s.fault(""); f != nil && writeFault(w, r, f) {
+			return
+		}
+		s.handleRateLimit(w, r)
+		return
+	}
+
+	if r.URL.Path == "/search/repositories" {
+		if f := s.fault(""); f != nil && writeFault(w, r, f) {
+			return
+		}
+		s.handleSearchRepos(w, r)
+		return
+	}
+
+	segs := strings.SplitN(strings.Trim(r.URL.Path, "/"), "/", 4)
+	if len(segs) < 3 {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	s.mu.Lock()
+	contentLimit := s.contentLimit
+	s.mu.Unlock()
+	req := &request{
+		baseURL:      "http://" + r.Host + "/",
+		owner:        segs[1],
+		repo:         segs[2],
+		ref:          r.URL.Query().Get("ref"),
+		contentLimit: contentLimit,
+	}
+	var rest string
+	if len(segs) == 4 {
+		rest = segs[3]
+	}
+	if len(segs) == 4 && segs[2] == "archive" && strings.HasSuffix(rest, ".tar.gz") {
+		// {owner}/{repo}/archive/{ref}.tar.gz
+		req.owner, req.repo, req.ref = segs[0], segs[1], strings.TrimSuffix(rest, ".tar.gz")
+		if f := s.fault(""); f != nil && writeFault(w, r, f) {
+			return
+		}
+		if !s.loadRoot(w, req) {
+			return
+		}
+		s.handleArchive(w, req)
+		return
+	}
+
+	var faultPath string
+	switch {
+	case segs[0] == "repos" && (rest == "contents" || strings.HasPrefix(rest, "contents/")):
+		faultPath = strings.Trim(strings.TrimPrefix(rest, "contents"), "/")
+	case segs[0] == "raw":
+		if parts := strings.SplitN(rest, "/", 2); len(parts) == 2 {
+			faultPath = parts[1]
+		}
+	}
+	if f := s.fault(faultPath); f != nil && writeFault(w, r, f) {
+		return
+	}
+	if segs[0] == "repos" {
+		if !s.authorize(w, r, req.owner, req.repo) {
+			return
+		}
+		if to, ok := s.renamed(req.owner + "/" + req.repo); ok {
+			u := *r.URL
+			u.Path = "/repos/" + to
+			if rest != "" {
+				u.Path += "/" + rest
+			}
+			http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+			return
+		}
+	}
+
+	// Serve the conditional requests as Github does, by the ETag of the response.
+	out, rec := w, httptest.NewRecorder()
+	defer func() {
+		for k, v := range rec.Header() {
+			out.Header()[k] = v
+		}
+		if rec.Code == http.StatusOK {
+			etag := fmt.Sprintf(`"%x"`, sha1.Sum(rec.Body.Bytes()))
+			out.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				out.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		out.WriteHeader(rec.Code)
+		out.Write(rec.Body.Bytes())
+	}()
+	w = rec
+
+	switch segs[0] {
+	case "repos":
+		switch {
+		case rest == "":
+			s.handleRepo(w, req)
+		case rest == "contents" || strings.HasPrefix(rest, "contents/"):
+			if !s.loadRoot(w, req) {
+				return
+			}
+			s.handleContents(w, req, strings.Trim(strings.TrimPrefix(rest, "contents"), "/"))
+		case strings.HasPrefix(rest, "git/trees/"):
+			req.ref = strings.TrimPrefix(rest, "git/trees/")
+			if !s.loadRoot(w, req) {
+				return
+			}
+			s.handleTree(w, req, r.URL.Query().Get("recursive") != "")
+		case rest == "commits":
+			req.ref = r.URL.Query().Get("sha")
+			if !s.loadRoot(w, req) {
+				return
+			}
+			s.handleCommits(w, req, r.URL.Query().Get("path"), r.URL.Query().Get("until"))
+		case rest == "tags":
+			s.handleTags(w, r, req)
+		case strings.HasPrefix(rest, "git/blobs/"):
+			if !s.loadRoot(w, req) {
+				return
+			}
+			s.handleBlob(w, req, strings.TrimPrefix(rest, "git/blobs/"), strings.Contains(r.Header.Get("Accept"), ".raw"))
+		default:
+			writeError(w, http.StatusNotFound, "Not Found")
+		}
+	case "raw":
+		// raw/{owner}/{repo}/{ref}/{path}
+		parts := strings.SplitN(rest, "/", 2)
+		if len(parts) != 2 {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		req.ref = parts[0]
+		if !s.loadRoot(w, req) {
+			return
+		}
+		s.handleRaw(w, req, parts[1])
+	case "orgs", "users":
+		// orgs/{org}/repos or users/{user}/repos
+		if req.repo != "repos" || rest != "" {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		s.handleRepos(w, r, req)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// loadRoot loads the repository tree of the request. It writes the error response and returns false on failure.
+func (s *Server) loadRoot(w http.ResponseWriter, req *request) bool {
+	name := req.owner + "/" + req.repo
+	dir, ok := s.repos[name+"@"+req.ref]
+	if !ok {
+		dir, ok = s.repos[name]
+	}
+	if !ok {
+		if req.ref != "" && s.hasRefs(req.owner, req.repo) {
+			writeError(w, http.StatusNotFound, "No commit found for the ref "+req.ref)
+			return false
+		}
+		writeError(w, http.StatusNotFound, "Not Found")
+		return false
+	}
+	root, err := load(dir, "")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	req.root = root
+	return true
+}
+
+func (s *Server) handleContents(w http.ResponseWriter, req *request, p string) {
+	n := req.root.lookup(p)
+	if n == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	if n.isDir() {
+		entries := make([]*github.RepositoryContent, 0, len(n.children))
+		for _, child := range n.children {
+			entries = append(entries, req.content(child, false))
+		}
+		writeJSON(w, entries)
+		return
+	}
+
+	// For a symlink pointing to a regular file, the content of the target file is returned.
+	if n.mode == githash.ModeSymlink {
+		target := path.Join(path.Dir(n.path), string(n.content))
+		if tn := req.root.lookup(target); tn != nil && !strings.HasPrefix(target, "../") && tn.mode != githash.ModeSymlink && !tn.isDir() {
+			n = tn
+		}
+	}
+	writeJSON(w, req.content(n, true))
+}
+
+func (s *Server) handleTree(w http.ResponseWriter, req *request, recursive bool) {
+	n := req.root
+	if req.ref != "HEAD" {
+		if tn := req.root.find(func(n *node) bool { return n.isDir() && n.sha == req.ref }); tn != nil {
+			n = tn
+		}
+	}
+
+	var entries []*github.TreeEntry
+	var add func(n *node, prefix string)
+	add = func(n *node, prefix string) {
+		for _, child := range n.children {
+			entry := &github.TreeEntry{
+				Path: github.String(path.Join(prefix, child.name)),
+				Mode: github.String(child.mode),
+				Type: github.String(child.gitType()),
+				SHA:  github.String(child.sha),
+				URL:  github.String(req.gitURL(child)),
+			}
+			if !child.isDir() {
+				entry.Size = github.Int(len(child.content))
+			}
+			entries = append(entries, entry)
+			if recursive && child.isDir() {
+				add(child, path.Join(prefix, child.name))
+			}
+		}
+	}
+	add(n, "")
+
+	s.mu.Lock()
+	limit := s.treeLimit
+	s.mu.Unlock()
+	truncated := recursive && limit > 0 && len(entries) > limit
+	if truncated {
+		entries = entries[:limit]
+	}
+
+	writeJSON(w, &github.Tree{
+		SHA:       github.String(n.sha),
+		Entries:   entries,
+		Truncated: github.Bool(truncated),
+	})
+}
+
+func (s *Server) handleBlob(w http.ResponseWriter, req *request, sha string, raw bool) {
+	n := req.root.find(func(n *node) bool { return !n.isDir() && n.sha == sha })
+	if n == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	if raw {
+		w.Header().Set("Content-Type", "application/vnd.github.v3.raw")
+		w.Write(n.content)
+		return
+	}
+	writeJSON(w, &github.Blob{
+		SHA:      github.String(n.sha),
+		Size:     github.Int(len(n.content)),
+		URL:      github.String(req.gitURL(n)),
+		Content:  github.String(encodeContent(n.content)),
+		Encoding: github.String("base64"),
+	})
+}
+
+func (s *Server) handleCommits(w http.ResponseWriter, req *request, p, until string) {
+	commits := []*github.RepositoryCommit{}
+	if until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		if t.Before(CommitDate) {
+			writeJSON(w, commits)
+			return
+		}
+	}
+	if p != "" && req.root.lookup(strings.Trim(p, "/")) == nil {
+		writeJSON(w, commits)
+		return
+	}
+	writeJSON(w, append(commits, req.commit()))
+}
+
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request, req *request) {
+	prefix := req.owner + "/" + req.repo + "@"
+	var names []string
+	for key := range s.repos {
+		if strings.HasPrefix(key, prefix) {
+			names = append(names, strings.TrimPrefix(key, prefix))
+		}
+	}
+	if len(names) == 0 {
+		if _, ok := s.repos[req.owner+"/"+req.repo]; !ok {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+	}
+	sort.Strings(names)
+	lo, hi := paginate(w, r, len(names))
+
+	tags := []*github.RepositoryTag{}
+	for _, name := range names[lo:hi] {
+		req.ref = name
+		if !s.loadRoot(w, req) {
+			return
+		}
+		commit := req.commit()
+		tags = append(tags, &github.RepositoryTag{
+			Name: github.String(name),
+			Commit: &github.Commit{
+				SHA: commit.SHA,
+				URL: commit.URL,
+			},
+		})
+	}
+	writeJSON(w, tags)
+}
+
+// handleArchive writes the gzipped tarball of the repository, whose entries are under the top level directory named
+// "{owner}-{repo}-{short SHA}", as Github does.
+func (s *Server) handleArchive(w http.ResponseWriter, req *request) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	prefix := fmt.Sprintf("%s-%s-%s/", req.owner, req.repo, req.commit().GetSHA()[:7])
+	var write func(n *node, ignores []exportIgnore) error
+	write = func(n *node, ignores []exportIgnore) error {
+		for _, ignore := range ignores {
+			if ignore.matches(n) {
+				return nil
+			}
+		}
+		if n.isDir() {
+			ignores = append(ignores[:len(ignores):len(ignores)], exportIgnores(n)...)
+		}
+		hdr := &tar.Header{Name: prefix + n.path, ModTime: CommitDate}
+		switch n.mode {
+		case githash.ModeDir:
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0775
+			if n.path != "" {
+				hdr.Name += "/"
+			}
+		case githash.ModeSymlink:
+			hdr.Typeflag, hdr.Mode, hdr.Linkname = tar.TypeSymlink, 0777, string(n.content)
+		case githash.ModeExecutable:
+			hdr.Typeflag, hdr.Mode, hdr.Size = tar.TypeReg, 0775, int64(len(n.content))
+		default:
+			hdr.Typeflag, hdr.Mode, hdr.Size = tar.TypeReg, 0664, int64(len(n.content))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write(n.content); err != nil {
+				return err
+			}
+		}
+		for _, child := range n.children {
+			if err := write(child, ignores); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := write(req.root, nil); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := tw.Close(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := gw.Close(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-gzip")
+	w.Write(buf.Bytes())
+}
+
+// exportIgnore is a pattern of the .gitattributes file in the directory dir, with the export-ignore attribute set.
+type exportIgnore struct {
+	dir     string
+	pattern string
+}
+
+// exportIgnores returns the export-ignore patterns of the .gitattributes file in the directory, if any.
+func exportIgnores(dir *node) []exportIgnore {
+	var ignores []exportIgnore
+	for _, child := range dir.children {
+		if child.name != ".gitattributes" || child.mode == githash.ModeSymlink || child.isDir() {
+			continue
+		}
+		for _, line := range strings.Split(string(child.content), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			for _, attr := range fields[1:] {
+				if attr == "export-ignore" {
+					ignores = append(ignores, exportIgnore{dir: dir.path, pattern: fields[0]})
+				}
+			}
+		}
+	}
+	return ignores
+}
+
+// matches tells whether the pattern matches the node, which is a subset of the gitattributes matching: a pattern
+// without a slash matches the name at any depth, otherwise it matches the path relative to the directory, both by
+// path.Match.
+func (e exportIgnore) matches(n *node) bool {
+	if e.dir != "" && !strings.HasPrefix(n.path, e.dir+"/") {
+		return false
+	}
+	if !strings.Contains(e.pattern, "/") {
+		ok, _ := path.Match(e.pattern, n.name)
+		return ok
+	}
+	rel := strings.TrimPrefix(strings.TrimPrefix(n.path, e.dir), "/")
+	ok, _ := path.Match(strings.TrimPrefix(e.pattern, "/"), rel)
+	return ok
+}
+
+// SetTreeLimit limits the number of the entries returned by the recursive Git Trees API, beyond which the tree is
+// truncated, as Github does for a large repository. Zero means no limit.
+func (s *Server) SetTreeLimit(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.treeLimit = n
+}
+
+// DefaultContentLimit is the size of the largest file whose content is returned by the Contents API, as Github does.
+const DefaultContentLimit = 1 << 20
+
+// SetContentLimit sets the size of the largest file whose content is returned by the Contents API, which defaults to
+// DefaultContentLimit. The larger files are returned with an empty content and the encoding "none", as Github does
+// for the files between 1 MB and 100 MB.
+func (s *Server) SetContentLimit(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contentLimit = n
+}
+
+// RenameRepo renames the repository from to the repository to, both of the form "owner/repo", as Github does when
+// a repository is renamed or transferred: the API requests against from are redirected to to with 301 Moved
+// Permanently. The to is expected to be served by the Server, while from is expected not to.
+func (s *Server) RenameRepo(from, to string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.renames == nil {
+		s.renames = map[string]string{}
+	}
+	s.renames[from] = to
+}
+
+// RepoFlags are the flags of a repository reported by the Server, see SetRepoFlags.
+type RepoFlags struct {
+	Fork     bool
+	Archived bool
+}
+
+// SetRepoFlags sets the flags of the repository name, of the form "owner/repo", reported by the repository metadata
+// and the repository listings.
+func (s *Server) SetRepoFlags(name string, flags RepoFlags) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.repoFlags == nil {
+		s.repoFlags = map[string]RepoFlags{}
+	}
+	s.repoFlags[name] = flags
+}
+
+// renamed returns the new name of the repository, if it is renamed.
+func (s *Server) renamed(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	to, ok := s.renames[name]
+	return to, ok
+}
+
+// handleRepo writes the repository, whose default branch is always "main".
+func (s *Server) handleRepo(w http.ResponseWriter, req *request) {
+	name := req.owner + "/" + req.repo
+	if _, ok := s.repos[name]; !ok && !s.hasRefs(req.owner, req.repo) {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, s.repository(req, req.repo))
+}
+
+// repository returns the repository of the request owner with the given name.
+func (s *Server) repository(req *request, name string) *github.Repository {
+	s.mu.Lock()
+	flags := s.repoFlags[req.owner+"/"+name]
+	s.mu.Unlock()
+	return &github.Repository{
+		Name:          github.String(name),
+		FullName:      github.String(req.owner + "/" + name),
+		Owner:         &github.User{Login: github.String(req.owner)},
+		URL:           github.String(req.baseURL + "repos/" + req.owner + "/" + name),
+		DefaultBranch: github.String("main"),
+		Fork:          github.Bool(flags.Fork),
+		Archived:      github.Bool(flags.Archived),
+	}
+}
+
+// handleRepos lists the repositories of the owner.
+func (s *Server) handleRepos(w http.ResponseWriter, r *http.Request, req *request) {
+	seen := map[string]bool{}
+	var names []string
+	for key := range s.repos {
+		name := strings.SplitN(key, "@", 2)[0]
+		parts := strings.SplitN(name, "/", 2)
+		if len(parts) != 2 || parts[0] != req.owner || seen[parts[1]] {
+			continue
+		}
+		seen[parts[1]] = true
+		names = append(names, parts[1])
+	}
+	if len(names) == 0 {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	sort.Strings(names)
+	lo, hi := paginate(w, r, len(names))
+
+	repos := []*github.Repository{}
+	for _, name := range names[lo:hi] {
+		repos = append(repos, s.repository(req, name))
+	}
+	writeJSON(w, repos)
+}
+
+// handleSearchRepos searches the repositories, sorted by their full names.
+func (s *Server) handleSearchRepos(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-RateLimit-Resource", "search")
+	w.Header().Set("X-RateLimit-Limit", "30")
+	w.Header().Set("X-RateLimit-Remaining", "30")
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+	var owners, keywords []string
+	for _, term := range strings.Fields(r.URL.Query().Get("q")) {
+		qualifier, value, ok := strings.Cut(term, ":")
+		switch {
+		case !ok:
+			keywords = append(keywords, term)
+		case qualifier == "org" || qualifier == "user":
+			owners = append(owners, value)
+		default:
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+			return
+		}
+	}
+
+	seen := map[string]bool{}
+	var names []string
+	for key := range s.repos {
+		name := strings.SplitN(key, "@", 2)[0]
+		parts := strings.SplitN(name, "/", 2)
+		if len(parts) != 2 || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if len(owners) > 0 && !slices.Contains(owners, parts[0]) {
+			continue
+		}
+		matched := true
+		for _, keyword := range keywords {
+			if !strings.Contains(parts[1], keyword) {
+				matched = false
+			}
+		}
+		if matched {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	lo, hi := paginate(w, r, len(names))
+
+	result := &github.RepositoriesSearchResult{
+		Total:             github.Int(len(names)),
+		IncompleteResults: github.Bool(false),
+		Repositories:      []*github.Repository{},
+	}
+	for _, name := range names[lo:hi] {
+		owner, repo, _ := strings.Cut(name, "/")
+		repository := s.repository(&request{baseURL: "http://" + r.Host + "/", owner: owner}, repo)
+		result.Repositories = append(result.Repositories, repository)
+	}
+	writeJSON(w, result)
+}
+
+type graphQLRequest struct {
+	Query     string            `json:"query"`
+	Variables map[string]string `json:"variables"`
+}
+
+type graphQLTreeEntry struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Mode   int    `json:"mode"`
+	OID    string `json:"oid"`
+	Object struct {
+		ByteSize *int `json:"byteSize,omitempty"`
+	} `json:"object"`
+}
+
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var greq graphQLRequest
+	if err := json.NewDecoder(r.Body).Decode(&greq); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	owner, repo := greq.Variables["owner"], greq.Variables["name"]
+	if _, ok := s.repos[owner+"/"+repo]; !ok && !s.hasRefs(owner, repo) {
+		writeJSON(w, map[string]interface{}{
+			"data": map[string]interface{}{"repository": nil},
+			"errors": []map[string]interface{}{
+				{
+					"type":    "NOT_FOUND",
+					"path":    []string{"repository"},
+					"message": fmt.Sprintf("Could not resolve to a Repository with the name '%s/%s'.", owner, repo),
+				},
+			},
+		})
+		return
+	}
+
+	repository := map[string]interface{}{}
+	for alias, expr := range greq.Variables {
+		if alias == "owner" || alias == "name" {
+			continue
+		}
+		repository[alias] = nil
+		parts := strings.SplitN(expr, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		dir, ok := s.repos[owner+"/"+repo+"@"+parts[0]]
+		if !ok {
+			if dir, ok = s.repos[owner+"/"+repo]; !ok {
+				continue
+			}
+		}
+		root, err := load(dir, "")
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		n := root.lookup(strings.Trim(parts[1], "/"))
+		if n == nil {
+			continue
+		}
+		if !n.isDir() {
+			// The fragment on Tree doesn't apply to a blob
+			repository[alias] = map[string]interface{}{}
+			continue
+		}
+		entries := make([]graphQLTreeEntry, 0, len(n.children))
+		for _, child := range n.children {
+			mode, _ := strconv.ParseInt(child.mode, 8, 32)
+			entry := graphQLTreeEntry{
+				Name: child.name,
+				Type: child.gitType(),
+				Mode: int(mode),
+				OID:  child.sha,
+			}
+			if !child.isDir() {
+				entry.Object.ByteSize = github.Int(len(child.content))
+			}
+			entries = append(entries, entry)
+		}
+		repository[alias] = map[string]interface{}{"entries": entries}
+	}
+	writeJSON(w, map[string]interface{}{
+		"data": map[string]interface{}{"repository": repository},
+	})
+}
+
+// hasRefs tells whether any ref of the repository is served.
+func (s *Server) hasRefs(owner, repo string) bool {
+	prefix := owner + "/" + repo + "@"
+	for key := range s.repos {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleRaw(w http.ResponseWriter, req *request, p string) {
+	n := req.root.lookup(p)
+	if n == nil || n.isDir() {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(n.content)
+}
+
+func (req *request) content(n *node, includeContent bool) *github.RepositoryContent {
+	ref := req.ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	c := &github.RepositoryContent{
+		Type:    github.String(n.contentType()),
+		Name:    github.String(n.name),
+		Path:    github.String(n.path),
+		SHA:     github.String(n.sha),
+		Size:    github.Int(len(n.content)),
+		URL:     github.String(fmt.Sprintf("%srepos/%s/%s/contents/%s?ref=%s", req.baseURL, req.owner, req.repo, n.path, ref)),
+		GitURL:  github.String(req.gitURL(n)),
+		HTMLURL: github.String(fmt.Sprintf("%s%s/%s/%s/%s/%s", req.baseURL, req.owner, req.repo, n.gitType(), ref, n.path)),
+	}
+	if n.isDir() {
+		c.Size = github.Int(0)
+		return c
+	}
+	c.DownloadURL = github.String(fmt.Sprintf("%sraw/%s/%s/%s/%s", req.baseURL, req.owner, req.repo, ref, n.path))
+	if !includeContent {
+		return c
+	}
+	if n.mode == githash.ModeSymlink {
+		c.Target = github.String(string(n.content))
+		return c
+	}
+	// The content of a large file is omitted, which is to be downloaded
+	if len(n.content) > req.contentLimit {
+		c.Encoding = github.String("none")
+		c.Content = github.String("")
+		return c
+	}
+	c.Encoding = github.String("base64")
+	c.Content = github.String(encodeContent(n.content))
+	return c
+}
+
+// CommitDate is the author and committer date of the commits served by the Server.
+var CommitDate = time.Date(2020, 9, 30, 0, 0, 0, 0, time.UTC)
+
+// commit returns the commit representing the current state of the repository.
+func (req *request) commit() *github.RepositoryCommit {
+	sha := fmt.Sprintf("%x", sha1.Sum([]byte("commit "+req.root.sha)))
+	author := &github.CommitAuthor{
+		Date:  &CommitDate,
+		Name:  github.String("ghwalktest"),
+		Email: github.String("ghwalktest@example.com"),
+	}
+	return &github.RepositoryCommit{
+		SHA: github.String(sha),
+		Commit: &github.Commit{
+			SHA:       github.String(sha),
+			Message:   github.String("fixture of " + req.owner + "/" + req.repo),
+			Author:    author,
+			Committer: author,
+			Tree:      &github.Tree{SHA: github.String(req.root.sha)},
+		},
+		URL:     github.String(fmt.Sprintf("%srepos/%s/%s/commits/%s", req.baseURL, req.owner, req.repo, sha)),
+		HTMLURL: github.String(fmt.Sprintf("%s%s/%s/commit/%s", req.baseURL, req.owner, req.repo, sha)),
+	}
+}
+
+func (req *request) gitURL(n *node) string {
+	return fmt.Sprintf("%srepos/%s/%s/git/%ss/%s", req.baseURL, req.owner, req.repo, n.gitType(), n.sha)
+}
+
+// encodeContent encodes the content in base64, wrapped at 60 characters per line as Github does.
+func encodeContent(content []byte) string {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	var sb strings.Builder
+	for len(encoded) > 60 {
+		sb.WriteString(encoded[:60] + "\n")
+		encoded = encoded[60:]
+	}
+	sb.WriteString(encoded + "\n")
+	return sb.String()
+}
+
+// paginate returns the range of the n items in the page requested by the "page" and "per_page" query parameters
+// (which default to 1 and 30, as Github does), and sets the Link header pointing to the next and last pages.
+func paginate(w http.ResponseWriter, r *http.Request, n int) (int, int) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = 30
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+	lastPage := (n + perPage - 1) / perPage
+	if page < lastPage {
+		link := func(page int, rel string) string {
+			u := *r.URL
+			u.Scheme, u.Host = "http", r.Host
+			q := u.Query()
+			q.Set("page", strconv.Itoa(page))
+			q.Set("per_page", strconv.Itoa(perPage))
+			u.RawQuery = q.Encode()
+			return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+		}
+		w.Header().Set("Link", link(page+1, "next")+", "+link(lastPage, "last"))
+	}
+	lo, hi := (page-1)*perPage, page*perPage
+	if lo > n {
+		lo = n
+	}
+	if hi > n {
+		hi = n
+	}
+	return lo, hi
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":           message,
+		"documentation_url": "https://docs.github.com/rest",
+	})
+}
@@ -0,0 +1,319 @@
+package ghwalktest
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, srv *Server) *github.Client {
+	client := github.NewClient(nil)
+	u, err := url.Parse(srv.BaseURL())
+	require.NoError(t, err)
+	client.BaseURL = u
+	return client
+}
+
+func TestServerContents(t *testing.T) {
+	srv := NewServer(map[string]string{"magodo/ghwalk": ".."})
+	defer srv.Close()
+	client := newTestClient(t, srv)
+	ctx := context.Background()
+
+	_, dir, _, err := client.Repositories.GetContents(ctx, "magodo", "ghwalk", "testdata", nil)
+	require.NoError(t, err)
+	var names, types []string
+	for _, c := range dir {
+		names = append(names, c.GetName())
+		types = append(types, c.GetType())
+	}
+	require.Equal(t, []string{"a", "b", "dir", "link_dir"}, names)
+	require.Equal(t, []string{"file", "file", "dir", "symlink"}, types)
+
+	file, _, _, err := client.Repositories.GetContents(ctx, "magodo", "ghwalk", "testdata/a", nil)
+	require.NoError(t, err)
+	require.Equal(t, "6069a889501d80bf232556e5397cf1c230960a5c", file.GetSHA())
+	content, err := file.GetContent()
+	require.NoError(t, err)
+	require.Equal(t, "content of a\n", content)
+
+	link, _, _, err := client.Repositories.GetContents(ctx, "magodo", "ghwalk", "testdata/link_dir", nil)
+	require.NoError(t, err)
+	require.Equal(t, "symlink", link.GetType())
+	require.Equal(t, "dir", link.GetTarget())
+
+	_, _, _, err = client.Repositories.GetContents(ctx, "magodo", "ghwalk", "testdata/non_existent", nil)
+	require.Error(t, err)
+	_, _, _, err = client.Repositories.GetContents(ctx, "magodo", "non_existent", "", nil)
+	require.Error(t, err)
+}
+
+func TestServerTreesAndBlobs(t *testing.T) {
+	srv := NewServer(map[string]string{"magodo/ghwalk": "../testdata"})
+	defer srv.Close()
+	client := newTestClient(t, srv)
+	ctx := context.Background()
+
+	tree, _, err := client.Git.GetTree(ctx, "magodo", "ghwalk", "HEAD", true)
+	require.NoError(t, err)
+	// The same as `git rev-parse HEAD:testdata`
+	require.Equal(t, "96de04f1113f0adc6bd407e00afb0cf723e89ab7", tree.GetSHA())
+	var paths []string
+	for _, e := range tree.Entries {
+		paths = append(paths, e.GetPath())
+	}
+	require.Equal(t, []string{"a", "b", "dir", "dir/c", "link_dir"}, paths)
+
+	subtree, _, err := client.Git.GetTree(ctx, "magodo", "ghwalk", "76f49cc8f7110196ec370864801ce6ab09704e32", false)
+	require.NoError(t, err)
+	require.Len(t, subtree.Entries, 1)
+	require.Equal(t, "c", subtree.Entries[0].GetPath())
+
+	blob, _, err := client.Git.GetBlob(ctx, "magodo", "ghwalk", "203ca1a091ca32c39dd43d375e7ac394ee21dcaf")
+	require.NoError(t, err)
+	require.Equal(t, 20, blob.GetSize())
+	require.Equal(t, "base64", blob.GetEncoding())
+
+	srv.SetTreeLimit(2)
+	tree, _, err = client.Git.GetTree(ctx, "magodo", "ghwalk", "HEAD", true)
+	require.NoError(t, err)
+	require.True(t, tree.GetTruncated())
+	require.Len(t, tree.Entries, 2)
+}
+
+func TestServerRepos(t *testing.T) {
+	srv := NewServer(map[string]string{
+		"magodo/ghwalk":    "../testdata",
+		"magodo/ghwalk@v1": "../testdata/dir",
+		"magodo/other":     "../testdata/dir",
+		"someone/ghwalk":   "../testdata",
+	})
+	defer srv.Close()
+	client := newTestClient(t, srv)
+	ctx := context.Background()
+
+	repos, _, err := client.Repositories.ListByOrg(ctx, "magodo", nil)
+	require.NoError(t, err)
+	var names []string
+	for _, repo := range repos {
+		names = append(names, repo.GetFullName())
+	}
+	require.Equal(t, []string{"magodo/ghwalk", "magodo/other"}, names)
+
+	repos, resp, err := client.Repositories.ListByOrg(ctx, "magodo", &github.RepositoryListByOrgOptions{ListOptions: github.ListOptions{PerPage: 1}})
+	require.NoError(t, err)
+	require.Len(t, repos, 1)
+	require.Equal(t, 2, resp.NextPage)
+	require.Equal(t, 2, resp.LastPage)
+	repos, resp, err = client.Repositories.ListByOrg(ctx, "magodo", &github.RepositoryListByOrgOptions{ListOptions: github.ListOptions{PerPage: 1, Page: 2}})
+	require.NoError(t, err)
+	require.Equal(t, "magodo/other", repos[0].GetFullName())
+	require.Equal(t, 0, resp.NextPage)
+
+	_, _, err = client.Repositories.ListByOrg(ctx, "nobody", nil)
+	require.Error(t, err)
+
+	srv.SetRepoFlags("someone/ghwalk", RepoFlags{Fork: true, Archived: true})
+	repos, _, err = client.Repositories.List(ctx, "someone", nil)
+	require.NoError(t, err)
+	require.Len(t, repos, 1)
+	require.Equal(t, "someone/ghwalk", repos[0].GetFullName())
+	require.True(t, repos[0].GetFork())
+	require.True(t, repos[0].GetArchived())
+	repo, _, err := client.Repositories.Get(ctx, "magodo", "ghwalk")
+	require.NoError(t, err)
+	require.False(t, repo.GetFork())
+}
+
+func TestServerRenameRepo(t *testing.T) {
+	srv := NewServer(map[string]string{"magodo/ghwalk": "../testdata"})
+	defer srv.Close()
+	srv.RenameRepo("someone/old", "magodo/ghwalk")
+	client := newTestClient(t, srv)
+	ctx := context.Background()
+
+	repo, _, err := client.Repositories.Get(ctx, "someone", "old")
+	require.NoError(t, err)
+	require.Equal(t, "magodo/ghwalk", repo.GetFullName())
+	require.Equal(t, "main", repo.GetDefaultBranch())
+
+	fc, _, _, err := client.Repositories.GetContents(ctx, "someone", "old", "dir/c", &github.RepositoryContentGetOptions{Ref: "HEAD"})
+	require.NoError(t, err)
+	content, err := fc.GetContent()
+	require.NoError(t, err)
+	require.Equal(t, "content of c in dir\n", content)
+
+	resp, err := http.Get(srv.URL + "/repos/someone/old/contents/a")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, srv.URL+"/repos/magodo/ghwalk/contents/a", resp.Request.URL.String())
+
+	_, _, err = client.Repositories.Get(ctx, "someone", "other")
+	require.Error(t, err)
+}
+
+func TestServerRateLimit(t *testing.T) {
+	srv := NewServer(map[string]string{"magodo/ghwalk": "../testdata"})
+	defer srv.Close()
+	client := newTestClient(t, srv)
+	ctx := context.Background()
+
+	// The client is unauthenticated
+	limits, resp, err := client.RateLimits(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 60, limits.Core.Limit)
+	require.Equal(t, 60, limits.Core.Remaining)
+	require.True(t, limits.Core.Reset.After(time.Now()))
+	require.Equal(t, 60, resp.Rate.Limit)
+
+	srv.SetRateLimit(60, 3)
+	_, resp, err = client.Repositories.Get(ctx, "magodo", "ghwalk")
+	require.NoError(t, err)
+	require.Equal(t, 60, resp.Rate.Limit)
+	require.Equal(t, 3, resp.Rate.Remaining)
+}
+
+func TestServerArchive(t *testing.T) {
+	srv := NewServer(map[string]string{"magodo/ghwalk": "../testdata"})
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/magodo/ghwalk/archive/HEAD.tar.gz")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	gr, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		name := hdr.Name
+		if hdr.Typeflag == tar.TypeSymlink {
+			name += " -> " + hdr.Linkname
+		}
+		names = append(names, name)
+	}
+	require.Equal(t, []string{
+		"magodo-ghwalk-505b532/",
+		"magodo-ghwalk-505b532/a",
+		"magodo-ghwalk-505b532/b",
+		"magodo-ghwalk-505b532/dir/",
+		"magodo-ghwalk-505b532/dir/c",
+		"magodo-ghwalk-505b532/link_dir -> dir",
+	}, names)
+
+	resp, err = http.Get(srv.URL + "/magodo/nonexist/archive/HEAD.tar.gz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestServerTokens(t *testing.T) {
+	srv := NewServer(map[string]string{"magodo/ghwalk": "../testdata", "acme/private": "../testdata"})
+	defer srv.Close()
+	ctx := context.Background()
+
+	newClient := func(token string) *github.Client {
+		if token == "" {
+			return newTestClient(t, srv)
+		}
+		client := github.NewClient(&http.Client{Transport: &tokenTransport{token: token}})
+		u, err := url.Parse(srv.BaseURL())
+		require.NoError(t, err)
+		client.BaseURL = u
+		return client
+	}
+
+	// Any token is accepted before any token is registered
+	user, _, err := newClient("any").Users.Get(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, "ghwalktest", user.GetLogin())
+
+	srv.AddToken("secret", Token{Login: "alice", Scopes: []string{"public_repo"}, Repos: []string{"magodo/ghwalk"}})
+
+	user, resp, err := newClient("secret").Users.Get(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, "alice", user.GetLogin())
+	require.Equal(t, "public_repo", resp.Header.Get("X-OAuth-Scopes"))
+
+	_, _, err = newClient("secret").Repositories.Get(ctx, "magodo", "ghwalk")
+	require.NoError(t, err)
+	_, resp, err = newClient("secret").Repositories.Get(ctx, "acme", "private")
+	require.Error(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	_, resp, err = newClient("other").Repositories.Get(ctx, "magodo", "ghwalk")
+	require.Error(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// The unauthenticated requests are not restricted
+	_, _, err = newClient("").Repositories.Get(ctx, "acme", "private")
+	require.NoError(t, err)
+	_, resp, err = newClient("").Users.Get(ctx, "")
+	require.Error(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+type tokenTransport struct {
+	token string
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token "+t.token)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestServerSearchRepos(t *testing.T) {
+	srv := NewServer(map[string]string{
+		"magodo/ghwalk":    "../testdata",
+		"magodo/ghwalk@v1": "../testdata/dir",
+		"magodo/other":     "../testdata/dir",
+		"someone/ghwalk":   "../testdata",
+	})
+	defer srv.Close()
+	client := newTestClient(t, srv)
+	ctx := context.Background()
+
+	cases := []struct {
+		query  string
+		expect []string
+	}{
+		{query: "ghwalk", expect: []string{"magodo/ghwalk", "someone/ghwalk"}},
+		{query: "org:magodo", expect: []string{"magodo/ghwalk", "magodo/other"}},
+		{query: "ghwalk user:someone", expect: []string{"someone/ghwalk"}},
+		{query: "nothing", expect: nil},
+	}
+	for _, c := range cases {
+		result, resp, err := client.Search.Repositories(ctx, c.query, nil)
+		require.NoError(t, err, c.query)
+		var names []string
+		for _, repo := range result.Repositories {
+			names = append(names, repo.GetFullName())
+		}
+		require.Equal(t, c.expect, names, c.query)
+		require.Equal(t, len(c.expect), result.GetTotal(), c.query)
+		require.Equal(t, 30, resp.Rate.Limit, c.query)
+	}
+
+	result, resp, err := client.Search.Repositories(ctx, "org:magodo", &github.SearchOptions{ListOptions: github.ListOptions{PerPage: 1}})
+	require.NoError(t, err)
+	require.Len(t, result.Repositories, 1)
+	require.Equal(t, 2, resp.NextPage)
+
+	_, _, err = client.Search.Repositories(ctx, "stars:>10", nil)
+	require.Error(t, err)
+}
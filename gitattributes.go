@@ -0,0 +1,218 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+)
+
+// gitAttributesFile is the name of the file defining the git attributes of the paths in the directory (recursively).
+const gitAttributesFile = ".gitattributes"
+
+// attrMacros are the built-in macro attributes, which expand to the other attributes.
+var attrMacros = map[string][]string{
+	"binary": {"-diff", "-merge", "-text"},
+}
+
+// attrRule is a line of the .gitattributes file.
+type attrRule struct {
+	// segments are the slash separated segments of the pattern, relative to the directory of the .gitattributes
+	segments []string
+	// anchored tells whether the pattern is matched against the relative path, rather than the base name
+	anchored bool
+	// dirOnly tells whether the pattern only matches directories
+	dirOnly bool
+	// attrs are the attributes assigned, where an empty value means the attribute is unspecified (i.e. "!attr")
+	attrs [][2]string
+}
+
+// parseGitAttributes parses the content of a .gitattributes file. The lines that are not understood (e.g. the
+// macro definitions) are ignored, as git does.
+func parseGitAttributes(content []byte) []attrRule {
+	var rules []attrRule
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[attr]") {
+			continue
+		}
+		pattern := fields[0]
+		if strings.HasPrefix(pattern, "!") {
+			// Negative patterns are forbidden
+			continue
+		}
+
+		var rule attrRule
+		if strings.HasSuffix(pattern, "/") {
+			rule.dirOnly = true
+			pattern = strings.TrimSuffix(pattern, "/")
+		}
+		if strings.Contains(pattern, "/") {
+			rule.anchored = true
+			pattern = strings.TrimPrefix(pattern, "/")
+		}
+		rule.segments = strings.Split(pattern, "/")
+		for _, field := range fields[1:] {
+			rule.attrs = append(rule.attrs, parseAttr(field)...)
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// parseAttr parses an attribute of the forms "attr", "-attr", "!attr" and "attr=value", into the name and value
+// pairs, with the macros expanded.
+func parseAttr(field string) [][2]string {
+	switch {
+	case strings.HasPrefix(field, "-"):
+		return [][2]string{{field[1:], "false"}}
+	case strings.HasPrefix(field, "!"):
+		return [][2]string{{field[1:], ""}}
+	case strings.Contains(field, "="):
+		kv := strings.SplitN(field, "=", 2)
+		return [][2]string{{kv[0], kv[1]}}
+	}
+	attrs := [][2]string{{field, "true"}}
+	for _, expanded := range attrMacros[field] {
+		attrs = append(attrs, parseAttr(expanded)...)
+	}
+	return attrs
+}
+
+// match tells whether the rule matches the slash separated path relative to the directory of the .gitattributes.
+func (r attrRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.segments[0], path.Base(rel))
+		return ok
+	}
+	return matchSegments(r.segments, strings.Split(rel, "/"))
+}
+
+// matchSegments matches the path segments against the pattern segments, where "**" matches zero or more segments,
+// except that a trailing "**" matches one or more, i.e. everything inside.
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) != 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return len(segments) != 0
+			}
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// gitAttributes resolves the git attributes of the paths from the .gitattributes files loaded.
+type gitAttributes struct {
+	// rules are the rules of the .gitattributes files, keyed by their directories
+	rules map[string][]attrRule
+}
+
+// load loads the .gitattributes file in the directory dir, it is a no-op if the file doesn't exist.
+func (g *gitAttributes) load(ctx context.Context, owner, repo, dir string, p ContentProvider) error {
+	info, err := p.ReadFile(ctx, owner, repo, path.Join(dir, gitAttributesFile))
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	content, err := readContent(ctx, info)
+	if err != nil {
+		return err
+	}
+	if g.rules == nil {
+		g.rules = map[string][]attrRule{}
+	}
+	g.rules[dir] = parseGitAttributes(content)
+	return nil
+}
+
+// attributes returns the git attributes of the path, where the set attributes are "true", the unset ones are
+// "false". It returns nil if no attribute is specified.
+func (g *gitAttributes) attributes(p string, isDir bool) map[string]string {
+	var dirs []string
+	for dir := parentDir(p); ; dir = parentDir(dir) {
+		dirs = append(dirs, dir)
+		if dir == "" {
+			break
+		}
+	}
+
+	var attrs map[string]string
+	// The deeper .gitattributes, and the later lines take precedence
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rel := p
+		if dirs[i] != "" {
+			rel = strings.TrimPrefix(p, dirs[i]+"/")
+		}
+		for _, rule := range g.rules[dirs[i]] {
+			if !rule.match(rel, isDir) {
+				continue
+			}
+			for _, attr := range rule.attrs {
+				if attr[1] == "" {
+					delete(attrs, attr[0])
+					continue
+				}
+				if attrs == nil {
+					attrs = map[string]string{}
+				}
+				attrs[attr[0]] = attr[1]
+			}
+		}
+	}
+	return attrs
+}
+
+// IsLinguistVendored tells whether the path is marked as vendored code by the "linguist-vendored" git attribute.
+// The Attributes of the info must be set, see EnableGitAttributes of WalkOptions.
+func IsLinguistVendored(info *FileInfo) bool {
+	return info != nil && info.Attributes["linguist-vendored"] == "true"
+}
+
+// IsLinguistGenerated tells whether the path is marked as generated code by the "linguist-generated" git attribute.
+// The Attributes of the info must be set, see EnableGitAttributes of WalkOptions.
+func IsLinguistGenerated(info *FileInfo) bool {
+	return info != nil && info.Attributes["linguist-generated"] == "true"
+}
+
+// SkipLinguistVendored is a PathFilterFunc skipping the paths marked as vendored code by the "linguist-vendored" git
+// attribute, as Github's language statistics do. It only works along with the EnableGitAttributes of WalkOptions.
+func SkipLinguistVendored(path string, info *FileInfo) bool {
+	return IsLinguistVendored(info)
+}
+
+// SkipLinguistGenerated is a PathFilterFunc skipping the paths marked as generated code by the "linguist-generated"
+// git attribute, as Github's language statistics do. It only works along with the EnableGitAttributes of WalkOptions.
+func SkipLinguistGenerated(path string, info *FileInfo) bool {
+	return IsLinguistGenerated(info)
+}
+
+// AnyFilter returns a PathFilterFunc skipping the paths skipped by any of the filters, e.g.
+// AnyFilter(SkipLinguistVendored, SkipLinguistGenerated). The nil filters are ignored.
+func AnyFilter(filters ...PathFilterFunc) PathFilterFunc {
+	return func(path string, info *FileInfo) bool {
+		for _, filter := range filters {
+			if filter != nil && filter(path, info) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,197 @@
+package ghwalk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitAttributes(t *testing.T) {
+	g := &gitAttributes{
+		rules: map[string][]attrRule{
+			"": parseGitAttributes([]byte(`# comment
+[attr]custom text
+*.go text eol=lf
+*.png binary
+/docs export-ignore
+tests/** export-ignore
+build/ linguist-generated
+vendor/**/*.go linguist-vendored -text
+`)),
+			"sub": parseGitAttributes([]byte(`*.go !eol -text
+`)),
+		},
+	}
+
+	cases := []struct {
+		path   string
+		isDir  bool
+		expect map[string]string
+	}{
+		{path: "README.md"},
+		{path: "main.go", expect: map[string]string{"text": "true", "eol": "lf"}},
+		{path: "img/logo.png", expect: map[string]string{"binary": "true", "diff": "false", "merge": "false", "text": "false"}},
+		{path: "docs", isDir: true, expect: map[string]string{"export-ignore": "true"}},
+		// Attributes don't apply to the paths inside the directory recursively
+		{path: "docs/a.md"},
+		{path: "sub/docs", isDir: true},
+		{path: "tests/a/b", expect: map[string]string{"export-ignore": "true"}},
+		// A trailing "**" only matches the paths inside
+		{path: "tests", isDir: true},
+		{path: "build", isDir: true, expect: map[string]string{"linguist-generated": "true"}},
+		{path: "build"},
+		{path: "vendor/x.go", expect: map[string]string{"linguist-vendored": "true", "text": "false", "eol": "lf"}},
+		{path: "vendor/x/y/z.go", expect: map[string]string{"linguist-vendored": "true", "text": "false", "eol": "lf"}},
+		{path: "sub/main.go", expect: map[string]string{"text": "false"}},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.expect, g.attributes(c.path, c.isDir), c.path)
+	}
+}
+
+func TestWalkWithGitAttributes(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{
+		"foo/bar": newFixture(t, map[string]string{
+			".gitattributes":     "/docs export-ignore\n*.md text\n",
+			"docs/guide.md":      "guide",
+			"src/.gitattributes": "*.gen.go linguist-generated export-ignore\n",
+			"src/a.go":           "package src",
+			"src/a.gen.go":       "package src",
+			"src/README.md":      "readme",
+		}),
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	cases := []struct {
+		path             string
+		skipExportIgnore bool
+		expect           map[string]map[string]string
+		expectSkipped    []string
+	}{
+		{
+			path: "",
+			expect: map[string]map[string]string{
+				"":                   nil,
+				".gitattributes":     nil,
+				"docs":               {"export-ignore": "true"},
+				"docs/guide.md":      {"text": "true"},
+				"src":                nil,
+				"src/.gitattributes": nil,
+				"src/README.md":      {"text": "true"},
+				"src/a.gen.go":       {"linguist-generated": "true", "export-ignore": "true"},
+				"src/a.go":           nil,
+			},
+		},
+		{
+			path:             "",
+			skipExportIgnore: true,
+			expect: map[string]map[string]string{
+				"":                   nil,
+				".gitattributes":     nil,
+				"src":                nil,
+				"src/.gitattributes": nil,
+				"src/README.md":      {"text": "true"},
+				"src/a.go":           nil,
+			},
+			expectSkipped: []string{"docs", "src/a.gen.go"},
+		},
+		{
+			// The .gitattributes of the parent directories apply
+			path:             "src/README.md",
+			skipExportIgnore: true,
+			expect: map[string]map[string]string{
+				"src/README.md": {"text": "true"},
+			},
+		},
+		{
+			path:             "src/a.gen.go",
+			skipExportIgnore: true,
+			expect:           map[string]map[string]string{},
+			expectSkipped:    []string{"src/a.gen.go"},
+		},
+	}
+
+	for idx, c := range cases {
+		attrs := map[string]map[string]string{}
+		var skipped []string
+		err := Walk(ctx, "foo", "bar", c.path,
+			&WalkOptions{
+				BaseURL:             srv.BaseURL(),
+				EnableGitAttributes: true,
+				SkipExportIgnore:    c.skipExportIgnore,
+				OnSkip: func(path string, reason SkipReason) {
+					require.Equal(t, SkipReasonExportIgnore, reason)
+					skipped = append(skipped, path)
+				},
+			},
+			func(path string, info *FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info == nil {
+					attrs[path] = nil
+					return nil
+				}
+				attrs[path] = info.Attributes
+				return nil
+			}, nil)
+		require.NoError(t, err, idx)
+		require.Equal(t, c.expect, attrs, idx)
+		require.Equal(t, c.expectSkipped, skipped, idx)
+	}
+}
+
+func TestWalkSkipLinguist(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{
+		"foo/bar": newFixture(t, map[string]string{
+			".gitattributes": "lib/** linguist-vendored\n*.pb.go linguist-generated\nlib/own.go -linguist-vendored\n",
+			"lib/dep.go":     "package lib",
+			"lib/own.go":     "package lib",
+			"api/api.pb.go":  "package api",
+			"api/api.go":     "package api",
+		}),
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	cases := []struct {
+		filterFn PathFilterFunc
+		expect   []string
+	}{
+		{
+			filterFn: SkipLinguistVendored,
+			expect:   []string{"", ".gitattributes", "api", "api/api.go", "api/api.pb.go", "lib", "lib/own.go"},
+		},
+		{
+			filterFn: SkipLinguistGenerated,
+			expect:   []string{"", ".gitattributes", "api", "api/api.go", "lib", "lib/dep.go", "lib/own.go"},
+		},
+		{
+			filterFn: AnyFilter(nil, SkipLinguistVendored, SkipLinguistGenerated),
+			expect:   []string{"", ".gitattributes", "api", "api/api.go", "lib", "lib/own.go"},
+		},
+	}
+
+	for idx, c := range cases {
+		var paths []string
+		err := Walk(ctx, "foo", "bar", "", &WalkOptions{BaseURL: srv.BaseURL(), EnableGitAttributes: true},
+			func(path string, info *FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				paths = append(paths, path)
+				return nil
+			}, c.filterFn)
+		require.NoError(t, err, idx)
+		require.Equal(t, c.expect, paths, idx)
+	}
+}
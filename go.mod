@@ -1,9 +1,20 @@
 module github.com/magodo/ghwalk
 
-go 1.15
+go 1.23
 
 require (
 	github.com/google/go-github/v32 v32.1.0
 	github.com/stretchr/testify v1.6.1
+	golang.org/x/mod v0.20.0
 	golang.org/x/oauth2 v0.0.0-20200902213428-5d25da1a8d43
+	golang.org/x/text v0.21.0
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/google/go-querystring v1.0.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9 // indirect
+	golang.org/x/net v0.0.0-20200822124328-c89045814202 // indirect
 )
@@ -0,0 +1,87 @@
+// Package githash computes the object IDs that git (and therefore Github) assigns to blobs and trees.
+package githash
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Git file modes, as they appear in tree objects.
+const (
+	ModeFile       = "100644"
+	ModeExecutable = "100755"
+	ModeSymlink    = "120000"
+	ModeDir        = "040000"
+	ModeSubmodule  = "160000"
+)
+
+// TreeEntry is an entry of a git tree object.
+type TreeEntry struct {
+	Mode string
+	Name string
+	SHA  string
+}
+
+// BlobSHA returns the hex encoded object ID of a blob with the given content.
+func BlobSHA(content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ReadBlobSHA returns the hex encoded object ID of a blob whose content of size bytes is read from r, without holding
+// the content in memory.
+func ReadBlobSHA(r io.Reader, size int64) (string, error) {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", size)
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", err
+	}
+	if n != size {
+		return "", fmt.Errorf("read %d bytes of the blob, expected %d", n, size)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// TreeSHA returns the hex encoded object ID of a tree consisting of the given entries.
+func TreeSHA(entries []TreeEntry) (string, error) {
+	entries = append([]TreeEntry(nil), entries...)
+	sort.Slice(entries, func(i, j int) bool {
+		return sortKey(entries[i]) < sortKey(entries[j])
+	})
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		sha, err := hex.DecodeString(e.SHA)
+		if err != nil {
+			return "", fmt.Errorf("invalid SHA %q of entry %q: %v", e.SHA, e.Name, err)
+		}
+		// Git omits the leading zero of the directory mode in tree objects
+		mode := e.Mode
+		if mode == ModeDir {
+			mode = "40000"
+		}
+		fmt.Fprintf(&buf, "%s %s\x00", mode, e.Name)
+		buf.Write(sha)
+	}
+
+	h := sha1.New()
+	fmt.Fprintf(h, "tree %d\x00", buf.Len())
+	h.Write(buf.Bytes())
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sortKey returns the key that git uses to order the tree entries, where directories are compared as if their names
+// had a trailing slash.
+func sortKey(e TreeEntry) string {
+	if e.Mode == ModeDir {
+		return e.Name + "/"
+	}
+	return e.Name
+}
@@ -0,0 +1,40 @@
+package githash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobSHA(t *testing.T) {
+	require.Equal(t, "6069a889501d80bf232556e5397cf1c230960a5c", BlobSHA([]byte("content of a\n")))
+	require.Equal(t, "87245193225f8ff56488ceab0dcd11467fe098d0", BlobSHA([]byte("dir")))
+	require.Equal(t, "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391", BlobSHA(nil))
+
+	sha, err := ReadBlobSHA(strings.NewReader("content of a\n"), 13)
+	require.NoError(t, err)
+	require.Equal(t, "6069a889501d80bf232556e5397cf1c230960a5c", sha)
+	_, err = ReadBlobSHA(strings.NewReader("short"), 13)
+	require.Error(t, err)
+}
+
+func TestTreeSHA(t *testing.T) {
+	dir, err := TreeSHA([]TreeEntry{
+		{Mode: ModeFile, Name: "c", SHA: "203ca1a091ca32c39dd43d375e7ac394ee21dcaf"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "76f49cc8f7110196ec370864801ce6ab09704e32", dir)
+
+	root, err := TreeSHA([]TreeEntry{
+		{Mode: ModeSymlink, Name: "link_dir", SHA: "87245193225f8ff56488ceab0dcd11467fe098d0"},
+		{Mode: ModeDir, Name: "dir", SHA: dir},
+		{Mode: ModeFile, Name: "b", SHA: "0bc67c2f18a9f5f0afcd37927b91db36b6edfd76"},
+		{Mode: ModeFile, Name: "a", SHA: "6069a889501d80bf232556e5397cf1c230960a5c"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "96de04f1113f0adc6bd407e00afb0cf723e89ab7", root)
+
+	_, err = TreeSHA([]TreeEntry{{Mode: ModeFile, Name: "a", SHA: "xyz"}})
+	require.Error(t, err)
+}
@@ -0,0 +1,489 @@
+package ghwalk
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Manifest records the files mirrored to a local directory by Mirror, which allows the next Mirror to only download
+// the files that have changed since then.
+type Manifest struct {
+	Owner string
+	Repo  string
+	Ref   string
+	Path  string
+
+	// Files maps the slash separated path of each mirrored file (relative to Path) to its git blob SHA.
+	Files map[string]string
+
+	// Partial tells that the manifest is of a Mirror stopped halfway, see MirrorError.
+	Partial bool `json:",omitempty"`
+}
+
+// MirrorError is the error returned by Mirror if it stops once it has started to change the local directory, e.g.
+// as the context is canceled. It tells exactly what has been done: each local file is either mirrored, or left as
+// it was before the Mirror, as the files are replaced atomically.
+type MirrorError struct {
+	// Manifest is the partial manifest of the local directory: the files mirrored are recorded with their new SHA,
+	// while the rest of the files of the previous manifest are recorded as they were. It can be passed as the prev
+	// of the next Mirror to continue.
+	Manifest *Manifest
+	// Completed are the slash separated paths (relative to the Path) of the files mirrored before stopping, in the
+	// order they were mirrored, including the ones that were already up to date.
+	Completed []string
+	// Removed are the paths of the files removed from the repository that have been deleted locally.
+	Removed []string
+	// Err is the error that stopped the Mirror.
+	Err error
+}
+
+func (e *MirrorError) Error() string {
+	return fmt.Sprintf("mirror stopped after %d files: %v", len(e.Completed), e.Err)
+}
+
+func (e *MirrorError) Unwrap() error {
+	return e.Err
+}
+
+// Write writes the manifest to w in JSON.
+func (m *Manifest) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// ReadManifest reads a manifest written by Manifest.Write or Manifest.WriteBinary from r, the encoding is detected.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	br := bufio.NewReader(r)
+	var m Manifest
+	if isBinary(br, manifestMagic) {
+		br.Discard(len(manifestMagic))
+		if err := gob.NewDecoder(br).Decode(&m); err != nil {
+			return nil, fmt.Errorf("decoding manifest: %v", err)
+		}
+		return &m, nil
+	}
+	if err := json.NewDecoder(br).Decode(&m); err != nil {
+		return nil, fmt.Errorf("decoding manifest: %v", err)
+	}
+	return &m, nil
+}
+
+// Mirror mirrors the directory path in the repository to the local directory dir, and returns the manifest of the
+// mirrored files. Symlinks are mirrored as symlinks, while submodules are skipped. Files with the git mode 100755 are
+// mirrored with the permission 0755, others with 0644. On a platform where the symlink can't be created (e.g. Windows
+// without the privilege), the content of the link target is copied instead, as long as the target is mirrored as well.
+//
+// If prev, the manifest returned by the previous Mirror to dir, is specified, only the files whose SHA has changed
+// (or that are missing locally) are downloaded, and the files that have been removed from the repository since then
+// are deleted from dir (before anything is written), along with the directories that become empty. The local files
+// that are not recorded in prev are left untouched.
+//
+// The remote tree is fetched with a single call to the Git Trees API if possible (see FindAll), and each file is
+// downloaded via the Git Blobs API, unless opt.Snapshot or opt.Provider is set. Files with identical content (i.e. the
+// same SHA) are only downloaded once, and copied locally for the other paths. In the latter case, or if the tree is
+// too large to be fetched at once, the file mode is unknown and all the files are mirrored as non-executable.
+//
+// Each file is written to a temporary file first, and renamed to replace the local one. The symlinks under dir are
+// never followed: a symlink (or file) in the way of a parent directory, e.g. mirrored before the repository changes
+// it to a directory, is replaced by the directory. Once the context is canceled (or any other error happens) halfway,
+// Mirror stops before the next file, leaving the file being downloaded as it was, and returns the partial manifest
+// along with a *MirrorError.
+func Mirror(ctx context.Context, owner, repo, path, dir string, prev *Manifest, opt *WalkOptions) (*Manifest, error) {
+	path, err := cleanRepoPath(owner, repo, path)
+	if err != nil {
+		return nil, err
+	}
+	opt, err = resolveOptions(ctx, owner, repo, opt)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := listTree(ctx, owner, repo, path, opt)
+	if err != nil {
+		return nil, err
+	}
+	fetch, err := newBlobFetcher(ctx, owner, repo, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		Owner: owner,
+		Repo:  repo,
+		Path:  path,
+		Files: map[string]string{},
+	}
+	if opt != nil {
+		manifest.Ref = opt.Ref
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return lessPath(infos[i].Path, infos[j].Path, false)
+	})
+	// files are the infos of the files to mirror, along with their paths relative to the path
+	var files []*FileInfo
+	var rels []string
+	for _, info := range infos {
+		if info.Path == path {
+			if !info.IsDir() {
+				return nil, fmt.Errorf("%s is not a directory", path)
+			}
+			continue
+		}
+		if info.IsDir() || info.Type == FileTypeSubmodule {
+			continue
+		}
+		rel := info.Path
+		if path != "" {
+			rel = strings.TrimPrefix(rel, path+"/")
+		}
+		files, rels = append(files, info), append(rels, rel)
+	}
+	// links are the symlinks that can't be created, which are copied from their targets once all the files are mirrored
+	var links []string
+	// completed and removed are the files mirrored and deleted so far, see MirrorError
+	var completed, removed []string
+	stop := func(err error) (*Manifest, error) {
+		partial := *manifest
+		partial.Files, partial.Partial = map[string]string{}, true
+		if prev != nil {
+			for rel, sha := range prev.Files {
+				partial.Files[rel] = sha
+			}
+		}
+		// The placeholders of the symlinks (see links) are not the previous files either
+		for _, rel := range links {
+			delete(partial.Files, rel)
+		}
+		for _, rel := range completed {
+			partial.Files[rel] = manifest.Files[rel]
+		}
+		for _, rel := range removed {
+			delete(partial.Files, rel)
+		}
+		return &partial, &MirrorError{Manifest: &partial, Completed: completed, Removed: removed, Err: err}
+	}
+	// The removed files are deleted first, as they might be in the way of the files to write, e.g. a symlink that has
+	// become a directory.
+	if prev != nil {
+		remote := make(map[string]bool, len(rels))
+		for _, rel := range rels {
+			remote[rel] = true
+		}
+		var gone []string
+		for rel := range prev.Files {
+			if !remote[rel] {
+				gone = append(gone, rel)
+			}
+		}
+		sort.Strings(gone)
+		for _, rel := range gone {
+			if err := ctx.Err(); err != nil {
+				return stop(err)
+			}
+			if err := removeLocalFile(dir, rel); err != nil {
+				return stop(err)
+			}
+			removed = append(removed, rel)
+		}
+	}
+
+	// blobs maps the SHA of each mirrored file to its local path, so that the same content is only downloaded once
+	blobs := map[string]string{}
+	for i, info := range files {
+		if err := ctx.Err(); err != nil {
+			return stop(err)
+		}
+		rel := rels[i]
+		manifest.Files[rel] = info.SHA
+
+		local := filepath.Join(dir, filepath.FromSlash(rel))
+		if prev != nil && prev.Files[rel] == info.SHA {
+			if fi, err := lstatLocal(dir, rel); err == nil && !fi.IsDir() {
+				// The mode change doesn't change the SHA
+				if info.Type == FileTypeFile && fi.Mode().IsRegular() && fi.Mode().Perm() != localFileMode(info) {
+					if err := os.Chmod(local, localFileMode(info)); err != nil {
+						return stop(err)
+					}
+				}
+				if info.Type == FileTypeFile {
+					blobs[info.SHA] = local
+				}
+				completed = append(completed, rel)
+				continue
+			}
+		}
+		var content []byte
+		// The transformed content depends on the path
+		if src, ok := blobs[info.SHA]; ok && info.Type == FileTypeFile && (opt == nil || opt.TransformContent == nil) {
+			if content, err = os.ReadFile(src); err != nil {
+				return stop(err)
+			}
+		} else if content, err = fetch(ctx, info); err != nil {
+			return stop(fmt.Errorf("downloading %s: %w", info.Path, err))
+		}
+		linked, err := writeLocalFile(dir, rel, info, content)
+		if err != nil {
+			return stop(err)
+		}
+		// The placeholder of the symlink is completed once replaced by the copy of its target
+		if !linked {
+			links = append(links, rel)
+			continue
+		}
+		if info.Type == FileTypeFile {
+			blobs[info.SHA] = local
+		}
+		completed = append(completed, rel)
+	}
+
+	for _, rel := range links {
+		if err := copyLinkTarget(dir, rel); err != nil {
+			return stop(err)
+		}
+		completed = append(completed, rel)
+	}
+	return manifest, nil
+}
+
+// blobFetcher fetches the raw content of a file, which is the link target for a symlink.
+type blobFetcher func(ctx context.Context, info *FileInfo) ([]byte, error)
+
+// newBlobFetcher returns the blobFetcher of the repository, which transforms the content of the files by the
+// TransformContent of opt, if any.
+func newBlobFetcher(ctx context.Context, owner, repo string, opt *WalkOptions) (blobFetcher, error) {
+	fetch, err := newRawBlobFetcher(ctx, owner, repo, opt)
+	if err != nil || opt == nil || opt.TransformContent == nil {
+		return fetch, err
+	}
+	return func(ctx context.Context, info *FileInfo) ([]byte, error) {
+		content, err := fetch(ctx, info)
+		if err != nil || info.Type != FileTypeFile {
+			return content, err
+		}
+		return transformContent(info.Path, bytes.NewReader(content), opt.TransformContent)
+	}, nil
+}
+
+func newRawBlobFetcher(ctx context.Context, owner, repo string, opt *WalkOptions) (blobFetcher, error) {
+	if opt != nil && (opt.Snapshot != nil || opt.Provider != nil) {
+		p, err := newProvider(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, info *FileInfo) ([]byte, error) {
+			info, err := p.ReadFile(ctx, owner, repo, info.rawPath())
+			if err != nil {
+				return nil, err
+			}
+			if info.FileOnlyInfo != nil && info.FileOnlyInfo.Target != nil {
+				return []byte(*info.FileOnlyInfo.Target), nil
+			}
+			return info.GetContentBytes()
+		}, nil
+	}
+
+	client, err := newClient(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, info *FileInfo) ([]byte, error) {
+		content, _, err := client.Git.GetBlobRaw(ctx, owner, repo, info.SHA)
+		return content, err
+	}, nil
+}
+
+// symlink creates the symlink, which is replaced in tests to simulate a platform without the symlink rights.
+var symlink = os.Symlink
+
+// writeLocalFile writes the file (or symlink) named by the slash separated path rel under dir. For a symlink, it
+// returns false if the symlink can't be created, in which case the link target is written as a placeholder.
+func writeLocalFile(dir, rel string, info *FileInfo, content []byte) (bool, error) {
+	if err := mkdirLocal(dir, parentDir(rel)); err != nil {
+		return false, err
+	}
+	local := filepath.Join(dir, filepath.FromSlash(rel))
+	// A directory can't be replaced by renaming, e.g. if a directory has become a file.
+	if fi, err := os.Lstat(local); err == nil && fi.IsDir() {
+		if err := os.RemoveAll(local); err != nil {
+			return false, err
+		}
+	}
+	if info.Type == FileTypeSymlink {
+		tmp := local + ".ghwalk-tmp"
+		if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		if err := symlink(filepath.FromSlash(string(content)), tmp); err == nil {
+			if err := os.Rename(tmp, local); err != nil {
+				os.Remove(tmp)
+				return false, err
+			}
+			return true, nil
+		}
+		return false, writeFileAtomic(local, content, 0644)
+	}
+	return true, writeFileAtomic(local, content, localFileMode(info))
+}
+
+// mkdirLocal creates the directory named by the slash separated path rel under dir, along with its parents. Unlike
+// os.MkdirAll, the symlinks under dir are not followed: a symlink or file in the way is replaced by the directory, so
+// that nothing is written outside dir.
+func mkdirLocal(dir, rel string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if rel == "" {
+		return nil
+	}
+	local := dir
+	for _, name := range strings.Split(rel, "/") {
+		local = filepath.Join(local, name)
+		fi, err := os.Lstat(local)
+		switch {
+		case err == nil && fi.IsDir():
+			continue
+		case err == nil:
+			if err := os.Remove(local); err != nil {
+				return err
+			}
+		case !os.IsNotExist(err):
+			return err
+		}
+		if err := os.Mkdir(local, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lstatLocal is os.Lstat of the file named by the slash separated path rel under dir, except that the symlinks under
+// dir are not followed: the file doesn't exist if any of its parents is not a directory.
+func lstatLocal(dir, rel string) (os.FileInfo, error) {
+	local := dir
+	if parent := parentDir(rel); parent != "" {
+		for _, name := range strings.Split(parent, "/") {
+			local = filepath.Join(local, name)
+			fi, err := os.Lstat(local)
+			if err != nil {
+				return nil, err
+			}
+			if !fi.IsDir() {
+				return nil, &os.PathError{Op: "lstat", Path: filepath.Join(dir, filepath.FromSlash(rel)), Err: os.ErrNotExist}
+			}
+		}
+	}
+	return os.Lstat(filepath.Join(dir, filepath.FromSlash(rel)))
+}
+
+// writeFileAtomic writes the content to the file of the permission, by writing a temporary file in the same directory
+// and renaming it, so that the file is either left as it was or completely written.
+func writeFileAtomic(name string, content []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".ghwalk-tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(content)
+	// Unlike the permission passed to OpenFile, Chmod is not subject to the umask.
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+func localFileMode(info *FileInfo) os.FileMode {
+	if info.Executable {
+		return 0755
+	}
+	return 0644
+}
+
+// copyLinkTarget replaces the placeholder of the symlink named by the slash separated path rel under dir with a copy
+// of its target. The target, which is resolved relative to the symlink, must be mirrored under dir as well.
+func copyLinkTarget(dir, rel string) error {
+	local := filepath.Join(dir, filepath.FromSlash(rel))
+	target, err := os.ReadFile(local)
+	if err != nil {
+		return err
+	}
+	src := filepath.Join(filepath.Dir(local), filepath.FromSlash(string(target)))
+	if r, err := filepath.Rel(dir, src); err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("can't create the symlink %s, and its target %s is not mirrored", rel, target)
+	}
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("can't create the symlink %s, and its target %s is not mirrored: %w", rel, target, err)
+	}
+	if err := os.Remove(local); err != nil {
+		return err
+	}
+	return copyLocal(src, local)
+}
+
+// copyLocal copies the file or directory src to dst, following the symlinks.
+func copyLocal(src, dst string) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		content, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(dst, content, fi.Mode().Perm())
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := copyLocal(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeLocalFile removes the file named by the slash separated path rel under dir, along with its parent directories
+// that become empty.
+func removeLocalFile(dir, rel string) error {
+	// The file is not there if any of its parents is not a directory, e.g. a symlink to somewhere else
+	if _, err := lstatLocal(dir, rel); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.Remove(filepath.Join(dir, filepath.FromSlash(rel))); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for parent := parentDir(rel); parent != ""; parent = parentDir(parent) {
+		entries, err := os.ReadDir(filepath.Join(dir, filepath.FromSlash(parent)))
+		if err != nil || len(entries) != 0 {
+			break
+		}
+		if err := os.Remove(filepath.Join(dir, filepath.FromSlash(parent))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package ghwalk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirror(t *testing.T) {
+	fixture := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(fixture, "root", "dir", "sub"), 0755))
+	files := map[string]string{
+		"root/a":         "a\n",
+		"root/dir/b":     "b\n",
+		"root/dir/sub/c": "c\n",
+		"other":          "other\n",
+	}
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(fixture, name), []byte(content), 0644))
+	}
+	require.NoError(t, os.Symlink("a", filepath.Join(fixture, "root", "link")))
+
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": fixture})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	opt := &WalkOptions{BaseURL: srv.BaseURL()}
+	manifest, err := Mirror(ctx, "foo", "bar", "root", dir, nil, opt)
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 4)
+	assertFile := func(name, content string) {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Equal(t, content, string(b))
+	}
+	assertFile("a", "a\n")
+	assertFile("dir/b", "b\n")
+	assertFile("dir/sub/c", "c\n")
+	target, err := os.Readlink(filepath.Join(dir, "link"))
+	require.NoError(t, err)
+	require.Equal(t, "a", target)
+
+	// The manifest survives a round trip
+	var buf bytes.Buffer
+	require.NoError(t, manifest.Write(&buf))
+	prev, err := ReadManifest(&buf)
+	require.NoError(t, err)
+	require.Equal(t, manifest, prev)
+
+	// Change the repository: modify a, remove dir/sub/c
+	require.NoError(t, ioutil.WriteFile(filepath.Join(fixture, "root", "a"), []byte("new a\n"), 0644))
+	require.NoError(t, os.RemoveAll(filepath.Join(fixture, "root", "dir", "sub")))
+
+	before := srv.RequestCount()
+	manifest, err = Mirror(ctx, "foo", "bar", "root", dir, prev, opt)
+	require.NoError(t, err)
+	// One for the tree, one for the blob of a
+	require.Equal(t, 2, srv.RequestCount()-before)
+	require.Len(t, manifest.Files, 3)
+	assertFile("a", "new a\n")
+	assertFile("dir/b", "b\n")
+	_, err = os.Stat(filepath.Join(dir, "dir", "sub"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestMirrorSymlinkToDir(t *testing.T) {
+	// The symlink points outside the mirror, which must not be written through
+	outside := t.TempDir()
+	fixture := newFixture(t, map[string]string{"b": "b\n"})
+	require.NoError(t, os.Symlink(outside, filepath.Join(fixture, "a")))
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": fixture})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	opt := &WalkOptions{BaseURL: srv.BaseURL()}
+	prev, err := Mirror(ctx, "foo", "bar", "", dir, nil, opt)
+	require.NoError(t, err)
+	target, err := os.Readlink(filepath.Join(dir, "a"))
+	require.NoError(t, err)
+	require.Equal(t, outside, target)
+
+	// The symlink becomes a directory
+	require.NoError(t, os.Remove(filepath.Join(fixture, "a")))
+	require.NoError(t, os.Mkdir(filepath.Join(fixture, "a"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(fixture, "a", "x"), []byte("x\n"), 0644))
+
+	manifest, err := Mirror(ctx, "foo", "bar", "", dir, prev, opt)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"a/x": manifest.Files["a/x"], "b": prev.Files["b"]}, manifest.Files)
+	fi, err := os.Lstat(filepath.Join(dir, "a"))
+	require.NoError(t, err)
+	require.True(t, fi.IsDir())
+	b, err := ioutil.ReadFile(filepath.Join(dir, "a", "x"))
+	require.NoError(t, err)
+	require.Equal(t, "x\n", string(b))
+	entries, err := os.ReadDir(outside)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// A symlink in the way is replaced as well, even if it is not mirrored before
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "a")))
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "a")))
+	_, err = Mirror(ctx, "foo", "bar", "", dir, nil, opt)
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(dir, "a", "x"))
+	entries, err = os.ReadDir(outside)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestMirrorFileMode(t *testing.T) {
+	fixture := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(fixture, "dir"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(fixture, "run.sh"), []byte("#!/bin/sh\n"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(fixture, "dir", "a"), []byte("a\n"), 0644))
+	require.NoError(t, os.Symlink("dir", filepath.Join(fixture, "link_dir")))
+	require.NoError(t, os.Symlink("run.sh", filepath.Join(fixture, "link_run")))
+
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": fixture})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	opt := &WalkOptions{BaseURL: srv.BaseURL()}
+
+	assertMode := func(dir, name string, mode os.FileMode) {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err, name)
+		require.Equal(t, mode, fi.Mode().Perm(), name)
+	}
+
+	dir := t.TempDir()
+	prev, err := Mirror(ctx, "foo", "bar", "", dir, nil, opt)
+	require.NoError(t, err)
+	assertMode(dir, "run.sh", 0755)
+	assertMode(dir, "dir/a", 0644)
+	target, err := os.Readlink(filepath.Join(dir, "link_dir"))
+	require.NoError(t, err)
+	require.Equal(t, "dir", target)
+
+	// The mode change alone is picked up by the next mirror
+	require.NoError(t, os.Chmod(filepath.Join(fixture, "run.sh"), 0644))
+	_, err = Mirror(ctx, "foo", "bar", "", dir, prev, opt)
+	require.NoError(t, err)
+	assertMode(dir, "run.sh", 0644)
+	require.NoError(t, os.Chmod(filepath.Join(fixture, "run.sh"), 0755))
+
+	// Copy the content of the link targets if the symlink can't be created
+	defer func(f func(string, string) error) { symlink = f }(symlink)
+	symlink = func(string, string) error { return os.ErrPermission }
+	dir = t.TempDir()
+	_, err = Mirror(ctx, "foo", "bar", "", dir, nil, opt)
+	require.NoError(t, err)
+	b, err := ioutil.ReadFile(filepath.Join(dir, "link_dir", "a"))
+	require.NoError(t, err)
+	require.Equal(t, "a\n", string(b))
+	assertMode(dir, "link_run", 0755)
+	fi, err := os.Lstat(filepath.Join(dir, "link_run"))
+	require.NoError(t, err)
+	require.True(t, fi.Mode().IsRegular())
+}
+
+func TestMirrorTransformContent(t *testing.T) {
+	fixture := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(fixture, "a"), []byte("same\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(fixture, "b"), []byte("same\n"), 0644))
+	require.NoError(t, os.Symlink("a", filepath.Join(fixture, "link")))
+
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": fixture})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	opt := &WalkOptions{
+		BaseURL: srv.BaseURL(),
+		TransformContent: func(path string, r io.Reader) (io.Reader, error) {
+			return io.MultiReader(strings.NewReader(path+": "), r), nil
+		},
+	}
+	_, err := Mirror(ctx, "foo", "bar", "", dir, nil, opt)
+	require.NoError(t, err)
+	for name, content := range map[string]string{"a": "a: same\n", "b": "b: same\n"} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Equal(t, content, string(b), name)
+	}
+	target, err := os.Readlink(filepath.Join(dir, "link"))
+	require.NoError(t, err)
+	require.Equal(t, "a", target)
+}
+
+func TestMirrorDedup(t *testing.T) {
+	fixture := t.TempDir()
+	for _, name := range []string{"vendor1/lib", "vendor2/lib"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(fixture, name), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(fixture, name, "a"), []byte("a\n"), 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(fixture, name, "b"), []byte("b\n"), 0755))
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(fixture, "c"), []byte("a\n"), 0755))
+
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": fixture})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	manifest, err := Mirror(ctx, "foo", "bar", "", dir, nil, &WalkOptions{BaseURL: srv.BaseURL()})
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 5)
+	// One for the tree, one for each distinct blob
+	require.Equal(t, 3, srv.RequestCount())
+
+	for name, content := range map[string]string{
+		"c":             "a\n",
+		"vendor1/lib/a": "a\n",
+		"vendor1/lib/b": "b\n",
+		"vendor2/lib/a": "a\n",
+		"vendor2/lib/b": "b\n",
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Equal(t, content, string(b), name)
+	}
+	// The copies have their own mode
+	fi, err := os.Stat(filepath.Join(dir, "c"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0755), fi.Mode().Perm())
+	fi, err = os.Stat(filepath.Join(dir, "vendor1", "lib", "a"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0644), fi.Mode().Perm())
+}
+
+// cancelingTransport cancels the context once the given number of blobs have been downloaded.
+type cancelingTransport struct {
+	blobs  int
+	cancel context.CancelFunc
+}
+
+func (t *cancelingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if strings.Contains(req.URL.Path, "/git/blobs/") {
+		if t.blobs--; t.blobs == 0 {
+			t.cancel()
+		}
+	}
+	return resp, err
+}
+
+func TestMirrorCancel(t *testing.T) {
+	fixture := newFixture(t, map[string]string{"a": "a\n", "b": "b\n", "c": "c\n", "d": "d\n", "old": "old\n"})
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": fixture})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	prev, err := Mirror(ctx, "foo", "bar", "", dir, nil, &WalkOptions{BaseURL: srv.BaseURL()})
+	require.NoError(t, err)
+
+	for _, name := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(fixture, name), []byte("new "+name+"\n"), 0644))
+	}
+	require.NoError(t, os.Remove(filepath.Join(fixture, "old")))
+
+	// Cancel once two files are downloaded
+	cctx, ccancel := context.WithCancel(ctx)
+	defer ccancel()
+	opt := &WalkOptions{BaseURL: srv.BaseURL(), Transport: &cancelingTransport{blobs: 2, cancel: ccancel}}
+	partial, err := Mirror(cctx, "foo", "bar", "", dir, prev, opt)
+	var merr *MirrorError
+	require.True(t, errors.As(err, &merr), err)
+	require.True(t, errors.Is(err, context.Canceled))
+	require.Equal(t, []string{"a", "b"}, merr.Completed)
+	// The removed files are deleted before any file is written
+	require.Equal(t, []string{"old"}, merr.Removed)
+	require.Equal(t, partial, merr.Manifest)
+	require.True(t, partial.Partial)
+	require.Equal(t, prev.Files["c"], partial.Files["c"])
+	require.NotContains(t, partial.Files, "old")
+	require.NotEqual(t, prev.Files["a"], partial.Files["a"])
+
+	// Each local file is either mirrored or left as it was
+	for name, content := range map[string]string{"a": "new a\n", "b": "new b\n", "c": "c\n", "d": "d\n"} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Equal(t, content, string(b), name)
+	}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+
+	// The partial manifest continues the mirror
+	before := srv.RequestCount()
+	manifest, err := Mirror(ctx, "foo", "bar", "", dir, partial, &WalkOptions{BaseURL: srv.BaseURL()})
+	require.NoError(t, err)
+	require.False(t, manifest.Partial)
+	// One for the tree, one for the blob of c and d each
+	require.Equal(t, 3, srv.RequestCount()-before)
+	require.Len(t, manifest.Files, 4)
+	b, err := ioutil.ReadFile(filepath.Join(dir, "d"))
+	require.NoError(t, err)
+	require.Equal(t, "new d\n", string(b))
+	require.NoFileExists(t, filepath.Join(dir, "old"))
+}
@@ -0,0 +1,49 @@
+package ghwalk
+
+import "golang.org/x/text/unicode/norm"
+
+// normalizeEntry returns the path in NFC, along with the info whose Name and Path are in NFC (see NormalizeUnicode of
+// WalkOptions). The info is copied with the RawName and RawPath set if normalizing changes it, otherwise it is
+// returned as is. The info can be nil.
+func normalizeEntry(path string, info *FileInfo) (string, *FileInfo) {
+	path = norm.NFC.String(path)
+	if info == nil {
+		return path, nil
+	}
+	name, ipath := norm.NFC.String(info.Name), norm.NFC.String(info.Path)
+	if name == info.Name && ipath == info.Path {
+		return path, info
+	}
+	normalized := *info
+	normalized.Name, normalized.Path = name, ipath
+	normalized.RawName, normalized.RawPath = info.Name, info.Path
+	return path, &normalized
+}
+
+// normalize makes the WalkFunc, PathFilterFunc and onLeave of w see the normalized entries, see normalizeEntry. The
+// skipped entries are normalized by skip.
+func (w *walker) normalize() {
+	walkFn, filterFn, onLeave := w.walkFn, w.filterFn, w.onLeave
+	w.walkFn = func(path string, info *FileInfo, err error) error {
+		path, info = normalizeEntry(path, info)
+		return walkFn(path, info, err)
+	}
+	if filterFn != nil {
+		w.filterFn = func(path string, info *FileInfo) bool {
+			return filterFn(normalizeEntry(path, info))
+		}
+	}
+	if onLeave != nil {
+		w.onLeave = func(path string, info *FileInfo) {
+			onLeave(normalizeEntry(path, info))
+		}
+	}
+}
+
+// rawPath returns the path of the file as stored in the repository, which is used to address it in the API requests.
+func (f *FileInfo) rawPath() string {
+	if f.RawPath != "" {
+		return f.RawPath
+	}
+	return f.Path
+}
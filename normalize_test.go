@@ -0,0 +1,86 @@
+package ghwalk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeUnicode(t *testing.T) {
+	// The names are in NFD, as written by macOS
+	const (
+		dir  = "cafe\u0301"
+		file = "cafe\u0301/re\u0301sume\u0301.txt"
+	)
+	srv := ghwalktest.NewServer(map[string]string{
+		"foo/bar": newFixture(t, map[string]string{file: "resume\n", "cafe\u0301/skipped": "", "plain": ""}),
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	cases := []struct {
+		normalize   bool
+		expectPaths []string
+	}{
+		{
+			normalize:   false,
+			expectPaths: []string{"", dir, file, "plain"},
+		},
+		{
+			normalize:   true,
+			expectPaths: []string{"", "café", "café/résumé.txt", "plain"},
+		},
+	}
+	for _, c := range cases {
+		opt := &WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true, NormalizeUnicode: c.normalize}
+		var paths []string
+		infos := map[string]*FileInfo{}
+		var skipped []string
+		opt.OnSkip = func(path string, reason SkipReason) {
+			skipped = append(skipped, path)
+		}
+		require.NoError(t, Walk(ctx, "foo", "bar", "", opt, func(path string, info *FileInfo, err error) error {
+			require.NoError(t, err)
+			paths = append(paths, path)
+			infos[path] = info
+			return nil
+		}, func(path string, info *FileInfo) bool {
+			return path == "café/skipped" || path == "cafe\u0301/skipped"
+		}))
+		require.Equal(t, c.expectPaths, paths, c.normalize)
+		require.Len(t, skipped, 1)
+
+		info := infos[c.expectPaths[2]]
+		require.Equal(t, c.expectPaths[2], info.Path)
+		content, err := info.GetContent()
+		require.NoError(t, err)
+		require.Equal(t, "resume\n", content)
+		if !c.normalize {
+			require.Empty(t, info.RawName)
+			require.Empty(t, info.RawPath)
+			continue
+		}
+		require.Equal(t, "résumé.txt", info.Name)
+		require.Equal(t, "re\u0301sume\u0301.txt", info.RawName)
+		require.Equal(t, file, info.RawPath)
+		require.Equal(t, "café/skipped", skipped[0])
+		// The names already in NFC are not copied
+		require.Empty(t, infos["plain"].RawPath)
+	}
+
+	// FindAll normalizes the entries of the whole tree as well
+	infos, err := FindAll(ctx, "foo", "bar", "", func(path string, info *FileInfo) bool {
+		return path == "café/résumé.txt"
+	}, &WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true, NormalizeUnicode: true})
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	require.Equal(t, file, infos[0].RawPath)
+	content, err := infos[0].GetContent()
+	require.NoError(t, err)
+	require.Equal(t, "resume\n", content)
+}
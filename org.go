@@ -0,0 +1,110 @@
+package ghwalk
+
+import (
+	"context"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// OrgWalkFunc is the type of the function called by WalkOrg for each file or directory visited, along with the name of
+// the repository it belongs to. Its behavior is the same as WalkFunc, except that returning SkipAll stops walking all
+// the remaining repositories, and returning SkipDir on the walked path skips the rest of the repository.
+type OrgWalkFunc func(repo, path string, info *FileInfo, err error) error
+
+// OrgRepoFunc is the type of the function called by WalkOrgRepos before walking each repository, with its metadata.
+// Returning SkipDir skips the repository, returning SkipAll stops walking all the remaining repositories, returning
+// any other error stops WalkOrgRepos with that error.
+type OrgRepoFunc func(repo *RepoMetadata) error
+
+// ListOrgRepos returns the names of the repositories of the organization, in the order returned by Github.
+// Only the Token, BaseURL and Transport of opt (and the others customizing the API requests) are used.
+func ListOrgRepos(ctx context.Context, org string, opt *WalkOptions) ([]string, error) {
+	repos, err := ListOrgRepoMetadata(ctx, org, opt)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		names = append(names, repo.Name)
+	}
+	return names, nil
+}
+
+// ListOrgRepoMetadata is the same as ListOrgRepos, except that it returns the metadata of the repositories, which
+// comes with the listing without any extra request.
+func ListOrgRepoMetadata(ctx context.Context, org string, opt *WalkOptions) ([]*RepoMetadata, error) {
+	client, err := newClient(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	listOpt := &github.RepositoryListByOrgOptions{ListOptions: opt.listOptions()}
+	var metadata []*RepoMetadata
+	for page := 1; ; page++ {
+		repos, resp, err := client.Repositories.ListByOrg(ctx, org, listOpt)
+		if err != nil {
+			return nil, err
+		}
+		for _, repo := range repos {
+			metadata = append(metadata, newRepoMetadata(repo))
+		}
+		if listOpt.Page = opt.nextPage(resp, page); listOpt.Page == 0 {
+			return metadata, nil
+		}
+	}
+}
+
+// WalkOrg walks the path in each repository of the organization (see ListOrgRepos) in turn, as Walk does. The Ref and
+// At of opt, if set, apply to every repository, otherwise the default branch of each repository is walked.
+//
+// The errors specific to a repository (e.g. the path doesn't exist in it) are passed to walkFn, as Walk does. WalkOrg
+// stops at the first error returned by walkFn (other than SkipDir and SkipAll), or by Walk itself (e.g. resolving At).
+func WalkOrg(ctx context.Context, org, path string, opt *WalkOptions, walkFn OrgWalkFunc, filterFn PathFilterFunc) error {
+	return WalkOrgRepos(ctx, org, path, opt, nil, walkFn, filterFn)
+}
+
+// WalkOrgRepos is the same as WalkOrg, except that repoFn, if not nil, is called before walking each repository with
+// its metadata (e.g. the default branch, the visibility and whether it is archived), in order to decide whether to walk
+// it.
+func WalkOrgRepos(ctx context.Context, org, path string, opt *WalkOptions, repoFn OrgRepoFunc, walkFn OrgWalkFunc, filterFn PathFilterFunc) error {
+	repos, err := ListOrgRepoMetadata(ctx, org, opt)
+	if err != nil {
+		return err
+	}
+	return walkRepos(ctx, org, repos, path, opt, repoFn, walkFn, filterFn)
+}
+
+// walkRepos walks the path in each of the repositories of the owner in turn, see WalkOrgRepos.
+func walkRepos(ctx context.Context, owner string, repos []*RepoMetadata, path string, opt *WalkOptions, repoFn OrgRepoFunc, walkFn OrgWalkFunc, filterFn PathFilterFunc) error {
+	for _, metadata := range repos {
+		if repoFn != nil {
+			switch err := repoFn(metadata); err {
+			case nil:
+			case SkipDir:
+				continue
+			case SkipAll:
+				return nil
+			default:
+				return err
+			}
+		}
+		stopped, err := walkRepo(ctx, owner, metadata.Name, path, opt, func(owner, repo, path string, info *FileInfo, err error) error {
+			return walkFn(repo, path, info, err)
+		}, filterFn)
+		if err != nil || stopped {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkRepo walks the path in the repository as Walk does, and tells whether walkFn has returned SkipAll, which stops
+// walking the other repositories.
+func walkRepo(ctx context.Context, owner, repo, path string, opt *WalkOptions, walkFn SearchWalkFunc, filterFn PathFilterFunc) (bool, error) {
+	var stopped bool
+	err := Walk(ctx, owner, repo, path, opt, func(path string, info *FileInfo, err error) error {
+		err = walkFn(owner, repo, path, info, err)
+		stopped = err == SkipAll
+		return err
+	}, filterFn)
+	return stopped, err
+}
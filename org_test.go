@@ -0,0 +1,162 @@
+package ghwalk
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkOrg(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{
+		"acme/a":   newFixture(t, map[string]string{"docs/x": "x", "y": "y"}),
+		"acme/b":   newFixture(t, map[string]string{"z": "z"}),
+		"acme/c":   newFixture(t, map[string]string{"docs/w": "w"}),
+		"other/d":  newFixture(t, map[string]string{"docs/v": "v"}),
+		"acme/a@1": newFixture(t, map[string]string{}),
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	opt := &WalkOptions{BaseURL: srv.BaseURL()}
+
+	repos, err := ListOrgRepos(ctx, "acme", opt)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, repos)
+
+	cases := []struct {
+		walkFn  func(repo, path string, info *FileInfo, err error) error
+		expect  []string
+		isError bool
+	}{
+		{
+			walkFn: func(repo, path string, info *FileInfo, err error) error {
+				return nil
+			},
+			expect: []string{"a:docs", "a:docs/x", "b:docs", "c:docs", "c:docs/w"},
+		},
+		{
+			walkFn: func(repo, path string, info *FileInfo, err error) error {
+				return err
+			},
+			expect:  []string{"a:docs", "a:docs/x", "b:docs"},
+			isError: true,
+		},
+		{
+			walkFn: func(repo, path string, info *FileInfo, err error) error {
+				if path == "docs/x" {
+					return SkipAll
+				}
+				return nil
+			},
+			expect: []string{"a:docs", "a:docs/x"},
+		},
+	}
+
+	for idx, c := range cases {
+		var visited []string
+		err := WalkOrg(ctx, "acme", "docs", opt, func(repo, path string, info *FileInfo, err error) error {
+			visited = append(visited, repo+":"+path)
+			return c.walkFn(repo, path, info, err)
+		}, nil)
+		if c.isError {
+			require.Error(t, err, idx)
+		} else {
+			require.NoError(t, err, idx)
+		}
+		require.Equal(t, c.expect, visited, idx)
+	}
+
+	_, err = ListOrgRepos(ctx, "nobody", opt)
+	require.Error(t, err)
+}
+
+func TestWalkOrgRepos(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{
+		"acme/a": newFixture(t, map[string]string{"docs/x": "x"}),
+		"acme/b": newFixture(t, map[string]string{"docs/y": "y"}),
+		"acme/c": newFixture(t, map[string]string{"docs/z": "z"}),
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	opt := &WalkOptions{BaseURL: srv.BaseURL()}
+
+	cases := []struct {
+		repoFn OrgRepoFunc
+		expect []string
+	}{
+		{
+			repoFn: func(repo *RepoMetadata) error {
+				if repo.Name == "b" {
+					return SkipDir
+				}
+				return nil
+			},
+			expect: []string{"a:docs", "a:docs/x", "c:docs", "c:docs/z"},
+		},
+		{
+			repoFn: func(repo *RepoMetadata) error {
+				if repo.Name == "b" {
+					return SkipAll
+				}
+				return nil
+			},
+			expect: []string{"a:docs", "a:docs/x"},
+		},
+		{
+			repoFn: func(repo *RepoMetadata) error {
+				if repo.DefaultBranch != "main" || repo.Visibility != "public" {
+					return fmt.Errorf("unexpected metadata of %s", repo.Name)
+				}
+				return nil
+			},
+			expect: []string{"a:docs", "a:docs/x", "b:docs", "b:docs/y", "c:docs", "c:docs/z"},
+		},
+	}
+	for idx, c := range cases {
+		var visited []string
+		err := WalkOrgRepos(ctx, "acme", "docs", opt, c.repoFn, func(repo, path string, info *FileInfo, err error) error {
+			visited = append(visited, repo+":"+path)
+			return err
+		}, nil)
+		require.NoError(t, err, idx)
+		require.Equal(t, c.expect, visited, idx)
+	}
+}
+
+func TestListOrgReposPagination(t *testing.T) {
+	repos := map[string]string{}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		repos["acme/"+name] = newFixture(t, map[string]string{"x": "x"})
+	}
+	srv := ghwalktest.NewServer(repos)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	cases := []struct {
+		perPage  int
+		maxPages int
+		expect   []string
+		requests int
+	}{
+		{expect: []string{"a", "b", "c", "d", "e"}, requests: 1},
+		{perPage: 2, expect: []string{"a", "b", "c", "d", "e"}, requests: 3},
+		{perPage: 2, maxPages: 2, expect: []string{"a", "b", "c", "d"}, requests: 2},
+		{perPage: 1000, maxPages: 1, expect: []string{"a", "b", "c", "d", "e"}, requests: 1},
+	}
+	for idx, c := range cases {
+		count := srv.RequestCount()
+		names, err := ListOrgRepos(ctx, "acme", &WalkOptions{BaseURL: srv.BaseURL(), PerPage: c.perPage, MaxPages: c.maxPages})
+		require.NoError(t, err, idx)
+		require.Equal(t, c.expect, names, idx)
+		require.Equal(t, c.requests, srv.RequestCount()-count, idx)
+	}
+}
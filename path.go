@@ -0,0 +1,90 @@
+package ghwalk
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidPath is the error (possibly wrapped) returned for a malformed path in a repository, e.g. one with ".."
+// components. Use errors.Is to check for it.
+var ErrInvalidPath = errors.New("invalid path")
+
+// ErrInvalidRepo is the error (possibly wrapped) returned for a malformed owner or repository name. Use errors.Is to
+// check for it.
+var ErrInvalidRepo = errors.New("invalid repository")
+
+// maxRepoName is the maximum length of a repository name allowed by Github.
+const maxRepoName = 100
+
+// CleanPath canonicalizes a user supplied path in a repository to the form used by ghwalk, i.e. slash separated
+// without any leading or trailing slash, where the repo root is the empty string. The "." components (including a
+// leading "./") are removed, and the doubled slashes are collapsed, e.g. "./a//b/" becomes "a/b".
+//
+// The path containing a ".." component or a NUL byte is rejected with ErrInvalidPath, as it can't be resolved within
+// the repository.
+func CleanPath(path string) (string, error) {
+	if strings.IndexByte(path, 0) >= 0 {
+		return "", fmt.Errorf("%w %q: contains a NUL byte", ErrInvalidPath, path)
+	}
+	var names []string
+	for _, name := range strings.Split(path, "/") {
+		switch name {
+		case "", ".":
+		case "..":
+			return "", fmt.Errorf("%w %q: contains a \"..\" component", ErrInvalidPath, path)
+		default:
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "/"), nil
+}
+
+// ValidateRepo checks that owner and repo are well-formed, as Github allows: the owner consists of alphanumerics,
+// hyphens and underscores (the latter for the managed users), and doesn't start with a hyphen; the repository name
+// consists of alphanumerics, hyphens, underscores and dots, up to 100 characters, other than "." and "..". It returns
+// ErrInvalidRepo otherwise, e.g. for "owner/repo" passed as the owner, which Github would answer with a bare 404.
+func ValidateRepo(owner, repo string) error {
+	switch {
+	case owner == "":
+		return fmt.Errorf("%w: the owner is empty", ErrInvalidRepo)
+	case strings.HasPrefix(owner, "-") || strings.IndexFunc(owner, func(r rune) bool { return !isNameChar(r) }) >= 0:
+		return fmt.Errorf("%w: the owner %q must consist of alphanumerics, hyphens and underscores, and not start with a hyphen", ErrInvalidRepo, owner)
+	case repo == "":
+		return fmt.Errorf("%w: the repository name of %s is empty", ErrInvalidRepo, owner)
+	case repo == "." || repo == ".." || strings.IndexFunc(repo, func(r rune) bool { return !isNameChar(r) && r != '.' }) >= 0:
+		return fmt.Errorf("%w: the repository name %q must consist of alphanumerics, hyphens, underscores and dots", ErrInvalidRepo, repo)
+	case len(repo) > maxRepoName:
+		return fmt.Errorf("%w: the repository name %q is longer than %d characters", ErrInvalidRepo, repo, maxRepoName)
+	}
+	return nil
+}
+
+// isNameChar tells whether r is allowed in both the owner and repository names.
+func isNameChar(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_'
+}
+
+// cleanRepoPath validates the owner and repo, and returns the canonical form of path, see CleanPath.
+func cleanRepoPath(owner, repo, path string) (string, error) {
+	if err := ValidateRepo(owner, repo); err != nil {
+		return "", err
+	}
+	return CleanPath(path)
+}
+
+// cleanRepoPaths is like cleanRepoPath, for each of the paths.
+func cleanRepoPaths(owner, repo string, paths []string) ([]string, error) {
+	if err := ValidateRepo(owner, repo); err != nil {
+		return nil, err
+	}
+	cleaned := make([]string, len(paths))
+	for i, path := range paths {
+		p, err := CleanPath(path)
+		if err != nil {
+			return nil, err
+		}
+		cleaned[i] = p
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,127 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanPath(t *testing.T) {
+	cases := []struct {
+		path    string
+		expect  string
+		isError bool
+	}{
+		{path: "", expect: ""},
+		{path: "/", expect: ""},
+		{path: ".", expect: ""},
+		{path: "./", expect: ""},
+		{path: "a", expect: "a"},
+		{path: "/a/b/", expect: "a/b"},
+		{path: "./a//b", expect: "a/b"},
+		{path: "a/./b/.", expect: "a/b"},
+		{path: "a/.b/..c", expect: "a/.b/..c"},
+		{path: "..", isError: true},
+		{path: "a/../b", isError: true},
+		{path: "a/\x00", isError: true},
+	}
+	for _, c := range cases {
+		p, err := CleanPath(c.path)
+		if c.isError {
+			require.True(t, errors.Is(err, ErrInvalidPath), c.path)
+			continue
+		}
+		require.NoError(t, err, c.path)
+		require.Equal(t, c.expect, p, c.path)
+	}
+}
+
+func TestValidateRepo(t *testing.T) {
+	cases := []struct {
+		owner   string
+		repo    string
+		isError bool
+	}{
+		{owner: "magodo", repo: "ghwalk"},
+		{owner: "some-org", repo: "my_repo.go"},
+		{owner: "user_acme", repo: ".github"},
+		{owner: "", repo: "ghwalk", isError: true},
+		{owner: "magodo/ghwalk", repo: "", isError: true},
+		{owner: "magodo", repo: "", isError: true},
+		{owner: "-magodo", repo: "ghwalk", isError: true},
+		{owner: "magodo", repo: "a/b", isError: true},
+		{owner: "magodo", repo: "..", isError: true},
+		{owner: "magodo", repo: "gh walk", isError: true},
+		{owner: "magodo", repo: strings.Repeat("a", 101), isError: true},
+	}
+	for _, c := range cases {
+		err := ValidateRepo(c.owner, c.repo)
+		if c.isError {
+			require.True(t, errors.Is(err, ErrInvalidRepo), c.owner+"/"+c.repo)
+			continue
+		}
+		require.NoError(t, err, c.owner+"/"+c.repo)
+	}
+}
+
+func TestWalkCleanPath(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	opt := &WalkOptions{BaseURL: srv.BaseURL()}
+
+	var visited []string
+	require.NoError(t, Walk(ctx, "magodo", "ghwalk", "./testdata//dir/", opt, func(path string, info *FileInfo, err error) error {
+		visited = append(visited, path)
+		return err
+	}, nil))
+	require.Equal(t, []string{"testdata/dir", "testdata/dir/c"}, visited)
+
+	// The other entry points canonicalize the path the same way
+	c, err := os.ReadFile("testdata/dir/c")
+	require.NoError(t, err)
+	for _, path := range []string{"./testdata", "testdata/", "/testdata"} {
+		found, err := FindAll(ctx, "magodo", "ghwalk", path, func(path string, info *FileInfo) bool { return true }, opt)
+		require.NoError(t, err)
+		require.Equal(t, "testdata", found[0].Path)
+		usages, err := DiskUsage(ctx, "magodo", "ghwalk", path, opt)
+		require.NoError(t, err)
+		require.Equal(t, "testdata", usages[0].Path)
+		exists, err := ExistsAll(ctx, "magodo", "ghwalk", []string{path, path + "/dir"}, opt)
+		require.NoError(t, err)
+		require.Equal(t, map[string]bool{path: true, path + "/dir": true}, exists)
+		infos, err := StatMany(ctx, "magodo", "ghwalk", []string{path + "/dir"}, opt)
+		require.NoError(t, err)
+		require.Equal(t, "testdata/dir", infos[0].Path)
+		snapshot, err := TakeSnapshot(ctx, "magodo", "ghwalk", path, opt)
+		require.NoError(t, err)
+		require.Equal(t, "testdata", snapshot.Path)
+		sums := &SHA256Sums{Path: path + "/dir", Files: map[string]string{"c": sha256Hex(string(c))}}
+		mismatches, err := sums.VerifyWalk(ctx, "magodo", "ghwalk", opt)
+		require.NoError(t, err)
+		require.Empty(t, mismatches)
+	}
+
+	// The malformed input is rejected without any request
+	requests := srv.RequestCount()
+	walkFn := func(path string, info *FileInfo, err error) error { return err }
+	err = Walk(ctx, "magodo", "ghwalk", "testdata/../..", opt, walkFn, nil)
+	require.True(t, errors.Is(err, ErrInvalidPath))
+	err = Walk(ctx, "magodo/ghwalk", "", "testdata", opt, walkFn, nil)
+	require.True(t, errors.Is(err, ErrInvalidRepo))
+	_, err = NewFS(ctx, "magodo", "ghwalk", "..", opt)
+	require.True(t, errors.Is(err, ErrInvalidPath))
+	_, err = FindAll(ctx, "magodo", "ghwalk", "..", func(path string, info *FileInfo) bool { return true }, opt)
+	require.True(t, errors.Is(err, ErrInvalidPath))
+	_, err = ExistsAll(ctx, "magodo", "ghwalk", []string{"testdata", "a/../b"}, opt)
+	require.True(t, errors.Is(err, ErrInvalidPath))
+	require.Equal(t, requests, srv.RequestCount())
+}
@@ -0,0 +1,91 @@
+package ghwalk
+
+import (
+	"context"
+	"path/filepath"
+)
+
+// future is the result of a fetch running in the background.
+type future[T any] struct {
+	done chan struct{}
+	v    T
+	err  error
+}
+
+// startFuture runs fn in the background, once a slot of the semaphore is acquired.
+func startFuture[T any](ctx context.Context, sem chan struct{}, fn func() (T, error)) *future[T] {
+	f := &future[T]{done: make(chan struct{})}
+	go func() {
+		defer close(f.done)
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			f.err = ctx.Err()
+			return
+		}
+		defer func() { <-sem }()
+		f.v, f.err = fn()
+	}()
+	return f
+}
+
+func (f *future[T]) wait() (T, error) {
+	<-f.done
+	return f.v, f.err
+}
+
+// prefetched holds the decisions and the prefetches for the entries of a directory, in the same order as the entries.
+type prefetched struct {
+	skipReasons []SkipReason
+	fetch       []bool
+	// contents and listings are nil for the entries not being prefetched
+	contents []*future[*FileInfo]
+	listings []*future[[]*FileInfo]
+}
+
+// prefetch starts fetching the content of the files and the listings of the subdirectories among entries in the
+// background. It returns nil if the walk is not concurrent.
+func (w *walker) prefetch(ctx context.Context, path string, entries []*FileInfo) *prefetched {
+	if w.listSem == nil && w.contentSem == nil {
+		return nil
+	}
+	pf := &prefetched{
+		skipReasons: make([]SkipReason, len(entries)),
+		fetch:       make([]bool, len(entries)),
+		contents:    make([]*future[*FileInfo], len(entries)),
+		listings:    make([]*future[[]*FileInfo], len(entries)),
+	}
+	for i, entry := range entries {
+		filename := filepath.Join(path, entry.Name)
+		if resumeSkips(w.opt.ResumeFrom, filename, entries, i, w.opt) {
+			continue
+		}
+		if pf.skipReasons[i] = w.skipReason(filename, entry); pf.skipReasons[i] != "" {
+			continue
+		}
+		pf.fetch[i] = w.fetchContent(filename, entry)
+		switch {
+		case pf.fetch[i] && w.contentSem != nil:
+			pf.contents[i] = startFuture(ctx, w.contentSem, func() (*FileInfo, error) {
+				info, err := readFile(ctx, w.owner, w.repo, filename, w.p, w.opt, entry)
+				w.prefetchFailed(err)
+				return info, err
+			})
+		case entry.IsDir() && w.listSem != nil && !w.deferred(filename, entry):
+			pf.listings[i] = startFuture(ctx, w.listSem, func() ([]*FileInfo, error) {
+				entries, err := readDirEntries(ctx, w.owner, w.repo, filename, w.p, w.opt)
+				w.prefetchFailed(err)
+				return entries, err
+			})
+		}
+	}
+	return pf
+}
+
+// prefetchFailed stops the walk right away if a prefetch fails in ErrorModeFailFast, rather than when the walk
+// reaches the failed entry.
+func (w *walker) prefetchFailed(err error) {
+	if err != nil && w.opt.ErrorMode == ErrorModeFailFast {
+		w.fail(err)
+	}
+}
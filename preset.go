@@ -0,0 +1,284 @@
+package ghwalk
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DependencyManifestEcosystems maps the base names of the dependency manifests (and lock files) looked for by
+// FindDependencyManifests to their package ecosystems. It can be modified before calling FindDependencyManifests to
+// look for other files.
+var DependencyManifestEcosystems = map[string]string{
+	"go.mod":              "go",
+	"go.sum":              "go",
+	"package.json":        "npm",
+	"package-lock.json":   "npm",
+	"npm-shrinkwrap.json": "npm",
+	"yarn.lock":           "npm",
+	"pnpm-lock.yaml":      "npm",
+	"requirements.txt":    "pip",
+	"Pipfile":             "pip",
+	"Pipfile.lock":        "pip",
+	"pyproject.toml":      "pip",
+	"poetry.lock":         "pip",
+	"Gemfile":             "rubygems",
+	"Gemfile.lock":        "rubygems",
+	"Cargo.toml":          "cargo",
+	"Cargo.lock":          "cargo",
+	"composer.json":       "composer",
+	"composer.lock":       "composer",
+	"pom.xml":             "maven",
+	"build.gradle":        "gradle",
+	"build.gradle.kts":    "gradle",
+	"gradle.lockfile":     "gradle",
+	"packages.config":     "nuget",
+	"packages.lock.json":  "nuget",
+	"mix.exs":             "hex",
+	"mix.lock":            "hex",
+	"Package.swift":       "swift",
+	"Package.resolved":    "swift",
+}
+
+// vendoredDirs are the directories holding the vendored dependencies, whose manifests belong to the dependencies
+// rather than the repository.
+var vendoredDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+}
+
+// DependencyManifest is a dependency manifest (or lock file) found by FindDependencyManifests.
+type DependencyManifest struct {
+	// Info is the FileInfo of the manifest, including the FileOnlyInfo.
+	Info FileInfo
+	// Ecosystem is the package ecosystem of the manifest, e.g. "go" or "npm".
+	Ecosystem string
+	// Content is the decoded content of the manifest.
+	Content []byte
+}
+
+// FindDependencyManifests returns the dependency manifests (and lock files) under path, whose base names are listed in
+// DependencyManifestEcosystems, along with their content, in the same order as Walk would visit them. The manifests
+// under the vendored directories (i.e. "node_modules" and "vendor") are skipped.
+//
+// The manifests are found by FindAll, so that only the content of the manifests is fetched, at one API call each.
+// The EnableFileOnlyInfo and FetchContentFunc of opt are ignored.
+func FindDependencyManifests(ctx context.Context, owner, repo, path string, opt *WalkOptions) ([]DependencyManifest, error) {
+	return findWithContent(ctx, owner, repo, path, opt, func(p string, info *FileInfo) (string, bool) {
+		if info.Type != FileTypeFile || underDirs(p, vendoredDirs) {
+			return "", false
+		}
+		ecosystem, ok := DependencyManifestEcosystems[info.Name]
+		return ecosystem, ok
+	}, func(info FileInfo, ecosystem string, content []byte) DependencyManifest {
+		return DependencyManifest{Info: info, Ecosystem: ecosystem, Content: content}
+	})
+}
+
+// findWithContent finds the files under path for which match returns true by FindAll, and builds a T for each of them
+// from the value returned by match and the content.
+func findWithContent[T, V any](ctx context.Context, owner, repo, path string, opt *WalkOptions,
+	match func(path string, info *FileInfo) (V, bool), build func(info FileInfo, v V, content []byte) T) ([]T, error) {
+	var o WalkOptions
+	if opt != nil {
+		o = *opt
+	}
+	o.FetchContentFunc = func(string, *FileInfo) bool { return true }
+
+	values := map[string]V{}
+	infos, err := FindAll(ctx, owner, repo, path, func(p string, info *FileInfo) bool {
+		v, ok := match(p, info)
+		if ok {
+			values[p] = v
+		}
+		return ok
+	}, &o)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]T, 0, len(infos))
+	for i := range infos {
+		content, err := readContent(ctx, &infos[i])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, build(infos[i], values[infos[i].Path], content))
+	}
+	return result, nil
+}
+
+// readContent reads the whole decoded content of a file with the FileOnlyInfo.
+func readContent(ctx context.Context, info *FileInfo) ([]byte, error) {
+	r, err := info.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// underDirs tells whether any of the parent directories of the slash separated p has the base name in dirs.
+func underDirs(p string, dirs map[string]bool) bool {
+	for _, name := range strings.Split(filepath.Dir(p), "/") {
+		if dirs[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultTerraformSkipDirs are the base names of the directories skipped by FindTerraformFiles by default, i.e. the
+// local working directories, the examples and the test fixtures.
+var DefaultTerraformSkipDirs = []string{".terraform", "examples", "test", "tests", "testdata", "fixtures"}
+
+// TerraformFile is a Terraform configuration file found by FindTerraformFiles.
+type TerraformFile struct {
+	// Info is the FileInfo of the file, including the FileOnlyInfo.
+	Info FileInfo
+	// Module is the slash separated path of the directory containing the file, i.e. the Terraform module it belongs to.
+	Module string
+	// JSON tells whether the file is in the JSON syntax (i.e. "*.tf.json"), rather than the native HCL syntax.
+	JSON bool
+	// Content is the decoded content of the file.
+	Content []byte
+}
+
+// FindTerraformFiles returns the Terraform configuration files (i.e. "*.tf" and "*.tf.json") under path, along with
+// their content, in the same order as Walk would visit them.
+//
+// The files under the directories (below path) whose base names are in skipDirs are skipped. If skipDirs is nil,
+// DefaultTerraformSkipDirs is used, while an empty skipDirs skips nothing.
+//
+// Like FindDependencyManifests, only the content of the matched files is fetched.
+func FindTerraformFiles(ctx context.Context, owner, repo, path string, skipDirs []string, opt *WalkOptions) ([]TerraformFile, error) {
+	path, err := cleanRepoPath(owner, repo, path)
+	if err != nil {
+		return nil, err
+	}
+	if skipDirs == nil {
+		skipDirs = DefaultTerraformSkipDirs
+	}
+	skip := map[string]bool{}
+	for _, dir := range skipDirs {
+		skip[dir] = true
+	}
+
+	return findWithContent(ctx, owner, repo, path, opt, func(p string, info *FileInfo) (bool, bool) {
+		if info.Type != FileTypeFile {
+			return false, false
+		}
+		rel := p
+		if path != "" {
+			rel = strings.TrimPrefix(rel, path+"/")
+		}
+		if underDirs(rel, skip) {
+			return false, false
+		}
+		switch {
+		case strings.HasSuffix(info.Name, ".tf"):
+			return false, true
+		case strings.HasSuffix(info.Name, ".tf.json"):
+			return true, true
+		default:
+			return false, false
+		}
+	}, func(info FileInfo, json bool, content []byte) TerraformFile {
+		return TerraformFile{Info: info, Module: parentDir(info.Path), JSON: json, Content: content}
+	})
+}
+
+// MarkdownDoc is a Markdown document found by FindMarkdownDocs.
+type MarkdownDoc struct {
+	// Info is the FileInfo of the document, including the FileOnlyInfo.
+	Info FileInfo
+	// FrontMatter is the decoded YAML front matter, which is nil if the document has none.
+	FrontMatter map[string]interface{}
+	// Title is the "title" of the front matter if any, otherwise the text of the first level 1 ATX heading.
+	Title string
+	// Body is the content of the document after the front matter.
+	Body []byte
+}
+
+// FindMarkdownDocs returns the Markdown documents (i.e. "*.md" and "*.mdx") under path, with their YAML front matter
+// decoded, in the same order as Walk would visit them.
+//
+// Like FindDependencyManifests, only the content of the matched files is fetched.
+func FindMarkdownDocs(ctx context.Context, owner, repo, path string, opt *WalkOptions) ([]MarkdownDoc, error) {
+	docs, err := findWithContent(ctx, owner, repo, path, opt, func(p string, info *FileInfo) (struct{}, bool) {
+		ext := strings.ToLower(filepath.Ext(info.Name))
+		return struct{}{}, info.Type == FileTypeFile && (ext == ".md" || ext == ".mdx")
+	}, func(info FileInfo, _ struct{}, content []byte) MarkdownDoc {
+		return MarkdownDoc{Info: info, Body: content}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range docs {
+		doc := &docs[i]
+		doc.FrontMatter, doc.Body, err = ParseFrontMatter(doc.Body)
+		if err != nil {
+			return nil, fmt.Errorf("parsing the front matter of %s: %v", doc.Info.Path, err)
+		}
+		if title, ok := doc.FrontMatter["title"].(string); ok {
+			doc.Title = title
+		} else {
+			doc.Title = markdownTitle(doc.Body)
+		}
+	}
+	return docs, nil
+}
+
+// ParseFrontMatter splits the YAML front matter, which is enclosed by the "---" lines at the start of the content
+// (the closing line can also be "..."), from the content, and decodes it. If there is no front matter, it returns nil
+// and the content as is.
+func ParseFrontMatter(content []byte) (map[string]interface{}, []byte, error) {
+	rest, ok := cutLine(content, "---")
+	if !ok {
+		return nil, content, nil
+	}
+	for body := rest; len(body) != 0; {
+		line := body
+		if i := bytes.IndexByte(body, '\n'); i >= 0 {
+			line, body = body[:i+1], body[i+1:]
+		} else {
+			body = nil
+		}
+		if delim := strings.TrimRight(string(line), "\r\n"); delim != "---" && delim != "..." {
+			continue
+		}
+		yml := rest[:len(rest)-len(body)-len(line)]
+		frontMatter := map[string]interface{}{}
+		if err := yaml.Unmarshal(yml, &frontMatter); err != nil {
+			return nil, nil, err
+		}
+		return frontMatter, body, nil
+	}
+	// Not closed, so it is not a front matter, but a thematic break.
+	return nil, content, nil
+}
+
+// cutLine returns the content after the first line if the first line is the delim.
+func cutLine(content []byte, delim string) ([]byte, bool) {
+	i := bytes.IndexByte(content, '\n')
+	if i < 0 || strings.TrimRight(string(content[:i]), "\r") != delim {
+		return nil, false
+	}
+	return content[i+1:], true
+}
+
+// markdownTitle returns the text of the first level 1 ATX heading of the Markdown content, if any.
+func markdownTitle(content []byte) string {
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "# ") {
+			return strings.TrimSpace(strings.TrimRight(line[2:], "#"))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,225 @@
+package ghwalk
+
+import (
+	"context"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindDependencyManifests(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	strPtr := func(s string) *string { return &s }
+	file := func(p, content string) *FileInfo {
+		return &FileInfo{
+			Type:         FileTypeFile,
+			Name:         path.Base(p),
+			Path:         p,
+			FileOnlyInfo: &FileOnlyInfo{Content: strPtr(content)},
+		}
+	}
+	entries := []*FileInfo{
+		{Type: FileTypeDir, Name: "app", Path: "app"},
+		{Type: FileTypeDir, Name: "node_modules", Path: "app/node_modules"},
+		{Type: FileTypeDir, Name: "left-pad", Path: "app/node_modules/left-pad"},
+		file("app/node_modules/left-pad/package.json", "{}"),
+		file("app/package.json", `{"name": "app"}`),
+		file("app/main.js", ""),
+		file("go.mod", "module foo"),
+		file("go.sum", ""),
+		file("main.go", "package main"),
+	}
+
+	cases := []struct {
+		path    string
+		expect  []DependencyManifest
+		isError bool
+	}{
+		{
+			path: "",
+			expect: []DependencyManifest{
+				{Info: *entries[4], Ecosystem: "npm", Content: []byte(`{"name": "app"}`)},
+				{Info: *entries[6], Ecosystem: "go", Content: []byte("module foo")},
+				{Info: *entries[7], Ecosystem: "go", Content: []byte{}},
+			},
+		},
+		{
+			path: "app",
+			expect: []DependencyManifest{
+				{Info: *entries[4], Ecosystem: "npm", Content: []byte(`{"name": "app"}`)},
+			},
+		},
+		{
+			path:    "nonexist",
+			isError: true,
+		},
+	}
+
+	for idx, c := range cases {
+		manifests, err := FindDependencyManifests(ctx, "foo", "bar", c.path, &WalkOptions{Snapshot: &Snapshot{Owner: "foo", Repo: "bar", Entries: entries}})
+		if c.isError {
+			require.Error(t, err, idx)
+			continue
+		}
+		require.NoError(t, err, idx)
+		require.Equal(t, c.expect, manifests, idx)
+	}
+}
+
+func TestFindTerraformFiles(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	strPtr := func(s string) *string { return &s }
+	file := func(p string) *FileInfo {
+		return &FileInfo{
+			Type:         FileTypeFile,
+			Name:         path.Base(p),
+			Path:         p,
+			FileOnlyInfo: &FileOnlyInfo{Content: strPtr(p)},
+		}
+	}
+	entries := []*FileInfo{
+		{Type: FileTypeDir, Name: ".terraform", Path: ".terraform"},
+		file(".terraform/modules.tf"),
+		{Type: FileTypeDir, Name: "examples", Path: "examples"},
+		{Type: FileTypeDir, Name: "basic", Path: "examples/basic"},
+		file("examples/basic/main.tf"),
+		file("main.tf"),
+		{Type: FileTypeDir, Name: "modules", Path: "modules"},
+		{Type: FileTypeDir, Name: "net", Path: "modules/net"},
+		file("modules/net/main.tf"),
+		file("modules/net/override.tf.json"),
+		file("modules/net/README.md"),
+		file("variables.tf"),
+	}
+	tfFile := func(p string, json bool) TerraformFile {
+		for _, entry := range entries {
+			if entry.Path == p {
+				return TerraformFile{Info: *entry, Module: parentDir(p), JSON: json, Content: []byte(p)}
+			}
+		}
+		panic(p)
+	}
+
+	cases := []struct {
+		path     string
+		skipDirs []string
+		expect   []TerraformFile
+	}{
+		{
+			path: "",
+			expect: []TerraformFile{
+				tfFile("main.tf", false),
+				tfFile("modules/net/main.tf", false),
+				tfFile("modules/net/override.tf.json", true),
+				tfFile("variables.tf", false),
+			},
+		},
+		{
+			path:     "",
+			skipDirs: []string{"modules"},
+			expect: []TerraformFile{
+				tfFile(".terraform/modules.tf", false),
+				tfFile("examples/basic/main.tf", false),
+				tfFile("main.tf", false),
+				tfFile("variables.tf", false),
+			},
+		},
+		{
+			path: "examples",
+			expect: []TerraformFile{
+				tfFile("examples/basic/main.tf", false),
+			},
+		},
+	}
+
+	for idx, c := range cases {
+		files, err := FindTerraformFiles(ctx, "foo", "bar", c.path, c.skipDirs, &WalkOptions{Snapshot: &Snapshot{Owner: "foo", Repo: "bar", Entries: entries}})
+		require.NoError(t, err, idx)
+		require.Equal(t, c.expect, files, idx)
+	}
+}
+
+func TestParseFrontMatter(t *testing.T) {
+	cases := []struct {
+		content     string
+		frontMatter map[string]interface{}
+		body        string
+		isError     bool
+	}{
+		{
+			content: "# Title\n",
+			body:    "# Title\n",
+		},
+		{
+			content:     "---\ntitle: Foo\ntags: [a, b]\n---\n# Title\n",
+			frontMatter: map[string]interface{}{"title": "Foo", "tags": []interface{}{"a", "b"}},
+			body:        "# Title\n",
+		},
+		{
+			content:     "---\r\nweight: 1\r\n...\r\nbody",
+			frontMatter: map[string]interface{}{"weight": 1},
+			body:        "body",
+		},
+		{
+			content:     "---\n---\n",
+			frontMatter: map[string]interface{}{},
+			body:        "",
+		},
+		{
+			// Not closed
+			content: "---\nfoo\n",
+			body:    "---\nfoo\n",
+		},
+		{
+			content: "---\n: :\n---\n",
+			isError: true,
+		},
+	}
+
+	for idx, c := range cases {
+		frontMatter, body, err := ParseFrontMatter([]byte(c.content))
+		if c.isError {
+			require.Error(t, err, idx)
+			continue
+		}
+		require.NoError(t, err, idx)
+		require.Equal(t, c.frontMatter, frontMatter, idx)
+		require.Equal(t, c.body, string(body), idx)
+	}
+}
+
+func TestFindMarkdownDocs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	strPtr := func(s string) *string { return &s }
+	file := func(p, content string) *FileInfo {
+		return &FileInfo{
+			Type:         FileTypeFile,
+			Name:         path.Base(p),
+			Path:         p,
+			FileOnlyInfo: &FileOnlyInfo{Content: strPtr(content)},
+		}
+	}
+	entries := []*FileInfo{
+		file("README.md", "# ghwalk #\n\nWalk Github."),
+		{Type: FileTypeDir, Name: "docs", Path: "docs"},
+		file("docs/guide.mdx", "---\ntitle: Guide\n---\n# Getting Started\n"),
+		file("docs/logo.png", ""),
+		file("docs/notes.MD", "no title"),
+	}
+
+	docs, err := FindMarkdownDocs(ctx, "foo", "bar", "", &WalkOptions{Snapshot: &Snapshot{Owner: "foo", Repo: "bar", Entries: entries}})
+	require.NoError(t, err)
+	require.Equal(t, []MarkdownDoc{
+		{Info: *entries[0], Title: "ghwalk", Body: []byte("# ghwalk #\n\nWalk Github.")},
+		{Info: *entries[2], FrontMatter: map[string]interface{}{"title": "Guide"}, Title: "Guide", Body: []byte("# Getting Started\n")},
+		{Info: *entries[4], Body: []byte("no title")},
+	}, docs)
+}
@@ -0,0 +1,353 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"golang.org/x/oauth2"
+)
+
+// ContentProvider provides the content of repositories to walk. By default, the content is provided by the Github
+// API, setting a custom ContentProvider as the Provider of the WalkOptions allows to substitute it, e.g. with a fake
+// in unit tests.
+//
+// The errors returned for a path that doesn't exist should wrap ErrNotExist.
+type ContentProvider interface {
+	// Stat returns the FileInfo of path, without the FileOnlyInfo. It returns nil FileInfo for the repo root.
+	Stat(ctx context.Context, owner, repo, path string) (*FileInfo, error)
+
+	// ReadDir returns the FileInfo of the entries of the directory named by path, without the FileOnlyInfo,
+	// in no particular order.
+	ReadDir(ctx context.Context, owner, repo, path string) ([]*FileInfo, error)
+
+	// ReadFile returns the FileInfo of the file named by path, including the FileOnlyInfo.
+	ReadFile(ctx context.Context, owner, repo, path string) (*FileInfo, error)
+}
+
+// NewGithubProvider returns the ContentProvider that retrieves the content via the Github Contents API, configured
+// by the Token, Ref, BaseURL and Transport of opt.
+func NewGithubProvider(ctx context.Context, opt *WalkOptions) (ContentProvider, error) {
+	client, err := newClient(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	return &githubProvider{client: client, opt: opt}, nil
+}
+
+func newProvider(ctx context.Context, opt *WalkOptions) (ContentProvider, error) {
+	if opt != nil && opt.Provider != nil {
+		return opt.Provider, nil
+	}
+	if opt != nil && opt.Snapshot != nil {
+		return NewSnapshotProvider(opt.Snapshot), nil
+	}
+	return NewGithubProvider(ctx, opt)
+}
+
+func newClient(ctx context.Context, opt *WalkOptions) (*github.Client, error) {
+	// construct the github client
+	client := github.NewClient(newHTTPClient(opt))
+
+	if baseURL := apiBaseURL(opt); baseURL != "" {
+		if !strings.HasSuffix(baseURL, "/") {
+			baseURL += "/"
+		}
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing base URL %q: %v", baseURL, err)
+		}
+		client.BaseURL = u
+	}
+
+	return client, nil
+}
+
+// newHTTPClient returns the HTTP client that sends the requests via the Transport of opt, authenticated by the access
+// token (if any).
+func newHTTPClient(opt *WalkOptions) *http.Client {
+	transport := http.DefaultTransport
+	if opt != nil && opt.Transport != nil {
+		transport = opt.Transport
+	}
+	if opt != nil && opt.Scheduler != nil {
+		transport = &schedulerTransport{base: transport, scheduler: opt.Scheduler, priority: opt.Priority}
+	}
+	if opt != nil && opt.Cache != nil {
+		transport = &cachingTransport{base: transport, cache: opt.Cache}
+	}
+	if opt != nil && (opt.WaitRateLimit || opt.OnRateLimit != nil) {
+		transport = &rateLimitTransport{
+			base:        transport,
+			wait:        opt.WaitRateLimit,
+			onRateLimit: opt.OnRateLimit,
+			now:         time.Now,
+		}
+	}
+	if opt != nil && opt.RetryPolicy != nil {
+		transport = &retryTransport{base: transport, policy: opt.RetryPolicy, onRetry: opt.OnRetry}
+	}
+	if opt != nil && opt.CircuitBreaker != nil {
+		transport = &breakerTransport{base: transport, breaker: opt.CircuitBreaker}
+	}
+	if token := accessToken(opt); token != "" {
+		ts := oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: token},
+		)
+		transport = &oauth2.Transport{Source: ts, Base: transport}
+	}
+	return &http.Client{Transport: transport}
+}
+
+// maxPerPage is the maximum page size allowed by Github, which is also the default PerPage.
+const maxPerPage = 100
+
+// listOptions returns the ListOptions of the first page of a paginated API request.
+func (opt *WalkOptions) listOptions() github.ListOptions {
+	perPage := maxPerPage
+	if opt != nil && opt.PerPage > 0 && opt.PerPage < maxPerPage {
+		perPage = opt.PerPage
+	}
+	return github.ListOptions{PerPage: perPage}
+}
+
+// nextPage returns the next page to request after the response of the given page (starting from 1), or 0 if the
+// pagination should stop.
+func (opt *WalkOptions) nextPage(resp *github.Response, page int) int {
+	if opt != nil && opt.MaxPages > 0 && page >= opt.MaxPages {
+		return 0
+	}
+	return resp.NextPage
+}
+
+// countingTransport counts the requests sent.
+type countingTransport struct {
+	base  http.RoundTripper
+	mu    sync.Mutex
+	count *int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	*t.count++
+	t.mu.Unlock()
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// accessToken returns the access token to use, which is resolved by the chain documented at the Token of the
+// WalkOptions.
+func accessToken(opt *WalkOptions) string {
+	if opt != nil && opt.Token != "" {
+		return opt.Token
+	}
+	if opt != nil && opt.DisableEnvironment {
+		return ""
+	}
+	host := apiHost(opt)
+	if envTokenHost(host) {
+		for _, env := range []string{"GH_TOKEN", "GITHUB_TOKEN"} {
+			if token := os.Getenv(env); token != "" {
+				return token
+			}
+		}
+	}
+	return storedToken(host)
+}
+
+// envTokenHost tells whether the GH_TOKEN and GITHUB_TOKEN environment variables are the tokens of the host, which is
+// the one named by GH_HOST (defaults to github.com), or the one of the GITHUB_API_URL (e.g. in Github Actions).
+func envTokenHost(host string) bool {
+	envHost := os.Getenv("GH_HOST")
+	if envHost == "" || envHost == "api.github.com" {
+		envHost = "github.com"
+	}
+	if host == envHost {
+		return true
+	}
+	if base := os.Getenv("GITHUB_API_URL"); base != "" {
+		return host == apiHost(&WalkOptions{BaseURL: base})
+	}
+	return false
+}
+
+var (
+	storedTokensMu sync.Mutex
+	// storedTokens caches the token found by storedToken for each host, as running gh is not cheap.
+	storedTokens map[string]string
+)
+
+// ghTokenTimeout bounds the time of running gh for storedToken, so that a hanging gh doesn't block the walk.
+var ghTokenTimeout = 5 * time.Second
+
+// storedToken returns the token of the host stored by the Github CLI, in the netrc file, or by DeviceFlow, in this
+// order. It returns an empty string if there is none.
+func storedToken(host string) string {
+	storedTokensMu.Lock()
+	token, ok := storedTokens[host]
+	storedTokensMu.Unlock()
+	if ok {
+		return token
+	}
+
+	// The lock isn't held while looking up the token, so that the other hosts (or the cached ones) aren't blocked by
+	// gh. The concurrent lookups of the same host find the same token anyway.
+	ctx, cancel := context.WithTimeout(context.Background(), ghTokenTimeout)
+	defer cancel()
+	// GHCLIToken fails if there is no token.
+	token, _ = GHCLIToken(ctx, host)
+	if token == "" {
+		machines := []string{host}
+		if host == "github.com" {
+			machines = append(machines, "api.github.com")
+		}
+		if file, err := netrcFile(); err == nil {
+			token, _ = readNetrcToken(file, machines...)
+		}
+	}
+	if token == "" {
+		if file, err := DeviceTokenFile(host); err == nil {
+			token, _ = readDeviceToken(file)
+		}
+	}
+	storedTokensMu.Lock()
+	defer storedTokensMu.Unlock()
+	if storedTokens == nil {
+		storedTokens = map[string]string{}
+	}
+	storedTokens[host] = token
+	return token
+}
+
+// apiHost returns the host name of the Github instance that the API base URL belongs to, e.g. "github.com" for the
+// default one.
+func apiHost(opt *WalkOptions) string {
+	base := apiBaseURL(opt)
+	if base == "" {
+		return "github.com"
+	}
+	u, err := url.Parse(base)
+	if err != nil || u.Hostname() == "" || u.Hostname() == "api.github.com" {
+		return "github.com"
+	}
+	return u.Hostname()
+}
+
+// apiBaseURL returns the API base URL to use, which falls back to the GITHUB_API_URL environment variable unless the
+// environment is disabled. An empty string means the default one.
+func apiBaseURL(opt *WalkOptions) string {
+	if opt != nil && opt.BaseURL != "" {
+		return opt.BaseURL
+	}
+	if opt != nil && opt.DisableEnvironment {
+		return ""
+	}
+	return os.Getenv("GITHUB_API_URL")
+}
+
+// githubProvider provides the repository content via the Github Contents API.
+type githubProvider struct {
+	client *github.Client
+	opt    *WalkOptions
+}
+
+func (p *githubProvider) Stat(ctx context.Context, owner, repo, path string) (*FileInfo, error) {
+	// The root directory of the repo has no meta info
+	if path == "" {
+		return nil, nil
+	}
+
+	parentPath := filepath.Dir(path)
+	// If the `path` is at the root level, then we explicitly turn its parent path to be empty
+	// string, which indicates to get repository content at the root level.
+	if parentPath == "." {
+		parentPath = ""
+	}
+
+	entries, err := p.ReadDir(ctx, owner, repo, parentPath)
+	if err != nil {
+		var nerr *notExistError
+		if errors.As(err, &nerr) {
+			return nil, &notExistError{path: path, err: nerr.err}
+		}
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.Name == filepath.Base(path) {
+			return entry, nil
+		}
+	}
+
+	return nil, errNoSuchPath(path)
+}
+
+func (p *githubProvider) ReadDir(ctx context.Context, owner, repo, path string) ([]*FileInfo, error) {
+	_, dircontent, _, err := p.client.Repositories.GetContents(ctx, owner, repo, path, newRepositoryGetContentOptions(p.opt))
+	if err != nil {
+		if isNotFound(err) {
+			return nil, &notExistError{path: path, err: err}
+		}
+		return nil, err
+	}
+	o := p.origin(owner, repo)
+	entries := make([]*FileInfo, 0, len(dircontent))
+	for _, content := range dircontent {
+		if content == nil {
+			continue
+		}
+		entry := newFileInfo(content, false)
+		entry.origin = o
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (p *githubProvider) ReadFile(ctx context.Context, owner, repo, path string) (*FileInfo, error) {
+	filecontent, _, _, err := p.client.Repositories.GetContents(ctx, owner, repo, path, newRepositoryGetContentOptions(p.opt))
+	if err != nil {
+		if isNotFound(err) {
+			return nil, &notExistError{path: path, err: err}
+		}
+		return nil, err
+	}
+	if filecontent == nil {
+		return nil, fmt.Errorf("%s is not a file", path)
+	}
+	info := newFileInfo(filecontent, true)
+	info.origin = p.origin(owner, repo)
+	return info, nil
+}
+
+func (p *githubProvider) origin(owner, repo string) *origin {
+	return &origin{client: p.client, owner: owner, repo: repo, opt: p.opt}
+}
+
+// origin is the repository on Github where a FileInfo is retrieved from, which allows to issue further API calls
+// about the FileInfo.
+type origin struct {
+	client *github.Client
+	owner  string
+	repo   string
+	opt    *WalkOptions
+}
+
+func newRepositoryGetContentOptions(opt *WalkOptions) *github.RepositoryContentGetOptions {
+	if opt == nil {
+		return nil
+	}
+	return &github.RepositoryContentGetOptions{
+		Ref: opt.Ref,
+	}
+}
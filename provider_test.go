@@ -0,0 +1,203 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+// failingProvider fails to read the file named by path.
+type failingProvider struct {
+	ContentProvider
+	path string
+}
+
+func (p failingProvider) ReadFile(ctx context.Context, owner, repo, path string) (*FileInfo, error) {
+	if path == p.path {
+		return nil, errors.New("boom")
+	}
+	return p.ContentProvider.ReadFile(ctx, owner, repo, path)
+}
+
+func TestWalkWithProvider(t *testing.T) {
+	content := "Zm9v"
+	encoding := "base64"
+	provider := NewSnapshotProvider(&Snapshot{
+		Owner: "foo",
+		Repo:  "bar",
+		Entries: []*FileInfo{
+			{Type: FileTypeDir, Name: "dir", Path: "dir"},
+			{Type: FileTypeFile, Name: "b", Path: "dir/b", FileOnlyInfo: &FileOnlyInfo{Content: &content, Encoding: &encoding}},
+			{Type: FileTypeFile, Name: "a", Path: "dir/a", FileOnlyInfo: &FileOnlyInfo{Content: &content, Encoding: &encoding}},
+		},
+	})
+
+	cases := []struct {
+		provider   ContentProvider
+		expectPath []string
+		expectErr  map[string]string
+	}{
+		{
+			provider:   provider,
+			expectPath: []string{"", "dir", "dir/a", "dir/b"},
+			expectErr:  map[string]string{},
+		},
+		{
+			provider:   failingProvider{ContentProvider: provider, path: "dir/a"},
+			expectPath: []string{"", "dir", "dir/a", "dir/b"},
+			expectErr:  map[string]string{"dir/a": "boom"},
+		},
+	}
+
+	for _, c := range cases {
+		traversedPath := []string{}
+		errs := map[string]string{}
+		err := Walk(context.Background(), "foo", "bar", "", &WalkOptions{Provider: c.provider, EnableFileOnlyInfo: true},
+			func(path string, info *FileInfo, err error) error {
+				traversedPath = append(traversedPath, path)
+				if err != nil {
+					errs[path] = err.Error()
+					return nil
+				}
+				if info != nil && !info.IsDir() {
+					content, err := info.GetContent()
+					require.NoError(t, err)
+					require.Equal(t, "foo", content)
+				}
+				return nil
+			},
+			nil)
+		require.NoError(t, err)
+		require.Equal(t, c.expectPath, traversedPath)
+		require.Equal(t, c.expectErr, errs)
+	}
+
+	_, err := provider.Stat(context.Background(), "foo", "bar", "dir/c")
+	require.True(t, errors.Is(err, ErrNotExist))
+}
+
+func TestEnvironment(t *testing.T) {
+	for _, env := range []string{"GH_TOKEN", "GITHUB_TOKEN", "GITHUB_API_URL"} {
+		if v, ok := os.LookupEnv(env); ok {
+			defer os.Setenv(env, v)
+		} else {
+			defer os.Unsetenv(env)
+		}
+		os.Unsetenv(env)
+	}
+
+	// Isolate from the Github CLI and the netrc file of the user
+	ghDir := t.TempDir()
+	netrc := filepath.Join(t.TempDir(), ".netrc")
+	t.Setenv("GH_CONFIG_DIR", ghDir)
+	t.Setenv("NETRC", netrc)
+	t.Setenv("PATH", "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	resetStoredTokens := func() {
+		storedTokensMu.Lock()
+		storedTokens = nil
+		storedTokensMu.Unlock()
+	}
+	resetStoredTokens()
+	defer resetStoredTokens()
+
+	require.Equal(t, "", accessToken(nil))
+	require.Equal(t, "", apiBaseURL(nil))
+
+	// The netrc file, then the Github CLI
+	require.NoError(t, ioutil.WriteFile(netrc, []byte("machine github.com login foo password netrc-token\n"), 0600))
+	resetStoredTokens()
+	require.Equal(t, "netrc-token", accessToken(nil))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(ghDir, "hosts.yml"), []byte("github.com:\n    oauth_token: gh-cli-token\n"), 0600))
+	resetStoredTokens()
+	require.Equal(t, "gh-cli-token", accessToken(nil))
+
+	os.Setenv("GITHUB_TOKEN", "github-token")
+	os.Setenv("GITHUB_API_URL", "https://github.example.com/api/v3")
+	require.Equal(t, "github-token", accessToken(nil))
+	require.Equal(t, "https://github.example.com/api/v3", apiBaseURL(nil))
+
+	os.Setenv("GH_TOKEN", "gh-token")
+	require.Equal(t, "gh-token", accessToken(&WalkOptions{}))
+	require.Equal(t, "explicit-token", accessToken(&WalkOptions{Token: "explicit-token"}))
+	require.Equal(t, "https://api.example.com/", apiBaseURL(&WalkOptions{BaseURL: "https://api.example.com/"}))
+
+	// The environment token is not sent to another host, unless GH_HOST says so
+	require.Equal(t, "", accessToken(&WalkOptions{BaseURL: "https://other.example.com/api/v3/"}))
+	t.Setenv("GH_HOST", "other.example.com")
+	require.Equal(t, "gh-token", accessToken(&WalkOptions{BaseURL: "https://other.example.com/api/v3/"}))
+	os.Unsetenv("GITHUB_API_URL")
+	require.Equal(t, "gh-cli-token", accessToken(nil))
+	os.Unsetenv("GH_HOST")
+	require.Equal(t, "gh-token", accessToken(nil))
+	os.Setenv("GITHUB_API_URL", "https://github.example.com/api/v3")
+
+	require.Equal(t, "", accessToken(&WalkOptions{DisableEnvironment: true}))
+	require.Equal(t, "", apiBaseURL(&WalkOptions{DisableEnvironment: true}))
+
+	client, err := newClient(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, "https://github.example.com/api/v3/", client.BaseURL.String())
+
+	// The token is not resolved for a snapshot
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": "testdata"})
+	defer srv.Close()
+	snapshot, err := TakeSnapshot(context.Background(), "foo", "bar", "", &WalkOptions{Token: "explicit-token", BaseURL: srv.BaseURL()})
+	require.NoError(t, err)
+	os.Unsetenv("GH_TOKEN")
+	os.Unsetenv("GITHUB_TOKEN")
+	resetStoredTokens()
+	require.NoError(t, Walk(context.Background(), "foo", "bar", "", &WalkOptions{Snapshot: snapshot}, func(path string, info *FileInfo, err error) error {
+		return err
+	}, nil))
+	storedTokensMu.Lock()
+	resolved := len(storedTokens)
+	storedTokensMu.Unlock()
+	require.Zero(t, resolved)
+}
+
+func TestStoredTokenHangingGH(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake gh is a shell script")
+	}
+	bin := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(bin, "gh"), []byte("#!/bin/sh\nexec /bin/sleep 60\n"), 0755))
+	t.Setenv("PATH", bin)
+	t.Setenv("GH_CONFIG_DIR", t.TempDir())
+	t.Setenv("NETRC", filepath.Join(t.TempDir(), ".netrc"))
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	defer func(timeout time.Duration) { ghTokenTimeout = timeout }(ghTokenTimeout)
+	ghTokenTimeout = 100 * time.Millisecond
+	resetStoredTokens := func() {
+		storedTokensMu.Lock()
+		storedTokens = nil
+		storedTokensMu.Unlock()
+	}
+	resetStoredTokens()
+	defer resetStoredTokens()
+
+	// The hanging gh is given up on, without blocking the lookups of the other hosts meanwhile
+	storedTokensMu.Lock()
+	storedTokens = map[string]string{"github.example.com": "cached"}
+	storedTokensMu.Unlock()
+	start := time.Now()
+	done := make(chan string)
+	go func() { done <- storedToken("github.com") }()
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, "cached", storedToken("github.example.com"))
+	require.Less(t, int64(time.Since(start)), int64(ghTokenTimeout))
+	select {
+	case token := <-done:
+		require.Equal(t, "", token)
+	case <-time.After(10 * time.Second):
+		t.Fatal("storedToken is blocked by gh")
+	}
+}
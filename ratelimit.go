@@ -0,0 +1,419 @@
+package ghwalk
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// RateLimitEventKind is the kind of a RateLimitEvent.
+type RateLimitEventKind string
+
+const (
+	// RateLimitThrottled means a request is rejected as the rate limit is exceeded.
+	RateLimitThrottled RateLimitEventKind = "throttled"
+	// RateLimitSleeping means the walker starts sleeping until the rate limit resets. It is only fired if
+	// WaitRateLimit is set.
+	RateLimitSleeping RateLimitEventKind = "sleeping"
+	// RateLimitResumed means the walker resumes sending the request after sleeping.
+	RateLimitResumed RateLimitEventKind = "resumed"
+	// RateLimitArchiveFallback means the walker starts downloading the repository archive to complete the walk,
+	// rather than failing. It is only fired if ArchiveFallback is set, and carries no rate limit status.
+	RateLimitArchiveFallback RateLimitEventKind = "archive-fallback"
+)
+
+// RateLimitEvent describes a change in the rate limit handling, which is passed to the OnRateLimit of WalkOptions.
+type RateLimitEvent struct {
+	Kind RateLimitEventKind
+
+	// Limit and Remaining are the rate limit status reported by the rejected response, both are zero if it is
+	// a secondary rate limit, which doesn't report them.
+	Limit     int
+	Remaining int
+
+	// Reset is the time at which the rate limit resets.
+	Reset time.Time
+
+	// Wait is the duration to sleep for, only set for RateLimitSleeping.
+	Wait time.Duration
+}
+
+// RateLimitStats is the rate limit status observed during a walk, e.g. for planning the capacity of the scheduled
+// walks.
+type RateLimitStats struct {
+	// Observed is the number of the responses reporting the rate limit status (i.e. X-RateLimit-Remaining), the
+	// following are only meaningful if it is greater than zero. The responses served from the Cache are not counted.
+	Observed int
+	// Limit is the rate limit reported by the last response.
+	Limit int
+	// MinRemaining, MaxRemaining and FinalRemaining are the minimum, maximum and last remaining requests reported.
+	MinRemaining   int
+	MaxRemaining   int
+	FinalRemaining int
+
+	// Throttled is the number of the requests rejected by the (primary or secondary) rate limit.
+	Throttled int
+	// Waits is the number of the times that the walk has waited for the rate limit to reset (see WaitRateLimit of
+	// WalkOptions), and Waited is the total duration of these waits.
+	Waits  int
+	Waited time.Duration
+}
+
+// rateLimitStatsTransport records the RateLimitStats from the responses, and from the rate limit events via
+// onRateLimit.
+type rateLimitStatsTransport struct {
+	base  http.RoundTripper
+	mu    sync.Mutex
+	stats *RateLimitStats
+}
+
+func (t *rateLimitStatsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	remaining, rerr := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if rerr != nil {
+		return resp, nil
+	}
+	limit, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.stats
+	if s.Observed == 0 || remaining < s.MinRemaining {
+		s.MinRemaining = remaining
+	}
+	if s.Observed == 0 || remaining > s.MaxRemaining {
+		s.MaxRemaining = remaining
+	}
+	s.Observed++
+	s.Limit, s.FinalRemaining = limit, remaining
+	return resp, nil
+}
+
+// onRateLimit returns the OnRateLimit hook that records the throttles and the waits, before calling next (if any).
+func (t *rateLimitStatsTransport) onRateLimit(next func(RateLimitEvent)) func(RateLimitEvent) {
+	return func(event RateLimitEvent) {
+		t.mu.Lock()
+		switch event.Kind {
+		case RateLimitThrottled:
+			t.stats.Throttled++
+		case RateLimitSleeping:
+			t.stats.Waits++
+			t.stats.Waited += event.Wait
+		}
+		t.mu.Unlock()
+		if next != nil {
+			next(event)
+		}
+	}
+}
+
+// rateLimitTransport notifies the rate limit events, and optionally waits for the rate limit to reset and retries the
+// request, rather than failing.
+type rateLimitTransport struct {
+	base        http.RoundTripper
+	wait        bool
+	onRateLimit func(RateLimitEvent)
+
+	// now is replaceable for testing
+	now func() time.Time
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		event, ok := rateLimitEvent(resp, t.now())
+		if !ok {
+			return resp, nil
+		}
+		t.notify(event)
+		if !t.wait || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		event.Kind = RateLimitSleeping
+		event.Wait = event.Reset.Sub(t.now())
+		if event.Wait < 0 {
+			event.Wait = 0
+		}
+		t.notify(event)
+		timer := time.NewTimer(event.Wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		event.Kind = RateLimitResumed
+		event.Wait = 0
+		t.notify(event)
+
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (t *rateLimitTransport) notify(event RateLimitEvent) {
+	if t.onRateLimit != nil {
+		t.onRateLimit(event)
+	}
+}
+
+// isRateLimited tells whether the response is rejected by the (primary or secondary) rate limit.
+func isRateLimited(resp *http.Response) bool {
+	_, ok := rateLimitEvent(resp, time.Now())
+	return ok
+}
+
+// rateLimitEvent returns the RateLimitThrottled event if the response is rejected by the (primary or secondary) rate
+// limit.
+func rateLimitEvent(resp *http.Response, now time.Time) (RateLimitEvent, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return RateLimitEvent{}, false
+	}
+
+	// Secondary rate limit
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			return RateLimitEvent{
+				Kind:  RateLimitThrottled,
+				Reset: now.Add(time.Duration(secs) * time.Second),
+			}, true
+		}
+	}
+
+	// Primary rate limit
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return RateLimitEvent{}, false
+	}
+	limit, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return RateLimitEvent{}, false
+	}
+	return RateLimitEvent{
+		Kind:  RateLimitThrottled,
+		Limit: limit,
+		Reset: time.Unix(reset, 0),
+	}, true
+}
+
+// Rate is the status of a rate limit.
+type Rate struct {
+	Limit     int
+	Remaining int
+	// Reset is the time at which the rate limit resets.
+	Reset time.Time
+}
+
+// RateLimits is the status of the rate limits of the Github API.
+type RateLimits struct {
+	// Core is the rate limit of the REST API, which the walks mostly consume.
+	Core Rate
+	// GraphQL is the rate limit of the GraphQL API, in points rather than requests.
+	GraphQL Rate
+	Search  Rate
+}
+
+// RateLimit returns the current status of the rate limits, for the token of opt (or for the client IP if there is no
+// token). Only the Token, BaseURL and Transport of opt (and the others customizing the API requests) are used.
+// The request doesn't count against the rate limits.
+func RateLimit(ctx context.Context, opt *WalkOptions) (*RateLimits, error) {
+	client, err := newClient(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	req, err := client.NewRequest(http.MethodGet, "rate_limit", nil)
+	if err != nil {
+		return nil, err
+	}
+	// The RateLimits of go-github doesn't cover the GraphQL API
+	var body struct {
+		Resources struct {
+			Core    github.Rate `json:"core"`
+			GraphQL github.Rate `json:"graphql"`
+			Search  github.Rate `json:"search"`
+		} `json:"resources"`
+	}
+	if _, err := client.Do(ctx, req, &body); err != nil {
+		return nil, err
+	}
+	rate := func(r github.Rate) Rate {
+		return Rate{Limit: r.Limit, Remaining: r.Remaining, Reset: r.Reset.Time}
+	}
+	return &RateLimits{
+		Core:    rate(body.Resources.Core),
+		GraphQL: rate(body.Resources.GraphQL),
+		Search:  rate(body.Resources.Search),
+	}, nil
+}
+
+// EstimateRequests estimates the number of the REST API requests that Walk would send, with the same arguments, by
+// a dry run against the repository tree. The estimate takes the Strategy, FetchContentFunc (or EnableFileOnlyInfo)
+// and filterFn into account, but not the SkipDir returned by the WalkFunc, nor the savings by the Cache.
+//
+// The dry run itself costs a single call to the Git Trees API if possible, see FindAll.
+func EstimateRequests(ctx context.Context, owner, repo, path string, opt *WalkOptions, filterFn PathFilterFunc) (int, error) {
+	path, err := cleanRepoPath(owner, repo, path)
+	if err != nil {
+		return 0, err
+	}
+	if opt != nil && (opt.Snapshot != nil || opt.Provider != nil) {
+		return 0, nil
+	}
+	entries, err := listTree(ctx, owner, repo, path, opt)
+	if err != nil {
+		return 0, err
+	}
+	est := estimateWalk(entries, path, opt, filterFn)
+
+	var n int
+	if opt != nil && opt.FetchRepoMetadata {
+		n++
+	}
+	if opt != nil && (opt.PinCommit || !opt.At.IsZero() || opt.ArchiveFallback) {
+		n++
+	}
+	var strategy Strategy
+	if opt != nil {
+		strategy = opt.Strategy
+	}
+	switch strategy {
+	case StrategyContents:
+		n += est.dirs + est.fetches
+		if path != "" {
+			// The stat of the walked path
+			n++
+		}
+	case StrategyTrees:
+		n += 1 + est.fetches
+	case StrategyArchive:
+		// The archive is downloaded from the web host, which doesn't count against the API rate limit
+	case StrategyAuto:
+		n++
+		if est.fetches <= autoArchiveThreshold {
+			n += est.fetches
+		}
+	}
+	return n, nil
+}
+
+// RateLimitBudgetError is returned by CheckRateLimitBudget if a walk is estimated to exceed the remaining rate limit.
+// It is also passed to the WalkFunc if an unauthenticated walk (i.e. without Token) is rejected by the rate limit at
+// the very start, which means the walk can't fit into the remaining budget at all.
+type RateLimitBudgetError struct {
+	// Unauthenticated tells whether the walk is unauthenticated, whose rate limit can be raised by setting the Token.
+	Unauthenticated bool
+	// Estimated is the estimated number of the requests of the walk.
+	Estimated int
+	// Remaining is the remaining number of the requests of the core rate limit.
+	Remaining int
+	// Reset is the time at which the rate limit resets.
+	Reset time.Time
+	// Err is the rate limit error that the walk fails with, if any.
+	Err error
+}
+
+func (e *RateLimitBudgetError) Error() string {
+	msg := fmt.Sprintf("the walk is estimated to send %d requests, exceeding the remaining rate limit %d (resets at %s)",
+		e.Estimated, e.Remaining, e.Reset.Format(time.RFC3339))
+	if e.Err != nil {
+		msg = fmt.Sprintf("the rate limit is exhausted (resets at %s): %v", e.Reset.Format(time.RFC3339), e.Err)
+	}
+	if e.Unauthenticated {
+		msg += "; the walk is unauthenticated, set a Github token to raise the rate limit"
+	}
+	return msg
+}
+
+func (e *RateLimitBudgetError) Unwrap() error {
+	return e.Err
+}
+
+// CheckRateLimitBudget returns a *RateLimitBudgetError if Walk, with the same arguments, is estimated (see
+// EstimateRequests) to send more requests than the remaining core rate limit, so that the caller can refuse to start
+// a walk that would die halfway. It is pointless if the WaitRateLimit of opt is set, as the walk waits for the rate
+// limit to reset rather than failing.
+func CheckRateLimitBudget(ctx context.Context, owner, repo, path string, opt *WalkOptions, filterFn PathFilterFunc) error {
+	limits, err := RateLimit(ctx, opt)
+	if err != nil {
+		return err
+	}
+	estimated, err := EstimateRequests(ctx, owner, repo, path, opt, filterFn)
+	if err != nil {
+		return err
+	}
+	// The dry run itself has consumed a request
+	remaining := limits.Core.Remaining - 1
+	if estimated > remaining {
+		return &RateLimitBudgetError{
+			Unauthenticated: accessToken(opt) == "",
+			Estimated:       estimated,
+			Remaining:       remaining,
+			Reset:           limits.Core.Reset,
+		}
+	}
+	return nil
+}
+
+// anonymousRateLimit is the rate limit of the unauthenticated requests.
+const anonymousRateLimit = 60
+
+// anonymousTransport logs a warning once the unauthenticated requests are found limited by the anonymous rate limit,
+// which is reported by the X-RateLimit-* headers of the first response.
+type anonymousTransport struct {
+	base http.RoundTripper
+	logf func(format string, args ...interface{})
+
+	once  sync.Once
+	mu    sync.Mutex
+	reset time.Time
+}
+
+func (t *anonymousTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return resp, nil
+	}
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		t.mu.Lock()
+		t.reset = time.Unix(reset, 0)
+		t.mu.Unlock()
+	}
+	t.once.Do(func() {
+		if limit <= anonymousRateLimit && t.logf != nil {
+			t.logf("ghwalk: the walk is unauthenticated, which is limited to %d requests per hour (%s remaining), set a Github token to raise the limit",
+				limit, resp.Header.Get("X-RateLimit-Remaining"))
+		}
+	})
+	return resp, nil
+}
+
+// budgetError wraps the rate limit error of the unauthenticated walk.
+func (t *anonymousTransport) budgetError(err error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &RateLimitBudgetError{Unauthenticated: true, Estimated: 1, Reset: t.reset, Err: err}
+}
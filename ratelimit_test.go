@@ -0,0 +1,237 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkRateLimit(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	cases := []struct {
+		wait        bool
+		expectKinds []RateLimitEventKind
+		isError     bool
+	}{
+		{
+			wait:        true,
+			expectKinds: []RateLimitEventKind{RateLimitThrottled, RateLimitSleeping, RateLimitResumed},
+		},
+		{
+			wait:        false,
+			expectKinds: []RateLimitEventKind{RateLimitThrottled},
+			isError:     true,
+		},
+	}
+
+	for _, c := range cases {
+		srv.ClearFaults()
+		srv.InjectFault(ghwalktest.Fault{Kind: ghwalktest.FaultRateLimit, Path: "testdata/dir", Call: 1, Duration: time.Second})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		kinds := []RateLimitEventKind{}
+		err := Walk(ctx, "magodo", "ghwalk", "testdata",
+			&WalkOptions{
+				BaseURL:       srv.BaseURL(),
+				WaitRateLimit: c.wait,
+				OnRateLimit: func(event RateLimitEvent) {
+					require.Equal(t, 60, event.Limit)
+					kinds = append(kinds, event.Kind)
+				},
+			},
+			func(path string, info *FileInfo, err error) error {
+				return err
+			},
+			nil)
+		require.Equal(t, c.expectKinds, kinds)
+		if c.isError {
+			var rateLimitErr *github.RateLimitError
+			require.True(t, errors.As(err, &rateLimitErr))
+			continue
+		}
+		require.NoError(t, err)
+	}
+}
+
+func TestWalkRateLimitStats(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+	srv.SetRateLimit(5000, 4000)
+	srv.InjectFault(ghwalktest.Fault{Kind: ghwalktest.FaultRateLimit, Path: "testdata/dir", Call: 1, Duration: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	result, err := WalkWithResult(ctx, "magodo", "ghwalk", "testdata",
+		&WalkOptions{BaseURL: srv.BaseURL(), Token: "token", WaitRateLimit: true},
+		func(path string, info *FileInfo, err error) error {
+			return err
+		},
+		nil)
+	require.NoError(t, err)
+
+	stats := result.Stats.RateLimit
+	require.Equal(t, result.Stats.Requests, stats.Observed)
+	require.Equal(t, 5000, stats.Limit)
+	require.Equal(t, 0, stats.MinRemaining)
+	require.Equal(t, 4000, stats.MaxRemaining)
+	require.Equal(t, 4000, stats.FinalRemaining)
+	require.Equal(t, 1, stats.Throttled)
+	require.Equal(t, 1, stats.Waits)
+	require.Greater(t, int64(stats.Waited), int64(0))
+}
+
+type stubTransport struct {
+	responses []*http.Response
+}
+
+func (t *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp := t.responses[0]
+	t.responses = t.responses[1:]
+	resp.Request = req
+	return resp, nil
+}
+
+func TestRateLimitTransportSecondary(t *testing.T) {
+	newResponse := func(status int, header http.Header) *http.Response {
+		return &http.Response{
+			StatusCode: status,
+			Header:     header,
+			Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		}
+	}
+	now := time.Now()
+	var events []RateLimitEvent
+	tr := &rateLimitTransport{
+		base: &stubTransport{responses: []*http.Response{
+			newResponse(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"0"}}),
+			newResponse(http.StatusOK, http.Header{}),
+		}},
+		wait: true,
+		onRateLimit: func(event RateLimitEvent) {
+			events = append(events, event)
+		},
+		now: func() time.Time { return now },
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+	require.NoError(t, err)
+	resp, err := tr.RoundTrip(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []RateLimitEvent{
+		{Kind: RateLimitThrottled, Reset: now},
+		{Kind: RateLimitSleeping, Reset: now},
+		{Kind: RateLimitResumed, Reset: now},
+	}, events)
+}
+
+func TestEstimateRequests(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	skipDir := func(path string, info *FileInfo) bool {
+		return path == "testdata/dir"
+	}
+	cases := []struct {
+		path     string
+		opt      WalkOptions
+		filterFn PathFilterFunc
+	}{
+		{path: "testdata"},
+		{path: "testdata", opt: WalkOptions{EnableFileOnlyInfo: true}},
+		{path: "testdata", opt: WalkOptions{EnableFileOnlyInfo: true}, filterFn: skipDir},
+		{path: "testdata/dir/c", opt: WalkOptions{EnableFileOnlyInfo: true}},
+		{path: "testdata", opt: WalkOptions{EnableFileOnlyInfo: true, PinCommit: true}},
+		{path: "testdata", opt: WalkOptions{EnableFileOnlyInfo: true, Strategy: StrategyTrees}},
+		{path: "testdata", opt: WalkOptions{EnableFileOnlyInfo: true, Strategy: StrategyAuto}},
+	}
+	for idx, c := range cases {
+		opt := c.opt
+		opt.BaseURL = srv.BaseURL()
+		estimated, err := EstimateRequests(ctx, "magodo", "ghwalk", c.path, &opt, c.filterFn)
+		require.NoError(t, err, idx)
+		result, err := WalkWithResult(ctx, "magodo", "ghwalk", c.path, &opt, func(path string, info *FileInfo, err error) error {
+			return err
+		}, c.filterFn)
+		require.NoError(t, err, idx)
+		require.Equal(t, result.Stats.Requests, estimated, idx)
+	}
+}
+
+func TestCheckRateLimitBudget(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	opt := &WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true, DisableEnvironment: true}
+
+	limits, err := RateLimit(ctx, opt)
+	require.NoError(t, err)
+	require.Equal(t, 60, limits.Core.Limit)
+	require.Equal(t, 60, limits.GraphQL.Remaining)
+	require.NoError(t, CheckRateLimitBudget(ctx, "magodo", "ghwalk", "testdata", opt, nil))
+
+	srv.SetRateLimit(60, 5)
+	err = CheckRateLimitBudget(ctx, "magodo", "ghwalk", "testdata", opt, nil)
+	var budgetErr *RateLimitBudgetError
+	require.True(t, errors.As(err, &budgetErr))
+	require.Equal(t, 4, budgetErr.Remaining)
+	require.Greater(t, budgetErr.Estimated, 4)
+}
+
+func TestWalkUnauthenticated(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	cases := []struct {
+		token  string
+		expect int
+	}{
+		{token: "", expect: 1},
+		{token: "token", expect: 0},
+	}
+	for _, c := range cases {
+		var warnings []string
+		opt := &WalkOptions{
+			BaseURL:            srv.BaseURL(),
+			Token:              c.token,
+			DisableEnvironment: true,
+			Logf: func(format string, args ...interface{}) {
+				warnings = append(warnings, fmt.Sprintf(format, args...))
+			},
+		}
+		require.NoError(t, Walk(ctx, "magodo", "ghwalk", "testdata", opt, func(path string, info *FileInfo, err error) error {
+			return err
+		}, nil))
+		require.Len(t, warnings, c.expect, c.token)
+	}
+
+	srv.InjectFault(ghwalktest.Fault{Kind: ghwalktest.FaultRateLimit, Duration: time.Hour})
+	defer srv.ClearFaults()
+	err := Walk(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{BaseURL: srv.BaseURL(), DisableEnvironment: true}, func(path string, info *FileInfo, err error) error {
+		return err
+	}, nil)
+	var budgetErr *RateLimitBudgetError
+	require.True(t, errors.As(err, &budgetErr))
+	require.True(t, budgetErr.Unauthenticated)
+	require.True(t, isRateLimitError(err))
+}
@@ -0,0 +1,60 @@
+package ghwalk
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// resolveOptions returns the WalkOptions to walk the repository with, where the Ref is resolved to the commit as of
+// the At time, or to the commit it currently points to if PinCommit is set.
+func resolveOptions(ctx context.Context, owner, repo string, opt *WalkOptions) (*WalkOptions, error) {
+	opt, _, err := resolveCommit(ctx, owner, repo, opt)
+	return opt, err
+}
+
+// resolveCommit is the same as resolveOptions, except that it also returns the resolved commit, which is nil if the
+// Ref is not resolved.
+func resolveCommit(ctx context.Context, owner, repo string, opt *WalkOptions) (*WalkOptions, *github.RepositoryCommit, error) {
+	if opt == nil || (opt.At.IsZero() && !opt.PinCommit) || opt.Snapshot != nil || opt.Provider != nil {
+		return opt, nil, nil
+	}
+
+	client, err := newClient(ctx, opt)
+	if err != nil {
+		return nil, nil, err
+	}
+	commit, err := commitAt(ctx, client, owner, repo, opt.Ref, opt.At)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	o := *opt
+	o.Ref = commit.GetSHA()
+	o.At = time.Time{}
+	o.PinCommit = false
+	return &o, commit, nil
+}
+
+// commitAt returns the latest commit on ref (defaults to the default branch) at or before t, or the latest commit if
+// t is zero.
+func commitAt(ctx context.Context, client *github.Client, owner, repo, ref string, t time.Time) (*github.RepositoryCommit, error) {
+	desc := fmt.Sprintf("of %q", ref)
+	if !t.IsZero() {
+		desc = "as of " + t.Format(time.RFC3339)
+	}
+	commits, _, err := client.Repositories.ListCommits(ctx, owner, repo, &github.CommitsListOptions{
+		SHA:         ref,
+		Until:       t,
+		ListOptions: github.ListOptions{PerPage: 1},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("resolving the commit %s: %w", desc, err)
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("no commit found %s", desc)
+	}
+	return commits[0], nil
+}
@@ -0,0 +1,95 @@
+package ghwalk
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkAt(t *testing.T) {
+	cases := []struct {
+		at      time.Time
+		isError bool
+	}{
+		{
+			at: time.Now(),
+		},
+		{
+			// before the repository is created
+			at:      time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+			isError: true,
+		},
+	}
+
+	for _, c := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		snapshot, err := TakeSnapshot(ctx, "magodo", "ghwalk", "testdata/dir",
+			&WalkOptions{Token: githubToken, BaseURL: githubBaseURL, At: c.at})
+		if c.isError {
+			require.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		require.Len(t, snapshot.Ref, 40)
+		require.Len(t, snapshot.Entries, 2)
+	}
+}
+
+type urlRecordingTransport struct {
+	urls []string
+}
+
+func (t *urlRecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.urls = append(t.urls, req.URL.String())
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestWalkPinCommit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	transport := &urlRecordingTransport{}
+	var paths []string
+	result, err := WalkWithResult(ctx, "magodo", "ghwalk", "testdata",
+		&WalkOptions{Token: githubToken, BaseURL: githubBaseURL, Transport: transport, PinCommit: true},
+		func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			paths = append(paths, path)
+			return nil
+		},
+		nil)
+	require.NoError(t, err)
+	require.Len(t, paths, 6)
+	require.Len(t, result.CommitSHA, 40)
+	require.Len(t, result.RootTreeSHA, 40)
+	require.NotEqual(t, result.CommitSHA, result.RootTreeSHA)
+	require.Equal(t, 6, result.Entries)
+	// Every response reports the rate limit status
+	require.Equal(t, len(transport.urls), result.Stats.RateLimit.Observed)
+	result.Stats.RateLimit = RateLimitStats{}
+	require.Equal(t, WalkStats{Files: 4, Dirs: 2, Requests: len(transport.urls)}, result.Stats)
+
+	// Every request but the first one (resolving the commit) is against the pinned commit
+	require.Greater(t, len(transport.urls), 1)
+	for _, u := range transport.urls[1:] {
+		require.Contains(t, u, "ref="+result.CommitSHA)
+	}
+
+	// The commit is not reported unless pinned
+	result, err = WalkWithResult(ctx, "magodo", "ghwalk", "testdata",
+		&WalkOptions{Token: githubToken, BaseURL: githubBaseURL},
+		func(path string, info *FileInfo, err error) error {
+			return err
+		},
+		nil)
+	require.NoError(t, err)
+	require.Empty(t, result.CommitSHA)
+	require.Empty(t, result.RootTreeSHA)
+	require.Equal(t, 6, result.Entries)
+}
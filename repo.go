@@ -0,0 +1,145 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// redirectTransport records whether any request against a repository is redirected, which Github does for the
+// renamed or transferred repositories.
+type redirectTransport struct {
+	base       http.RoundTripper
+	redirected *atomic.Bool
+}
+
+func (t *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		if strings.Contains(req.URL.Path, "/repos/") {
+			t.redirected.Store(true)
+		}
+	}
+	return resp, nil
+}
+
+// RepoMetadata is the metadata of a repository.
+type RepoMetadata struct {
+	// Owner and Name are the canonical owner and name of the repository.
+	Owner string
+	Name  string
+	// DefaultBranch is the name of the default branch.
+	DefaultBranch string
+	// Visibility is one of "public", "private" and "internal".
+	Visibility string
+	// Archived tells whether the repository is archived, i.e. read-only.
+	Archived bool
+	// Fork tells whether the repository is a fork.
+	Fork bool
+	// Size is the size of the repository in kilobytes, as reported by Github.
+	Size   int
+	Topics []string
+}
+
+func newRepoMetadata(r *github.Repository) *RepoMetadata {
+	visibility := r.GetVisibility()
+	if visibility == "" {
+		visibility = "public"
+		if r.GetPrivate() {
+			visibility = "private"
+		}
+	}
+	return &RepoMetadata{
+		Owner:         r.GetOwner().GetLogin(),
+		Name:          r.GetName(),
+		DefaultBranch: r.GetDefaultBranch(),
+		Visibility:    visibility,
+		Archived:      r.GetArchived(),
+		Fork:          r.GetFork(),
+		Size:          r.GetSize(),
+		Topics:        r.Topics,
+	}
+}
+
+// GetRepoMetadata returns the metadata of the repository, following the redirect if it has been renamed or
+// transferred. Only the Token, BaseURL and Transport of opt (and the others customizing the API requests) are used.
+func GetRepoMetadata(ctx context.Context, owner, repo string, opt *WalkOptions) (*RepoMetadata, error) {
+	client, err := newClient(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	r, _, err := client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	return newRepoMetadata(r), nil
+}
+
+// RefNotFoundError is the error passed to the WalkFunc if the Ref of the WalkOptions doesn't exist in the repository.
+// It matches ErrNotExist.
+type RefNotFoundError struct {
+	Owner string
+	Repo  string
+	Ref   string
+	// Err is the underlying error returned by the Github API.
+	Err error
+}
+
+func (e *RefNotFoundError) Error() string {
+	return fmt.Sprintf("ref %q is not found in repository %s/%s", e.Ref, e.Owner, e.Repo)
+}
+
+func (e *RefNotFoundError) Is(target error) bool {
+	return target == ErrNotExist
+}
+
+func (e *RefNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// diagnoseNotExist tells why the walked path is not found by Github, as Github responds 404 Not Found alike for a
+// missing path, a missing ref, a missing repository and a private repository that the token has no access to. It
+// probes the repository and the ref, and returns a *TokenError or a *RefNotFoundError accordingly, or err as is if
+// the path itself is missing.
+func diagnoseNotExist(ctx context.Context, owner, repo string, opt *WalkOptions, err error) error {
+	if !isNotFound(err) {
+		// The directory is listed, but the path is not in it
+		return err
+	}
+	client, cerr := newClient(ctx, opt)
+	if cerr != nil {
+		return err
+	}
+	if _, _, rerr := client.Repositories.Get(ctx, owner, repo); rerr != nil {
+		if aerr := repoAccessError(owner, repo, nil, rerr); aerr != rerr {
+			return aerr
+		}
+		return err
+	}
+	if opt == nil || opt.Ref == "" {
+		return err
+	}
+	_, _, rerr := client.Repositories.ListCommits(ctx, owner, repo, &github.CommitsListOptions{
+		SHA:         opt.Ref,
+		ListOptions: github.ListOptions{PerPage: 1},
+	})
+	var eresp *github.ErrorResponse
+	if errors.As(rerr, &eresp) && eresp.Response != nil &&
+		(eresp.Response.StatusCode == http.StatusNotFound || eresp.Response.StatusCode == http.StatusUnprocessableEntity) {
+		return &RefNotFoundError{Owner: owner, Repo: repo, Ref: opt.Ref, Err: rerr}
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkRenamedRepo(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+	srv.RenameRepo("someone/old", "magodo/ghwalk")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	cases := []struct {
+		owner string
+		repo  string
+		opt   *WalkOptions
+	}{
+		{owner: "magodo", repo: "ghwalk", opt: &WalkOptions{BaseURL: srv.BaseURL()}},
+		{owner: "someone", repo: "old", opt: &WalkOptions{BaseURL: srv.BaseURL()}},
+		{owner: "someone", repo: "old", opt: &WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true, PinCommit: true}},
+		{owner: "someone", repo: "old", opt: &WalkOptions{BaseURL: srv.BaseURL(), Strategy: StrategyTrees}},
+	}
+	for _, c := range cases {
+		var paths []string
+		result, err := WalkWithResult(ctx, c.owner, c.repo, "testdata", c.opt, func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			paths = append(paths, path)
+			return nil
+		}, nil)
+		require.NoError(t, err, c.owner+"/"+c.repo)
+		require.Equal(t, []string{"testdata", "testdata/a", "testdata/b", "testdata/dir", "testdata/dir/c", "testdata/link_dir"}, paths)
+		require.Equal(t, "magodo", result.Owner)
+		require.Equal(t, "ghwalk", result.Repo)
+	}
+}
+
+func TestWalkFetchRepoMetadata(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+	srv.RenameRepo("someone/old", "magodo/ghwalk")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	expect := &RepoMetadata{Owner: "magodo", Name: "ghwalk", DefaultBranch: "main", Visibility: "public"}
+	cases := []struct {
+		repo   string
+		fetch  bool
+		expect *RepoMetadata
+	}{
+		{repo: "magodo/ghwalk", fetch: false, expect: nil},
+		{repo: "magodo/ghwalk", fetch: true, expect: expect},
+		{repo: "someone/old", fetch: false, expect: expect},
+		{repo: "someone/old", fetch: true, expect: expect},
+	}
+	for _, c := range cases {
+		parts := strings.SplitN(c.repo, "/", 2)
+		result, err := WalkWithResult(ctx, parts[0], parts[1], "testdata/dir", &WalkOptions{BaseURL: srv.BaseURL(), FetchRepoMetadata: c.fetch}, func(path string, info *FileInfo, err error) error {
+			return err
+		}, nil)
+		require.NoError(t, err, c.repo)
+		require.Equal(t, c.expect, result.Repository, c.repo)
+	}
+
+	metadata, err := GetRepoMetadata(ctx, "someone", "old", &WalkOptions{BaseURL: srv.BaseURL()})
+	require.NoError(t, err)
+	require.Equal(t, expect, metadata)
+}
+
+func TestWalkNotFoundDiagnosis(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{
+		"magodo/ghwalk": ".",
+		"acme/private":  ".",
+		"acme/tagged@1": ".",
+	})
+	defer srv.Close()
+	srv.AddToken("public", ghwalktest.Token{Scopes: []string{"public_repo"}, Repos: []string{"magodo/ghwalk", "acme/tagged"}})
+	srv.AddToken("fine-grained", ghwalktest.Token{Repos: []string{"magodo/ghwalk"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	cases := []struct {
+		repo   string
+		path   string
+		token  string
+		ref    string
+		expect func(err error) bool
+	}{
+		{
+			repo: "magodo/ghwalk", path: "testdata/nonexist",
+			expect: func(err error) bool {
+				var nerr *notExistError
+				return errors.As(err, &nerr)
+			},
+		},
+		{
+			repo: "magodo/ghwalk", path: "nonexist/dir",
+			expect: func(err error) bool {
+				var nerr *notExistError
+				return errors.As(err, &nerr)
+			},
+		},
+		{
+			repo: "acme/private", path: "testdata", token: "public",
+			expect: func(err error) bool {
+				var terr *TokenError
+				return errors.As(err, &terr) && terr.Kind == TokenMissingScope
+			},
+		},
+		{
+			repo: "acme/private", path: "", token: "fine-grained",
+			expect: func(err error) bool {
+				var terr *TokenError
+				return errors.As(err, &terr) && terr.Kind == TokenNoRepoAccess
+			},
+		},
+		{
+			repo: "acme/nonexist", path: "testdata",
+			expect: func(err error) bool {
+				var terr *TokenError
+				return errors.As(err, &terr) && terr.Kind == TokenNoRepoAccess
+			},
+		},
+		{
+			repo: "acme/tagged", path: "testdata", ref: "2",
+			expect: func(err error) bool {
+				var rerr *RefNotFoundError
+				return errors.As(err, &rerr) && rerr.Ref == "2"
+			},
+		},
+	}
+	for _, c := range cases {
+		parts := strings.SplitN(c.repo, "/", 2)
+		opt := &WalkOptions{BaseURL: srv.BaseURL(), Token: c.token, Ref: c.ref, DisableEnvironment: true}
+		err := Walk(ctx, parts[0], parts[1], c.path, opt, func(path string, info *FileInfo, err error) error {
+			return err
+		}, nil)
+		require.True(t, errors.Is(err, ErrNotExist), c.repo+":"+c.path)
+		require.True(t, c.expect(err), "%s:%s: %v", c.repo, c.path, err)
+	}
+}
@@ -0,0 +1,213 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy decides whether and when to retry a failed API request.
+type RetryPolicy interface {
+	// ShouldRetry is called after the attempt-th (starting from 1) attempt of a request fails with err, it returns
+	// the delay before the next attempt, and whether to retry at all.
+	//
+	// The err is either the error returned by the transport (e.g. a network error), or a *StatusError for
+	// an unsuccessful response. The rate limit errors are never passed here, see WaitRateLimit of WalkOptions instead.
+	ShouldRetry(err error, attempt int) (time.Duration, bool)
+}
+
+// StatusError is the error passed to RetryPolicy for an unsuccessful (i.e. 4xx or 5xx) response.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response status: %s", e.Status)
+}
+
+// ExponentialBackoff is a RetryPolicy that retries the temporary failures (network errors and 5xx responses) up to
+// MaxAttempts attempts in total, with the delay growing from BaseDelay by Multiplier after each attempt, capped by
+// MaxDelay.
+//
+// The defaults suit a developer machine, while a shared runner (e.g. CI) usually wants a gentler pacing, e.g. a larger
+// BaseDelay with Jitter, so that the concurrent jobs don't retry in lockstep.
+type ExponentialBackoff struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	// Multiplier is the factor that the delay grows by after each attempt, which defaults to 2 if not greater than 1.
+	Multiplier float64
+	// MaxDelay, if greater than zero, caps the delay
+	MaxDelay time.Duration
+	// MaxElapsed, if greater than zero, caps the total delay of the retries of a request, i.e. the retry is given up
+	// once the delays so far (excluding the jitter) would exceed it, regardless of MaxAttempts.
+	MaxElapsed time.Duration
+	// Jitter, in [0, 1], randomizes each delay by up to this fraction in either direction, e.g. 0.2 gives a delay of
+	// 1s in [0.8s, 1.2s]. The randomized delay is still capped by MaxDelay.
+	Jitter float64
+}
+
+// randFloat64 returns the random number in [0, 1) used for the jitter, which is replaced in tests.
+var randFloat64 = rand.Float64
+
+// ShouldRetry implements RetryPolicy.
+func (b ExponentialBackoff) ShouldRetry(err error, attempt int) (time.Duration, bool) {
+	if attempt >= b.MaxAttempts || !IsTemporary(err) {
+		return 0, false
+	}
+	multiplier := b.Multiplier
+	if multiplier <= 1 {
+		multiplier = 2
+	}
+	var delay, elapsed time.Duration
+	for i := 1; i <= attempt; i++ {
+		if i == 1 {
+			delay = b.BaseDelay
+		} else if b.MaxDelay <= 0 || delay < b.MaxDelay {
+			delay = time.Duration(float64(delay) * multiplier)
+		}
+		if b.MaxDelay > 0 && delay > b.MaxDelay {
+			delay = b.MaxDelay
+		}
+		elapsed += delay
+	}
+	if b.MaxElapsed > 0 && elapsed > b.MaxElapsed {
+		return 0, false
+	}
+	if b.Jitter > 0 {
+		delay += time.Duration(float64(delay) * b.Jitter * (2*randFloat64() - 1))
+		if b.MaxDelay > 0 && delay > b.MaxDelay {
+			delay = b.MaxDelay
+		}
+	}
+	return delay, true
+}
+
+// DefaultRetryPolicy retries the temporary failures up to 3 attempts in total, with the delay starting from 1 second.
+var DefaultRetryPolicy RetryPolicy = ExponentialBackoff{
+	MaxAttempts: 3,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+}
+
+// IsTemporary tells whether the error passed to RetryPolicy is likely temporary, i.e. a network error or a 5xx
+// response, so that the request is worth retrying.
+func IsTemporary(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var serr *StatusError
+	if errors.As(err, &serr) {
+		return serr.StatusCode >= 500
+	}
+	return true
+}
+
+// RetryEvent describes how a failed API request is handled, which is passed to the OnRetry of WalkOptions.
+type RetryEvent struct {
+	Method string
+	URL    string
+
+	// Attempt is the failed attempt, starting from 1.
+	Attempt int
+	// Err is the failure, see RetryPolicy.
+	Err error
+
+	// Idempotent tells whether the request is idempotent (see WithIdempotent), the others are never retried
+	// regardless of the RetryPolicy, to avoid duplicate side effects.
+	Idempotent bool
+	// Retry tells whether the request is retried after the Delay.
+	Retry bool
+	Delay time.Duration
+}
+
+type idempotentKey struct{}
+
+// WithIdempotent marks the requests sent with the returned context as idempotent, so that they are retried by the
+// RetryPolicy even if the method is not. The GET, HEAD and OPTIONS requests are always regarded as idempotent, while
+// the others (i.e. the writes) are not retried unless marked explicitly. The read-only GraphQL queries sent by ghwalk
+// are marked already.
+func WithIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// isIdempotent tells whether the request can be retried without side effects.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	idempotent, _ := req.Context().Value(idempotentKey{}).(bool)
+	return idempotent
+}
+
+// retryTransport retries the failed idempotent requests according to the RetryPolicy.
+type retryTransport struct {
+	base    http.RoundTripper
+	policy  RetryPolicy
+	onRetry func(RetryEvent)
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The retries are sent as the clones of req, as a RoundTripper must not modify the request.
+	areq := req
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(areq)
+
+		var rerr error
+		switch {
+		case err != nil:
+			rerr = err
+		case resp.StatusCode >= 400 && !isRateLimited(resp):
+			rerr = &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+		default:
+			return resp, nil
+		}
+
+		idempotent := isIdempotent(req)
+		var delay time.Duration
+		var ok bool
+		// The request can't be resent if its body can't be rewound.
+		if idempotent && (req.Body == nil || req.GetBody != nil) {
+			delay, ok = t.policy.ShouldRetry(rerr, attempt)
+		}
+		if t.onRetry != nil {
+			t.onRetry(RetryEvent{
+				Method:     req.Method,
+				URL:        req.URL.String(),
+				Attempt:    attempt,
+				Err:        rerr,
+				Idempotent: idempotent,
+				Retry:      ok,
+				Delay:      delay,
+			})
+		}
+		if !ok {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		areq = req.Clone(req.Context())
+		if req.GetBody != nil {
+			if areq.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
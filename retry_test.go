@@ -0,0 +1,221 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	policy := ExponentialBackoff{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 3 * time.Second}
+	cases := []struct {
+		err         error
+		attempt     int
+		expectDelay time.Duration
+		expectRetry bool
+	}{
+		{err: errors.New("connection reset"), attempt: 1, expectDelay: time.Second, expectRetry: true},
+		{err: &StatusError{StatusCode: 502}, attempt: 2, expectDelay: 2 * time.Second, expectRetry: true},
+		{err: &StatusError{StatusCode: 503}, attempt: 3, expectDelay: 3 * time.Second, expectRetry: true},
+		{err: &StatusError{StatusCode: 503}, attempt: 4},
+		{err: &StatusError{StatusCode: 404}, attempt: 1},
+		{err: context.Canceled, attempt: 1},
+	}
+	for _, c := range cases {
+		delay, retry := policy.ShouldRetry(c.err, c.attempt)
+		require.Equal(t, c.expectRetry, retry, c.err)
+		require.Equal(t, c.expectDelay, delay, c.err)
+	}
+}
+
+func TestExponentialBackoffOptions(t *testing.T) {
+	defer func(f func() float64) { randFloat64 = f }(randFloat64)
+	randFloat64 = func() float64 { return 1 }
+
+	cases := []struct {
+		name        string
+		policy      ExponentialBackoff
+		attempt     int
+		expectDelay time.Duration
+		expectRetry bool
+	}{
+		{
+			name:        "multiplier",
+			policy:      ExponentialBackoff{MaxAttempts: 5, BaseDelay: time.Second, Multiplier: 3},
+			attempt:     3,
+			expectDelay: 9 * time.Second,
+			expectRetry: true,
+		},
+		{
+			name:        "max elapsed not exceeded",
+			policy:      ExponentialBackoff{MaxAttempts: 5, BaseDelay: time.Second, MaxElapsed: 3 * time.Second},
+			attempt:     2,
+			expectDelay: 2 * time.Second,
+			expectRetry: true,
+		},
+		{
+			name:    "max elapsed exceeded",
+			policy:  ExponentialBackoff{MaxAttempts: 5, BaseDelay: time.Second, MaxElapsed: 3 * time.Second},
+			attempt: 3,
+		},
+		{
+			name:        "jitter",
+			policy:      ExponentialBackoff{MaxAttempts: 5, BaseDelay: time.Second, Jitter: 0.5},
+			attempt:     2,
+			expectDelay: 3 * time.Second,
+			expectRetry: true,
+		},
+		{
+			name:        "jitter capped by max delay",
+			policy:      ExponentialBackoff{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 2 * time.Second, Jitter: 0.5},
+			attempt:     3,
+			expectDelay: 2 * time.Second,
+			expectRetry: true,
+		},
+	}
+	for _, c := range cases {
+		delay, retry := c.policy.ShouldRetry(errors.New("connection reset"), c.attempt)
+		require.Equal(t, c.expectRetry, retry, c.name)
+		require.Equal(t, c.expectDelay, delay, c.name)
+	}
+}
+
+type countingPolicy struct {
+	errs []error
+}
+
+func (p *countingPolicy) ShouldRetry(err error, attempt int) (time.Duration, bool) {
+	p.errs = append(p.errs, err)
+	return 0, attempt < 2
+}
+
+func TestWalkRetry(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	cases := []struct {
+		faultCalls []int
+		expectErrs int
+		isError    bool
+	}{
+		{
+			faultCalls: []int{1},
+			expectErrs: 1,
+		},
+		{
+			faultCalls: []int{1, 2},
+			expectErrs: 2,
+			isError:    true,
+		},
+	}
+
+	for _, c := range cases {
+		srv.ClearFaults()
+		for _, call := range c.faultCalls {
+			srv.InjectFault(ghwalktest.Fault{Kind: ghwalktest.FaultServerError, Path: "testdata/dir", Call: call})
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		policy := &countingPolicy{}
+		err := Walk(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{BaseURL: srv.BaseURL(), RetryPolicy: policy},
+			func(path string, info *FileInfo, err error) error {
+				return err
+			},
+			nil)
+		require.Len(t, policy.errs, c.expectErrs)
+		for _, err := range policy.errs {
+			var serr *StatusError
+			require.True(t, errors.As(err, &serr))
+			require.Equal(t, 500, serr.StatusCode)
+		}
+		if c.isError {
+			require.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+	}
+}
+
+func TestRetryIdempotent(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		method       string
+		idempotent   bool
+		expectEvents []RetryEvent
+	}{
+		{
+			method: http.MethodGet,
+			expectEvents: []RetryEvent{
+				{Method: "GET", Attempt: 1, Idempotent: true, Retry: true},
+				{Method: "GET", Attempt: 2, Idempotent: true},
+			},
+		},
+		{
+			method: http.MethodPost,
+			expectEvents: []RetryEvent{
+				{Method: "POST", Attempt: 1},
+			},
+		},
+		{
+			method:     http.MethodPost,
+			idempotent: true,
+			expectEvents: []RetryEvent{
+				{Method: "POST", Attempt: 1, Idempotent: true, Retry: true},
+				{Method: "POST", Attempt: 2, Idempotent: true},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		var events []RetryEvent
+		bodies = nil
+		transport := &retryTransport{
+			base:   http.DefaultTransport,
+			policy: &countingPolicy{},
+			onRetry: func(e RetryEvent) {
+				events = append(events, e)
+			},
+		}
+		ctx := context.Background()
+		if c.idempotent {
+			ctx = WithIdempotent(ctx)
+		}
+		req, err := http.NewRequestWithContext(ctx, c.method, srv.URL, strings.NewReader("{}"))
+		require.NoError(t, err)
+		body := req.Body
+		resp, err := transport.RoundTrip(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		// Each attempt sends the whole body, while the request of the caller is left untouched
+		require.Len(t, bodies, len(c.expectEvents))
+		for _, b := range bodies {
+			require.Equal(t, "{}", b)
+		}
+		require.True(t, req.Body == body)
+
+		for i := range events {
+			var serr *StatusError
+			require.True(t, errors.As(events[i].Err, &serr))
+			require.Equal(t, srv.URL, events[i].URL)
+			events[i].Err, events[i].URL = nil, ""
+		}
+		require.Equal(t, c.expectEvents, events, c.method)
+	}
+}
@@ -0,0 +1,224 @@
+package ghwalk
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// SBOMFormat is the format of the file inventory written by WriteSBOM.
+type SBOMFormat string
+
+const (
+	// SBOMFormatSPDX is the SPDX 2.3 JSON format.
+	SBOMFormatSPDX SBOMFormat = "spdx-json"
+	// SBOMFormatCycloneDX is the CycloneDX 1.5 JSON format.
+	SBOMFormatCycloneDX SBOMFormat = "cyclonedx-json"
+)
+
+// sbomFile is a file in the inventory.
+type sbomFile struct {
+	path   string
+	size   int
+	sha1   string
+	sha256 string
+}
+
+// WriteSBOM walks the path in the repository, and writes the inventory of the files under it (i.e. their paths,
+// sizes, SHA-1 and SHA-256 checksums) to w in the format. Symlinks and submodules are not included.
+//
+// The checksums are of the file content (rather than the git blob SHA), so the content of each file is fetched and
+// hashed while being read (see InlineContentLimit), regardless of the EnableFileOnlyInfo and FetchContentFunc of opt.
+// The version of the inventory is the commit walked if it is resolved (see PinCommit), otherwise the Ref of opt.
+func WriteSBOM(ctx context.Context, owner, repo, path string, format SBOMFormat, w io.Writer, opt *WalkOptions) error {
+	if format != SBOMFormatSPDX && format != SBOMFormatCycloneDX {
+		return fmt.Errorf("unsupported SBOM format: %s", format)
+	}
+
+	var o WalkOptions
+	if opt != nil {
+		o = *opt
+	}
+	o.FetchContentFunc = func(path string, info *FileInfo) bool {
+		return info.Type == FileTypeFile
+	}
+
+	var files []sbomFile
+	result, err := WalkWithResult(ctx, owner, repo, path, &o, func(path string, info *FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info == nil || info.Type != FileTypeFile {
+			return nil
+		}
+		file, err := hashFile(ctx, info)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+		return nil
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	version := o.Ref
+	if result.CommitSHA != "" {
+		version = result.CommitSHA
+	}
+	name := owner + "/" + repo
+	created := time.Now().UTC().Truncate(time.Second)
+
+	var doc interface{}
+	if format == SBOMFormatSPDX {
+		doc = spdxDocument(name, version, created, files)
+	} else {
+		doc = cycloneDXDocument(name, version, created, files)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func hashFile(ctx context.Context, info *FileInfo) (sbomFile, error) {
+	r, err := info.Open(ctx)
+	if err != nil {
+		return sbomFile{}, err
+	}
+	defer r.Close()
+	h1, h256 := sha1.New(), sha256.New()
+	n, err := io.Copy(io.MultiWriter(h1, h256), r)
+	if err != nil {
+		return sbomFile{}, fmt.Errorf("reading %s: %v", info.Path, err)
+	}
+	return sbomFile{
+		path:   info.Path,
+		size:   int(n),
+		sha1:   hex.EncodeToString(h1.Sum(nil)),
+		sha256: hex.EncodeToString(h256.Sum(nil)),
+	}, nil
+}
+
+type spdxDoc struct {
+	SPDXVersion       string           `json:"spdxVersion"`
+	DataLicense       string           `json:"dataLicense"`
+	SPDXID            string           `json:"SPDXID"`
+	Name              string           `json:"name"`
+	DocumentNamespace string           `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo `json:"creationInfo"`
+	Files             []spdxFile       `json:"files"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxFile struct {
+	FileName  string         `json:"fileName"`
+	SPDXID    string         `json:"SPDXID"`
+	Checksums []spdxChecksum `json:"checksums"`
+	Comment   string         `json:"comment"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+func spdxDocument(name, version string, created time.Time, files []sbomFile) *spdxDoc {
+	docName := name
+	if version != "" {
+		docName += "@" + version
+	}
+	doc := &spdxDoc{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              docName,
+		DocumentNamespace: fmt.Sprintf("https://spdx.org/spdxdocs/%s-%d", docName, created.Unix()),
+		CreationInfo: spdxCreationInfo{
+			Created:  created.Format(time.RFC3339),
+			Creators: []string{"Tool: ghwalk"},
+		},
+		Files: make([]spdxFile, 0, len(files)),
+	}
+	for i, f := range files {
+		doc.Files = append(doc.Files, spdxFile{
+			FileName: "./" + f.path,
+			SPDXID:   "SPDXRef-File-" + strconv.Itoa(i+1),
+			Checksums: []spdxChecksum{
+				{Algorithm: "SHA1", ChecksumValue: f.sha1},
+				{Algorithm: "SHA256", ChecksumValue: f.sha256},
+			},
+			Comment: fmt.Sprintf("size: %d bytes", f.size),
+		})
+	}
+	return doc
+}
+
+type cycloneDXDoc struct {
+	BOMFormat   string               `json:"bomFormat"`
+	SpecVersion string               `json:"specVersion"`
+	Version     int                  `json:"version"`
+	Metadata    cycloneDXMetadata    `json:"metadata"`
+	Components  []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string               `json:"timestamp"`
+	Tools     []cycloneDXComponent `json:"tools"`
+	Component cycloneDXComponent   `json:"component"`
+}
+
+type cycloneDXComponent struct {
+	Type       string              `json:"type,omitempty"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	Hashes     []cycloneDXHash     `json:"hashes,omitempty"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func cycloneDXDocument(name, version string, created time.Time, files []sbomFile) *cycloneDXDoc {
+	doc := &cycloneDXDoc{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: created.Format(time.RFC3339),
+			Tools:     []cycloneDXComponent{{Name: "ghwalk"}},
+			Component: cycloneDXComponent{Type: "application", Name: name, Version: version},
+		},
+		Components: make([]cycloneDXComponent, 0, len(files)),
+	}
+	for _, f := range files {
+		doc.Components = append(doc.Components, cycloneDXComponent{
+			Type: "file",
+			Name: f.path,
+			Hashes: []cycloneDXHash{
+				{Alg: "SHA-1", Content: f.sha1},
+				{Alg: "SHA-256", Content: f.sha256},
+			},
+			Properties: []cycloneDXProperty{
+				{Name: "ghwalk:size", Value: strconv.Itoa(f.size)},
+			},
+		})
+	}
+	return doc
+}
@@ -0,0 +1,58 @@
+package ghwalk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSBOM(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	opt := &WalkOptions{Token: githubToken, BaseURL: githubBaseURL}
+
+	// The same as `sha1sum testdata/dir/c` and `sha256sum testdata/dir/c`
+	const (
+		sha1c   = "d3dc2cf69a2a65df07390c0fb3a53292d9925a88"
+		sha256c = "eecd60ede99d91c80be6fecc1276b6762a505d523bdfc63e8d53caf72526429e"
+	)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSBOM(ctx, "magodo", "ghwalk", "testdata/dir", SBOMFormatSPDX, &buf, opt))
+	var spdx spdxDoc
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &spdx))
+	require.Equal(t, "SPDX-2.3", spdx.SPDXVersion)
+	require.Equal(t, "magodo/ghwalk", spdx.Name)
+	require.Equal(t, []spdxFile{
+		{
+			FileName: "./testdata/dir/c",
+			SPDXID:   "SPDXRef-File-1",
+			Checksums: []spdxChecksum{
+				{Algorithm: "SHA1", ChecksumValue: sha1c},
+				{Algorithm: "SHA256", ChecksumValue: sha256c},
+			},
+			Comment: "size: 20 bytes",
+		},
+	}, spdx.Files)
+
+	buf.Reset()
+	require.NoError(t, WriteSBOM(ctx, "magodo", "ghwalk", "testdata", SBOMFormatCycloneDX, &buf, opt))
+	var cdx cycloneDXDoc
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &cdx))
+	require.Equal(t, "CycloneDX", cdx.BOMFormat)
+	require.Equal(t, "magodo/ghwalk", cdx.Metadata.Component.Name)
+	var names []string
+	for _, c := range cdx.Components {
+		names = append(names, c.Name)
+	}
+	// The symlink is not included
+	require.Equal(t, []string{"testdata/a", "testdata/b", "testdata/dir/c"}, names)
+	require.Equal(t, []cycloneDXHash{{Alg: "SHA-1", Content: sha1c}, {Alg: "SHA-256", Content: sha256c}}, cdx.Components[2].Hashes)
+	require.Equal(t, []cycloneDXProperty{{Name: "ghwalk:size", Value: "20"}}, cdx.Components[2].Properties)
+
+	require.Error(t, WriteSBOM(ctx, "magodo", "ghwalk", "testdata", "xml", &buf, opt))
+}
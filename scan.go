@@ -0,0 +1,104 @@
+package ghwalk
+
+import (
+	"context"
+	"sync"
+)
+
+// Scanner scans the content of the files, e.g. for secrets or licenses. Its methods are called sequentially, one
+// file after another, but concurrently with the other scanners of the ScanPipeline.
+type Scanner interface {
+	// Match tells whether the file (rather than dir) is to be scanned. The info argument has no FileOnlyInfo.
+	Match(path string, info *FileInfo) bool
+	// Scan scans the decoded content of a matched file. Returning an error stops the pipeline.
+	Scan(ctx context.Context, path string, info *FileInfo, content []byte) error
+}
+
+// ScanPipeline runs multiple Scanners against the files in one walk, so that the content of each file is fetched only
+// once, and fanned out to the scanners matching the file.
+type ScanPipeline struct {
+	scanners []Scanner
+}
+
+// NewScanPipeline returns a ScanPipeline running the scanners.
+func NewScanPipeline(scanners ...Scanner) *ScanPipeline {
+	return &ScanPipeline{scanners: scanners}
+}
+
+// Register adds the scanner to the pipeline. It must not be called while the pipeline is running.
+func (p *ScanPipeline) Register(scanner Scanner) {
+	p.scanners = append(p.scanners, scanner)
+}
+
+// Run walks the path in the repository, and passes the content of each file to the scanners matching it,
+// concurrently. Only the content of the files matched by any scanner is fetched, regardless of the EnableFileOnlyInfo
+// and FetchContentFunc of opt. The filterFn is the same as the one of Walk.
+//
+// Run stops at the first error, either of the walk or returned by a scanner.
+func (p *ScanPipeline) Run(ctx context.Context, owner, repo, path string, opt *WalkOptions, filterFn PathFilterFunc) error {
+	var o WalkOptions
+	if opt != nil {
+		o = *opt
+	}
+	// The matched scanners are kept till the file is visited, so that each scanner matches each file only once.
+	var mu sync.Mutex
+	matched := map[string][]Scanner{}
+	o.FetchContentFunc = func(path string, info *FileInfo) bool {
+		scanners := p.match(path, info)
+		if len(scanners) == 0 {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		matched[path] = scanners
+		return true
+	}
+
+	return Walk(ctx, owner, repo, path, &o, func(path string, info *FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info == nil || info.IsDir() {
+			return nil
+		}
+		mu.Lock()
+		scanners := matched[path]
+		delete(matched, path)
+		mu.Unlock()
+		if len(scanners) == 0 {
+			return nil
+		}
+		content, err := readContent(ctx, info)
+		if err != nil {
+			return err
+		}
+
+		errs := make([]error, len(scanners))
+		var wg sync.WaitGroup
+		for i, scanner := range scanners {
+			wg.Add(1)
+			go func(i int, scanner Scanner) {
+				defer wg.Done()
+				errs[i] = scanner.Scan(ctx, path, info, content)
+			}(i, scanner)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, filterFn)
+}
+
+// match returns the scanners matching the file.
+func (p *ScanPipeline) match(path string, info *FileInfo) []Scanner {
+	var scanners []Scanner
+	for _, scanner := range p.scanners {
+		if scanner.Match(path, info) {
+			scanners = append(scanners, scanner)
+		}
+	}
+	return scanners
+}
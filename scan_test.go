@@ -0,0 +1,63 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// lineScanner records the first line of the files with the suffix.
+type lineScanner struct {
+	suffix string
+	lines  map[string]string
+	err    error
+}
+
+func (s *lineScanner) Match(path string, info *FileInfo) bool {
+	return strings.HasSuffix(path, s.suffix)
+}
+
+func (s *lineScanner) Scan(ctx context.Context, path string, info *FileInfo, content []byte) error {
+	if s.lines == nil {
+		s.lines = map[string]string{}
+	}
+	s.lines[path] = strings.SplitN(string(content), "\n", 2)[0]
+	return s.err
+}
+
+func TestScanPipeline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	transport := &urlRecordingTransport{}
+	opt := &WalkOptions{Token: githubToken, BaseURL: githubBaseURL, Transport: transport}
+
+	all := &lineScanner{suffix: ""}
+	onlyC := &lineScanner{suffix: "/c"}
+	pipeline := NewScanPipeline(all)
+	pipeline.Register(onlyC)
+	require.NoError(t, pipeline.Run(ctx, "magodo", "ghwalk", "testdata", opt, func(path string, info *FileInfo) bool {
+		return path == "testdata/b"
+	}))
+	require.Equal(t, map[string]string{
+		"testdata/a":        "content of a",
+		"testdata/dir/c":    "content of c in dir",
+		"testdata/link_dir": "dir",
+	}, all.lines)
+	require.Equal(t, map[string]string{"testdata/dir/c": all.lines["testdata/dir/c"]}, onlyC.lines)
+
+	// Only the content of the matched files is fetched
+	transport.urls = nil
+	require.NoError(t, NewScanPipeline(&lineScanner{suffix: "/c"}).Run(ctx, "magodo", "ghwalk", "testdata", opt, nil))
+	for _, u := range transport.urls {
+		require.NotContains(t, u, "testdata/a")
+	}
+
+	failing := &lineScanner{suffix: "/a", err: errors.New("boom")}
+	err := NewScanPipeline(all, failing).Run(ctx, "magodo", "ghwalk", "testdata", opt, nil)
+	require.EqualError(t, err, "boom")
+}
@@ -0,0 +1,268 @@
+package ghwalk
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitScheduler coordinates the API requests of the concurrent walks sharing a token (e.g. set as the Scheduler
+// of their WalkOptions), so that they spend the rate limit together rather than each exhausting it on its own. A
+// scheduler can be shared process-wide, e.g. as a package level variable, as long as the walks using it share the
+// token and the Github instance.
+//
+// The scheduler tracks the remaining rate limit of each resource (i.e. "core", "search" and "graphql") from the
+// responses. Once it is used up, the requests wait for the reset, and are then let through in the order of the
+// Priority of their walks (higher first), rather than all at once. The requests revalidating the responses in the
+// Cache go through the scheduler as well, i.e. they wait for their turn and take up one of the MaxConcurrent, even
+// though the 304 Not Modified responses don't cost any rate limit.
+//
+// The zero value is a scheduler without any limit on the concurrency or reserve, ready to use.
+type RateLimitScheduler struct {
+	// MaxConcurrent, if greater than zero, is the maximum number of the requests in flight across the walks, the
+	// requests beyond wait in the order of the priority.
+	MaxConcurrent int
+
+	// Reserve is the number of the remaining requests of each resource kept for the walks of positive priority: once
+	// the remaining rate limit falls to Reserve, the walks of priority zero or lower wait for the reset.
+	Reserve int
+
+	// now, if not nil, replaces time.Now for testing
+	now func() time.Time
+
+	mu       sync.Mutex
+	inflight int
+	rates    map[string]*schedulerRate
+	waiters  []*schedulerWaiter
+	seq      int
+	timer    *time.Timer
+}
+
+type schedulerRate struct {
+	Rate
+	// pending is the number of the requests in flight, which are not yet reflected in the Remaining.
+	pending int
+}
+
+type schedulerWaiter struct {
+	resource string
+	priority int
+	seq      int
+	granted  chan struct{}
+}
+
+// NewRateLimitScheduler returns a RateLimitScheduler without any limit on the concurrency or reserve, which is the
+// same as the zero value.
+func NewRateLimitScheduler() *RateLimitScheduler {
+	return &RateLimitScheduler{}
+}
+
+func (s *RateLimitScheduler) clock() time.Time {
+	if s.now == nil {
+		return time.Now()
+	}
+	return s.now()
+}
+
+// Rate returns the rate limit status of the resource (e.g. "core") last observed by the scheduler, and whether it has
+// been observed since the last reset.
+func (s *RateLimitScheduler) Rate(resource string) (Rate, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.rate(resource)
+	if r == nil {
+		return Rate{}, false
+	}
+	return r.Rate, true
+}
+
+// rate returns the rate limit status of the resource, which is nil if it is unknown or has been reset.
+func (s *RateLimitScheduler) rate(resource string) *schedulerRate {
+	r := s.rates[resource]
+	if r == nil || !s.clock().Before(r.Reset) {
+		return nil
+	}
+	return r
+}
+
+// admits tells whether a request of the resource and priority can be sent now.
+func (s *RateLimitScheduler) admits(resource string, priority int) bool {
+	if s.MaxConcurrent > 0 && s.inflight >= s.MaxConcurrent {
+		return false
+	}
+	r := s.rate(resource)
+	if r == nil {
+		return true
+	}
+	floor := 0
+	if priority <= 0 {
+		floor = s.Reserve
+	}
+	return r.Remaining-r.pending > floor
+}
+
+// acquire waits until the request of the resource and priority can be sent.
+func (s *RateLimitScheduler) acquire(ctx context.Context, resource string, priority int) error {
+	s.mu.Lock()
+	if len(s.waiters) == 0 && s.admits(resource, priority) {
+		s.grant(resource)
+		s.mu.Unlock()
+		return nil
+	}
+	s.seq++
+	w := &schedulerWaiter{resource: resource, priority: priority, seq: s.seq, granted: make(chan struct{})}
+	s.waiters = append(s.waiters, w)
+	sort.SliceStable(s.waiters, func(i, j int) bool {
+		if s.waiters[i].priority != s.waiters[j].priority {
+			return s.waiters[i].priority > s.waiters[j].priority
+		}
+		return s.waiters[i].seq < s.waiters[j].seq
+	})
+	s.dispatch()
+	s.mu.Unlock()
+
+	select {
+	case <-w.granted:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-w.granted:
+			// Granted meanwhile, give it back
+			s.release(resource)
+		default:
+			for i, waiter := range s.waiters {
+				if waiter == w {
+					s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+					break
+				}
+			}
+			s.dispatch()
+		}
+		return ctx.Err()
+	}
+}
+
+func (s *RateLimitScheduler) grant(resource string) {
+	s.inflight++
+	if r := s.rate(resource); r != nil {
+		r.pending++
+	}
+}
+
+func (s *RateLimitScheduler) release(resource string) {
+	s.inflight--
+	if r := s.rate(resource); r != nil && r.pending > 0 {
+		r.pending--
+	}
+	s.dispatch()
+}
+
+// done releases the request of the resource, and records the rate limit status reported by its response (if any).
+func (s *RateLimitScheduler) done(resource string, resp *http.Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if resp != nil {
+		observed := resource
+		if v := resp.Header.Get("X-RateLimit-Resource"); v != "" {
+			observed = v
+		}
+		s.observe(observed, resp)
+	}
+	s.release(resource)
+}
+
+// observe records the rate limit status reported by the response.
+func (s *RateLimitScheduler) observe(resource string, resp *http.Response) {
+	now := s.clock()
+	var rate Rate
+	if event, ok := rateLimitEvent(resp, now); ok {
+		rate = Rate{Limit: event.Limit, Reset: event.Reset}
+	} else {
+		remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+		if err != nil {
+			return
+		}
+		reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil {
+			return
+		}
+		limit, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+		rate = Rate{Limit: limit, Remaining: remaining, Reset: time.Unix(reset, 0)}
+	}
+	r := s.rate(resource)
+	if r == nil {
+		r = &schedulerRate{}
+		if s.rates == nil {
+			s.rates = map[string]*schedulerRate{}
+		}
+		s.rates[resource] = r
+	}
+	r.Rate = rate
+}
+
+// dispatch grants the waiting requests that can be sent now, in the order of the priority. If any is held by the
+// rate limit, it is dispatched again once the rate limit resets.
+func (s *RateLimitScheduler) dispatch() {
+	var reset time.Time
+	waiters := s.waiters[:0]
+	for _, w := range s.waiters {
+		if s.admits(w.resource, w.priority) {
+			s.grant(w.resource)
+			close(w.granted)
+			continue
+		}
+		if r := s.rate(w.resource); r != nil && (s.MaxConcurrent <= 0 || s.inflight < s.MaxConcurrent) {
+			if reset.IsZero() || r.Reset.Before(reset) {
+				reset = r.Reset
+			}
+		}
+		waiters = append(waiters, w)
+	}
+	s.waiters = waiters
+	if !reset.IsZero() && s.timer == nil {
+		s.timer = time.AfterFunc(reset.Sub(s.clock()), s.wake)
+	}
+}
+
+// wake dispatches the requests held by the rate limit once it resets.
+func (s *RateLimitScheduler) wake() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timer = nil
+	s.dispatch()
+}
+
+// schedulerTransport sends the requests via the RateLimitScheduler.
+type schedulerTransport struct {
+	base      http.RoundTripper
+	scheduler *RateLimitScheduler
+	priority  int
+}
+
+func (t *schedulerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resource := rateLimitResource(req)
+	if err := t.scheduler.acquire(req.Context(), resource, t.priority); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	t.scheduler.done(resource, resp)
+	return resp, err
+}
+
+// rateLimitResource returns the rate limit resource that the request counts against, which is reported by the
+// X-RateLimit-Resource header of the response as well.
+func rateLimitResource(req *http.Request) string {
+	switch path := req.URL.Path; {
+	case strings.HasSuffix(path, "/graphql"):
+		return "graphql"
+	case strings.Contains(path, "/search/"):
+		return "search"
+	}
+	return "core"
+}
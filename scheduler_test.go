@@ -0,0 +1,146 @@
+package ghwalk
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+// waitWaiters waits until n requests are waiting in the scheduler.
+func waitWaiters(t *testing.T, s *RateLimitScheduler, n int) {
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.waiters) == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestRateLimitSchedulerPriority(t *testing.T) {
+	s := NewRateLimitScheduler()
+	s.MaxConcurrent = 1
+	ctx := context.Background()
+
+	require.NoError(t, s.acquire(ctx, "core", 0))
+	granted := make(chan int, 3)
+	for i, priority := range []int{0, 5, 1} {
+		go func() {
+			require.NoError(t, s.acquire(ctx, "core", priority))
+			granted <- priority
+		}()
+		waitWaiters(t, s, i+1)
+	}
+
+	var order []int
+	for range 3 {
+		s.done("core", nil)
+		order = append(order, <-granted)
+	}
+	require.Equal(t, []int{5, 1, 0}, order)
+
+	// The cancelled request gives up waiting
+	cctx, cancel := context.WithCancel(ctx)
+	errc := make(chan error)
+	go func() { errc <- s.acquire(cctx, "core", 0) }()
+	waitWaiters(t, s, 1)
+	cancel()
+	require.Equal(t, context.Canceled, <-errc)
+	waitWaiters(t, s, 0)
+}
+
+func TestRateLimitSchedulerReserve(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewRateLimitScheduler()
+	s.Reserve = 2
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	require.NoError(t, s.acquire(ctx, "core", 0))
+	s.done("core", &http.Response{StatusCode: http.StatusOK, Header: http.Header{
+		"X-Ratelimit-Limit":     {"10"},
+		"X-Ratelimit-Remaining": {"3"},
+		"X-Ratelimit-Reset":     {strconv.FormatInt(now.Add(time.Hour).Unix(), 10)},
+	}})
+	rate, ok := s.Rate("core")
+	require.True(t, ok)
+	require.Equal(t, 3, rate.Remaining)
+
+	// Another resource is not limited
+	require.NoError(t, s.acquire(ctx, "search", 0))
+
+	// The requests of priority zero leave the reserve alone
+	require.NoError(t, s.acquire(ctx, "core", 0))
+	granted := make(chan struct{})
+	go func() {
+		require.NoError(t, s.acquire(ctx, "core", 0))
+		close(granted)
+	}()
+	waitWaiters(t, s, 1)
+	require.NoError(t, s.acquire(ctx, "core", 1))
+	require.NoError(t, s.acquire(ctx, "core", 1))
+
+	// The waiting request is let through once the rate limit resets
+	now = now.Add(time.Hour)
+	s.wake()
+	<-granted
+	_, ok = s.Rate("core")
+	require.False(t, ok)
+}
+
+func TestWalkScheduler(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	s := NewRateLimitScheduler()
+	s.MaxConcurrent = 1
+	var wg sync.WaitGroup
+	for priority := range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opt := &WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true, ContentConcurrency: 4, Scheduler: s, Priority: priority}
+			var paths []string
+			require.NoError(t, Walk(ctx, "magodo", "ghwalk", "testdata", opt, func(path string, info *FileInfo, err error) error {
+				require.NoError(t, err)
+				paths = append(paths, path)
+				return nil
+			}, nil))
+			require.Len(t, paths, 6)
+		}()
+	}
+	wg.Wait()
+
+	rate, ok := s.Rate("core")
+	require.True(t, ok)
+	require.Equal(t, 60, rate.Limit)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	require.Zero(t, s.inflight)
+}
+
+func TestRateLimitSchedulerZeroValue(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	s := &RateLimitScheduler{MaxConcurrent: 2}
+	_, ok := s.Rate("core")
+	require.False(t, ok)
+	opt := &WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true, ContentConcurrency: 4, Scheduler: s}
+	require.NoError(t, Walk(ctx, "magodo", "ghwalk", "testdata", opt, func(path string, info *FileInfo, err error) error {
+		return err
+	}, nil))
+	rate, ok := s.Rate("core")
+	require.True(t, ok)
+	require.Equal(t, 60, rate.Limit)
+}
@@ -0,0 +1,75 @@
+package ghwalk
+
+import (
+	"context"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// SearchWalkFunc is the type of the function called by WalkSearch for each file or directory visited, along with the
+// owner and name of the repository it belongs to. Its behavior is the same as OrgWalkFunc.
+type SearchWalkFunc func(owner, repo, path string, info *FileInfo, err error) error
+
+// SearchRepos returns the metadata of the repositories matching the query of the Github repository search (e.g.
+// "topic:terraform-module org:foo"), in the order returned by Github. Github returns up to 1000 results of a search.
+//
+// The search API has its own rate limit, which is as low as 30 requests per minute, but resets every minute. So the
+// search requests rejected by it always wait for the reset, regardless of the WaitRateLimit of opt. Only the Token,
+// BaseURL and Transport of opt (and the others customizing the API requests) are used.
+func SearchRepos(ctx context.Context, query string, opt *WalkOptions) ([]*RepoMetadata, error) {
+	var metadata []*RepoMetadata
+	err := searchRepos(ctx, query, opt, func(repos []*RepoMetadata) (bool, error) {
+		metadata = append(metadata, repos...)
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// searchRepos runs the repository search, and calls fn with each page of the results, until it tells to stop.
+func searchRepos(ctx context.Context, query string, opt *WalkOptions, fn func(repos []*RepoMetadata) (bool, error)) error {
+	var searchOpt WalkOptions
+	if opt != nil {
+		searchOpt = *opt
+	}
+	searchOpt.WaitRateLimit = true
+	client, err := newClient(ctx, &searchOpt)
+	if err != nil {
+		return err
+	}
+	listOpt := &github.SearchOptions{ListOptions: opt.listOptions()}
+	for page := 1; ; page++ {
+		result, resp, err := client.Search.Repositories(ctx, query, listOpt)
+		if err != nil {
+			return err
+		}
+		repos := make([]*RepoMetadata, 0, len(result.Repositories))
+		for _, repo := range result.Repositories {
+			repos = append(repos, newRepoMetadata(repo))
+		}
+		stop, err := fn(repos)
+		if err != nil || stop {
+			return err
+		}
+		if listOpt.Page = opt.nextPage(resp, page); listOpt.Page == 0 {
+			return nil
+		}
+	}
+}
+
+// WalkSearch walks the path in each repository matching the query of the Github repository search (see SearchRepos)
+// in turn, as WalkOrg does for an organization. The results are searched page by page along with the walks, so that
+// returning SkipAll saves the search requests of the remaining results as well.
+func WalkSearch(ctx context.Context, query, path string, opt *WalkOptions, walkFn SearchWalkFunc, filterFn PathFilterFunc) error {
+	return searchRepos(ctx, query, opt, func(repos []*RepoMetadata) (bool, error) {
+		for _, metadata := range repos {
+			stopped, err := walkRepo(ctx, metadata.Owner, metadata.Name, path, opt, walkFn, filterFn)
+			if err != nil || stopped {
+				return true, err
+			}
+		}
+		return false, nil
+	})
+}
@@ -0,0 +1,87 @@
+package ghwalk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkSearch(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{
+		"acme/module-a":  newFixture(t, map[string]string{"main.tf": "a"}),
+		"acme/module-b":  newFixture(t, map[string]string{"main.tf": "b"}),
+		"acme/tool":      newFixture(t, map[string]string{"main.go": "c"}),
+		"other/module-c": newFixture(t, map[string]string{"main.tf": "d"}),
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	cases := []struct {
+		name   string
+		query  string
+		opt    *WalkOptions
+		walkFn func(visited *[]string) SearchWalkFunc
+		expect []string
+	}{
+		{
+			name:   "org",
+			query:  "org:acme",
+			opt:    &WalkOptions{BaseURL: srv.BaseURL()},
+			expect: []string{"acme/module-a:", "acme/module-a:main.tf", "acme/module-b:", "acme/module-b:main.tf", "acme/tool:", "acme/tool:main.go"},
+		},
+		{
+			name:   "keyword across owners paginated",
+			query:  "module",
+			opt:    &WalkOptions{BaseURL: srv.BaseURL(), PerPage: 1},
+			expect: []string{"acme/module-a:", "acme/module-a:main.tf", "acme/module-b:", "acme/module-b:main.tf", "other/module-c:", "other/module-c:main.tf"},
+		},
+		{
+			name:  "skip all",
+			query: "module",
+			opt:   &WalkOptions{BaseURL: srv.BaseURL(), PerPage: 1},
+			walkFn: func(visited *[]string) SearchWalkFunc {
+				return func(owner, repo, path string, info *FileInfo, err error) error {
+					*visited = append(*visited, owner+"/"+repo+":"+path)
+					if repo == "module-b" {
+						return SkipAll
+					}
+					return err
+				}
+			},
+			expect: []string{"acme/module-a:", "acme/module-a:main.tf", "acme/module-b:"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var visited []string
+			walkFn := func(owner, repo, path string, info *FileInfo, err error) error {
+				visited = append(visited, owner+"/"+repo+":"+path)
+				return err
+			}
+			if c.walkFn != nil {
+				walkFn = c.walkFn(&visited)
+			}
+			require.NoError(t, WalkSearch(ctx, c.query, "", c.opt, walkFn, nil))
+			require.Equal(t, c.expect, visited)
+		})
+	}
+
+	// The search rate limit is waited for, though WaitRateLimit is not set
+	opt := &WalkOptions{BaseURL: srv.BaseURL()}
+	srv.InjectFault(ghwalktest.Fault{Kind: ghwalktest.FaultRateLimit, Call: 1, Duration: time.Second})
+	repos, err := SearchRepos(ctx, "module org:other", opt)
+	require.NoError(t, err)
+	require.Len(t, repos, 1)
+	require.Equal(t, "other", repos[0].Owner)
+	require.Equal(t, "module-c", repos[0].Name)
+	srv.ClearFaults()
+
+	_, err = SearchRepos(ctx, "stars:>10", opt)
+	require.Error(t, err)
+}
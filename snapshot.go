@@ -0,0 +1,486 @@
+package ghwalk
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Snapshot is the captured state of a path in a repository. It can be persisted, and walked later on without any
+// network access by setting it as the Snapshot of the WalkOptions.
+type Snapshot struct {
+	// Version is the SchemaVersion of the snapshot, which is set by Write. Zero means version 1, i.e. the snapshots
+	// written before the schema was versioned.
+	Version int `json:"Version,omitempty"`
+
+	Owner string `json:"Owner"`
+	Repo  string `json:"Repo"`
+	Ref   string `json:"Ref"`
+	Path  string `json:"Path"`
+
+	// Entries are the FileInfo of the files and directories under Path (including Path itself, unless it is the repo
+	// root), in walk order. If the snapshot is taken with EnableFileOnlyInfo, the files have their FileOnlyInfo set,
+	// which caches the file content.
+	Entries []*FileInfo `json:"Entries"`
+
+	// Partial tells that the snapshot only captures the part walked before a failure.
+	Partial bool `json:"Partial,omitempty"`
+
+	// index indexes the Entries for the queries (e.g. Stat), which is built on the first query and rebuilt if the
+	// number of the Entries changes.
+	mu    sync.Mutex
+	index *snapshotIndex
+}
+
+// SchemaVersion is the version of the JSON schema of Snapshot and FileInfo written by this package. Within a version,
+// the schema only changes compatibly, i.e. by adding optional fields, which the older readers ignore. ReadSnapshot
+// reads the snapshots of any version up to SchemaVersion.
+//
+// The schema of version 1 is an object of Version, Owner, Repo, Ref, Path, Partial and Entries, where each entry is
+// a FileInfo object of:
+//
+//   - Type (string): one of "file", "dir", "symlink" and "submodule"
+//   - Size (number), Name, Path, SHA, URL, GitURL and HTMLURL (string)
+//   - Executable (boolean, optional)
+//   - RawName and RawPath (string, optional)
+//   - Attributes (object of string, optional)
+//   - FileOnlyInfo (object or null): of Target, Encoding and Content (string or null), DownloadURL (string), and
+//     Truncated (boolean, optional)
+const SchemaVersion = 1
+
+// TakeSnapshot walks path in the repository and captures its state. The opt controls what is captured, e.g. set
+// EnableFileOnlyInfo to also cache the file content in the snapshot.
+//
+// If the walk fails halfway (e.g. the context is cancelled), the snapshot of the part walked so far is returned along
+// with the error, with Partial set, so that the progress can be persisted.
+func TakeSnapshot(ctx context.Context, owner, repo, path string, opt *WalkOptions) (*Snapshot, error) {
+	path, err := cleanRepoPath(owner, repo, path)
+	if err != nil {
+		return nil, err
+	}
+	opt, err = resolveOptions(ctx, owner, repo, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{
+		Owner: owner,
+		Repo:  repo,
+		Path:  path,
+	}
+	if opt != nil {
+		snapshot.Ref = opt.Ref
+	}
+	err = Walk(ctx, owner, repo, path, opt,
+		func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			// repo root has no info
+			if info == nil {
+				return nil
+			}
+			// Github serves a symlink pointing to a file as the file, which is captured under the path of the symlink
+			if info.Path != path {
+				file := *info
+				file.Name, file.Path = filepath.Base(path), path
+				info = &file
+			}
+			snapshot.Entries = append(snapshot.Entries, info)
+			return nil
+		},
+		nil)
+	if err != nil {
+		snapshot.Partial = true
+		return snapshot, err
+	}
+	return snapshot, nil
+}
+
+// Write writes the snapshot to w in JSON, of the SchemaVersion.
+func (s *Snapshot) Write(w io.Writer) error {
+	out := &Snapshot{
+		Version: SchemaVersion,
+		Owner:   s.Owner,
+		Repo:    s.Repo,
+		Ref:     s.Ref,
+		Path:    s.Path,
+		Entries: s.Entries,
+		Partial: s.Partial,
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// SnapshotColumn is a column of the table written by Snapshot.WriteTable.
+type SnapshotColumn string
+
+const (
+	ColumnPath    SnapshotColumn = "path"
+	ColumnName    SnapshotColumn = "name"
+	ColumnType    SnapshotColumn = "type"
+	ColumnSize    SnapshotColumn = "size"
+	ColumnSHA     SnapshotColumn = "sha"
+	ColumnURL     SnapshotColumn = "url"
+	ColumnHTMLURL SnapshotColumn = "html_url"
+	ColumnGitURL  SnapshotColumn = "git_url"
+	// ColumnSHA256 is the SHA-256 checksum of the file content, which is empty unless the content is captured.
+	ColumnSHA256 SnapshotColumn = "sha256"
+)
+
+// DefaultSnapshotColumns are the columns written by Snapshot.WriteTable if none is specified.
+var DefaultSnapshotColumns = []SnapshotColumn{ColumnPath, ColumnType, ColumnSize, ColumnSHA}
+
+// WriteTable writes the entries of the snapshot to w as a table, one row per entry following a header row of the
+// column names, for the spreadsheet oriented consumers. The comma is the field delimiter, i.e. ',' for CSV and '\t'
+// for TSV. The columns default to DefaultSnapshotColumns.
+func (s *Snapshot) WriteTable(w io.Writer, comma rune, columns ...SnapshotColumn) error {
+	if len(columns) == 0 {
+		columns = DefaultSnapshotColumns
+	}
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		switch column {
+		case ColumnPath, ColumnName, ColumnType, ColumnSize, ColumnSHA, ColumnURL, ColumnHTMLURL, ColumnGitURL, ColumnSHA256:
+		default:
+			return fmt.Errorf("unknown column %q", column)
+		}
+		record[i] = string(column)
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+	for _, entry := range s.Entries {
+		for i, column := range columns {
+			switch column {
+			case ColumnPath:
+				record[i] = entry.Path
+			case ColumnName:
+				record[i] = entry.Name
+			case ColumnType:
+				record[i] = string(entry.Type)
+			case ColumnSize:
+				record[i] = strconv.Itoa(entry.Size)
+			case ColumnSHA:
+				record[i] = entry.SHA
+			case ColumnURL:
+				record[i] = entry.URL
+			case ColumnHTMLURL:
+				record[i] = entry.HTMLURL
+			case ColumnGitURL:
+				record[i] = entry.GitURL
+			case ColumnSHA256:
+				sum, err := contentSHA256(entry)
+				if err != nil {
+					return fmt.Errorf("checksumming %s: %v", entry.Path, err)
+				}
+				record[i] = sum
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteDOT writes the structure of the snapshot to w as a Graphviz DOT graph, where the directories are clusters
+// (holding a folder node of the directory itself), the other entries are nodes, and each symlink has a dashed edge to
+// its target, if the target is captured in the snapshot (see FileOnlyInfo.Target).
+func (s *Snapshot) WriteDOT(w io.Writer) error {
+	entries := map[string]*FileInfo{}
+	children := map[string][]*FileInfo{}
+	for _, entry := range s.Entries {
+		entries[entry.Path] = entry
+	}
+	var tops []*FileInfo
+	for _, entry := range s.Entries {
+		if parent := parentDir(entry.Path); parent != entry.Path {
+			if _, ok := entries[parent]; ok {
+				children[parent] = append(children[parent], entry)
+				continue
+			}
+		}
+		tops = append(tops, entry)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "digraph %s {\n", strconv.Quote(s.Owner+"/"+s.Repo))
+	fmt.Fprintf(&buf, "  node [shape=box];\n")
+	var write func(entry *FileInfo, indent string)
+	write = func(entry *FileInfo, indent string) {
+		id, label := strconv.Quote(entry.Path), strconv.Quote(entry.Name)
+		switch entry.Type {
+		case FileTypeDir:
+			fmt.Fprintf(&buf, "%ssubgraph %s {\n", indent, strconv.Quote("cluster_"+entry.Path))
+			fmt.Fprintf(&buf, "%s  label=%s;\n", indent, label)
+			fmt.Fprintf(&buf, "%s  %s [shape=folder, label=%s];\n", indent, id, label)
+			for _, child := range children[entry.Path] {
+				write(child, indent+"  ")
+			}
+			fmt.Fprintf(&buf, "%s}\n", indent)
+		case FileTypeSymlink:
+			fmt.Fprintf(&buf, "%s%s [label=%s, style=dashed];\n", indent, id, label)
+		case FileTypeSubmodule:
+			fmt.Fprintf(&buf, "%s%s [label=%s, shape=component];\n", indent, id, label)
+		default:
+			fmt.Fprintf(&buf, "%s%s [label=%s];\n", indent, id, label)
+		}
+	}
+	for _, entry := range tops {
+		write(entry, "  ")
+	}
+	for _, entry := range s.Entries {
+		if entry.Type != FileTypeSymlink || entry.FileOnlyInfo == nil || entry.FileOnlyInfo.Target == nil {
+			continue
+		}
+		target := path.Join(path.Dir(entry.Path), *entry.FileOnlyInfo.Target)
+		if _, ok := entries[target]; ok {
+			fmt.Fprintf(&buf, "  %s -> %s [style=dashed];\n", strconv.Quote(entry.Path), strconv.Quote(target))
+		}
+	}
+	buf.WriteString("}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// ReadSnapshot reads a snapshot written by Snapshot.Write or Snapshot.WriteBinary from r, the encoding is detected.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	br := bufio.NewReader(r)
+	if isBinary(br, snapshotMagic) {
+		return readSnapshotBinary(br)
+	}
+	var s Snapshot
+	if err := json.NewDecoder(br).Decode(&s); err != nil {
+		return nil, fmt.Errorf("decoding snapshot: %v", err)
+	}
+	if s.Version > SchemaVersion {
+		return nil, fmt.Errorf("decoding snapshot: schema version %d is newer than the supported version %d", s.Version, SchemaVersion)
+	}
+	return &s, nil
+}
+
+// snapshotIndex indexes the Entries of a snapshot by their paths.
+type snapshotIndex struct {
+	// n is the number of the indexed Entries
+	n       int
+	entries map[string]*FileInfo
+	// children are the entries of each directory (the repo root is ""), sorted by name
+	children map[string][]*FileInfo
+}
+
+// indexed returns the index of the Entries, which is built on the first call.
+func (s *Snapshot) indexed() *snapshotIndex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.index != nil && s.index.n == len(s.Entries) {
+		return s.index
+	}
+	idx := &snapshotIndex{
+		n:        len(s.Entries),
+		entries:  map[string]*FileInfo{},
+		children: map[string][]*FileInfo{},
+	}
+	for _, entry := range s.Entries {
+		idx.entries[entry.Path] = entry
+		parent := parentDir(entry.Path)
+		idx.children[parent] = append(idx.children[parent], entry)
+	}
+	for _, children := range idx.children {
+		sort.Slice(children, func(i, j int) bool { return children[i].Name < children[j].Name })
+	}
+	s.index = idx
+	return idx
+}
+
+// Stat returns the FileInfo of the entry named by path (relative to the repo root, like the Path of the Entries), as
+// captured in the snapshot. It returns nil FileInfo for the repo root. The error wraps ErrNotExist if the path is not
+// captured.
+func (s *Snapshot) Stat(path string) (*FileInfo, error) {
+	if path == "" {
+		return nil, nil
+	}
+	entry, ok := s.indexed().entries[path]
+	if !ok {
+		return nil, errNoSuchPath(path)
+	}
+	return entry, nil
+}
+
+// ReadDir returns the FileInfo of the entries of the directory named by path (or the repo root, if empty), sorted by
+// name.
+func (s *Snapshot) ReadDir(path string) ([]*FileInfo, error) {
+	idx := s.indexed()
+	if path != "" {
+		entry, ok := idx.entries[path]
+		if !ok {
+			return nil, errNoSuchPath(path)
+		}
+		if !entry.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", path)
+		}
+	}
+	return append([]*FileInfo(nil), idx.children[path]...), nil
+}
+
+// Glob returns the FileInfo of the entries whose Path matches pattern, in the order of the Entries. The pattern
+// syntax is the one of path.Match, i.e. "*" doesn't match across the "/". The only possible error is
+// path.ErrBadPattern.
+func (s *Snapshot) Glob(pattern string) ([]*FileInfo, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	var matches []*FileInfo
+	for _, entry := range s.Entries {
+		if ok, _ := path.Match(pattern, entry.Path); ok {
+			matches = append(matches, entry)
+		}
+	}
+	return matches, nil
+}
+
+// SnapshotChangeKind is the kind of the difference of an entry between two snapshots.
+type SnapshotChangeKind string
+
+const (
+	// SnapshotAdded means the entry only exists in the other snapshot.
+	SnapshotAdded SnapshotChangeKind = "added"
+	// SnapshotRemoved means the entry only exists in this snapshot.
+	SnapshotRemoved SnapshotChangeKind = "removed"
+	// SnapshotModified means the entry exists in both snapshots, but with a different type or SHA.
+	SnapshotModified SnapshotChangeKind = "modified"
+)
+
+// SnapshotChange is an entry that differs between two snapshots.
+type SnapshotChange struct {
+	Path string
+	Kind SnapshotChangeKind
+	// Old is the FileInfo of the entry in this snapshot, nil if it is added.
+	Old *FileInfo
+	// New is the FileInfo of the entry in the other snapshot, nil if it is removed.
+	New *FileInfo
+}
+
+// Diff compares the snapshot with other, usually a later one of the same path, and returns the entries that differ,
+// sorted by path in the walk order. The entries are compared by their type and SHA (see Identical), so a directory is
+// modified if anything under it is.
+func (s *Snapshot) Diff(other *Snapshot) []SnapshotChange {
+	old, new := s.indexed().entries, other.indexed().entries
+	var changes []SnapshotChange
+	for p, info := range old {
+		ninfo, ok := new[p]
+		switch {
+		case !ok:
+			changes = append(changes, SnapshotChange{Path: p, Kind: SnapshotRemoved, Old: info})
+		case !Identical([]*FileInfo{info, ninfo}):
+			changes = append(changes, SnapshotChange{Path: p, Kind: SnapshotModified, Old: info, New: ninfo})
+		}
+	}
+	for p, info := range new {
+		if _, ok := old[p]; !ok {
+			changes = append(changes, SnapshotChange{Path: p, Kind: SnapshotAdded, New: info})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return lessPath(changes[i].Path, changes[j].Path, false)
+	})
+	return changes
+}
+
+// FS returns the fs.FS of the Path of the snapshot, which serves the cached data without any network access. The
+// content of a file can only be read if it is captured (see TakeSnapshot). The symlinks are followed, as long as
+// their targets are captured.
+func (s *Snapshot) FS() fs.FS {
+	return &providerFS{
+		ctx:      context.Background(),
+		owner:    s.Owner,
+		repo:     s.Repo,
+		root:     s.Path,
+		provider: NewSnapshotProvider(s),
+	}
+}
+
+// snapshotProvider provides the repository content from a snapshot.
+type snapshotProvider struct {
+	snapshot *Snapshot
+}
+
+// NewSnapshotProvider returns the ContentProvider that provides the content from the snapshot. As the Snapshot can be
+// built in memory, this is also a convenient way to fake a repository in unit tests.
+func NewSnapshotProvider(snapshot *Snapshot) ContentProvider {
+	return &snapshotProvider{snapshot: snapshot}
+}
+
+func (p *snapshotProvider) checkRepo(owner, repo string) error {
+	if owner != p.snapshot.Owner || repo != p.snapshot.Repo {
+		return fmt.Errorf("the snapshot is taken from %s/%s, not %s/%s", p.snapshot.Owner, p.snapshot.Repo, owner, repo)
+	}
+	return nil
+}
+
+func (p *snapshotProvider) Stat(ctx context.Context, owner, repo, path string) (*FileInfo, error) {
+	if err := p.checkRepo(owner, repo); err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, nil
+	}
+	entry, err := p.snapshot.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return stripFileOnlyInfo(entry), nil
+}
+
+func (p *snapshotProvider) ReadDir(ctx context.Context, owner, repo, path string) ([]*FileInfo, error) {
+	if err := p.checkRepo(owner, repo); err != nil {
+		return nil, err
+	}
+	children, err := p.snapshot.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*FileInfo, 0, len(children))
+	for _, child := range children {
+		entries = append(entries, stripFileOnlyInfo(child))
+	}
+	return entries, nil
+}
+
+func (p *snapshotProvider) ReadFile(ctx context.Context, owner, repo, path string) (*FileInfo, error) {
+	if err := p.checkRepo(owner, repo); err != nil {
+		return nil, err
+	}
+	entry, ok := p.snapshot.indexed().entries[path]
+	if !ok {
+		return nil, errNoSuchPath(path)
+	}
+	if entry.FileOnlyInfo == nil {
+		return nil, fmt.Errorf("the file only info of %s is not captured in the snapshot", path)
+	}
+	info := *entry
+	return &info, nil
+}
+
+// stripFileOnlyInfo returns a copy of the FileInfo without FileOnlyInfo, as is returned by the Github directory
+// listing.
+func stripFileOnlyInfo(info *FileInfo) *FileInfo {
+	out := *info
+	out.FileOnlyInfo = nil
+	return &out
+}
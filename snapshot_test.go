@@ -0,0 +1,345 @@
+package ghwalk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	snapshot, err := TakeSnapshot(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{Token: githubToken, BaseURL: githubBaseURL, EnableFileOnlyInfo: true})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, snapshot.Write(&buf))
+	snapshot, err = ReadSnapshot(&buf)
+	require.NoError(t, err)
+
+	cases := []struct {
+		path          string
+		reverse       bool
+		fileOnlyInfo  bool
+		expectPath    []string
+		expectContent map[string]string
+	}{
+		{
+			path: "testdata",
+			expectPath: []string{
+				"testdata",
+				"testdata/a",
+				"testdata/b",
+				"testdata/dir",
+				"testdata/dir/c",
+				"testdata/link_dir",
+			},
+		},
+		{
+			path:    "testdata/dir",
+			reverse: true,
+			expectPath: []string{
+				"testdata/dir",
+				"testdata/dir/c",
+			},
+		},
+		{
+			path:         "testdata",
+			fileOnlyInfo: true,
+			expectPath: []string{
+				"testdata",
+				"testdata/a",
+				"testdata/b",
+				"testdata/dir",
+				"testdata/dir/c",
+				"testdata/link_dir",
+			},
+			expectContent: map[string]string{
+				"testdata/a":     "content of a\n",
+				"testdata/b":     "content of b\n",
+				"testdata/dir/c": "content of c in dir\n",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		traversedPath := []string{}
+		content := map[string]string{}
+		// The base URL is unreachable, the walk must not send any request
+		err := Walk(ctx, "magodo", "ghwalk", c.path,
+			&WalkOptions{BaseURL: "http://127.0.0.1:0/", Snapshot: snapshot, Reverse: c.reverse, EnableFileOnlyInfo: c.fileOnlyInfo},
+			func(path string, info *FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				traversedPath = append(traversedPath, path)
+				if info.FileOnlyInfo != nil && info.Type == FileTypeFile {
+					content[path], err = info.GetContent()
+				}
+				return err
+			},
+			nil)
+		require.NoError(t, err)
+		require.Equal(t, c.expectPath, traversedPath)
+		if c.expectContent != nil {
+			require.Equal(t, c.expectContent, content)
+		}
+	}
+
+	err = Walk(ctx, "magodo", "other", "testdata", &WalkOptions{Snapshot: snapshot},
+		func(path string, info *FileInfo, err error) error {
+			return err
+		},
+		nil)
+	require.Error(t, err)
+}
+
+func TestTakeSnapshotPartial(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+	srv.InjectFault(ghwalktest.Fault{Kind: ghwalktest.FaultTimeout, Path: "testdata/dir"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	snapshot, err := TakeSnapshot(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{BaseURL: srv.BaseURL()})
+	require.Error(t, err)
+	require.True(t, snapshot.Partial)
+	var paths []string
+	for _, entry := range snapshot.Entries {
+		paths = append(paths, entry.Path)
+	}
+	require.Equal(t, []string{"testdata", "testdata/a", "testdata/b"}, paths)
+}
+
+func TestSnapshotSchema(t *testing.T) {
+	content, encoding := "YQo=", "base64"
+	snapshot := &Snapshot{
+		Owner: "foo",
+		Repo:  "bar",
+		Entries: []*FileInfo{
+			{Type: FileTypeDir, Name: "dir", Path: "dir"},
+			{
+				Type:         FileTypeFile,
+				Size:         2,
+				Name:         "a",
+				Path:         "dir/a",
+				SHA:          "78981922613b2afb6025042ff6bd878ac1994e85",
+				Executable:   true,
+				FileOnlyInfo: &FileOnlyInfo{Encoding: &encoding, Content: &content},
+			},
+		},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, snapshot.Write(&buf))
+	require.Contains(t, buf.String(), `"Version": 1`)
+	read, err := ReadSnapshot(&buf)
+	require.NoError(t, err)
+	snapshot.Version = SchemaVersion
+	require.Equal(t, snapshot, read)
+
+	cases := []struct {
+		name   string
+		input  string
+		expect *Snapshot
+		err    string
+	}{
+		{
+			name:   "unversioned",
+			input:  `{"Owner":"foo","Repo":"bar","Ref":"","Path":"","Entries":[{"Type":"file","Size":1,"Name":"a","Path":"a","SHA":"","URL":"","GitURL":"","HTMLURL":"","FileOnlyInfo":null}]}`,
+			expect: &Snapshot{Owner: "foo", Repo: "bar", Entries: []*FileInfo{{Type: FileTypeFile, Size: 1, Name: "a", Path: "a"}}},
+		},
+		{
+			name:   "unknown fields",
+			input:  `{"Version":1,"Owner":"foo","Mode":"fast","Entries":[{"Type":"dir","Path":"d","Mode":"040000"}]}`,
+			expect: &Snapshot{Version: 1, Owner: "foo", Entries: []*FileInfo{{Type: FileTypeDir, Path: "d"}}},
+		},
+		{
+			name:  "newer version",
+			input: `{"Version":2,"Owner":"foo"}`,
+			err:   "decoding snapshot: schema version 2 is newer than the supported version 1",
+		},
+		{
+			name:  "unknown type",
+			input: `{"Entries":[{"Type":"socket","Path":"s"}]}`,
+			err:   `decoding snapshot: unknown file type "socket" of s`,
+		},
+	}
+	for _, c := range cases {
+		read, err := ReadSnapshot(strings.NewReader(c.input))
+		if c.err != "" {
+			require.EqualError(t, err, c.err, c.name)
+			continue
+		}
+		require.NoError(t, err, c.name)
+		require.Equal(t, c.expect, read, c.name)
+	}
+}
+
+func TestSnapshotWriteTable(t *testing.T) {
+	content := "a,\"quoted\"\n"
+	snapshot := &Snapshot{
+		Owner: "foo",
+		Repo:  "bar",
+		Entries: []*FileInfo{
+			{Type: FileTypeDir, Name: "dir", Path: "dir", SHA: "sha-dir"},
+			{Type: FileTypeFile, Name: "a,b", Path: "dir/a,b", Size: len(content), SHA: "sha-a", FileOnlyInfo: &FileOnlyInfo{Content: &content}},
+		},
+	}
+
+	cases := []struct {
+		comma   rune
+		columns []SnapshotColumn
+		expect  string
+		isError bool
+	}{
+		{
+			comma:  ',',
+			expect: "path,type,size,sha\ndir,dir,0,sha-dir\n\"dir/a,b\",file,11,sha-a\n",
+		},
+		{
+			comma:   '\t',
+			columns: []SnapshotColumn{ColumnName, ColumnSHA256},
+			expect:  "name\tsha256\ndir\t\na,b\t379333b072695002effe45e363155873f06f8445a6733286bc7cc94caf6cab45\n",
+		},
+		{
+			comma:   ',',
+			columns: []SnapshotColumn{"unknown"},
+			isError: true,
+		},
+	}
+	for idx, c := range cases {
+		var buf bytes.Buffer
+		err := snapshot.WriteTable(&buf, c.comma, c.columns...)
+		if c.isError {
+			require.Error(t, err, idx)
+			continue
+		}
+		require.NoError(t, err, idx)
+		require.Equal(t, c.expect, buf.String(), idx)
+	}
+}
+
+func TestSnapshotWriteDOT(t *testing.T) {
+	target := "dir"
+	snapshot := &Snapshot{
+		Owner: "foo",
+		Repo:  "bar",
+		Path:  "root",
+		Entries: []*FileInfo{
+			{Type: FileTypeDir, Name: "root", Path: "root"},
+			{Type: FileTypeFile, Name: "a", Path: "root/a"},
+			{Type: FileTypeDir, Name: "dir", Path: "root/dir"},
+			{Type: FileTypeFile, Name: `"c"`, Path: `root/dir/"c"`},
+			{Type: FileTypeSymlink, Name: "link_dir", Path: "root/link_dir", FileOnlyInfo: &FileOnlyInfo{Target: &target}},
+			{Type: FileTypeSubmodule, Name: "sub", Path: "root/sub"},
+		},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, snapshot.WriteDOT(&buf))
+	require.Equal(t, `digraph "foo/bar" {
+  node [shape=box];
+  subgraph "cluster_root" {
+    label="root";
+    "root" [shape=folder, label="root"];
+    "root/a" [label="a"];
+    subgraph "cluster_root/dir" {
+      label="dir";
+      "root/dir" [shape=folder, label="dir"];
+      "root/dir/\"c\"" [label="\"c\""];
+    }
+    "root/link_dir" [label="link_dir", style=dashed];
+    "root/sub" [label="sub", shape=component];
+  }
+  "root/link_dir" -> "root/dir" [style=dashed];
+}
+`, buf.String())
+}
+
+func TestSnapshotQuery(t *testing.T) {
+	snapshot := &Snapshot{
+		Owner: "foo",
+		Repo:  "bar",
+		Path:  "root",
+		Entries: []*FileInfo{
+			{Type: FileTypeDir, Name: "root", Path: "root", SHA: "1"},
+			{Type: FileTypeFile, Name: "b", Path: "root/b", SHA: "2"},
+			{Type: FileTypeFile, Name: "a.go", Path: "root/a.go", SHA: "3"},
+			{Type: FileTypeDir, Name: "dir", Path: "root/dir", SHA: "4"},
+			{Type: FileTypeFile, Name: "c.go", Path: "root/dir/c.go", SHA: "5"},
+		},
+	}
+	paths := func(infos []*FileInfo) []string {
+		var paths []string
+		for _, info := range infos {
+			paths = append(paths, info.Path)
+		}
+		return paths
+	}
+
+	info, err := snapshot.Stat("root/dir/c.go")
+	require.NoError(t, err)
+	require.Equal(t, "5", info.SHA)
+	info, err = snapshot.Stat("")
+	require.NoError(t, err)
+	require.Nil(t, info)
+	_, err = snapshot.Stat("root/nonexist")
+	require.True(t, errors.Is(err, ErrNotExist))
+
+	entries, err := snapshot.ReadDir("root")
+	require.NoError(t, err)
+	require.Equal(t, []string{"root/a.go", "root/b", "root/dir"}, paths(entries))
+	entries, err = snapshot.ReadDir("")
+	require.NoError(t, err)
+	require.Equal(t, []string{"root"}, paths(entries))
+	_, err = snapshot.ReadDir("root/b")
+	require.Error(t, err)
+
+	matches, err := snapshot.Glob("root/*.go")
+	require.NoError(t, err)
+	require.Equal(t, []string{"root/a.go"}, paths(matches))
+	matches, err = snapshot.Glob("root/*/*.go")
+	require.NoError(t, err)
+	require.Equal(t, []string{"root/dir/c.go"}, paths(matches))
+	_, err = snapshot.Glob("[")
+	require.Equal(t, path.ErrBadPattern, err)
+
+	// The index is rebuilt as the entries grow
+	snapshot.Entries = append(snapshot.Entries, &FileInfo{Type: FileTypeFile, Name: "d", Path: "root/dir/d", SHA: "6"})
+	entries, err = snapshot.ReadDir("root/dir")
+	require.NoError(t, err)
+	require.Equal(t, []string{"root/dir/c.go", "root/dir/d"}, paths(entries))
+
+	later := &Snapshot{
+		Owner: "foo",
+		Repo:  "bar",
+		Path:  "root",
+		Entries: []*FileInfo{
+			{Type: FileTypeDir, Name: "root", Path: "root", SHA: "1"},
+			{Type: FileTypeFile, Name: "a.go", Path: "root/a.go", SHA: "3"},
+			{Type: FileTypeFile, Name: "b", Path: "root/b", SHA: "7"},
+			{Type: FileTypeDir, Name: "dir", Path: "root/dir", SHA: "8"},
+			{Type: FileTypeFile, Name: "c.go", Path: "root/dir/c.go", SHA: "5"},
+			{Type: FileTypeFile, Name: "e", Path: "root/dir/e", SHA: "9"},
+		},
+	}
+	var changes []string
+	for _, change := range snapshot.Diff(later) {
+		changes = append(changes, fmt.Sprintf("%s %s", change.Kind, change.Path))
+	}
+	require.Equal(t, []string{
+		"modified root/b",
+		"modified root/dir",
+		"removed root/dir/d",
+		"added root/dir/e",
+	}, changes)
+	require.Empty(t, later.Diff(later))
+}
@@ -0,0 +1,221 @@
+package ghwalk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// statManyBatchSize is the maximum number of trees looked up in one GraphQL query.
+const statManyBatchSize = 100
+
+// StatMany returns the FileInfo of each of the paths, in the same order as paths. The FileInfo is nil if the path
+// doesn't exist (or it is the repo root, which has no FileInfo).
+//
+// Rather than calling the Contents API for each path, StatMany looks up the entries of the parent trees of the paths
+// via the Github GraphQL API, issuing a single query for up to 100 distinct parent directories. As a result, only the
+// metadata available in a tree entry is set in the returned FileInfo (e.g. URL and HTMLURL are empty), and the
+// FileOnlyInfo is never set. Note that the GraphQL API requires an access token.
+// If opt.Snapshot or opt.Provider is set, StatMany falls back to stat each path via the provider.
+func StatMany(ctx context.Context, owner, repo string, paths []string, opt *WalkOptions) ([]*FileInfo, error) {
+	paths, err := cleanRepoPaths(owner, repo, paths)
+	if err != nil {
+		return nil, err
+	}
+	if opt != nil && (opt.Snapshot != nil || opt.Provider != nil) {
+		return statManyByProvider(ctx, owner, repo, paths, opt)
+	}
+
+	opt, err = resolveOptions(ctx, owner, repo, opt)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClient(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Group the paths by their parent directories, each of which is looked up once.
+	parentSet := map[string]bool{}
+	for _, path := range paths {
+		if path != "" {
+			parentSet[parentDir(path)] = true
+		}
+	}
+	parents := make([]string, 0, len(parentSet))
+	for parent := range parentSet {
+		parents = append(parents, parent)
+	}
+	sort.Strings(parents)
+
+	o := &origin{client: client, owner: owner, repo: repo, opt: opt}
+	infos := map[string]*FileInfo{}
+	for len(parents) != 0 {
+		n := len(parents)
+		if n > statManyBatchSize {
+			n = statManyBatchSize
+		}
+		trees, err := queryTrees(ctx, client, owner, repo, treeRef(opt), parents[:n])
+		if err != nil {
+			return nil, err
+		}
+		for i, entries := range trees {
+			for _, entry := range entries {
+				info := newFileInfoFromGraphQLTreeEntry(client, owner, repo, parents[i], entry)
+				info.origin = o
+				infos[info.Path] = info
+			}
+		}
+		parents = parents[n:]
+	}
+
+	result := make([]*FileInfo, len(paths))
+	for i, path := range paths {
+		result[i] = infos[path]
+	}
+	return result, nil
+}
+
+func statManyByProvider(ctx context.Context, owner, repo string, paths []string, opt *WalkOptions) ([]*FileInfo, error) {
+	p, err := newProvider(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*FileInfo, len(paths))
+	for i, path := range paths {
+		info, err := p.Stat(ctx, owner, repo, path)
+		if err != nil {
+			if errors.Is(err, ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		result[i] = info
+	}
+	return result, nil
+}
+
+// parentDir returns the parent directory of path, which is empty for the repo root.
+func parentDir(path string) string {
+	parent := filepath.Dir(path)
+	if parent == "." {
+		parent = ""
+	}
+	return parent
+}
+
+type graphQLTreeEntry struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Mode   int    `json:"mode"`
+	OID    string `json:"oid"`
+	Object struct {
+		ByteSize int `json:"byteSize"`
+	} `json:"object"`
+}
+
+type graphQLError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// queryTrees looks up the entries of the trees of dirs at ref via the GraphQL API, in the same order as dirs. The
+// entries are nil if the directory doesn't exist.
+func queryTrees(ctx context.Context, client *github.Client, owner, repo, ref string, dirs []string) ([][]graphQLTreeEntry, error) {
+	var params, fields []string
+	variables := map[string]string{
+		"owner": owner,
+		"name":  repo,
+	}
+	for i, dir := range dirs {
+		alias := fmt.Sprintf("t%d", i)
+		params = append(params, fmt.Sprintf("$%s: String!", alias))
+		fields = append(fields, fmt.Sprintf("%s: object(expression: $%s) { ...treeEntries }", alias, alias))
+		variables[alias] = ref + ":" + dir
+	}
+	query := fmt.Sprintf(`query($owner: String!, $name: String!, %s) {
+  repository(owner: $owner, name: $name) {
+    %s
+  }
+}
+fragment treeEntries on Tree {
+  entries { name type mode oid object { ... on Blob { byteSize } } }
+}`, strings.Join(params, ", "), strings.Join(fields, "\n    "))
+
+	req, err := client.NewRequest("POST", graphQLURL(client), map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Data struct {
+			Repository map[string]*struct {
+				Entries []graphQLTreeEntry `json:"entries"`
+			} `json:"repository"`
+		} `json:"data"`
+		Errors []graphQLError `json:"errors"`
+	}
+	// The query is read-only, which is safe to retry
+	if _, err := client.Do(WithIdempotent(ctx), req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Errors) != 0 {
+		msgs := make([]string, 0, len(resp.Errors))
+		for _, e := range resp.Errors {
+			msgs = append(msgs, e.Message)
+		}
+		return nil, fmt.Errorf("querying trees of %s/%s: %s", owner, repo, strings.Join(msgs, "; "))
+	}
+
+	trees := make([][]graphQLTreeEntry, len(dirs))
+	for i := range dirs {
+		if tree := resp.Data.Repository[fmt.Sprintf("t%d", i)]; tree != nil {
+			trees[i] = tree.Entries
+		}
+	}
+	return trees, nil
+}
+
+// graphQLURL returns the GraphQL API endpoint that pairs with the REST API base URL of the client. For Github
+// Enterprise Server, the REST API is served under "/api/v3/" while the GraphQL API is served at "/api/graphql".
+func graphQLURL(client *github.Client) string {
+	u := *client.BaseURL
+	if strings.HasSuffix(u.Path, "/api/v3/") {
+		u.Path = strings.TrimSuffix(u.Path, "v3/") + "graphql"
+		return u.String()
+	}
+	u.Path += "graphql"
+	return u.String()
+}
+
+func newFileInfoFromGraphQLTreeEntry(client *github.Client, owner, repo, dir string, entry graphQLTreeEntry) *FileInfo {
+	var typ FileType
+	switch {
+	case entry.Type == "tree":
+		typ = FileTypeDir
+	case entry.Type == "commit":
+		typ = FileTypeSubmodule
+	case entry.Mode == 0120000:
+		typ = FileTypeSymlink
+	default:
+		typ = FileTypeFile
+	}
+
+	path := filepath.Join(dir, entry.Name)
+	return &FileInfo{
+		Type:       typ,
+		Size:       entry.Object.ByteSize,
+		Name:       entry.Name,
+		Path:       path,
+		SHA:        entry.OID,
+		GitURL:     fmt.Sprintf("%srepos/%s/%s/git/%ss/%s", client.BaseURL, owner, repo, entry.Type, entry.OID),
+		Executable: entry.Mode == 0100755,
+	}
+}
@@ -0,0 +1,70 @@
+package ghwalk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatMany(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{
+		"foo/bar":     "testdata",
+		"foo/bar@old": "testdata/dir",
+	})
+	defer srv.Close()
+
+	cases := []struct {
+		paths       []string
+		ref         string
+		expectTypes []FileType
+	}{
+		{
+			paths:       []string{"a", "dir", "dir/c", "link_dir", "nonexist", "dir/nonexist", "a/b", ""},
+			expectTypes: []FileType{FileTypeFile, FileTypeDir, FileTypeFile, FileTypeSymlink, "", "", "", ""},
+		},
+		{
+			paths:       []string{"/dir/c/", "c"},
+			ref:         "old",
+			expectTypes: []FileType{"", FileTypeFile},
+		},
+	}
+
+	for _, c := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		infos, err := StatMany(ctx, "foo", "bar", c.paths, &WalkOptions{BaseURL: srv.BaseURL(), Ref: c.ref})
+		require.NoError(t, err)
+		require.Len(t, infos, len(c.paths))
+		for i, info := range infos {
+			if c.expectTypes[i] == "" {
+				require.Nil(t, info, c.paths[i])
+				continue
+			}
+			require.NotNil(t, info, c.paths[i])
+			require.Equal(t, c.expectTypes[i], info.Type)
+		}
+	}
+
+	// The metadata is the same as Walk
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	opt := &WalkOptions{BaseURL: srv.BaseURL()}
+	infos, err := StatMany(ctx, "foo", "bar", []string{"dir/c"}, opt)
+	require.NoError(t, err)
+	p, err := NewGithubProvider(ctx, opt)
+	require.NoError(t, err)
+	expect, err := p.Stat(ctx, "foo", "bar", "dir/c")
+	require.NoError(t, err)
+	require.Equal(t, expect.Name, infos[0].Name)
+	require.Equal(t, expect.Path, infos[0].Path)
+	require.Equal(t, expect.SHA, infos[0].SHA)
+	require.Equal(t, expect.Size, infos[0].Size)
+	require.Equal(t, expect.GitURL, infos[0].GitURL)
+
+	// Unknown repository
+	_, err = StatMany(ctx, "foo", "baz", []string{"a"}, opt)
+	require.Error(t, err)
+}
@@ -0,0 +1,138 @@
+package ghwalk
+
+import (
+	"context"
+	"strings"
+)
+
+// Strategy is the way the walker retrieves the repository content from Github.
+type Strategy string
+
+const (
+	// StrategyContents lists each directory via the Contents API, and fetches each file content via the Contents API.
+	// It is the default, and suits the walks that only visit a small part of the repository (e.g. by SkipDir).
+	StrategyContents Strategy = ""
+	// StrategyTrees fetches the whole repository tree with a single call to the Git Trees API, and fetches each file
+	// content via the Contents API. The FileInfo listed from the tree has no URL and HTMLURL. It falls back to
+	// StrategyContents if the tree is too large to be returned at once.
+	StrategyTrees Strategy = "trees"
+	// StrategyArchive downloads the archive of the repository, which contains the content of all the files, see
+	// ArchiveFallback of WalkOptions for the caveats, e.g. the export-ignore paths are missing from the archive.
+	StrategyArchive Strategy = "archive"
+	// StrategyAuto picks one of the above for the walk, according to the number of the file contents to fetch, which
+	// is estimated from the repository tree with the PathFilterFunc and the FetchContentFunc (or EnableFileOnlyInfo)
+	// applied. The picked strategy is reported by the WalkResult of WalkWithResult, the caveats of StrategyArchive
+	// apply if it is picked.
+	StrategyAuto Strategy = "auto"
+)
+
+// autoArchiveThreshold is the number of the file contents to fetch, above which StrategyAuto downloads the archive
+// rather than calling the Contents API for each file.
+const autoArchiveThreshold = 100
+
+// newStrategyProvider returns the ContentProvider implementing the Strategy of opt, along with the strategy picked.
+func newStrategyProvider(ctx context.Context, owner, repo, path string, opt *WalkOptions, filterFn PathFilterFunc) (ContentProvider, Strategy, error) {
+	p, err := newProvider(ctx, opt)
+	if err != nil {
+		return nil, "", err
+	}
+	if opt == nil || opt.Strategy == StrategyContents || opt.Snapshot != nil || opt.Provider != nil {
+		return p, StrategyContents, nil
+	}
+
+	if opt.Strategy == StrategyArchive {
+		snapshot, err := downloadArchive(ctx, owner, repo, treeRef(opt), opt)
+		if err != nil {
+			return nil, "", err
+		}
+		return NewSnapshotProvider(snapshot), StrategyArchive, nil
+	}
+
+	client, err := newClient(ctx, opt)
+	if err != nil {
+		return nil, "", err
+	}
+	tree, _, err := client.Git.GetTree(ctx, owner, repo, treeRef(opt), true)
+	if err != nil {
+		return nil, "", err
+	}
+	if tree.GetTruncated() {
+		return p, StrategyContents, nil
+	}
+	o := &origin{client: client, owner: owner, repo: repo, opt: opt}
+	snapshot := &Snapshot{Owner: owner, Repo: repo, Ref: opt.Ref}
+	for _, entry := range tree.Entries {
+		info := newFileInfoFromTreeEntry(entry)
+		info.origin = o
+		snapshot.Entries = append(snapshot.Entries, info)
+	}
+
+	if opt.Strategy == StrategyAuto && estimateWalk(snapshot.Entries, path, opt, filterFn).fetches > autoArchiveThreshold {
+		snapshot, err := downloadArchive(ctx, owner, repo, treeRef(opt), opt)
+		if err != nil {
+			return nil, "", err
+		}
+		return NewSnapshotProvider(snapshot), StrategyArchive, nil
+	}
+	return &treesProvider{ContentProvider: p, tree: NewSnapshotProvider(snapshot)}, StrategyTrees, nil
+}
+
+// walkEstimate is the estimated amount of work of a walk.
+type walkEstimate struct {
+	// dirs is the number of the directories to list, including the walked path itself.
+	dirs int
+	// fetches is the number of the file contents to fetch.
+	fetches int
+}
+
+// estimateWalk estimates the work to walk path, given the entries of the whole repository tree in any order.
+func estimateWalk(entries []*FileInfo, path string, opt *WalkOptions, filterFn PathFilterFunc) walkEstimate {
+	under := func(p string) bool {
+		return path == "" || p == path || strings.HasPrefix(p, path+"/")
+	}
+	skipped := map[string]bool{}
+	if filterFn != nil {
+		for _, entry := range entries {
+			if under(entry.Path) && filterFn(entry.Path, entry) {
+				skipped[entry.Path] = true
+			}
+		}
+	}
+
+	var est walkEstimate
+	if path == "" {
+		// The repo root isn't among the entries
+		est.dirs++
+	}
+	for _, entry := range entries {
+		if !under(entry.Path) || skipped[entry.Path] {
+			continue
+		}
+		var inSkipped bool
+		for dir := parentDir(entry.Path); dir != "" && !inSkipped; dir = parentDir(dir) {
+			inSkipped = skipped[dir]
+		}
+		switch {
+		case inSkipped:
+		case entry.IsDir():
+			est.dirs++
+		case opt.fetchContent(entry.Path, entry):
+			est.fetches++
+		}
+	}
+	return est
+}
+
+// treesProvider lists the directories from the repository tree, and reads the files via the provider.
+type treesProvider struct {
+	ContentProvider
+	tree ContentProvider
+}
+
+func (p *treesProvider) Stat(ctx context.Context, owner, repo, path string) (*FileInfo, error) {
+	return p.tree.Stat(ctx, owner, repo, path)
+}
+
+func (p *treesProvider) ReadDir(ctx context.Context, owner, repo, path string) ([]*FileInfo, error) {
+	return p.tree.ReadDir(ctx, owner, repo, path)
+}
@@ -0,0 +1,100 @@
+package ghwalk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkWithStrategy(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	type entry struct {
+		Type    FileType
+		Size    int
+		SHA     string
+		Content string
+	}
+	walk := func(strategy Strategy) (map[string]entry, *WalkResult) {
+		entries := map[string]entry{}
+		result, err := WalkWithResult(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{
+			BaseURL:            srv.BaseURL(),
+			EnableFileOnlyInfo: true,
+			Strategy:           strategy,
+		}, func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			e := entry{Type: info.Type, Size: info.Size, SHA: info.SHA}
+			if info.Type == FileTypeFile {
+				e.Content, err = info.GetContent()
+				require.NoError(t, err)
+			}
+			entries[path] = e
+			return nil
+		}, nil)
+		require.NoError(t, err, strategy)
+		return entries, result
+	}
+
+	expect, result := walk(StrategyContents)
+	require.Equal(t, StrategyContents, result.Strategy)
+
+	cases := []struct {
+		strategy Strategy
+		expect   Strategy
+	}{
+		{strategy: StrategyTrees, expect: StrategyTrees},
+		{strategy: StrategyArchive, expect: StrategyArchive},
+		// The few files of testdata aren't worth an archive download
+		{strategy: StrategyAuto, expect: StrategyTrees},
+	}
+	for _, c := range cases {
+		entries, result := walk(c.strategy)
+		require.Equal(t, expect, entries, c.strategy)
+		require.Equal(t, c.expect, result.Strategy, c.strategy)
+	}
+}
+
+func TestEstimateWalk(t *testing.T) {
+	// The entries are in no particular order
+	entries := []*FileInfo{
+		{Path: "vendor/d", Type: FileTypeFile},
+		{Path: "a", Type: FileTypeFile},
+		{Path: "dir/sub/c", Type: FileTypeFile},
+		{Path: "dir", Type: FileTypeDir},
+		{Path: "dir/b", Type: FileTypeFile},
+		{Path: "dir/sub", Type: FileTypeDir},
+		{Path: "vendor", Type: FileTypeDir},
+	}
+	skipVendor := func(path string, info *FileInfo) bool {
+		return path == "vendor"
+	}
+	cases := []struct {
+		path     string
+		opt      *WalkOptions
+		filterFn PathFilterFunc
+		expect   walkEstimate
+	}{
+		{opt: &WalkOptions{}, expect: walkEstimate{dirs: 4}},
+		{opt: &WalkOptions{EnableFileOnlyInfo: true}, expect: walkEstimate{dirs: 4, fetches: 4}},
+		{opt: &WalkOptions{EnableFileOnlyInfo: true}, filterFn: skipVendor, expect: walkEstimate{dirs: 3, fetches: 3}},
+		{path: "dir", opt: &WalkOptions{EnableFileOnlyInfo: true}, expect: walkEstimate{dirs: 2, fetches: 2}},
+		{
+			opt: &WalkOptions{FetchContentFunc: func(path string, info *FileInfo) bool {
+				return path == "a"
+			}},
+			expect: walkEstimate{dirs: 4, fetches: 1},
+		},
+	}
+	for i, c := range cases {
+		require.Equal(t, c.expect, estimateWalk(entries, c.path, c.opt, c.filterFn), i)
+	}
+}
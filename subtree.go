@@ -0,0 +1,34 @@
+package ghwalk
+
+import (
+	"context"
+	"time"
+)
+
+// Subtree is a directory deferred by the DeferFunc of the WalkOptions, i.e. visited by a walk without being walked
+// into. It only holds plain data, so that it can be persisted or sent to another worker (e.g. in JSON), and walked
+// there later.
+type Subtree struct {
+	Owner string
+	Repo  string
+	// Ref is the Ref of the walk that has deferred the directory, which is the commit SHA if the walk is pinned to a
+	// commit (see PinCommit and At of WalkOptions). Otherwise, the subtree is walked at whatever the ref points to
+	// then.
+	Ref  string
+	Path string
+	// Info is the FileInfo of the directory.
+	Info *FileInfo
+}
+
+// Walk walks the subtree, the same as WalkWithResult does for its Path, at its Ref. The walkFn is called for the
+// directory itself first, even though it has been visited by the walk that has deferred it. The opt applies as for
+// WalkWithResult, except for the Ref and At, e.g. a DeferFunc defers the directories under the subtree further.
+func (s *Subtree) Walk(ctx context.Context, opt *WalkOptions, walkFn WalkFunc, filterFn PathFilterFunc) (*WalkResult, error) {
+	var o WalkOptions
+	if opt != nil {
+		o = *opt
+	}
+	o.Ref = s.Ref
+	o.At = time.Time{}
+	return WalkWithResult(ctx, s.Owner, s.Repo, s.Path, &o, walkFn, filterFn)
+}
@@ -0,0 +1,84 @@
+package ghwalk
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkDeferFunc(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+
+	cases := []struct {
+		name            string
+		listConcurrency int
+	}{
+		{
+			name: "sequential",
+		},
+		{
+			name:            "concurrent",
+			listConcurrency: 4,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+			defer cancel()
+
+			var skipped []string
+			opt := &WalkOptions{
+				BaseURL:         srv.BaseURL(),
+				PinCommit:       true,
+				ListConcurrency: c.listConcurrency,
+				DeferFunc: func(path string, info *FileInfo) bool {
+					return info.Name == "dir" || path == "testdata"
+				},
+				OnSkip: func(path string, reason SkipReason) {
+					skipped = append(skipped, path+" "+string(reason))
+				},
+			}
+			var paths []string
+			walkFn := func(path string, info *FileInfo, err error) error {
+				require.NoError(t, err)
+				paths = append(paths, path)
+				return nil
+			}
+
+			full := srv.RequestCount()
+			_, err := WalkWithResult(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{BaseURL: srv.BaseURL(), PinCommit: true}, func(string, *FileInfo, error) error { return nil }, nil)
+			require.NoError(t, err)
+			full = srv.RequestCount() - full
+
+			requests := srv.RequestCount()
+			result, err := WalkWithResult(ctx, "magodo", "ghwalk", "testdata", opt, walkFn, nil)
+			require.NoError(t, err)
+			// The walked path itself is never deferred
+			require.Equal(t, []string{"testdata", "testdata/a", "testdata/b", "testdata/dir", "testdata/link_dir"}, paths)
+			require.Equal(t, []string{"testdata/dir deferred"}, skipped)
+			// The deferred directory is not listed
+			require.Equal(t, full-1, srv.RequestCount()-requests)
+			require.Len(t, result.Subtrees, 1)
+			subtree := result.Subtrees[0]
+			require.Equal(t, "testdata/dir", subtree.Path)
+			require.Equal(t, result.CommitSHA, subtree.Ref)
+
+			// The subtree can be walked by another worker
+			b, err := json.Marshal(subtree)
+			require.NoError(t, err)
+			var decoded Subtree
+			require.NoError(t, json.Unmarshal(b, &decoded))
+			paths = nil
+			result, err = decoded.Walk(ctx, &WalkOptions{BaseURL: srv.BaseURL()}, walkFn, nil)
+			require.NoError(t, err)
+			require.Equal(t, []string{"testdata/dir", "testdata/dir/c"}, paths)
+			require.Empty(t, result.Subtrees)
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package ghwalk
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SHA256Sums are the SHA-256 checksums of the files under a directory, which are written and read in the format of
+// the SHA256SUMS file of the sha256sum tool, e.g. to record the provenance of the content walked, and verify a local
+// copy (or the repository later on) against it.
+type SHA256Sums struct {
+	// Path is the directory in the repository that the files are under, which is not written to the SHA256SUMS file.
+	Path string
+	// Files maps the slash separated path of each file (relative to Path) to the hex encoded SHA-256 of its content.
+	Files map[string]string
+}
+
+// NewSHA256Sums returns the empty SHA256Sums of the files under the directory path in the repository.
+func NewSHA256Sums(path string) *SHA256Sums {
+	return &SHA256Sums{Path: path, Files: map[string]string{}}
+}
+
+// WalkFunc returns a WalkFunc that records the checksum of each file visited under the Path, and then calls walkFn
+// (if not nil) along. It is meant for a content walk, i.e. the one with EnableFileOnlyInfo or FetchContentFunc
+// selecting all the files, as the content of the file is read via FileInfo.Open. A symlink to a file is recorded with
+// the content of the file, as the Contents API returns it as the file, while the other symlinks and submodules are not
+// recorded, neither are the entries failed to visit (i.e. err is not nil).
+func (s *SHA256Sums) WalkFunc(ctx context.Context, walkFn WalkFunc) WalkFunc {
+	return func(path string, info *FileInfo, err error) error {
+		if err == nil && info != nil && info.Type == FileTypeFile {
+			if err := s.add(ctx, path, info); err != nil {
+				return err
+			}
+		}
+		if walkFn == nil {
+			return err
+		}
+		return walkFn(path, info, err)
+	}
+}
+
+func (s *SHA256Sums) add(ctx context.Context, path string, info *FileInfo) error {
+	dir, err := CleanPath(s.Path)
+	if err != nil {
+		return err
+	}
+	rel, ok := relPath(dir, path)
+	if !ok {
+		return nil
+	}
+	if info.FileOnlyInfo == nil {
+		return fmt.Errorf("the content of %s is not available to checksum, enable the file only info", path)
+	}
+	file, err := hashFile(ctx, info)
+	if err != nil {
+		return err
+	}
+	if s.Files == nil {
+		s.Files = map[string]string{}
+	}
+	s.Files[rel] = file.sha256
+	return nil
+}
+
+// relPath returns the path relative to the directory dir, or false if it is not under dir.
+func relPath(dir, path string) (string, bool) {
+	if dir == "" {
+		return path, path != ""
+	}
+	if !strings.HasPrefix(path, dir+"/") {
+		return "", false
+	}
+	return strings.TrimPrefix(path, dir+"/"), true
+}
+
+// Write writes the checksums to w in the format of the SHA256SUMS file, sorted by the path. The path containing a
+// backslash or newline is escaped as sha256sum does.
+func (s *SHA256Sums) Write(w io.Writer) error {
+	rels := make([]string, 0, len(s.Files))
+	for rel := range s.Files {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	bw := bufio.NewWriter(w)
+	for _, rel := range rels {
+		var line string
+		if strings.ContainsAny(rel, "\\\n") {
+			line = `\` + s.Files[rel] + "  " + strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(rel) + "\n"
+		} else {
+			line = s.Files[rel] + "  " + rel + "\n"
+		}
+		if _, err := bw.WriteString(line); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadSHA256Sums reads the checksums from r in the format of the SHA256SUMS file, i.e. as written by
+// SHA256Sums.Write or sha256sum, of the files under the directory path in the repository. The binary mode marker
+// ("*" before the path) is accepted, while the lines in the BSD style are not.
+func ReadSHA256Sums(r io.Reader, path string) (*SHA256Sums, error) {
+	sums := NewSHA256Sums(path)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		escaped := strings.HasPrefix(line, `\`)
+		if escaped {
+			line = line[1:]
+		}
+		if len(line) < sha256.Size*2+2 || line[sha256.Size*2] != ' ' || (line[sha256.Size*2+1] != ' ' && line[sha256.Size*2+1] != '*') {
+			return nil, fmt.Errorf("decoding SHA256SUMS: malformed line %d", n)
+		}
+		sum, rel := strings.ToLower(line[:sha256.Size*2]), line[sha256.Size*2+2:]
+		if _, err := hex.DecodeString(sum); err != nil {
+			return nil, fmt.Errorf("decoding SHA256SUMS: invalid checksum at line %d", n)
+		}
+		if escaped {
+			rel = strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(rel)
+		}
+		rel = strings.TrimPrefix(rel, "./")
+		if rel == "" {
+			return nil, fmt.Errorf("decoding SHA256SUMS: empty path at line %d", n)
+		}
+		sums.Files[rel] = sum
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("decoding SHA256SUMS: %v", err)
+	}
+	return sums, nil
+}
+
+// ChecksumMismatchKind is the kind of a file that fails the verification against the SHA256Sums.
+type ChecksumMismatchKind string
+
+const (
+	// ChecksumModified means the checksum of the file differs from the recorded one.
+	ChecksumModified ChecksumMismatchKind = "modified"
+	// ChecksumMissing means the recorded file doesn't exist.
+	ChecksumMissing ChecksumMismatchKind = "missing"
+	// ChecksumUnlisted means the file exists, but is not recorded.
+	ChecksumUnlisted ChecksumMismatchKind = "unlisted"
+)
+
+// ChecksumMismatch is a file that fails the verification against the SHA256Sums.
+type ChecksumMismatch struct {
+	// Path is the slash separated path of the file, relative to the Path of the SHA256Sums.
+	Path string
+	Kind ChecksumMismatchKind
+	// Expected is the recorded checksum, empty if the file is unlisted.
+	Expected string
+	// Actual is the checksum of the file, empty if the file is missing.
+	Actual string
+}
+
+// VerifyDir verifies the files under the local directory dir against the checksums, and returns the files that fail,
+// in the same order as Walk would visit them. Only regular files (including the symlinks to them, like sha256sum) are
+// verified, and the ".git" directory at the top of dir is ignored.
+func (s *SHA256Sums) VerifyDir(dir string) ([]ChecksumMismatch, error) {
+	actual := map[string]string{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			if fi, err := os.Stat(p); err != nil || !fi.Mode().IsRegular() {
+				return nil
+			}
+		} else if !d.Type().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		actual[rel] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.compare(actual, false), nil
+}
+
+// VerifyWalk walks the directory Path in the repository, and verifies its files against the checksums like
+// VerifyDir does. The content of each file is fetched and hashed while being read (see InlineContentLimit),
+// regardless of the EnableFileOnlyInfo and FetchContentFunc of opt.
+func (s *SHA256Sums) VerifyWalk(ctx context.Context, owner, repo string, opt *WalkOptions) ([]ChecksumMismatch, error) {
+	var o WalkOptions
+	if opt != nil {
+		o = *opt
+	}
+	o.FetchContentFunc = func(path string, info *FileInfo) bool {
+		return info.Type == FileTypeFile || info.Type == FileTypeSymlink
+	}
+	path, err := cleanRepoPath(owner, repo, s.Path)
+	if err != nil {
+		return nil, err
+	}
+	actual := &SHA256Sums{Path: path, Files: map[string]string{}}
+	if err := Walk(ctx, owner, repo, path, &o, actual.WalkFunc(ctx, nil), nil); err != nil {
+		return nil, err
+	}
+	return s.compare(actual.Files, o.Reverse), nil
+}
+
+// compare returns the mismatches between the recorded checksums and the actual ones.
+func (s *SHA256Sums) compare(actual map[string]string, reverse bool) []ChecksumMismatch {
+	var mismatches []ChecksumMismatch
+	for rel, expected := range s.Files {
+		sum, ok := actual[rel]
+		switch {
+		case !ok:
+			mismatches = append(mismatches, ChecksumMismatch{Path: rel, Kind: ChecksumMissing, Expected: expected})
+		case sum != expected:
+			mismatches = append(mismatches, ChecksumMismatch{Path: rel, Kind: ChecksumModified, Expected: expected, Actual: sum})
+		}
+	}
+	for rel, sum := range actual {
+		if _, ok := s.Files[rel]; !ok {
+			mismatches = append(mismatches, ChecksumMismatch{Path: rel, Kind: ChecksumUnlisted, Actual: sum})
+		}
+	}
+	sort.Slice(mismatches, func(i, j int) bool {
+		return lessPath(mismatches[i].Path, mismatches[j].Path, reverse)
+	})
+	return mismatches
+}
@@ -0,0 +1,98 @@
+package ghwalk
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestSHA256Sums(t *testing.T) {
+	fixture := newFixture(t, map[string]string{"root/a": "a\n", "root/dir/b": "b\n", "other": "other\n"})
+	require.NoError(t, os.Symlink("a", filepath.Join(fixture, "root", "link")))
+	srv := ghwalktest.NewServer(map[string]string{"foo/bar": fixture})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	opt := &WalkOptions{BaseURL: srv.BaseURL(), EnableFileOnlyInfo: true}
+
+	// Generate the checksums along a content walk
+	sums := NewSHA256Sums("root")
+	var visited int
+	require.NoError(t, Walk(ctx, "foo", "bar", "root", opt, sums.WalkFunc(ctx, func(path string, info *FileInfo, err error) error {
+		visited++
+		return err
+	}), nil))
+	require.Equal(t, 5, visited)
+	// The symlink to a file is recorded as the file
+	require.Equal(t, map[string]string{"a": sha256Hex("a\n"), "dir/b": sha256Hex("b\n"), "link": sha256Hex("a\n")}, sums.Files)
+
+	// The content is required
+	err := Walk(ctx, "foo", "bar", "root", &WalkOptions{BaseURL: srv.BaseURL()}, NewSHA256Sums("root").WalkFunc(ctx, nil), nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not available to checksum")
+
+	// Round trip, along with the escaped path
+	sums.Files["we\\ird\nname"] = sha256Hex("")
+	var buf bytes.Buffer
+	require.NoError(t, sums.Write(&buf))
+	require.Equal(t, sha256Hex("a\n")+"  a\n"+sha256Hex("b\n")+"  dir/b\n"+sha256Hex("a\n")+"  link\n"+`\`+sha256Hex("")+"  we\\\\ird\\nname\n", buf.String())
+	read, err := ReadSHA256Sums(&buf, "root")
+	require.NoError(t, err)
+	require.Equal(t, sums, read)
+	delete(sums.Files, "we\\ird\nname")
+
+	// The output of sha256sum is read as well
+	read, err = ReadSHA256Sums(strings.NewReader(strings.ToUpper(sha256Hex("a\n"))+" *./a\n\n"), "root")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"a": sha256Hex("a\n")}, read.Files)
+	for _, input := range []string{"abc  a\n", sha256Hex("a\n") + "a\n", strings.Repeat("z", 64) + "  a\n"} {
+		_, err = ReadSHA256Sums(strings.NewReader(input), "")
+		require.Error(t, err, input)
+	}
+
+	// Verify a local copy
+	dir := t.TempDir()
+	_, err = Mirror(ctx, "foo", "bar", "root", dir, nil, &WalkOptions{BaseURL: srv.BaseURL()})
+	require.NoError(t, err)
+	mismatches, err := sums.VerifyDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, mismatches)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a"), []byte("changed\n"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(dir, "dir", "b")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "extra"), []byte("extra\n"), 0644))
+	mismatches, err = sums.VerifyDir(dir)
+	require.NoError(t, err)
+	require.Equal(t, []ChecksumMismatch{
+		{Path: "a", Kind: ChecksumModified, Expected: sha256Hex("a\n"), Actual: sha256Hex("changed\n")},
+		{Path: "dir/b", Kind: ChecksumMissing, Expected: sha256Hex("b\n")},
+		{Path: "extra", Kind: ChecksumUnlisted, Actual: sha256Hex("extra\n")},
+		// The symlink is followed
+		{Path: "link", Kind: ChecksumModified, Expected: sha256Hex("a\n"), Actual: sha256Hex("changed\n")},
+	}, mismatches)
+
+	// Verify another walk
+	mismatches, err = sums.VerifyWalk(ctx, "foo", "bar", &WalkOptions{BaseURL: srv.BaseURL()})
+	require.NoError(t, err)
+	require.Empty(t, mismatches)
+	require.NoError(t, os.WriteFile(filepath.Join(fixture, "root", "dir", "b"), []byte("new b\n"), 0644))
+	mismatches, err = sums.VerifyWalk(ctx, "foo", "bar", &WalkOptions{BaseURL: srv.BaseURL()})
+	require.NoError(t, err)
+	require.Equal(t, []ChecksumMismatch{
+		{Path: "dir/b", Kind: ChecksumModified, Expected: sha256Hex("b\n"), Actual: sha256Hex("new b\n")},
+	}, mismatches)
+}
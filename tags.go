@@ -0,0 +1,184 @@
+package ghwalk
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"golang.org/x/mod/semver"
+)
+
+// TagWalkFunc is the type of the function called for each file or directory visited by WalkTags. It is the same as
+// WalkFunc, except that the tag argument is the tag being walked.
+type TagWalkFunc func(tag, path string, info *FileInfo, err error) error
+
+// WalkTags walks path at each tag of the repository that satisfies the semantic version constraint, calling walkFn
+// for each file or directory in the tree, in the same way as Walk does. An empty constraint matches all the tags,
+// including those not being a semantic version.
+//
+// The constraint consists of comma separated comparisons, e.g. ">=1.2.0, <2.0.0", where each comparison is an
+// operator (one of "=", "!=", ">", ">=", "<", "<=", defaults to "=") followed by a semantic version.
+// A leading "v" is optional for both the constraint and the tags.
+//
+// The tags are walked in ascending order of their versions, followed by the tags that are not a semantic version in
+// lexical order. If walkFn returns SkipAll, the remaining tags are skipped as well.
+func WalkTags(ctx context.Context, owner, repo, path, constraint string, opt *WalkOptions, walkFn TagWalkFunc, filterFn PathFilterFunc) error {
+	path, err := cleanRepoPath(owner, repo, path)
+	if err != nil {
+		return err
+	}
+	match, err := parseSemverConstraint(constraint)
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient(ctx, opt)
+	if err != nil {
+		return err
+	}
+	tags, err := listTags(ctx, client, owner, repo, opt)
+	if err != nil {
+		return err
+	}
+
+	var matched []string
+	for _, tag := range tags {
+		if constraint == "" || match(tag) {
+			matched = append(matched, tag)
+		}
+	}
+	sortTags(matched)
+
+	for _, tag := range matched {
+		var tagOpt WalkOptions
+		if opt != nil {
+			tagOpt = *opt
+		}
+		tagOpt.Ref = tag
+		tagOpt.At = time.Time{}
+
+		var skipAll bool
+		err := Walk(ctx, owner, repo, path, &tagOpt,
+			func(path string, info *FileInfo, err error) error {
+				err = walkFn(tag, path, info, err)
+				if err == SkipAll {
+					skipAll = true
+				}
+				return err
+			},
+			filterFn)
+		if err != nil {
+			return err
+		}
+		if skipAll {
+			return nil
+		}
+	}
+	return nil
+}
+
+func listTags(ctx context.Context, client *github.Client, owner, repo string, opt *WalkOptions) ([]string, error) {
+	listOpt := opt.listOptions()
+	var tags []string
+	for page := 1; ; page++ {
+		items, resp, err := client.Repositories.ListTags(ctx, owner, repo, &listOpt)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range items {
+			tags = append(tags, tag.GetName())
+		}
+		if listOpt.Page = opt.nextPage(resp, page); listOpt.Page == 0 {
+			return tags, nil
+		}
+	}
+}
+
+// canonicalSemver returns the version in the form accepted by the semver package, i.e. with the leading "v".
+func canonicalSemver(v string) string {
+	if !strings.HasPrefix(v, "v") {
+		v = "v" + v
+	}
+	return v
+}
+
+// sortTags sorts the tags in ascending order of their versions, followed by the tags that are not a semantic version
+// in lexical order.
+func sortTags(tags []string) {
+	sort.SliceStable(tags, func(i, j int) bool {
+		vi, vj := canonicalSemver(tags[i]), canonicalSemver(tags[j])
+		iValid, jValid := semver.IsValid(vi), semver.IsValid(vj)
+		switch {
+		case iValid && jValid:
+			if c := semver.Compare(vi, vj); c != 0 {
+				return c < 0
+			}
+			return tags[i] < tags[j]
+		case iValid != jValid:
+			return iValid
+		default:
+			return tags[i] < tags[j]
+		}
+	})
+}
+
+// parseSemverConstraint parses the semantic version constraint, returning a function that tells whether a tag
+// satisfies it.
+func parseSemverConstraint(constraint string) (func(tag string) bool, error) {
+	type comparison struct {
+		op      string
+		version string
+	}
+	var comparisons []comparison
+	for _, expr := range strings.Split(constraint, ",") {
+		expr = strings.TrimSpace(expr)
+		if expr == "" {
+			continue
+		}
+		op := "="
+		for _, candidate := range []string{">=", "<=", "!=", ">", "<", "="} {
+			if strings.HasPrefix(expr, candidate) {
+				op = candidate
+				expr = strings.TrimSpace(strings.TrimPrefix(expr, candidate))
+				break
+			}
+		}
+		version := canonicalSemver(expr)
+		if !semver.IsValid(version) {
+			return nil, fmt.Errorf("invalid semantic version %q in constraint %q", expr, constraint)
+		}
+		comparisons = append(comparisons, comparison{op: op, version: version})
+	}
+
+	return func(tag string) bool {
+		v := canonicalSemver(tag)
+		if !semver.IsValid(v) {
+			return false
+		}
+		for _, c := range comparisons {
+			cmp := semver.Compare(v, c.version)
+			var ok bool
+			switch c.op {
+			case "=":
+				ok = cmp == 0
+			case "!=":
+				ok = cmp != 0
+			case ">":
+				ok = cmp > 0
+			case ">=":
+				ok = cmp >= 0
+			case "<":
+				ok = cmp < 0
+			case "<=":
+				ok = cmp <= 0
+			}
+			if !ok {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
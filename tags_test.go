@@ -0,0 +1,69 @@
+package ghwalk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkTags(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{
+		"foo/bar@v2.0.0": "testdata",
+		"foo/bar@1.1.0":  "testdata/dir",
+		"foo/bar@v1.0.0": "testdata/dir",
+		"foo/bar@latest": "testdata",
+	})
+	defer srv.Close()
+
+	cases := []struct {
+		constraint string
+		expectTags []string
+		isError    bool
+	}{
+		{
+			constraint: "",
+			expectTags: []string{"v1.0.0", "1.1.0", "v2.0.0", "latest"},
+		},
+		{
+			constraint: ">=1.1.0, <v2.0.0",
+			expectTags: []string{"1.1.0"},
+		},
+		{
+			constraint: "!=1.1.0",
+			expectTags: []string{"v1.0.0", "v2.0.0"},
+		},
+		{
+			constraint: ">= foo",
+			isError:    true,
+		},
+	}
+
+	for _, c := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		tags := []string{}
+		err := WalkTags(ctx, "foo", "bar", "", c.constraint, &WalkOptions{BaseURL: srv.BaseURL()},
+			func(tag, path string, info *FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if path == "" {
+					tags = append(tags, tag)
+				}
+				if path == "c" {
+					require.Contains(t, []string{"v1.0.0", "1.1.0"}, tag)
+				}
+				return nil
+			},
+			nil)
+		if c.isError {
+			require.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, c.expectTags, tags)
+	}
+}
@@ -0,0 +1,160 @@
+package ghwalk
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// DirUsage is the disk usage of a directory, accumulated over all the files under it (recursively).
+type DirUsage struct {
+	Path string
+	// Size is the total size in bytes of the files.
+	Size int64
+	// Files is the number of the files, including symlinks but not submodules.
+	Files int
+}
+
+// DiskUsage returns the disk usage of path and each directory under it, in the same order as Walk would visit them.
+// The path must be a directory, or the repo root.
+//
+// Like FindAll, DiskUsage fetches the whole repository tree with a single call to the Git Trees API, unless the tree
+// is too large to be returned at once, or opt.Snapshot or opt.Provider is set, in which case it falls back to Walk.
+func DiskUsage(ctx context.Context, owner, repo, path string, opt *WalkOptions) ([]DirUsage, error) {
+	path, err := cleanRepoPath(owner, repo, path)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := listTree(ctx, owner, repo, path, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	usages := map[string]*DirUsage{
+		path: {Path: path},
+	}
+	for _, info := range infos {
+		if info.Path == path {
+			if !info.IsDir() {
+				return nil, fmt.Errorf("%s is not a directory", path)
+			}
+			continue
+		}
+		if info.IsDir() {
+			if _, ok := usages[info.Path]; !ok {
+				usages[info.Path] = &DirUsage{Path: info.Path}
+			}
+			continue
+		}
+		if info.Type == FileTypeSubmodule {
+			continue
+		}
+
+		// Accumulate the file to each of its ancestors up to path.
+		for dir := parentDir(info.Path); ; dir = parentDir(dir) {
+			usage, ok := usages[dir]
+			if !ok {
+				usage = &DirUsage{Path: dir}
+				usages[dir] = usage
+			}
+			usage.Size += int64(info.Size)
+			usage.Files++
+			if dir == path || dir == "" {
+				break
+			}
+		}
+	}
+
+	reverse := opt != nil && opt.Reverse
+	result := make([]DirUsage, 0, len(usages))
+	for _, usage := range usages {
+		result = append(result, *usage)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return lessPath(result[i].Path, result[j].Path, reverse)
+	})
+	return result, nil
+}
+
+// LargestFiles returns the FileInfo (without FileOnlyInfo) of the n largest files under path (or path itself, if it is
+// a file), in descending order of size. The files of the same size are ordered as Walk would visit them. All the
+// files are returned if n is not positive. Symlinks are regarded as files, while submodules are not.
+//
+// Like DiskUsage, LargestFiles costs a single call to the Git Trees API if possible.
+func LargestFiles(ctx context.Context, owner, repo, path string, n int, opt *WalkOptions) ([]FileInfo, error) {
+	path, err := cleanRepoPath(owner, repo, path)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := listTree(ctx, owner, repo, path, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*FileInfo
+	for _, info := range infos {
+		if info.IsDir() || info.Type == FileTypeSubmodule {
+			continue
+		}
+		files = append(files, info)
+	}
+	reverse := opt != nil && opt.Reverse
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Size != files[j].Size {
+			return files[i].Size > files[j].Size
+		}
+		return lessPath(files[i].Path, files[j].Path, reverse)
+	})
+	if n > 0 && len(files) > n {
+		files = files[:n]
+	}
+
+	result := make([]FileInfo, 0, len(files))
+	for _, info := range files {
+		result = append(result, *info)
+	}
+	return result, nil
+}
+
+// listTree returns the FileInfo (without FileOnlyInfo) of every file or directory under path (including path itself,
+// unless it is the repo root), in no particular order. It fetches the whole repository tree with a single call to the
+// Git Trees API if possible, otherwise it falls back to Walk.
+func listTree(ctx context.Context, owner, repo, path string, opt *WalkOptions) ([]*FileInfo, error) {
+	if opt == nil || (opt.Snapshot == nil && opt.Provider == nil) {
+		// The resolved options are kept for the fallback to Walk, so that the commit isn't resolved once again
+		var err error
+		if opt, err = resolveOptions(ctx, owner, repo, opt); err != nil {
+			return nil, err
+		}
+		infos, _, ok, err := treeEntries(ctx, owner, repo, path, opt)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return infos, nil
+		}
+	}
+
+	var walkOpt WalkOptions
+	if opt != nil {
+		walkOpt = *opt
+	}
+	walkOpt.EnableFileOnlyInfo = false
+	walkOpt.FetchContentFunc = nil
+	var infos []*FileInfo
+	err := Walk(ctx, owner, repo, path, &walkOpt,
+		func(path string, info *FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info != nil {
+				infos = append(infos, info)
+			}
+			return nil
+		},
+		nil)
+	if err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
@@ -0,0 +1,164 @@
+package ghwalk
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskUsage(t *testing.T) {
+	snapshot := &Snapshot{
+		Owner: "foo",
+		Repo:  "bar",
+		Entries: []*FileInfo{
+			{Type: FileTypeFile, Name: "a", Path: "a", Size: 1},
+			{Type: FileTypeDir, Name: "dir", Path: "dir"},
+			{Type: FileTypeFile, Name: "b", Path: "dir/b", Size: 10},
+			{Type: FileTypeDir, Name: "empty", Path: "dir/empty"},
+			{Type: FileTypeDir, Name: "sub", Path: "dir/sub"},
+			{Type: FileTypeSymlink, Name: "c", Path: "dir/sub/c", Size: 100},
+			{Type: FileTypeSubmodule, Name: "mod", Path: "dir/sub/mod"},
+		},
+	}
+
+	cases := []struct {
+		path    string
+		reverse bool
+		expect  []DirUsage
+		isError bool
+	}{
+		{
+			path: "",
+			expect: []DirUsage{
+				{Path: "", Size: 111, Files: 3},
+				{Path: "dir", Size: 110, Files: 2},
+				{Path: "dir/empty"},
+				{Path: "dir/sub", Size: 100, Files: 1},
+			},
+		},
+		{
+			path:    "dir",
+			reverse: true,
+			expect: []DirUsage{
+				{Path: "dir", Size: 110, Files: 2},
+				{Path: "dir/sub", Size: 100, Files: 1},
+				{Path: "dir/empty"},
+			},
+		},
+		{
+			path:    "a",
+			isError: true,
+		},
+		{
+			path:    "nonexist",
+			isError: true,
+		},
+	}
+
+	for _, c := range cases {
+		usages, err := DiskUsage(context.Background(), "foo", "bar", c.path, &WalkOptions{Snapshot: snapshot, Reverse: c.reverse})
+		if c.isError {
+			require.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, c.expect, usages)
+	}
+}
+
+func TestDiskUsageFromTree(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	usages, err := DiskUsage(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{Token: githubToken, BaseURL: githubBaseURL})
+	require.NoError(t, err)
+	require.Equal(t, []DirUsage{
+		{Path: "testdata", Size: 13 + 13 + 20 + 3, Files: 4},
+		{Path: "testdata/dir", Size: 20, Files: 1},
+	}, usages)
+}
+
+// commitsCountingTransport counts the requests listing the commits.
+type commitsCountingTransport struct {
+	mu    sync.Mutex
+	count int
+}
+
+func (t *commitsCountingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, "/commits") {
+		t.mu.Lock()
+		t.count++
+		t.mu.Unlock()
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestDiskUsagePinCommitFallback(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{"magodo/ghwalk": "."})
+	defer srv.Close()
+	// The truncated tree makes DiskUsage fall back to Walk
+	srv.SetTreeLimit(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	transport := &commitsCountingTransport{}
+	usages, err := DiskUsage(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{BaseURL: srv.BaseURL(), Transport: transport, PinCommit: true})
+	require.NoError(t, err)
+	require.Equal(t, "testdata", usages[0].Path)
+	// The commit is resolved once, for both the tree and the walk
+	require.Equal(t, 1, transport.count)
+}
+
+func TestLargestFiles(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	cases := []struct {
+		path       string
+		n          int
+		expectPath []string
+		isError    bool
+	}{
+		{
+			path:       "testdata",
+			n:          2,
+			expectPath: []string{"testdata/dir/c", "testdata/a"},
+		},
+		{
+			path:       "testdata",
+			n:          0,
+			expectPath: []string{"testdata/dir/c", "testdata/a", "testdata/b", "testdata/link_dir"},
+		},
+		{
+			path:       "testdata/a",
+			n:          10,
+			expectPath: []string{"testdata/a"},
+		},
+		{
+			path:    "testdata/nonexist",
+			isError: true,
+		},
+	}
+
+	for _, c := range cases {
+		files, err := LargestFiles(ctx, "magodo", "ghwalk", c.path, c.n, &WalkOptions{Token: githubToken, BaseURL: githubBaseURL})
+		if c.isError {
+			require.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		paths := []string{}
+		for _, f := range files {
+			require.NotEmpty(t, f.SHA)
+			paths = append(paths, f.Path)
+		}
+		require.Equal(t, c.expectPath, paths)
+	}
+}
@@ -0,0 +1,64 @@
+package ghwalk
+
+import (
+	"context"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// ListUserRepoMetadata returns the metadata of the repositories owned by the user, in the order returned by Github.
+// If user is empty, the repositories owned by the authenticated user (i.e. of the Token) are returned, including the
+// private ones. Only the Token, BaseURL and Transport of opt (and the others customizing the API requests) are used.
+func ListUserRepoMetadata(ctx context.Context, user string, opt *WalkOptions) ([]*RepoMetadata, error) {
+	client, err := newClient(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	listOpt := &github.RepositoryListOptions{Type: "owner", ListOptions: opt.listOptions()}
+	var metadata []*RepoMetadata
+	for page := 1; ; page++ {
+		repos, resp, err := client.Repositories.List(ctx, user, listOpt)
+		if err != nil {
+			return nil, err
+		}
+		for _, repo := range repos {
+			metadata = append(metadata, newRepoMetadata(repo))
+		}
+		if listOpt.Page = opt.nextPage(resp, page); listOpt.Page == 0 {
+			return metadata, nil
+		}
+	}
+}
+
+// WalkUser walks the path in each repository owned by the user (see ListUserRepoMetadata) in turn, the same as
+// WalkOrg does for an organization, including the forks and the archived repositories. Use WalkUserRepos with
+// SkipRepos to leave them out.
+func WalkUser(ctx context.Context, user, path string, opt *WalkOptions, walkFn OrgWalkFunc, filterFn PathFilterFunc) error {
+	return WalkUserRepos(ctx, user, path, opt, nil, walkFn, filterFn)
+}
+
+// WalkUserRepos is the same as WalkUser, except that repoFn, if not nil, is called before walking each repository
+// with its metadata, in order to decide whether to walk it, the same as WalkOrgRepos.
+func WalkUserRepos(ctx context.Context, user, path string, opt *WalkOptions, repoFn OrgRepoFunc, walkFn OrgWalkFunc, filterFn PathFilterFunc) error {
+	repos, err := ListUserRepoMetadata(ctx, user, opt)
+	if err != nil {
+		return err
+	}
+	owner := user
+	if owner == "" && len(repos) > 0 {
+		// The authenticated user
+		owner = repos[0].Owner
+	}
+	return walkRepos(ctx, owner, repos, path, opt, repoFn, walkFn, filterFn)
+}
+
+// SkipRepos returns the OrgRepoFunc for WalkOrgRepos and WalkUserRepos that skips the forks and/or the archived
+// repositories.
+func SkipRepos(forks, archived bool) OrgRepoFunc {
+	return func(repo *RepoMetadata) error {
+		if (forks && repo.Fork) || (archived && repo.Archived) {
+			return SkipDir
+		}
+		return nil
+	}
+}
@@ -0,0 +1,69 @@
+package ghwalk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkUser(t *testing.T) {
+	srv := ghwalktest.NewServer(map[string]string{
+		"alice/a": newFixture(t, map[string]string{"docs/x": "x"}),
+		"alice/b": newFixture(t, map[string]string{"docs/y": "y"}),
+		"alice/c": newFixture(t, map[string]string{"docs/z": "z"}),
+		"bob/d":   newFixture(t, map[string]string{"docs/w": "w"}),
+	})
+	defer srv.Close()
+	srv.SetRepoFlags("alice/b", ghwalktest.RepoFlags{Fork: true})
+	srv.SetRepoFlags("alice/c", ghwalktest.RepoFlags{Archived: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	opt := &WalkOptions{BaseURL: srv.BaseURL()}
+
+	cases := []struct {
+		name   string
+		repoFn OrgRepoFunc
+		expect []string
+	}{
+		{
+			name:   "all",
+			expect: []string{"a:docs", "a:docs/x", "b:docs", "b:docs/y", "c:docs", "c:docs/z"},
+		},
+		{
+			name:   "no forks",
+			repoFn: SkipRepos(true, false),
+			expect: []string{"a:docs", "a:docs/x", "c:docs", "c:docs/z"},
+		},
+		{
+			name:   "no forks nor archived",
+			repoFn: SkipRepos(true, true),
+			expect: []string{"a:docs", "a:docs/x"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var visited []string
+			err := WalkUserRepos(ctx, "alice", "docs", opt, c.repoFn, func(repo, path string, info *FileInfo, err error) error {
+				visited = append(visited, repo+":"+path)
+				return err
+			}, nil)
+			require.NoError(t, err)
+			require.Equal(t, c.expect, visited)
+		})
+	}
+
+	var visited []string
+	require.NoError(t, WalkUser(ctx, "bob", "", opt, func(repo, path string, info *FileInfo, err error) error {
+		visited = append(visited, repo+":"+path)
+		return err
+	}, nil))
+	require.Equal(t, []string{"d:", "d:docs", "d:docs/w"}, visited)
+
+	_, err := ListUserRepoMetadata(ctx, "nobody", opt)
+	require.Error(t, err)
+}
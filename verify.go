@@ -0,0 +1,103 @@
+package ghwalk
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// DeviationKind is the kind of the deviation of an entry from the expected snapshot, see Verify.
+type DeviationKind string
+
+const (
+	// DeviationMissing means the entry is expected, but doesn't exist.
+	DeviationMissing DeviationKind = "missing"
+	// DeviationExtra means the entry exists, but is not expected.
+	DeviationExtra DeviationKind = "extra"
+	// DeviationChanged means the entry exists as expected, but with a different type or content (i.e. SHA).
+	DeviationChanged DeviationKind = "changed"
+)
+
+// Deviation is an entry that deviates from the expected snapshot, see Verify.
+type Deviation struct {
+	// Path is the slash separated path of the entry, relative to both the path verified and the Path of the expected
+	// snapshot. It is empty for the path verified itself.
+	Path string
+	Kind DeviationKind
+	// Expected is the FileInfo of the entry in the expected snapshot, nil if it is extra.
+	Expected *FileInfo
+	// Actual is the FileInfo of the entry in the repository, nil if it is missing.
+	Actual *FileInfo
+}
+
+// Verify checks that the path in the repository looks exactly like the expected snapshot, e.g. to enforce that the
+// files of a policy are kept as they are, and returns the entries that deviate, in the same order as Walk would visit
+// them. The entries are matched by their paths relative to the path and the Path of the snapshot respectively, so the
+// snapshot can be taken from another path or repository.
+//
+// The files, symlinks and submodules are compared by their type and SHA, while a directory only deviates if it is a
+// file (or vice versa) at the other side, as anything deviating under it is reported by itself. The empty result means
+// the path matches the snapshot.
+//
+// The state of the path is captured by TakeSnapshot with opt, which should be the same as the options the expected
+// snapshot is taken with, e.g. a symlink to a file is captured as the file if its content is fetched (see
+// EnableFileOnlyInfo). A Partial snapshot can't be verified against, as what is expected is unknown.
+func Verify(ctx context.Context, owner, repo, path string, expected *Snapshot, opt *WalkOptions) ([]Deviation, error) {
+	if expected.Partial {
+		return nil, fmt.Errorf("the expected snapshot of %s/%s/%s is partial", expected.Owner, expected.Repo, expected.Path)
+	}
+	root, err := CleanPath(expected.Path)
+	if err != nil {
+		return nil, fmt.Errorf("the expected snapshot: %w", err)
+	}
+	path, err = cleanRepoPath(owner, repo, path)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := TakeSnapshot(ctx, owner, repo, path, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	want, got := relEntries(expected, root), relEntries(actual, path)
+	var deviations []Deviation
+	for rel, info := range want {
+		ainfo, ok := got[rel]
+		switch {
+		case !ok:
+			if !info.IsDir() {
+				deviations = append(deviations, Deviation{Path: rel, Kind: DeviationMissing, Expected: info})
+			}
+		case info.IsDir() && ainfo.IsDir():
+		case !Identical([]*FileInfo{info, ainfo}):
+			deviations = append(deviations, Deviation{Path: rel, Kind: DeviationChanged, Expected: info, Actual: ainfo})
+		}
+	}
+	for rel, info := range got {
+		if _, ok := want[rel]; !ok && !info.IsDir() {
+			deviations = append(deviations, Deviation{Path: rel, Kind: DeviationExtra, Actual: info})
+		}
+	}
+
+	reverse := opt != nil && opt.Reverse
+	sort.Slice(deviations, func(i, j int) bool {
+		return lessPath(deviations[i].Path, deviations[j].Path, reverse)
+	})
+	return deviations, nil
+}
+
+// relEntries returns the entries of the snapshot under root (including root itself, keyed by ""), keyed by the path
+// relative to root.
+func relEntries(s *Snapshot, root string) map[string]*FileInfo {
+	entries := map[string]*FileInfo{}
+	for p, info := range s.indexed().entries {
+		if p == root {
+			entries[""] = info
+			continue
+		}
+		if rel, ok := relPath(root, p); ok {
+			entries[rel] = info
+		}
+	}
+	return entries
+}
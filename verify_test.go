@@ -0,0 +1,77 @@
+package ghwalk
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/magodo/ghwalk/ghwalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	files := map[string]string{
+		".github/CODEOWNERS":            "* @owners\n",
+		".github/workflows/policy.yml":  "on: push\n",
+		".github/workflows/release.yml": "on: release\n",
+	}
+	template := newFixture(t, files)
+	fixture := newFixture(t, map[string]string{
+		"ci/.github/CODEOWNERS":            files[".github/CODEOWNERS"],
+		"ci/.github/workflows/policy.yml":  files[".github/workflows/policy.yml"],
+		"ci/.github/workflows/release.yml": files[".github/workflows/release.yml"],
+		"README":                           "readme\n",
+	})
+	srv := ghwalktest.NewServer(map[string]string{"foo/template": template, "foo/bar": fixture})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	opt := &WalkOptions{BaseURL: srv.BaseURL()}
+
+	// The snapshot is taken from another repository and path
+	expected, err := TakeSnapshot(ctx, "foo", "template", ".github", opt)
+	require.NoError(t, err)
+	deviations, err := Verify(ctx, "foo", "bar", "ci/.github", expected, opt)
+	require.NoError(t, err)
+	require.Empty(t, deviations)
+
+	require.NoError(t, os.WriteFile(filepath.Join(fixture, "ci", ".github", "workflows", "policy.yml"), []byte("on: pull_request\n"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(fixture, "ci", ".github", "workflows", "release.yml")))
+	require.NoError(t, os.WriteFile(filepath.Join(fixture, "ci", ".github", "workflows", "extra.yml"), []byte("on: push\n"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(fixture, "ci", ".github", "CODEOWNERS")))
+	require.NoError(t, os.MkdirAll(filepath.Join(fixture, "ci", ".github", "CODEOWNERS"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(fixture, "ci", ".github", "CODEOWNERS", "a"), []byte("a\n"), 0644))
+
+	deviations, err = Verify(ctx, "foo", "bar", "ci/.github", expected, opt)
+	require.NoError(t, err)
+	var got []string
+	for _, d := range deviations {
+		got = append(got, string(d.Kind)+" "+d.Path)
+		switch d.Kind {
+		case DeviationMissing:
+			require.Nil(t, d.Actual)
+			require.NotNil(t, d.Expected)
+		case DeviationExtra:
+			require.Nil(t, d.Expected)
+			require.NotNil(t, d.Actual)
+		case DeviationChanged:
+			require.NotNil(t, d.Expected)
+			require.NotNil(t, d.Actual)
+		}
+	}
+	require.Equal(t, []string{
+		"changed CODEOWNERS",
+		"extra CODEOWNERS/a",
+		"extra workflows/extra.yml",
+		"changed workflows/policy.yml",
+		"missing workflows/release.yml",
+	}, got)
+
+	// A partial snapshot can't be verified against
+	expected.Partial = true
+	_, err = Verify(ctx, "foo", "bar", "ci/.github", expected, opt)
+	require.Error(t, err)
+}
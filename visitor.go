@@ -0,0 +1,60 @@
+package ghwalk
+
+import "context"
+
+// Visitor is an alternative to WalkFunc, which has a method per kind of the visited entry. The return values have
+// the same meaning as the WalkFunc, e.g. VisitDir can return SkipDir to skip the directory.
+type Visitor interface {
+	// VisitDir is called for each directory, the info is nil for the repo root.
+	VisitDir(path string, info *FileInfo) error
+	// VisitFile is called for each file, including submodules.
+	VisitFile(path string, info *FileInfo) error
+	// VisitSymlink is called for each symlink.
+	VisitSymlink(path string, info *FileInfo) error
+	// OnError is called for each error that WalkFunc would be called with.
+	OnError(path string, info *FileInfo, err error) error
+}
+
+// VisitorWalkFunc returns the WalkFunc that dispatches the calls to the Visitor.
+func VisitorWalkFunc(v Visitor) WalkFunc {
+	return func(path string, info *FileInfo, err error) error {
+		switch {
+		case err != nil:
+			return v.OnError(path, info, err)
+		case info == nil || info.IsDir():
+			return v.VisitDir(path, info)
+		case info.Type == FileTypeSymlink:
+			return v.VisitSymlink(path, info)
+		default:
+			return v.VisitFile(path, info)
+		}
+	}
+}
+
+// WalkFuncVisitor returns the Visitor that calls the WalkFunc from all its methods.
+func WalkFuncVisitor(fn WalkFunc) Visitor {
+	return walkFuncVisitor(fn)
+}
+
+type walkFuncVisitor WalkFunc
+
+func (fn walkFuncVisitor) VisitDir(path string, info *FileInfo) error {
+	return fn(path, info, nil)
+}
+
+func (fn walkFuncVisitor) VisitFile(path string, info *FileInfo) error {
+	return fn(path, info, nil)
+}
+
+func (fn walkFuncVisitor) VisitSymlink(path string, info *FileInfo) error {
+	return fn(path, info, nil)
+}
+
+func (fn walkFuncVisitor) OnError(path string, info *FileInfo, err error) error {
+	return fn(path, info, err)
+}
+
+// WalkVisitor is the same as Walk, except that the visited entries are passed to the Visitor.
+func WalkVisitor(ctx context.Context, owner, repo, path string, opt *WalkOptions, v Visitor, filterFn PathFilterFunc) error {
+	return Walk(ctx, owner, repo, path, opt, VisitorWalkFunc(v), filterFn)
+}
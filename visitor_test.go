@@ -0,0 +1,72 @@
+package ghwalk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingVisitor struct {
+	calls []string
+}
+
+func (v *recordingVisitor) VisitDir(path string, info *FileInfo) error {
+	v.calls = append(v.calls, "dir:"+path)
+	return nil
+}
+
+func (v *recordingVisitor) VisitFile(path string, info *FileInfo) error {
+	v.calls = append(v.calls, "file:"+path)
+	if path == "testdata/a" {
+		// skip the remaining files in the directory
+		return SkipDir
+	}
+	return nil
+}
+
+func (v *recordingVisitor) VisitSymlink(path string, info *FileInfo) error {
+	v.calls = append(v.calls, "symlink:"+path)
+	return nil
+}
+
+func (v *recordingVisitor) OnError(path string, info *FileInfo, err error) error {
+	v.calls = append(v.calls, "error:"+path)
+	return err
+}
+
+func TestWalkVisitor(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	v := &recordingVisitor{}
+	err := WalkVisitor(ctx, "magodo", "ghwalk", "testdata", &WalkOptions{Token: githubToken, BaseURL: githubBaseURL, Reverse: true}, v, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"dir:testdata",
+		"symlink:testdata/link_dir",
+		"dir:testdata/dir",
+		"file:testdata/dir/c",
+		"file:testdata/b",
+		"file:testdata/a",
+	}, v.calls)
+
+	v = &recordingVisitor{}
+	err = WalkVisitor(ctx, "magodo", "ghwalk", "testdata/nonexist", &WalkOptions{Token: githubToken, BaseURL: githubBaseURL}, v, nil)
+	require.Error(t, err)
+	require.Equal(t, []string{"error:testdata/nonexist"}, v.calls)
+}
+
+func TestWalkFuncVisitor(t *testing.T) {
+	var paths []string
+	v := WalkFuncVisitor(func(path string, info *FileInfo, err error) error {
+		paths = append(paths, path)
+		return err
+	})
+	require.NoError(t, v.VisitDir("a", nil))
+	require.NoError(t, v.VisitFile("b", nil))
+	require.NoError(t, v.VisitSymlink("c", nil))
+	require.Equal(t, SkipDir, v.OnError("d", nil, SkipDir))
+	require.Equal(t, []string{"a", "b", "c", "d"}, paths)
+}